@@ -5,13 +5,22 @@ import (
 	"os"
 	"time"
 
+	"los-tecnicos/backend/internal/alerts"
+	"los-tecnicos/backend/internal/auth"
 	"los-tecnicos/backend/internal/blockchain"
 	"los-tecnicos/backend/internal/cache"
 	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/forecast"
 	"los-tecnicos/backend/internal/handlers"
+	"los-tecnicos/backend/internal/kyc"
+	"los-tecnicos/backend/internal/ledger"
 	"los-tecnicos/backend/internal/matching"
 	"los-tecnicos/backend/internal/mqtt"
+	"los-tecnicos/backend/internal/ota"
+	"los-tecnicos/backend/internal/quality"
+	"los-tecnicos/backend/internal/shadow"
 	"los-tecnicos/backend/internal/simulation"
+	"los-tecnicos/backend/internal/telemetry"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,6 +28,15 @@ import (
 var SorobanClient *blockchain.SorobanClient
 
 func main() {
+	// Refuse to sign tokens with the public default secret outside development
+	if err := auth.CheckSecret(); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+	// Likewise for the secret price quotes are signed with
+	if err := handlers.CheckQuoteSecret(); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+
 	// Initialize database connection
 	if _, err := database.Connect(); err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
@@ -29,6 +47,33 @@ func main() {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
 
+	// Load the JWT signing keys, creating the first one on a fresh database
+	if err := auth.LoadKeys(); err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+
+	// Select the KYC verification provider
+	if err := kyc.Init(); err != nil {
+		log.Fatalf("Failed to initialise KYC provider: %v", err)
+	}
+
+	// Select the alert notification sender
+	if err := alerts.Init(); err != nil {
+		log.Fatalf("Failed to initialise alert sender: %v", err)
+	}
+
+	// Load the keys firmware releases and update manifests are signed with
+	if err := ota.Init(); err != nil {
+		log.Fatalf("Failed to load firmware signing keys: %v", err)
+	}
+
+	// Handlers for what devices report over MQTT, registered before connecting
+	telemetry.Subscribe() // Telemetry samples
+	quality.Subscribe()   // Lock command outcomes
+	alerts.Subscribe()    // Device alerts
+	ota.Subscribe()       // Firmware update progress
+	shadow.Subscribe()    // Reported device configs
+
 	// Initialize MQTT client
 	if err := mqtt.Connect(); err != nil {
 		log.Printf("Warning: Failed to connect to MQTT broker: %v", err)
@@ -38,8 +83,18 @@ func main() {
 	// In a real app, this URL would come from config
 	SorobanClient = blockchain.NewSorobanClient("https://rpc.lightsail.network/")
 
-	// Start the matching engine in the background
-	go matching.RunMatchingEngine(SorobanClient)
+	// Background jobs
+	go forecast.RunRefit()                       // Seasonal forecast behind the pricing scarcity factor
+	go matching.RunMatchingEngine(SorobanClient) // Continuous market
+	go matching.RunOrderSweeper()                // Order expiry
+	go matching.RunDayAheadMarket(SorobanClient) // Day-ahead auctions
+	go ledger.RunReconciliation(SorobanClient)   // Ledger against accounts and the chain
+	go auth.RunKeyRotation()                     // JWT signing keys
+	go telemetry.RunRetention()                  // Telemetry rollups and pruning
+	go quality.RunScoring()                      // Device quality scores
+	go alerts.RunOfflineCheck()                  // Devices that stopped reporting
+	go ota.RunCampaigns()                        // Firmware rollout stages
+	go shadow.RunReconciliation()                // Device config drift
 
 	// Seed mock data and start simulation
 	simulation.SeedMockData()
@@ -0,0 +1,164 @@
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/mqtt"
+
+	"gorm.io/gorm"
+)
+
+// AlertTopic carries alerts raised by the device firmware itself.
+const AlertTopic = "energy/device/+/alert"
+
+// offlineCheckInterval is how often devices are checked for missed reports.
+const offlineCheckInterval = time.Minute
+
+// deviceAlert is the JSON body of a device-reported alert.
+type deviceAlert struct {
+	Type     string   `json:"type"`
+	Severity string   `json:"severity"` // Defaults to critical
+	Message  string   `json:"message"`
+	Value    *float64 `json:"value"`
+}
+
+// Subscribe registers the device alert handler with the MQTT client. It must
+// be called before mqtt.Connect.
+func Subscribe() {
+	mqtt.Handle(AlertTopic, HandleDeviceAlert)
+}
+
+// CheckTelemetry runs the telemetry rules against a stored sample, raising,
+// escalating and resolving the device's alerts.
+func CheckTelemetry(sample domain.DeviceTelemetry) {
+	var device domain.IoTDevice
+	if err := database.DB.First(&device, "id = ?", sample.DeviceID).Error; err != nil {
+		log.Printf("Error loading device %s for alert rules: %v", sample.DeviceID, err)
+		return
+	}
+	for _, f := range Evaluate(device, sample) {
+		if err := apply(device, f, domain.AlertSourceRule, sample.RecordedAt); err != nil {
+			log.Printf("Error applying %s alert rule to device %s: %v", f.Type, device.ID, err)
+		}
+	}
+}
+
+// RunOfflineCheck starts a background process that marks devices that
+// stopped reporting Offline and raises device_offline for them.
+func RunOfflineCheck() {
+	log.Println("Starting device offline check...")
+	ticker := time.NewTicker(offlineCheckInterval)
+
+	for range ticker.C {
+		if err := CheckOffline(time.Now()); err != nil {
+			log.Printf("Error checking for offline devices: %v", err)
+		}
+	}
+}
+
+// CheckOffline raises device_offline for every Online device that has not
+// reported in ALERT_OFFLINE_MINUTES.
+func CheckOffline(now time.Time) error {
+	offline, err := database.MarkDevicesOffline(database.DB, now.Add(-offlineAfter))
+	for _, device := range offline {
+		f := offlineFinding(device.LastPing)
+		minutes := now.Sub(device.LastPing).Minutes()
+		f.Value = &minutes
+		if err := apply(device, f, domain.AlertSourceRule, now); err != nil {
+			log.Printf("Error raising offline alert for device %s: %v", device.ID, err)
+		}
+	}
+	return err
+}
+
+// HandleDeviceAlert records an alert reported by a device over MQTT. Alerts
+// from unknown or decommissioned devices are dropped.
+func HandleDeviceAlert(topic string, payload []byte) {
+	deviceID, f, err := parseDeviceAlert(topic, payload)
+	if err != nil {
+		log.Printf("Dropping device alert on %s: %v", topic, err)
+		return
+	}
+
+	var device domain.IoTDevice
+	err = database.DB.Where("id = ? AND status <> ?", deviceID, domain.DeviceStatusDecommissioned).First(&device).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Dropping alert from unknown device %s", deviceID)
+		} else {
+			log.Printf("Error loading device %s for its alert: %v", deviceID, err)
+		}
+		return
+	}
+	if err := apply(device, f, domain.AlertSourceDevice, time.Now()); err != nil {
+		log.Printf("Error recording alert from device %s: %v", deviceID, err)
+	}
+}
+
+func parseDeviceAlert(topic string, payload []byte) (string, Finding, error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 4 || parts[2] == "" || parts[3] != "alert" {
+		return "", Finding{}, fmt.Errorf("not a device alert topic")
+	}
+
+	var report deviceAlert
+	if err := json.Unmarshal(payload, &report); err != nil {
+		return "", Finding{}, fmt.Errorf("invalid device alert: %w", err)
+	}
+	if report.Type == "" {
+		return "", Finding{}, fmt.Errorf("device alert has no type")
+	}
+	if report.Severity == "" {
+		report.Severity = domain.AlertSeverityCritical
+	}
+	if !domain.IsAlertSeverity(report.Severity) {
+		return "", Finding{}, fmt.Errorf("unknown alert severity %q", report.Severity)
+	}
+
+	return parts[2], Finding{Type: report.Type, Severity: report.Severity, Message: report.Message, Value: report.Value}, nil
+}
+
+// Apply raises or resolves the rule alert a finding describes on a device,
+// for server-side checks outside this package.
+func Apply(device domain.IoTDevice, f Finding, at time.Time) error {
+	return apply(device, f, domain.AlertSourceRule, at)
+}
+
+// apply raises or resolves the alert a finding describes and notifies the
+// device's owner of any change.
+func apply(device domain.IoTDevice, f Finding, source string, at time.Time) error {
+	if f.Severity == "" {
+		resolved, err := database.ResolveAlert(database.DB, device.ID, f.Type, at)
+		if err != nil || resolved == nil {
+			return err
+		}
+		Notify(device.OwnerID, domain.AlertEventResolved, *resolved)
+		return nil
+	}
+
+	alert := domain.DeviceAlert{
+		DeviceID:  device.ID,
+		Type:      f.Type,
+		Source:    source,
+		Severity:  f.Severity,
+		Message:   f.Message,
+		Value:     f.Value,
+		CreatedAt: at,
+	}
+	if f.Threshold != 0 {
+		alert.Threshold = &f.Threshold
+	}
+	event, err := database.RaiseAlert(database.DB, &alert)
+	if err != nil || event == "" {
+		return err
+	}
+	Notify(device.OwnerID, event, alert)
+	return nil
+}
@@ -0,0 +1,179 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+// findings indexes Evaluate's findings by type.
+func findings(device domain.IoTDevice, sample domain.DeviceTelemetry) map[string]Finding {
+	result := map[string]Finding{}
+	for _, f := range Evaluate(device, sample) {
+		result[f.Type] = f
+	}
+	return result
+}
+
+func TestEvaluateSoC(t *testing.T) {
+	cases := []struct {
+		soc      float64
+		severity string
+		changes  bool
+	}{
+		{0.05, domain.AlertSeverityCritical, true},
+		{0.15, domain.AlertSeverityWarning, true},
+		{0.22, "", false}, // Inside the hysteresis band
+		{0.3, "", true},
+	}
+	for _, tc := range cases {
+		f, ok := findings(domain.IoTDevice{}, domain.DeviceTelemetry{SoC: ptr(tc.soc)})[domain.AlertLowSoC]
+		if ok != tc.changes || f.Severity != tc.severity {
+			t.Errorf("SoC %g: expected severity %q (finding: %v), got %+v (finding: %v)", tc.soc, tc.severity, tc.changes, f, ok)
+		}
+	}
+}
+
+func TestEvaluateTemperature(t *testing.T) {
+	cases := []struct {
+		temperature float64
+		severity    string
+		changes     bool
+	}{
+		{65, domain.AlertSeverityCritical, true},
+		{52, domain.AlertSeverityWarning, true},
+		{47, "", false},
+		{40, "", true},
+	}
+	for _, tc := range cases {
+		f, ok := findings(domain.IoTDevice{}, domain.DeviceTelemetry{TemperatureC: ptr(tc.temperature)})[domain.AlertOverTemperature]
+		if ok != tc.changes || f.Severity != tc.severity {
+			t.Errorf("%g °C: expected severity %q (finding: %v), got %+v (finding: %v)", tc.temperature, tc.severity, tc.changes, f, ok)
+		}
+	}
+}
+
+func TestEvaluateVoltageUsesNominalVoltage(t *testing.T) {
+	device := domain.IoTDevice{Metadata: domain.DeviceMetadata{domain.MetadataNominalVoltage: "48"}}
+	if f := findings(device, domain.DeviceTelemetry{VoltageV: ptr(50)})[domain.AlertVoltageOutOfBand]; f.Severity != "" {
+		t.Errorf("Expected 50 V to be within 15%% of 48 V, got %+v", f)
+	}
+	f := findings(device, domain.DeviceTelemetry{VoltageV: ptr(57)})[domain.AlertVoltageOutOfBand]
+	if f.Severity != domain.AlertSeverityCritical || math.Abs(f.Threshold-55.2) > 1e-9 {
+		t.Errorf("Expected 57 V to be above the 48 V band, got %+v", f)
+	}
+
+	// Without a nominal voltage the absolute limits apply
+	if f := findings(domain.IoTDevice{}, domain.DeviceTelemetry{VoltageV: ptr(57)})[domain.AlertVoltageOutOfBand]; f.Severity != "" {
+		t.Errorf("Expected 57 V to be within the default band, got %+v", f)
+	}
+}
+
+func TestEvaluateClearsOfflineAndSkipsMissingMetrics(t *testing.T) {
+	got := findings(domain.IoTDevice{}, domain.DeviceTelemetry{})
+	if len(got) != 1 {
+		t.Fatalf("Expected only device_offline for an empty sample, got %+v", got)
+	}
+	if f, ok := got[domain.AlertDeviceOffline]; !ok || f.Severity != "" {
+		t.Errorf("Expected device_offline to be cleared, got %+v", f)
+	}
+}
+
+func TestParseDeviceAlert(t *testing.T) {
+	deviceID, f, err := parseDeviceAlert("energy/device/dev-1/alert", []byte(`{"type":"bms_fault","message":"Cell 3 imbalance","value":0.4}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deviceID != "dev-1" || f.Type != "bms_fault" || f.Severity != domain.AlertSeverityCritical || f.Value == nil || *f.Value != 0.4 {
+		t.Errorf("Unexpected alert from dev-1: %+v", f)
+	}
+
+	for _, tc := range []struct{ topic, payload string }{
+		{"energy/device/dev-1/status", `{"type":"bms_fault"}`},
+		{"energy/device/dev-1/alert", `{"severity":"warning"}`},
+		{"energy/device/dev-1/alert", `{"type":"bms_fault","severity":"fatal"}`},
+		{"energy/device/dev-1/alert", `not json`},
+	} {
+		if _, _, err := parseDeviceAlert(tc.topic, []byte(tc.payload)); err == nil {
+			t.Errorf("Expected %s on %s to be rejected", tc.payload, tc.topic)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"event":"raised"}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=8d9b734ce6c39d85a0894a2814cc6f94a3d460006248a2e5ef56278d8cc98666"
+	if got := Sign("secret", []byte(`{"event":"raised"}`)); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if Sign("secret", []byte("a")) == Sign("other", []byte("a")) {
+		t.Error("Expected signatures under different secrets to differ")
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+	if err := ValidateWebhookURL("https://example.com/hooks/alerts"); err != nil {
+		t.Errorf("Expected an https URL to be accepted, got %v", err)
+	}
+	for _, raw := range []string{"http://example.com/hook", "ftp://example.com", "/relative", "https://",
+		"https://localhost/hook", "https://127.0.0.1/hook", "https://10.1.2.3/hook", "https://192.168.0.10/hook",
+		"https://169.254.169.254/latest/meta-data", "https://[::1]/hook", "https://[fe80::1]/hook", "https://0.0.0.0/hook"} {
+		if err := ValidateWebhookURL(raw); err == nil {
+			t.Errorf("Expected %q to be rejected", raw)
+		}
+	}
+
+	t.Setenv("APP_ENV", "development")
+	if err := ValidateWebhookURL("http://localhost:9000/hook"); err != nil {
+		t.Errorf("Expected http to be accepted in development, got %v", err)
+	}
+}
+
+func TestWebhookRefusesInternalDestinations(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+	for _, address := range []string{"127.0.0.1:443", "10.0.0.5:443", "172.16.0.1:443", "169.254.169.254:80", "100.64.0.1:443", "[::1]:443", "[fd00::1]:443", "[::ffff:127.0.0.1]:443"} {
+		if err := checkDestination("tcp", address, nil); !errors.Is(err, errBlockedDestination) {
+			t.Errorf("Expected %s to be refused, got %v", address, err)
+		}
+	}
+	for _, address := range []string{"93.184.216.34:443", "[2606:2800:220:1::1]:443"} {
+		if err := checkDestination("tcp", address, nil); err != nil {
+			t.Errorf("Expected %s to be allowed, got %v", address, err)
+		}
+	}
+
+	// The check runs when connecting, whatever the URL says
+	var received bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { received = true }))
+	defer server.Close()
+	hook := domain.AlertWebhook{URL: server.URL, Secret: "secret"}
+	if err := NewWebhookSender().post(context.Background(), hook, "raised", []byte("{}")); !errors.Is(err, errBlockedDestination) || received {
+		t.Errorf("Expected delivery to a loopback server to be refused, got %v", err)
+	}
+
+	t.Setenv("APP_ENV", "development")
+	if err := NewWebhookSender().post(context.Background(), hook, "raised", []byte("{}")); err != nil || !received {
+		t.Errorf("Expected local delivery in development, got %v", err)
+	}
+}
+
+func TestSenderRegistry(t *testing.T) {
+	if _, err := New("carrier-pigeon"); err == nil {
+		t.Error("Expected an unknown sender to be rejected")
+	}
+	sender, err := New("none")
+	if err != nil || sender.Name() != "none" {
+		t.Fatalf("Expected the none sender, got %v (%v)", sender, err)
+	}
+	if err := sender.Send(context.Background(), "user", Notification{}); err != nil {
+		t.Errorf("Expected the none sender to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/feed"
+)
+
+// Notification tells a device's owner what happened to one of its alerts.
+type Notification struct {
+	Event string             `json:"event"` // raised, escalated, resolved or acknowledged
+	Alert domain.DeviceAlert `json:"alert"`
+}
+
+// Sender delivers notifications outside the WebSocket feed. Implementations
+// for other channels (e-mail, SMS, push) register themselves with Register.
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, userID string, n Notification) error
+}
+
+// senders maps sender names to constructors.
+var senders = map[string]func() Sender{
+	"none":    func() Sender { return noSender{} },
+	"webhook": func() Sender { return NewWebhookSender() },
+}
+
+// Register makes a sender available under name for ALERT_SENDER.
+func Register(name string, factory func() Sender) {
+	senders[name] = factory
+}
+
+// New returns the sender registered under name.
+func New(name string) (Sender, error) {
+	factory, ok := senders[name]
+	if !ok {
+		names := make([]string, 0, len(senders))
+		for n := range senders {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown alert sender %q (available: %v)", name, names)
+	}
+	return factory(), nil
+}
+
+// Current is the sender selected by ALERT_SENDER (default webhook), set by
+// Init at startup.
+var Current Sender = NewWebhookSender()
+
+// Init selects the sender named by ALERT_SENDER.
+func Init() error {
+	sender, err := New(config.GetEnv("ALERT_SENDER", "webhook"))
+	if err != nil {
+		return err
+	}
+	Current = sender
+	return nil
+}
+
+// sendTimeout bounds one delivery through the sender, retries included.
+const sendTimeout = 30 * time.Second
+
+// Notify pushes a notification to the owner's WebSocket feed and hands it to
+// the sender in the background.
+func Notify(ownerID, event string, alert domain.DeviceAlert) {
+	n := Notification{Event: event, Alert: alert}
+	feed.Users.Publish(ownerID, "device_alert", n)
+
+	sender := Current
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		if err := sender.Send(ctx, ownerID, n); err != nil {
+			log.Printf("Failed to send %s alert %s to user %s via %s: %v", event, alert.ID, ownerID, sender.Name(), err)
+		}
+	}()
+}
+
+// noSender delivers over the WebSocket feed only.
+type noSender struct{}
+
+func (noSender) Name() string { return "none" }
+
+func (noSender) Send(context.Context, string, Notification) error { return nil }
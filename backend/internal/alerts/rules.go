@@ -0,0 +1,121 @@
+package alerts
+
+import (
+	"fmt"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+// Rule thresholds. An alert clears once its reading is back past the warning
+// threshold by the hysteresis, so a value hovering at the limit does not flap.
+var (
+	lowSoC              = config.GetEnvAsFloat("ALERT_LOW_SOC", 0.2)
+	criticalSoC         = config.GetEnvAsFloat("ALERT_CRITICAL_SOC", 0.1)
+	maxTemperature      = config.GetEnvAsFloat("ALERT_MAX_TEMPERATURE_C", 50)
+	criticalTemperature = config.GetEnvAsFloat("ALERT_CRITICAL_TEMPERATURE_C", 60)
+	// The voltage band applies to devices without a nominal voltage; the
+	// defaults match the firmware's safety limits
+	minVoltage = config.GetEnvAsFloat("ALERT_MIN_VOLTAGE", 10)
+	maxVoltage = config.GetEnvAsFloat("ALERT_MAX_VOLTAGE", 65)
+	// voltageBand is the allowed deviation, as a fraction, from a device's nominal voltage
+	voltageBand = config.GetEnvAsFloat("ALERT_VOLTAGE_BAND", 0.15)
+	// offlineAfter is how long an Online device may go without reporting
+	offlineAfter = time.Duration(config.GetEnvAsInt("ALERT_OFFLINE_MINUTES", 5)) * time.Minute
+)
+
+const (
+	socHysteresis         = 0.05
+	temperatureHysteresis = 5.0
+)
+
+// Finding is a rule's verdict on a reading: raise (or escalate) an alert of
+// Type with Severity, or clear it when Severity is empty.
+type Finding struct {
+	Type      string
+	Severity  string
+	Message   string
+	Value     *float64 // The reading, if there is one
+	Threshold float64
+}
+
+// Evaluate applies the telemetry rules to a sample. Metrics missing from the
+// sample leave their alerts as they are; any sample clears device_offline.
+func Evaluate(device domain.IoTDevice, sample domain.DeviceTelemetry) []Finding {
+	findings := []Finding{{Type: domain.AlertDeviceOffline}}
+
+	if soc := sample.SoC; soc != nil {
+		f := Finding{Type: domain.AlertLowSoC, Value: soc}
+		switch {
+		case *soc < criticalSoC:
+			f.Severity, f.Threshold = domain.AlertSeverityCritical, criticalSoC
+		case *soc < lowSoC:
+			f.Severity, f.Threshold = domain.AlertSeverityWarning, lowSoC
+		case *soc < lowSoC+socHysteresis:
+			f.Type = "" // Inside the hysteresis band: no change
+		}
+		if f.Severity != "" {
+			f.Message = fmt.Sprintf("State of charge %.0f%% is below %.0f%%", *soc*100, f.Threshold*100)
+		}
+		findings = append(findings, f)
+	}
+
+	if t := sample.TemperatureC; t != nil {
+		f := Finding{Type: domain.AlertOverTemperature, Value: t}
+		switch {
+		case *t >= criticalTemperature:
+			f.Severity, f.Threshold = domain.AlertSeverityCritical, criticalTemperature
+		case *t >= maxTemperature:
+			f.Severity, f.Threshold = domain.AlertSeverityWarning, maxTemperature
+		case *t > maxTemperature-temperatureHysteresis:
+			f.Type = ""
+		}
+		if f.Severity != "" {
+			f.Message = fmt.Sprintf("Battery temperature %.1f °C is at or above %.1f °C", *t, f.Threshold)
+		}
+		findings = append(findings, f)
+	}
+
+	if v := sample.VoltageV; v != nil {
+		low, high := VoltageBand(device)
+		f := Finding{Type: domain.AlertVoltageOutOfBand, Value: v}
+		switch {
+		case *v < low:
+			f.Severity, f.Threshold = domain.AlertSeverityCritical, low
+			f.Message = fmt.Sprintf("Voltage %.2f V is below %.2f V", *v, low)
+		case *v > high:
+			f.Severity, f.Threshold = domain.AlertSeverityCritical, high
+			f.Message = fmt.Sprintf("Voltage %.2f V is above %.2f V", *v, high)
+		}
+		findings = append(findings, f)
+	}
+
+	// Drop the "no change" placeholders
+	result := findings[:0]
+	for _, f := range findings {
+		if f.Type != "" {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// VoltageBand is the allowed voltage range of a device: its nominal voltage
+// plus or minus ALERT_VOLTAGE_BAND, or ALERT_MIN_VOLTAGE to ALERT_MAX_VOLTAGE.
+func VoltageBand(device domain.IoTDevice) (float64, float64) {
+	if nominal, ok := device.NominalVoltage(); ok {
+		return nominal * (1 - voltageBand), nominal * (1 + voltageBand)
+	}
+	return minVoltage, maxVoltage
+}
+
+// offlineFinding raises device_offline for a device last heard from at lastPing.
+func offlineFinding(lastPing time.Time) Finding {
+	return Finding{
+		Type:      domain.AlertDeviceOffline,
+		Severity:  domain.AlertSeverityWarning,
+		Message:   fmt.Sprintf("No telemetry since %s", lastPing.UTC().Format(time.RFC3339)),
+		Threshold: offlineAfter.Minutes(),
+	}
+}
@@ -0,0 +1,169 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+
+	"gorm.io/gorm"
+)
+
+// webhookAttempts is how many times a delivery is tried before giving up.
+const webhookAttempts = 3
+
+// WebhookSender POSTs notifications as JSON to the URL each user configured,
+// signed with their secret: X-Alert-Signature is "sha256=" and the hex
+// HMAC-SHA256 of the body.
+type WebhookSender struct {
+	client *http.Client
+}
+
+// errBlockedDestination is returned for a webhook that would reach an internal address.
+var errBlockedDestination = errors.New("webhook destination is a loopback, private or link-local address")
+
+// NewWebhookSender creates a webhook sender. Its client refuses to connect to
+// internal addresses (see checkDestination) and does not use a proxy, so the
+// address checked is the one the request goes to.
+func NewWebhookSender() *WebhookSender {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: checkDestination}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &WebhookSender{client: &http.Client{Timeout: 10 * time.Second, Transport: transport}}
+}
+
+// Name implements Sender.
+func (s *WebhookSender) Name() string { return "webhook" }
+
+// Send implements Sender. Users without a webhook, or whose minimum severity
+// is above the alert's, are skipped.
+func (s *WebhookSender) Send(ctx context.Context, userID string, n Notification) error {
+	var hook domain.AlertWebhook
+	if err := database.DB.First(&hook, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if domain.AlertSeverityRank(n.Alert.Severity) < domain.AlertSeverityRank(hook.MinSeverity) {
+		return nil
+	}
+
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < webhookAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if lastErr = s.post(ctx, hook, n.Event, body); lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func (s *WebhookSender) post(ctx context.Context, hook domain.AlertWebhook, event string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Alert-Event", event)
+	req.Header.Set("X-Alert-Signature", Sign(hook.Secret, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the X-Alert-Signature of body under secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateWebhookURL accepts absolute HTTPS URLs, and HTTP ones in development.
+// Outside development, URLs naming localhost or an internal IP address are
+// rejected; hostnames are checked again on every delivery, as DNS can change.
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("webhook URL must be an absolute URL")
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && config.IsDevelopment()) {
+		return fmt.Errorf("webhook URL must use https")
+	}
+	if config.IsDevelopment() {
+		return nil
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errBlockedDestination
+	}
+	if ip := net.ParseIP(host); ip != nil && isInternal(ip) {
+		return errBlockedDestination
+	}
+	return nil
+}
+
+// checkDestination is the webhook dialer's Control hook: it runs for the
+// resolved address of every connection, including redirects, and refuses
+// internal ones outside development.
+func checkDestination(network, address string, _ syscall.RawConn) error {
+	if config.IsDevelopment() {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isInternal(ip) {
+		return errBlockedDestination
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, 100.64.0.0/10.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isInternal reports whether ip is not a public unicast address: loopback,
+// private, link-local (including cloud metadata at 169.254.169.254),
+// unspecified, multicast or carrier-grade NAT.
+func isInternal(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
@@ -0,0 +1,154 @@
+package auction
+
+import (
+	"math"
+	"sort"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+// kwhEpsilon absorbs floating point error when comparing kWh quantities.
+const kwhEpsilon = 1e-9
+
+// Allocation is a quantity traded between one buy and one sell bid.
+type Allocation struct {
+	Buy  domain.EnergyOrder
+	Sell domain.EnergyOrder
+	Kwh  float64
+}
+
+// Result is the outcome of clearing one auction.
+type Result struct {
+	Cleared     bool    // False if the highest bid is below the lowest ask
+	Price       float64 // Uniform clearing price
+	VolumeKwh   float64
+	SupplyKwh   float64 // Total offered by sell bids
+	DemandKwh   float64 // Total requested by buy bids
+	Allocations []Allocation
+}
+
+// Clear runs a uniform-price double auction. Sell bids are stacked by ascending
+// price into a supply curve and buy bids by descending price into a demand
+// curve (earliest priority first within a price); the cleared volume is where the curves
+// cross. Every matched bid trades at one price, chosen as reference clamped to
+// the range that keeps every accepted bid within its limit and leaves no
+// rejected bid willing to trade. Marginal bids may be partially filled. A
+// user's own bids never trade with each other, so the volume can fall short of
+// the crossing when the same user is on both sides.
+func Clear(sells, buys []domain.EnergyOrder, reference float64) Result {
+	sells = sortedBids(sells, true)
+	buys = sortedBids(buys, false)
+
+	var result Result
+	for _, o := range sells {
+		result.SupplyKwh += o.RemainingKwh()
+	}
+	for _, o := range buys {
+		result.DemandKwh += o.RemainingKwh()
+	}
+
+	remainingSell, remainingBuy := 0.0, 0.0
+	var marginalAsk, marginalBid float64
+	accepted := false
+	acceptedSells, acceptedBuys := 0, 0
+	i, j := 0, 0
+	for i < len(sells) && j < len(buys) && buys[j].TokenPrice >= sells[i].TokenPrice {
+		if remainingSell <= kwhEpsilon {
+			remainingSell = sells[i].RemainingKwh()
+		}
+		if remainingBuy <= kwhEpsilon {
+			remainingBuy = buys[j].RemainingKwh()
+		}
+
+		qty := math.Min(remainingSell, remainingBuy)
+		accepted = true
+		acceptedSells, acceptedBuys = i+1, j+1
+		marginalAsk, marginalBid = sells[i].TokenPrice, buys[j].TokenPrice
+
+		remainingSell -= qty
+		remainingBuy -= qty
+		if remainingSell <= kwhEpsilon {
+			i++
+		}
+		if remainingBuy <= kwhEpsilon {
+			j++
+		}
+	}
+
+	if !accepted {
+		return result
+	}
+
+	// The next bids left out of the auction narrow the range: the price must not
+	// be above an unmatched ask nor below an unmatched bid.
+	low, high := marginalAsk, marginalBid
+	if j < len(buys) {
+		low = math.Max(low, buys[j].TokenPrice)
+	}
+	if i < len(sells) {
+		high = math.Min(high, sells[i].TokenPrice)
+	}
+
+	result.Allocations = allocate(sells[:acceptedSells], buys[:acceptedBuys])
+	for _, a := range result.Allocations {
+		result.VolumeKwh += a.Kwh
+	}
+	if len(result.Allocations) == 0 {
+		return result
+	}
+
+	result.Cleared = true
+	result.Price = math.Min(math.Max(reference, low), high)
+	return result
+}
+
+// allocate fills the accepted bids against each other in merit order, skipping
+// pairs that belong to the same user. Every accepted bid is within its limit at
+// the clearing price, so any other pairing may trade.
+func allocate(sells, buys []domain.EnergyOrder) []Allocation {
+	remaining := make(map[string]float64, len(sells)+len(buys))
+	for _, o := range sells {
+		remaining[o.ID] = o.RemainingKwh()
+	}
+	for _, o := range buys {
+		remaining[o.ID] = o.RemainingKwh()
+	}
+
+	var allocations []Allocation
+	for _, sell := range sells {
+		for _, buy := range buys {
+			if remaining[sell.ID] <= kwhEpsilon {
+				break
+			}
+			if remaining[buy.ID] <= kwhEpsilon || buy.UserID == sell.UserID {
+				continue
+			}
+
+			qty := math.Min(remaining[sell.ID], remaining[buy.ID])
+			remaining[sell.ID] -= qty
+			remaining[buy.ID] -= qty
+			allocations = append(allocations, Allocation{Buy: buy, Sell: sell, Kwh: qty})
+		}
+	}
+	return allocations
+}
+
+// sortedBids returns a copy of bids in merit order.
+func sortedBids(bids []domain.EnergyOrder, ascending bool) []domain.EnergyOrder {
+	sorted := make([]domain.EnergyOrder, 0, len(bids))
+	for _, o := range bids {
+		if o.RemainingKwh() > kwhEpsilon {
+			sorted = append(sorted, o)
+		}
+	}
+	sort.SliceStable(sorted, func(a, b int) bool {
+		if sorted[a].TokenPrice != sorted[b].TokenPrice {
+			if ascending {
+				return sorted[a].TokenPrice < sorted[b].TokenPrice
+			}
+			return sorted[a].TokenPrice > sorted[b].TokenPrice
+		}
+		return sorted[a].PriorityAt.Before(sorted[b].PriorityAt)
+	})
+	return sorted
+}
@@ -0,0 +1,155 @@
+package auction
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+var epoch = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func bid(id, orderType string, kwh, price float64, age int) domain.EnergyOrder {
+	return domain.EnergyOrder{
+		ID:         id,
+		UserID:     "user_" + id,
+		Type:       orderType,
+		Market:     domain.MarketDayAhead,
+		KwhAmount:  kwh,
+		TokenPrice: price,
+		CreatedAt:  epoch.Add(time.Duration(age) * time.Second),
+		PriorityAt: epoch.Add(time.Duration(age) * time.Second),
+	}
+}
+
+func allocated(r Result, id string) float64 {
+	total := 0.0
+	for _, a := range r.Allocations {
+		if a.Buy.ID == id || a.Sell.ID == id {
+			total += a.Kwh
+		}
+	}
+	return total
+}
+
+func TestClearFindsIntersection(t *testing.T) {
+	sells := []domain.EnergyOrder{
+		bid("s3", "sell", 4, 6.0, 0),
+		bid("s1", "sell", 3, 2.0, 0),
+		bid("s2", "sell", 2, 4.0, 0),
+	}
+	buys := []domain.EnergyOrder{
+		bid("b1", "buy", 2, 7.0, 0),
+		bid("b2", "buy", 3, 5.0, 0),
+		bid("b3", "buy", 5, 3.0, 0),
+	}
+
+	r := Clear(sells, buys, 4.5)
+
+	// Supply 3@2, 2@4 meets demand 2@7, 3@5: 5 kWh clear in the range [4, 5]
+	if !r.Cleared || math.Abs(r.VolumeKwh-5) > kwhEpsilon {
+		t.Fatalf("Expected 5 kWh cleared, got %+v", r)
+	}
+	if allocated(r, "s1") != 3 || allocated(r, "s2") != 2 || allocated(r, "s3") != 0 {
+		t.Errorf("Unexpected sell allocation: s1=%f s2=%f s3=%f", allocated(r, "s1"), allocated(r, "s2"), allocated(r, "s3"))
+	}
+	if allocated(r, "b1") != 2 || allocated(r, "b2") != 3 || allocated(r, "b3") != 0 {
+		t.Errorf("Unexpected buy allocation: b1=%f b2=%f b3=%f", allocated(r, "b1"), allocated(r, "b2"), allocated(r, "b3"))
+	}
+	if r.Price != 4.5 {
+		t.Errorf("Expected the reference price 4.5 inside the clearing range, got %f", r.Price)
+	}
+	if r.SupplyKwh != 9 || r.DemandKwh != 10 {
+		t.Errorf("Expected 9 kWh offered and 10 kWh requested, got %f/%f", r.SupplyKwh, r.DemandKwh)
+	}
+}
+
+func TestClearPriceRespectsEveryBid(t *testing.T) {
+	sells := []domain.EnergyOrder{bid("s1", "sell", 3, 2.0, 0), bid("s2", "sell", 2, 4.0, 0)}
+	buys := []domain.EnergyOrder{bid("b1", "buy", 2, 7.0, 0), bid("b2", "buy", 3, 5.0, 0), bid("b3", "buy", 5, 3.0, 0)}
+
+	// The range is [4, 5]: s2 (ask 4) is matched, b2 (bid 5) is matched, b3 (bid 3) is not
+	for reference, want := range map[float64]float64{1.0: 4.0, 4.2: 4.2, 9.0: 5.0} {
+		r := Clear(sells, buys, reference)
+		if r.Price != want {
+			t.Errorf("Reference %f: expected clearing price %f, got %f", reference, want, r.Price)
+		}
+		for _, a := range r.Allocations {
+			if a.Buy.TokenPrice < r.Price || a.Sell.TokenPrice > r.Price {
+				t.Errorf("Allocation %s->%s violates a limit at %f", a.Sell.ID, a.Buy.ID, r.Price)
+			}
+		}
+	}
+}
+
+func TestClearUnmatchedBidsNarrowTheRange(t *testing.T) {
+	// Only 1 kWh of demand; the unmatched seller asking 3 caps the price at 3
+	sells := []domain.EnergyOrder{bid("s1", "sell", 1, 2.0, 0), bid("s2", "sell", 1, 3.0, 0)}
+	buys := []domain.EnergyOrder{bid("b1", "buy", 1, 8.0, 0)}
+
+	r := Clear(sells, buys, 5.0)
+	if r.Price != 3.0 {
+		t.Errorf("Expected price capped at the next ask 3.0, got %f", r.Price)
+	}
+}
+
+func TestClearPartiallyFilledMarginalAskSetsPrice(t *testing.T) {
+	// The marginal seller still has 2 kWh unsold, so any price above its ask
+	// would leave it willing to sell more than the market takes
+	sells := []domain.EnergyOrder{bid("s1", "sell", 3, 2.0, 0), bid("s2", "sell", 4, 4.0, 0)}
+	buys := []domain.EnergyOrder{bid("b1", "buy", 5, 6.0, 0)}
+
+	r := Clear(sells, buys, 5.0)
+	if r.Price != 4.0 || allocated(r, "s2") != 2 {
+		t.Errorf("Expected 2 kWh from s2 at its ask 4.0, got %f kWh at %f", allocated(r, "s2"), r.Price)
+	}
+}
+
+func TestClearNoCross(t *testing.T) {
+	r := Clear([]domain.EnergyOrder{bid("s", "sell", 1, 6.0, 0)}, []domain.EnergyOrder{bid("b", "buy", 1, 5.0, 0)}, 5.5)
+	if r.Cleared || len(r.Allocations) != 0 || r.VolumeKwh != 0 {
+		t.Errorf("Expected no clearing when the bid is below the ask, got %+v", r)
+	}
+
+	if r := Clear(nil, nil, 5.0); r.Cleared {
+		t.Error("Expected an empty auction not to clear")
+	}
+}
+
+func TestClearTimePriorityAtMarginalPrice(t *testing.T) {
+	sells := []domain.EnergyOrder{bid("new", "sell", 2, 4.0, 5), bid("old", "sell", 2, 4.0, 1)}
+	buys := []domain.EnergyOrder{bid("b", "buy", 3, 5.0, 0)}
+
+	r := Clear(sells, buys, 4.5)
+	if allocated(r, "old") != 2 || allocated(r, "new") != 1 {
+		t.Errorf("Expected the older marginal ask filled first, got old=%f new=%f", allocated(r, "old"), allocated(r, "new"))
+	}
+}
+
+func TestClearSkipsSelfTrades(t *testing.T) {
+	own := func(b domain.EnergyOrder) domain.EnergyOrder {
+		b.UserID = "user_a"
+		return b
+	}
+
+	// A user alone on both sides does not trade with themselves
+	r := Clear([]domain.EnergyOrder{own(bid("s", "sell", 2, 3.0, 0))}, []domain.EnergyOrder{own(bid("b", "buy", 2, 6.0, 0))}, 4.5)
+	if r.Cleared || len(r.Allocations) != 0 || r.VolumeKwh != 0 {
+		t.Errorf("Expected a self-crossing auction not to clear, got %+v", r)
+	}
+
+	// Their bid and ask each trade with other users instead
+	sells := []domain.EnergyOrder{own(bid("s1", "sell", 3, 2.0, 0)), bid("s2", "sell", 3, 4.0, 0)}
+	buys := []domain.EnergyOrder{own(bid("b1", "buy", 3, 7.0, 0)), bid("b2", "buy", 3, 5.0, 0)}
+
+	r = Clear(sells, buys, 4.5)
+	for _, a := range r.Allocations {
+		if a.Buy.UserID == a.Sell.UserID {
+			t.Errorf("Allocation %s->%s is a self-trade", a.Sell.ID, a.Buy.ID)
+		}
+	}
+	if !r.Cleared || math.Abs(r.VolumeKwh-6) > kwhEpsilon || r.Price != 4.5 {
+		t.Errorf("Expected 6 kWh to clear at 4.5 across users, got %f kWh at %f", r.VolumeKwh, r.Price)
+	}
+}
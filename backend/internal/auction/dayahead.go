@@ -0,0 +1,52 @@
+package auction
+
+import (
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+)
+
+// GateHour is the UTC hour on the day before delivery at which the day-ahead
+// gate closes and the auctions for every hour of the delivery day are cleared.
+var GateHour = config.GetEnvAsInt("DAY_AHEAD_GATE_HOUR", 12)
+
+// SlotDuration is the length of one day-ahead delivery slot.
+const SlotDuration = time.Hour
+
+// DeliveryDay truncates t to the start of its UTC day.
+func DeliveryDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// GateClosure returns when bidding for the given delivery day closes.
+func GateClosure(day time.Time) time.Time {
+	return DeliveryDay(day).Add(-24*time.Hour + time.Duration(GateHour)*time.Hour)
+}
+
+// OpenDeliveryDay returns the delivery day currently accepting bids. The gate
+// for a day opens when the previous day's gate closes, so exactly one day is
+// open at any time.
+func OpenDeliveryDay(now time.Time) time.Time {
+	day := DeliveryDay(now).Add(24 * time.Hour)
+	if !now.Before(GateClosure(day)) {
+		day = day.Add(24 * time.Hour)
+	}
+	return day
+}
+
+// LastClosedDeliveryDay returns the most recent delivery day whose gate has
+// closed, i.e. the day that should have been cleared.
+func LastClosedDeliveryDay(now time.Time) time.Time {
+	return OpenDeliveryDay(now).Add(-24 * time.Hour)
+}
+
+// Slots returns the start of every delivery slot in the day.
+func Slots(day time.Time) []time.Time {
+	day = DeliveryDay(day)
+	slots := make([]time.Time, 0, 24)
+	for t := day; t.Before(day.Add(24 * time.Hour)); t = t.Add(SlotDuration) {
+		slots = append(slots, t)
+	}
+	return slots
+}
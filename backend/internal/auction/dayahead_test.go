@@ -0,0 +1,40 @@
+package auction
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDayAheadGate(t *testing.T) {
+	day := time.Date(2026, 6, 2, 0, 0, 0, 0, time.UTC)
+	if want := time.Date(2026, 6, 1, GateHour, 0, 0, 0, time.UTC); !GateClosure(day).Equal(want) {
+		t.Errorf("Expected gate closure %s, got %s", want, GateClosure(day))
+	}
+
+	cases := []struct {
+		now      time.Time
+		wantOpen time.Time
+	}{
+		{time.Date(2026, 6, 1, GateHour-1, 59, 0, 0, time.UTC), day},
+		{time.Date(2026, 6, 1, GateHour, 0, 0, 0, time.UTC), day.Add(24 * time.Hour)},
+		{time.Date(2026, 6, 1, 23, 0, 0, 0, time.UTC), day.Add(24 * time.Hour)},
+	}
+	for _, tc := range cases {
+		if got := OpenDeliveryDay(tc.now); !got.Equal(tc.wantOpen) {
+			t.Errorf("At %s: expected open day %s, got %s", tc.now, tc.wantOpen, got)
+		}
+		if got := LastClosedDeliveryDay(tc.now); !got.Equal(tc.wantOpen.Add(-24 * time.Hour)) {
+			t.Errorf("At %s: expected last closed day %s, got %s", tc.now, tc.wantOpen.Add(-24*time.Hour), got)
+		}
+	}
+}
+
+func TestSlots(t *testing.T) {
+	slots := Slots(time.Date(2026, 6, 2, 15, 30, 0, 0, time.UTC))
+	if len(slots) != 24 {
+		t.Fatalf("Expected 24 hourly slots, got %d", len(slots))
+	}
+	if !slots[0].Equal(time.Date(2026, 6, 2, 0, 0, 0, 0, time.UTC)) || !slots[23].Equal(time.Date(2026, 6, 2, 23, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected slot range %s .. %s", slots[0], slots[23])
+	}
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"los-tecnicos/backend/internal/cache"
+)
+
+// RevokeToken denylists a single access token by its jti until it expires.
+func RevokeToken(jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	return cache.Rdb.Set(context.Background(), "jwt:denylist:jti:"+jti, 1, ttl).Err()
+}
+
+// RevokeSession denylists every access token issued under a session family
+// until the last of them has expired.
+func RevokeSession(sid string) error {
+	if sid == "" {
+		return nil
+	}
+	return cache.Rdb.Set(context.Background(), "jwt:denylist:sid:"+sid, 1, AccessTokenTTL).Err()
+}
+
+// IsRevoked reports whether the access token jti, or the session sid it was
+// issued under, has been denylisted.
+func IsRevoked(jti, sid string) (bool, error) {
+	keys := []string{"jwt:denylist:jti:" + jti}
+	if sid != "" {
+		keys = append(keys, "jwt:denylist:sid:"+sid)
+	}
+	n, err := cache.Rdb.Exists(context.Background(), keys...).Result()
+	return n > 0, err
+}
@@ -0,0 +1,174 @@
+// Package auth signs and verifies access tokens with a rotating set of
+// Ed25519 keys, and keeps the denylist of revoked access tokens.
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Algorithm is the JWS algorithm access tokens are signed with.
+const Algorithm = "EdDSA"
+
+// AccessTokenTTL is how long an access token is valid.
+var AccessTokenTTL = time.Duration(config.GetEnvAsInt("ACCESS_TOKEN_TTL_MINUTES", 15)) * time.Minute
+
+// Issuer is the iss claim of our access tokens, which other services verifying
+// them against the JWKS should also check.
+var Issuer = config.GetEnv("JWT_ISSUER", "los-tecnicos")
+
+var (
+	// ErrNoSigningKey is returned when the key set has no active key to sign with.
+	ErrNoSigningKey = errors.New("no signing key available")
+	// ErrUnknownKey is returned for a token whose kid is not a key we verify with.
+	ErrUnknownKey = errors.New("unknown or expired signing key")
+)
+
+// Key is one Ed25519 key of the key set.
+type Key struct {
+	ID          string // kid
+	Private     ed25519.PrivateKey
+	Public      ed25519.PublicKey
+	ActivatesAt time.Time  // When it starts signing
+	ExpiresAt   *time.Time // When it stops verifying; nil while it is the newest key
+}
+
+// NewKey generates a key that starts signing at activatesAt.
+func NewKey(activatesAt time.Time) (Key, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Key{}, err
+	}
+	return Key{ID: uuid.New().String(), Private: private, Public: public, ActivatesAt: activatesAt}, nil
+}
+
+// verifies reports whether the key may still verify tokens at now.
+func (k Key) verifies(now time.Time) bool {
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// KeySet holds the keys access tokens are signed and verified with.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []Key // Ordered by ActivatesAt
+}
+
+// Keys is the key set the API signs and verifies with. LoadKeys fills it from
+// the database and RunKeyRotation keeps it current.
+var Keys = &KeySet{}
+
+// Replace swaps the set's keys for keys.
+func (s *KeySet) Replace(keys []Key) {
+	sorted := append([]Key(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ActivatesAt.Before(sorted[j].ActivatesAt) })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = sorted
+}
+
+// Signing returns the key to sign with at now: the most recently activated
+// key that still verifies.
+func (s *KeySet) Signing(now time.Time) (Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		if !s.keys[i].ActivatesAt.After(now) && s.keys[i].verifies(now) {
+			return s.keys[i], true
+		}
+	}
+	return Key{}, false
+}
+
+// Newest returns the most recently published key, which may not sign yet.
+func (s *KeySet) Newest() (Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.keys) == 0 {
+		return Key{}, false
+	}
+	return s.keys[len(s.keys)-1], true
+}
+
+// verifying returns the key named kid if it may verify tokens at now.
+func (s *KeySet) verifying(kid string, now time.Time) (Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, key := range s.keys {
+		if key.ID == kid && key.verifies(now) {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// Sign signs claims with the current signing key, naming it in the kid header.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key, ok := s.Signing(time.Now())
+	if !ok {
+		return "", ErrNoSigningKey
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// Parse verifies tokenString with the key its kid names, checks its issuer
+// and expiry, and decodes it into claims.
+func (s *KeySet) Parse(tokenString string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.verifying(kid, time.Now())
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		return key.Public, nil
+	}, jwt.WithValidMethods([]string{Algorithm}), jwt.WithIssuer(Issuer), jwt.WithExpirationRequired())
+	return err
+}
+
+// JWK is the public half of a key in RFC 8037 (OKP) form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// JWKSet is the body of the JWKS endpoint.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns every key that verifies at now, including a published key
+// that does not sign yet, so verifiers know it before tokens carry it.
+func (s *KeySet) JWKS(now time.Time) JWKSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range s.keys {
+		if key.verifies(now) {
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(key.Public),
+				Kid: key.ID,
+				Alg: Algorithm,
+				Use: "sig",
+			})
+		}
+	}
+	return set
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func mustKey(t *testing.T, activatesAt time.Time) Key {
+	t.Helper()
+	key, err := NewKey(activatesAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKeySetRotation(t *testing.T) {
+	now := time.Now()
+	oldExpiry := now.Add(time.Hour)
+	old := mustKey(t, now.Add(-30*24*time.Hour))
+	old.ExpiresAt = &oldExpiry
+	next := mustKey(t, now.Add(publishAhead))
+
+	set := &KeySet{}
+	set.Replace([]Key{next, old})
+
+	// The published successor does not sign until it activates...
+	if key, _ := set.Signing(now); key.ID != old.ID {
+		t.Errorf("Expected the old key to sign before its successor activates, got %s", key.ID)
+	}
+	if key, _ := set.Signing(now.Add(publishAhead)); key.ID != next.ID {
+		t.Errorf("Expected the successor to sign once active, got %s", key.ID)
+	}
+	// ...but both are in the JWKS meanwhile
+	if jwks := set.JWKS(now); len(jwks.Keys) != 2 {
+		t.Errorf("Expected both keys to be published, got %d", len(jwks.Keys))
+	}
+
+	// After the overlap the old key no longer verifies
+	if _, ok := set.verifying(old.ID, oldExpiry); ok {
+		t.Error("Expected the old key to stop verifying at its expiry")
+	}
+	if jwks := set.JWKS(oldExpiry); len(jwks.Keys) != 1 || jwks.Keys[0].Kid != next.ID {
+		t.Errorf("Expected only the successor to be published after the overlap, got %+v", jwks.Keys)
+	}
+}
+
+func TestSignAndParse(t *testing.T) {
+	set := &KeySet{}
+	if _, err := set.Sign(jwt.RegisteredClaims{}); !errors.Is(err, ErrNoSigningKey) {
+		t.Fatalf("Expected ErrNoSigningKey from an empty key set, got %v", err)
+	}
+
+	key := mustKey(t, time.Now().Add(-time.Minute))
+	set.Replace([]Key{key})
+
+	claims := jwt.RegisteredClaims{
+		ID:        "token-1",
+		Issuer:    Issuer,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+	token, err := set.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed jwt.RegisteredClaims
+	if err := set.Parse(token, &parsed); err != nil {
+		t.Fatalf("Expected the token to verify, got %v", err)
+	}
+	if parsed.ID != "token-1" {
+		t.Errorf("Expected jti token-1, got %q", parsed.ID)
+	}
+
+	// A key set that does not hold the signing key rejects the token
+	other := &KeySet{}
+	other.Replace([]Key{mustKey(t, time.Now().Add(-time.Minute))})
+	if err := other.Parse(token, &jwt.RegisteredClaims{}); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey for a foreign kid, got %v", err)
+	}
+
+	// So does one that has let the key expire
+	expired := time.Now().Add(-time.Second)
+	key.ExpiresAt = &expired
+	set.Replace([]Key{key})
+	if err := set.Parse(token, &jwt.RegisteredClaims{}); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey for an expired key, got %v", err)
+	}
+}
+
+func TestParseRejectsOtherAlgorithmsAndIssuers(t *testing.T) {
+	key := mustKey(t, time.Now().Add(-time.Minute))
+	set := &KeySet{}
+	set.Replace([]Key{key})
+	expiry := jwt.NewNumericDate(time.Now().Add(time.Minute))
+
+	// The old HS256 tokens, even if signed with the public key bytes as the secret
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Issuer: Issuer, ExpiresAt: expiry})
+	hs.Header["kid"] = key.ID
+	token, _ := hs.SignedString([]byte(key.Public))
+	if err := set.Parse(token, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("Expected an HS256 token to be rejected")
+	}
+
+	foreign := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.RegisteredClaims{Issuer: "someone-else", ExpiresAt: expiry})
+	foreign.Header["kid"] = key.ID
+	token, _ = foreign.SignedString(key.Private)
+	if err := set.Parse(token, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("Expected a token from another issuer to be rejected")
+	}
+
+	noExpiry := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.RegisteredClaims{Issuer: Issuer})
+	noExpiry.Header["kid"] = key.ID
+	token, _ = noExpiry.SignedString(key.Private)
+	if err := set.Parse(token, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("Expected a token without an expiry to be rejected")
+	}
+}
+
+func TestRotationDue(t *testing.T) {
+	now := time.Now()
+	if rotationDue(Key{ActivatesAt: now.Add(-rotationPeriod + time.Minute)}, now) {
+		t.Error("Expected a key younger than the rotation period not to be due")
+	}
+	if !rotationDue(Key{ActivatesAt: now.Add(-rotationPeriod)}, now) {
+		t.Error("Expected a key as old as the rotation period to be due")
+	}
+	if overlap() < AccessTokenTTL {
+		t.Errorf("Expected the overlap (%s) to cover the access token TTL (%s)", overlap(), AccessTokenTTL)
+	}
+}
+
+func TestSealedKeys(t *testing.T) {
+	key := mustKey(t, time.Now())
+	sealed, err := sealKey("secret", key.ID, key.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := openKey("secret", key.ID, sealed)
+	if err != nil {
+		t.Fatalf("Expected the key to open, got %v", err)
+	}
+	if !opened.Equal(key.Private) {
+		t.Error("Expected the opened key to match the original")
+	}
+
+	if _, err := openKey("other secret", key.ID, sealed); err == nil {
+		t.Error("Expected a different JWT_SECRET not to open the key")
+	}
+	if _, err := openKey("secret", "another-kid", sealed); err == nil {
+		t.Error("Expected the sealed key not to open under another key ID")
+	}
+}
+
+func TestCheckSecret(t *testing.T) {
+	for _, secret := range []string{"", DefaultSecret} {
+		if !errors.Is(checkSecret(secret), ErrDefaultSecret) {
+			t.Errorf("Expected %q to be refused", secret)
+		}
+	}
+	if err := checkSecret("a-long-random-production-secret"); err != nil {
+		t.Errorf("Expected a private secret to be accepted, got %v", err)
+	}
+}
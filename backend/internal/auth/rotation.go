@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+)
+
+// rotationPeriod is how long a key signs before a successor is published.
+var rotationPeriod = time.Duration(config.GetEnvAsInt("JWT_KEY_ROTATION_HOURS", 30*24)) * time.Hour
+
+// keyOverlap is how long a key keeps verifying after its successor starts
+// signing. It never drops below AccessTokenTTL, so no valid token is orphaned.
+var keyOverlap = time.Duration(config.GetEnvAsInt("JWT_KEY_OVERLAP_MINUTES", 60)) * time.Minute
+
+// refreshInterval is how often each replica reloads the key set.
+const refreshInterval = time.Minute
+
+// publishAhead is how long a new key is published before it signs. It exceeds
+// refreshInterval and the JWKS cache lifetime, so every replica and verifier
+// knows a key before tokens carry it.
+const publishAhead = 10 * time.Minute
+
+// LoadKeys loads the key set from the database, creating the first key if
+// there is none or rotating if the newest key is due.
+func LoadKeys() error {
+	return rotateIfDue(time.Now())
+}
+
+// RunKeyRotation starts a background process that reloads the key set every
+// minute and publishes a new key every JWT_KEY_ROTATION_HOURS.
+func RunKeyRotation() {
+	log.Println("Starting signing key rotation...")
+	ticker := time.NewTicker(refreshInterval)
+
+	for range ticker.C {
+		if err := rotateIfDue(time.Now()); err != nil {
+			log.Printf("Error rotating signing keys: %v", err)
+		}
+	}
+}
+
+// rotateIfDue reloads the key set and, if the newest key has signed for
+// rotationPeriod (or there is no key), publishes its successor.
+func rotateIfDue(now time.Time) error {
+	if err := reload(); err != nil {
+		return err
+	}
+
+	newest, ok := Keys.Newest()
+	if ok && !rotationDue(newest, now) {
+		return nil
+	}
+
+	// The very first key has no predecessor to overlap with, so it signs at once
+	activatesAt, currentID := now, ""
+	if ok {
+		activatesAt, currentID = now.Add(publishAhead), newest.ID
+	}
+	next, err := NewKey(activatesAt)
+	if err != nil {
+		return err
+	}
+	sealed, err := sealKey(secret, next.ID, next.Private)
+	if err != nil {
+		return err
+	}
+
+	err = database.AddSigningKey(database.DB, &domain.SigningKey{
+		ID:          next.ID,
+		Algorithm:   Algorithm,
+		PublicKey:   base64.RawURLEncoding.EncodeToString(next.Public),
+		PrivateKey:  sealed,
+		CreatedAt:   now,
+		ActivatesAt: next.ActivatesAt,
+	}, currentID, activatesAt.Add(overlap()))
+	if err != nil && !errors.Is(err, database.ErrSigningKeyRotated) {
+		return err
+	}
+	if err == nil {
+		log.Printf("Published signing key %s; it signs from %s", next.ID, next.ActivatesAt.Format(time.RFC3339))
+	}
+	return reload()
+}
+
+// rotationDue reports whether newest has signed for rotationPeriod at now.
+func rotationDue(newest Key, now time.Time) bool {
+	return !now.Before(newest.ActivatesAt.Add(rotationPeriod))
+}
+
+func overlap() time.Duration {
+	if keyOverlap < AccessTokenTTL {
+		return AccessTokenTTL
+	}
+	return keyOverlap
+}
+
+// reload replaces Keys with the verifying keys stored in the database.
+func reload() error {
+	stored, err := database.SigningKeys(database.DB)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]Key, 0, len(stored))
+	for _, s := range stored {
+		private, err := openKey(secret, s.ID, s.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("signing key %s cannot be decrypted (has JWT_SECRET changed?): %w", s.ID, err)
+		}
+		keys = append(keys, Key{
+			ID:          s.ID,
+			Private:     private,
+			Public:      private.Public().(ed25519.PublicKey),
+			ActivatesAt: s.ActivatesAt,
+			ExpiresAt:   s.ExpiresAt,
+		})
+	}
+	Keys.Replace(keys)
+	return nil
+}
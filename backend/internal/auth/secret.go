@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+
+	"los-tecnicos/backend/internal/config"
+)
+
+// DefaultSecret is the JWT_SECRET used when none is configured. It is public,
+// so CheckSecret only accepts it in development.
+const DefaultSecret = "a-very-secret-key"
+
+// secret encrypts the signing keys' private halves at rest.
+var secret = config.GetEnv("JWT_SECRET", DefaultSecret)
+
+// ErrDefaultSecret is returned by CheckSecret outside development when
+// JWT_SECRET is unset, empty or the default.
+var ErrDefaultSecret = errors.New("JWT_SECRET must be set to a private value outside development (APP_ENV=development)")
+
+// CheckSecret refuses to run with the default JWT_SECRET unless APP_ENV is
+// development: anyone with it could decrypt the signing keys.
+func CheckSecret() error {
+	if config.IsDevelopment() {
+		return nil
+	}
+	return checkSecret(secret)
+}
+
+func checkSecret(secret string) error {
+	if secret == "" || secret == DefaultSecret {
+		return ErrDefaultSecret
+	}
+	return nil
+}
+
+// sealKey encrypts a private key's seed with AES-GCM under JWT_SECRET, bound
+// to the key's ID.
+func sealKey(secret, kid string, private ed25519.PrivateKey) (string, error) {
+	gcm, err := newGCM(secret)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, private.Seed(), []byte(kid))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openKey reverses sealKey.
+func openKey(secret, kid, sealed string) (ed25519.PrivateKey, error) {
+	gcm, err := newGCM(secret)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("sealed key too short")
+	}
+	seed, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(kid))
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.New("sealed key has the wrong size")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func newGCM(secret string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
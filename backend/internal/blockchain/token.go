@@ -0,0 +1,102 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+
+	"los-tecnicos/backend/internal/config"
+
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+// tokenDecimals is the number of decimal places in energy_token amounts.
+var tokenDecimals = config.GetEnvAsInt("ENERGY_TOKEN_DECIMALS", 7)
+
+// TokenBalance reads wallet's energy_token balance straight from the
+// contract's persistent storage (DataKey::Balance(wallet)), so no transaction
+// has to be simulated. A wallet that never held tokens has balance 0.
+func (c *SorobanClient) TokenBalance(contractID, wallet string) (float64, error) {
+	key, err := tokenBalanceKey(contractID, wallet)
+	if err != nil {
+		return 0, err
+	}
+	keyXDR, err := xdr.MarshalBase64(key)
+	if err != nil {
+		return 0, err
+	}
+
+	raw, err := c.sendRPC("getLedgerEntries", map[string]interface{}{"keys": []string{keyXDR}})
+	if err != nil {
+		return 0, err
+	}
+	var result struct {
+		Entries []struct {
+			XDR string `json:"xdr"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return 0, fmt.Errorf("bad getLedgerEntries result: %v", err)
+	}
+	if len(result.Entries) == 0 {
+		return 0, nil
+	}
+
+	var data xdr.LedgerEntryData
+	if err := xdr.SafeUnmarshalBase64(result.Entries[0].XDR, &data); err != nil {
+		return 0, fmt.Errorf("bad ledger entry: %v", err)
+	}
+	if data.ContractData == nil || data.ContractData.Val.I128 == nil {
+		return 0, fmt.Errorf("balance entry for %s is not an i128", wallet)
+	}
+	return i128ToAmount(*data.ContractData.Val.I128, tokenDecimals), nil
+}
+
+// tokenBalanceKey builds the ledger key of wallet's balance in the
+// energy_token contract. Soroban stores the enum variant Balance(Address) as
+// the vector [Symbol("Balance"), Address].
+func tokenBalanceKey(contractID, wallet string) (xdr.LedgerKey, error) {
+	contractBytes, err := strkey.Decode(strkey.VersionByteContract, contractID)
+	if err != nil {
+		return xdr.LedgerKey{}, fmt.Errorf("invalid contract id: %v", err)
+	}
+	var contractHash xdr.Hash
+	copy(contractHash[:], contractBytes)
+	contractIDHash := xdr.ContractId(contractHash)
+
+	accountID, err := xdr.AddressToAccountId(wallet)
+	if err != nil {
+		return xdr.LedgerKey{}, fmt.Errorf("invalid wallet address: %v", err)
+	}
+
+	variant := xdr.ScSymbol("Balance")
+	vec := &xdr.ScVec{
+		{Type: xdr.ScValTypeScvSymbol, Sym: &variant},
+		{Type: xdr.ScValTypeScvAddress, Address: &xdr.ScAddress{
+			Type:      xdr.ScAddressTypeScAddressTypeAccount,
+			AccountId: &accountID,
+		}},
+	}
+
+	return xdr.LedgerKey{
+		Type: xdr.LedgerEntryTypeContractData,
+		ContractData: &xdr.LedgerKeyContractData{
+			Contract: xdr.ScAddress{
+				Type:       xdr.ScAddressTypeScAddressTypeContract,
+				ContractId: &contractIDHash,
+			},
+			Key:        xdr.ScVal{Type: xdr.ScValTypeScvVec, Vec: &vec},
+			Durability: xdr.ContractDataDurabilityPersistent,
+		},
+	}, nil
+}
+
+// i128ToAmount converts a raw i128 token amount to whole tokens.
+func i128ToAmount(parts xdr.Int128Parts, decimals int) float64 {
+	value := new(big.Int).Lsh(big.NewInt(int64(parts.Hi)), 64)
+	value.Add(value, new(big.Int).SetUint64(uint64(parts.Lo)))
+	amount, _ := new(big.Float).Quo(new(big.Float).SetInt(value), big.NewFloat(math.Pow10(decimals))).Float64()
+	return amount
+}
@@ -0,0 +1,80 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+func TestTokenBalance(t *testing.T) {
+	contractID, err := strkey.Encode(strkey.VersionByteContract, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet := keypair.MustRandom().Address()
+	key, err := tokenBalanceKey(contractID, wallet)
+	if err != nil {
+		t.Fatalf("Failed to build key: %v", err)
+	}
+	keyXDR, _ := xdr.MarshalBase64(key)
+
+	// 12.5 tokens with 7 decimals
+	entry := xdr.LedgerEntryData{
+		Type: xdr.LedgerEntryTypeContractData,
+		ContractData: &xdr.ContractDataEntry{
+			Contract:   key.ContractData.Contract,
+			Key:        key.ContractData.Key,
+			Durability: xdr.ContractDataDurabilityPersistent,
+			Val:        xdr.ScVal{Type: xdr.ScValTypeScvI128, I128: &xdr.Int128Parts{Lo: 125000000}},
+		},
+	}
+	entryXDR, _ := xdr.MarshalBase64(entry)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+			Params struct {
+				Keys []string `json:"keys"`
+			} `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		entries := []map[string]string{}
+		if req.Method == "getLedgerEntries" && len(req.Params.Keys) == 1 && req.Params.Keys[0] == keyXDR {
+			entries = append(entries, map[string]string{"key": keyXDR, "xdr": entryXDR})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0", "id": 1,
+			"result": map[string]interface{}{"entries": entries},
+		})
+	}))
+	defer server.Close()
+
+	client := NewSorobanClient(server.URL)
+	balance, err := client.TokenBalance(contractID, wallet)
+	if err != nil {
+		t.Fatalf("TokenBalance failed: %v", err)
+	}
+	if balance != 12.5 {
+		t.Errorf("Expected 12.5 tokens, got %v", balance)
+	}
+
+	// A wallet that never held tokens has no entry
+	balance, err = client.TokenBalance(contractID, keypair.MustRandom().Address())
+	if err != nil || balance != 0 {
+		t.Errorf("Expected 0 for an unknown wallet, got %v, %v", balance, err)
+	}
+}
+
+func TestI128ToAmount(t *testing.T) {
+	if got := i128ToAmount(xdr.Int128Parts{Hi: -1, Lo: ^xdr.Uint64(0)}, 0); got != -1 {
+		t.Errorf("Expected -1, got %v", got)
+	}
+	if got := i128ToAmount(xdr.Int128Parts{Hi: 1, Lo: 0}, 0); got != 18446744073709551616 {
+		t.Errorf("Expected 2^64, got %v", got)
+	}
+}
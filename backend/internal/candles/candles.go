@@ -0,0 +1,106 @@
+package candles
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+// Intervals maps the supported interval names to their durations.
+var Intervals = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// MaxCandles bounds how many buckets a single request may span.
+const MaxCandles = 1000
+
+// Sample is a single priced observation: a trade, or an indicative price with no volume.
+type Sample struct {
+	Time   time.Time
+	Price  float64
+	Kwh    float64
+	Tokens float64
+}
+
+// Candle is an OHLCV bar. Timestamps are RFC3339 in UTC.
+type Candle struct {
+	Start        string  `json:"start"`
+	End          string  `json:"end"`
+	Open         float64 `json:"open"`
+	High         float64 `json:"high"`
+	Low          float64 `json:"low"`
+	Close        float64 `json:"close"`
+	VolumeKwh    float64 `json:"volume_kwh"`
+	VolumeTokens float64 `json:"volume_tokens"`
+	Count        int     `json:"count"`
+}
+
+// ParseInterval resolves an interval name such as "5m".
+func ParseInterval(name string) (time.Duration, error) {
+	d, ok := Intervals[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported interval %q (use 1m, 5m, 1h or 1d)", name)
+	}
+	return d, nil
+}
+
+// Aggregate buckets samples into candles aligned to the interval in UTC.
+// Buckets without samples are omitted.
+func Aggregate(samples []Sample, interval time.Duration) []Candle {
+	sorted := make([]Sample, len(samples))
+	copy(sorted, samples)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	result := []Candle{}
+	var current *Candle
+	var currentStart time.Time
+
+	for _, s := range sorted {
+		start := s.Time.UTC().Truncate(interval)
+		if current == nil || !start.Equal(currentStart) {
+			result = append(result, Candle{
+				Start: start.Format(time.RFC3339),
+				End:   start.Add(interval).Format(time.RFC3339),
+				Open:  s.Price,
+				High:  s.Price,
+				Low:   s.Price,
+			})
+			current = &result[len(result)-1]
+			currentStart = start
+		}
+
+		current.High = math.Max(current.High, s.Price)
+		current.Low = math.Min(current.Low, s.Price)
+		current.Close = s.Price
+		current.VolumeKwh += s.Kwh
+		current.VolumeTokens += s.Tokens
+		current.Count++
+	}
+
+	return result
+}
+
+// FromBuckets turns price buckets aggregated in the database into candles. The
+// buckets carry no volume, like indicative Samples.
+func FromBuckets(buckets []domain.PriceBucket, interval time.Duration) []Candle {
+	result := make([]Candle, 0, len(buckets))
+	for _, b := range buckets {
+		start := b.BucketStart.UTC()
+		result = append(result, Candle{
+			Start: start.Format(time.RFC3339),
+			End:   start.Add(interval).Format(time.RFC3339),
+			Open:  b.Open,
+			High:  b.High,
+			Low:   b.Low,
+			Close: b.Close,
+			Count: b.Samples,
+		})
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package candles
+
+import (
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func TestAggregate(t *testing.T) {
+	base := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	samples := []Sample{
+		{Time: base.Add(90 * time.Second), Price: 5.5, Kwh: 1, Tokens: 5.5},
+		{Time: base.Add(10 * time.Second), Price: 5.0, Kwh: 2, Tokens: 10},
+		{Time: base.Add(30 * time.Second), Price: 6.0, Kwh: 1, Tokens: 6},
+		{Time: base.Add(50 * time.Second), Price: 4.5, Kwh: 3, Tokens: 13.5},
+		{Time: base.Add(10 * time.Minute), Price: 7.0, Kwh: 1, Tokens: 7},
+	}
+
+	got := Aggregate(samples, time.Minute)
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 candles (empty minutes omitted), got %d", len(got))
+	}
+
+	first := got[0]
+	if first.Start != "2026-05-01T10:00:00Z" || first.End != "2026-05-01T10:01:00Z" {
+		t.Errorf("Unexpected bucket bounds %s - %s", first.Start, first.End)
+	}
+	if first.Open != 5.0 || first.High != 6.0 || first.Low != 4.5 || first.Close != 4.5 {
+		t.Errorf("Unexpected OHLC: %+v", first)
+	}
+	if first.VolumeKwh != 6 || first.VolumeTokens != 29.5 || first.Count != 3 {
+		t.Errorf("Unexpected volume: %+v", first)
+	}
+
+	if got[2].Start != "2026-05-01T10:10:00Z" {
+		t.Errorf("Expected last candle at 10:10, got %s", got[2].Start)
+	}
+}
+
+func TestAggregateDailyUsesUTCMidnight(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	samples := []Sample{
+		{Time: time.Date(2026, 5, 2, 3, 0, 0, 0, ist), Price: 5}, // 2026-05-01 21:30 UTC
+		{Time: time.Date(2026, 5, 2, 6, 0, 0, 0, ist), Price: 6}, // 2026-05-02 00:30 UTC
+	}
+
+	got := Aggregate(samples, 24*time.Hour)
+	if len(got) != 2 || got[0].Start != "2026-05-01T00:00:00Z" || got[1].Start != "2026-05-02T00:00:00Z" {
+		t.Errorf("Unexpected daily buckets: %+v", got)
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	if d, err := ParseInterval("5m"); err != nil || d != 5*time.Minute {
+		t.Errorf("Expected 5m, got %v (%v)", d, err)
+	}
+	if _, err := ParseInterval("2h"); err == nil {
+		t.Error("Expected error for unsupported interval")
+	}
+}
+
+func TestFromBuckets(t *testing.T) {
+	start := time.Date(2026, 5, 1, 10, 0, 0, 0, time.FixedZone("IST", 5*3600+1800))
+	got := FromBuckets([]domain.PriceBucket{{BucketStart: start, Open: 5, High: 6, Low: 4.5, Close: 4.5, Samples: 3}}, time.Hour)
+	if len(got) != 1 || got[0].Start != "2026-05-01T04:30:00Z" || got[0].End != "2026-05-01T05:30:00Z" {
+		t.Fatalf("Expected one candle with UTC bounds, got %+v", got)
+	}
+	if got[0].Open != 5 || got[0].Close != 4.5 || got[0].Count != 3 || got[0].VolumeKwh != 0 {
+		t.Errorf("Unexpected candle: %+v", got[0])
+	}
+}
@@ -24,3 +24,21 @@ func GetEnvAsInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+// GetEnvAsFloat gets an environment variable as a float or returns a default value.
+func GetEnvAsFloat(key string, fallback float64) float64 {
+	if value, ok := os.LookupEnv(key); ok {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fallback
+		}
+		return f
+	}
+	return fallback
+}
+
+// IsDevelopment reports whether APP_ENV is "development". Anything else,
+// including an unset APP_ENV, is treated as production.
+func IsDevelopment() bool {
+	return GetEnv("APP_ENV", "production") == "development"
+}
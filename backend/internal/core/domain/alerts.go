@@ -0,0 +1,86 @@
+package domain
+
+import "time"
+
+// Alert severities, in increasing order.
+const (
+	AlertSeverityInfo     = "info"
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+)
+
+// Alert types raised by the server-side rules. Devices may report other types.
+const (
+	AlertLowSoC           = "low_soc"
+	AlertOverTemperature  = "over_temperature"
+	AlertVoltageOutOfBand = "voltage_out_of_band"
+	AlertDeviceOffline    = "device_offline"
+	AlertConfigDrift      = "config_drift" // The device applied its desired configuration but reports other values
+)
+
+// Alert sources.
+const (
+	AlertSourceRule   = "rule"   // Raised and resolved by a server-side rule
+	AlertSourceDevice = "device" // Reported by the device on energy/device/{id}/alert
+)
+
+// Alert statuses.
+const (
+	AlertStatusOpen     = "open"
+	AlertStatusResolved = "resolved"
+)
+
+// Alert notification events.
+const (
+	AlertEventRaised       = "raised"
+	AlertEventEscalated    = "escalated"
+	AlertEventResolved     = "resolved"
+	AlertEventAcknowledged = "acknowledged"
+)
+
+// AlertSeverityRank orders severities; unknown ones rank lowest.
+func AlertSeverityRank(severity string) int {
+	switch severity {
+	case AlertSeverityCritical:
+		return 2
+	case AlertSeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// IsAlertSeverity reports whether s is a known severity.
+func IsAlertSeverity(s string) bool {
+	return s == AlertSeverityInfo || s == AlertSeverityWarning || s == AlertSeverityCritical
+}
+
+// DeviceAlert is a condition on a device that its owner should know about.
+// A device has at most one open alert of each type; raising it again while
+// open counts another occurrence and may escalate its severity.
+type DeviceAlert struct {
+	ID             string     `json:"id"`
+	DeviceID       string     `json:"device_id" gorm:"index;not null;uniqueIndex:idx_device_alerts_open,where:status = 'open'"` // One open alert per device and type
+	Type           string     `json:"type" gorm:"not null;uniqueIndex:idx_device_alerts_open,where:status = 'open'"`
+	Source         string     `json:"source" gorm:"not null"`
+	Severity       string     `json:"severity" gorm:"not null"`
+	Status         string     `json:"status" gorm:"not null;index"`
+	Message        string     `json:"message"`
+	Value          *float64   `json:"value,omitempty"`     // The reading that raised it
+	Threshold      *float64   `json:"threshold,omitempty"` // The limit it crossed
+	Occurrences    int        `json:"occurrences" gorm:"not null;default:1"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastSeenAt     time.Time  `json:"last_seen_at"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty"`
+}
+
+// AlertWebhook is where a user's device alerts are POSTed.
+type AlertWebhook struct {
+	UserID      string    `json:"user_id" gorm:"primaryKey"`
+	URL         string    `json:"url" gorm:"not null"`
+	Secret      string    `json:"-" gorm:"not null"` // Signs the body (X-Alert-Signature)
+	MinSeverity string    `json:"min_severity" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
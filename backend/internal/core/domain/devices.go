@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Device statuses.
+const (
+	DeviceStatusOnline         = "Online"
+	DeviceStatusOffline        = "Offline"
+	DeviceStatusRegistered     = "registered"
+	DeviceStatusDecommissioned = "Decommissioned" // Terminal: the device can no longer trade, change or change hands
+)
+
+// Device lifecycle event types, recorded in DeviceEvent.
+const (
+	DeviceEventRegistered        = "registered"
+	DeviceEventUpdated           = "updated"
+	DeviceEventDecommissioned    = "decommissioned"
+	DeviceEventTransferRequested = "transfer_requested"
+	DeviceEventTransferAccepted  = "transfer_accepted"
+	DeviceEventTransferDeclined  = "transfer_declined"
+	DeviceEventTransferCancelled = "transfer_cancelled"
+)
+
+// Device transfer statuses.
+const (
+	DeviceTransferPending   = "pending"
+	DeviceTransferAccepted  = "accepted"
+	DeviceTransferDeclined  = "declined"
+	DeviceTransferCancelled = "cancelled" // By the sender, by decommissioning, or on expiry
+)
+
+// MetadataNominalVoltage is the device metadata key for the battery's nominal
+// voltage, used to score and alert on voltage deviations.
+const MetadataNominalVoltage = "nominal_voltage"
+
+// DeviceMetadata is free-form owner-supplied information about a device
+// (model, installer, firmware notes...), stored as JSON.
+type DeviceMetadata map[string]string
+
+// Value implements driver.Valuer.
+func (m DeviceMetadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner.
+func (m *DeviceMetadata) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*m = DeviceMetadata{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into DeviceMetadata", value)
+	}
+	return json.Unmarshal(data, m)
+}
+
+// NominalVoltage returns the nominal voltage set in the device's metadata, if
+// it is a positive number.
+func (d IoTDevice) NominalVoltage() (float64, bool) {
+	v, err := strconv.ParseFloat(d.Metadata[MetadataNominalVoltage], 64)
+	if err != nil || v <= 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// IsDecommissioned reports whether the device has been retired.
+func (d IoTDevice) IsDecommissioned() bool {
+	return d.Status == DeviceStatusDecommissioned
+}
+
+// StoredKwh is the energy currently in the device's battery.
+func (d IoTDevice) StoredKwh() float64 {
+	return d.BatteryLevel * d.CapacityKwh
+}
+
+// AvailableKwh is the stored energy not already reserved for open sell orders.
+func (d IoTDevice) AvailableKwh() float64 {
+	return d.StoredKwh() - d.ReservedKwh
+}
+
+// SellingDeviceStatuses are the statuses in which a device can back sell orders.
+var SellingDeviceStatuses = []string{DeviceStatusOnline, DeviceStatusRegistered}
+
+// CanSell reports whether the device may back sell orders: it is online, or
+// registered and has reported a battery level but sent no telemetry yet.
+func (d IoTDevice) CanSell() bool {
+	switch d.Status {
+	case DeviceStatusOnline:
+		return true
+	case DeviceStatusRegistered:
+		return d.BatteryLevel > 0
+	}
+	return false
+}
+
+// CanDeliver reports whether the device is an ESP32 that can sell with at least kwh available.
+func (d IoTDevice) CanDeliver(kwh float64) bool {
+	return d.DeviceType == "esp32" && d.CanSell() && d.AvailableKwh() >= kwh-kwhEpsilon
+}
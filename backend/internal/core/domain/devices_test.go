@@ -0,0 +1,28 @@
+package domain
+
+import "testing"
+
+func TestDeviceMetadataRoundTrip(t *testing.T) {
+	value, err := DeviceMetadata{"model": "ESP32-S3", "installer": "Solar Co"}.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var scanned DeviceMetadata
+	if err := scanned.Scan([]byte(value.(string))); err != nil {
+		t.Fatal(err)
+	}
+	if scanned["model"] != "ESP32-S3" || scanned["installer"] != "Solar Co" {
+		t.Errorf("Expected the metadata to round-trip, got %v", scanned)
+	}
+
+	if value, _ := DeviceMetadata(nil).Value(); value != "{}" {
+		t.Errorf("Expected nil metadata to be stored as {}, got %v", value)
+	}
+	if err := scanned.Scan(nil); err != nil || scanned == nil || len(scanned) != 0 {
+		t.Errorf("Expected NULL to scan as empty metadata, got %v, %v", scanned, err)
+	}
+	if err := scanned.Scan(42); err == nil {
+		t.Error("Expected scanning a number to fail")
+	}
+}
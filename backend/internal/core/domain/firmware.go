@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Firmware campaign statuses.
+const (
+	CampaignDraft     = "draft"
+	CampaignRunning   = "running"
+	CampaignPaused    = "paused"
+	CampaignHalted    = "halted"    // Stopped automatically: too many updates failed
+	CampaignCompleted = "completed" // Terminal
+	CampaignCancelled = "cancelled" // Terminal
+)
+
+// Firmware update statuses. Sent, downloading and installing are active;
+// the rest but pending are terminal.
+const (
+	UpdatePending     = "pending" // Waiting for its stage, or for the device to come online
+	UpdateSent        = "sent"
+	UpdateDownloading = "downloading"
+	UpdateInstalling  = "installing"
+	UpdateSucceeded   = "succeeded"
+	UpdateFailed      = "failed"
+	UpdateTimedOut    = "timed_out"
+	UpdateSkipped     = "skipped"   // The device did not come online in time to be sent it
+	UpdateCancelled   = "cancelled" // Its campaign was cancelled before it was sent
+)
+
+// ActiveUpdateStatuses are the statuses of updates a device is working on.
+var ActiveUpdateStatuses = []string{UpdateSent, UpdateDownloading, UpdateInstalling}
+
+// IsTerminalUpdateStatus reports whether an update in status is finished.
+func IsTerminalUpdateStatus(status string) bool {
+	switch status {
+	case UpdateSucceeded, UpdateFailed, UpdateTimedOut, UpdateSkipped, UpdateCancelled:
+		return true
+	}
+	return false
+}
+
+// MetadataGroup is the device metadata key naming the group a device belongs
+// to, which firmware campaigns target.
+const MetadataGroup = "group"
+
+// Firmware is a firmware release in the registry. The binary is hosted at URL;
+// Signature is the release key's Ed25519 signature of its SHA-256 digest.
+type Firmware struct {
+	ID        string    `json:"id"`
+	Version   string    `json:"version" gorm:"uniqueIndex;not null"`
+	URL       string    `json:"url" gorm:"not null"`
+	SHA256    string    `json:"sha256" gorm:"column:sha256;not null"` // Hex
+	Size      int64     `json:"size" gorm:"not null"`                 // Bytes
+	Signature string    `json:"signature" gorm:"not null"`            // Base64
+	Notes     string    `json:"notes,omitempty"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RolloutStages are the cumulative percentages of a campaign's devices
+// updated by the end of each stage, e.g. [5, 25, 100].
+type RolloutStages []int
+
+// Value implements driver.Valuer.
+func (s RolloutStages) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(s)
+	return string(data), err
+}
+
+// Scan implements sql.Scanner.
+func (s *RolloutStages) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*s = RolloutStages{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into RolloutStages", value)
+	}
+	return json.Unmarshal(data, s)
+}
+
+// FirmwareCampaign rolls a firmware release out to a group of devices in
+// stages. Its devices are fixed when it is created, one FirmwareUpdate each.
+type FirmwareCampaign struct {
+	ID             string        `json:"id"`
+	Name           string        `json:"name" gorm:"not null"`
+	FirmwareID     string        `json:"firmware_id" gorm:"index;not null"`
+	TargetGroup    string        `json:"target_group,omitempty"` // Device metadata group; empty with explicit devices
+	Stages         RolloutStages `json:"stages" gorm:"type:jsonb;not null"`
+	CurrentStage   int           `json:"current_stage" gorm:"not null;default:0"` // Index into Stages
+	MaxFailureRate float64       `json:"max_failure_rate" gorm:"not null"`        // Halts above this share of finished updates failing
+	Status         string        `json:"status" gorm:"not null;index"`
+	HaltReason     string        `json:"halt_reason,omitempty"`
+	CreatedBy      string        `json:"created_by"`
+	CreatedAt      time.Time     `json:"created_at"`
+	StartedAt      *time.Time    `json:"started_at,omitempty"`
+	FinishedAt     *time.Time    `json:"finished_at,omitempty"`
+}
+
+// FirmwareUpdate tracks one device's update in a campaign. A device works on
+// at most one update at a time, across campaigns.
+type FirmwareUpdate struct {
+	ID          string     `json:"id"`
+	CampaignID  string     `json:"campaign_id" gorm:"not null;uniqueIndex:idx_firmware_updates_campaign_device"`
+	DeviceID    string     `json:"device_id" gorm:"not null;uniqueIndex:idx_firmware_updates_campaign_device;uniqueIndex:idx_firmware_updates_active,where:status = 'sent' OR status = 'downloading' OR status = 'installing'"`
+	Stage       int        `json:"stage" gorm:"not null"` // The stage that updates this device
+	Status      string     `json:"status" gorm:"not null;index"`
+	Progress    int        `json:"progress"` // Percent, as last reported
+	Error       string     `json:"error,omitempty"`
+	FromVersion string     `json:"from_version,omitempty"`
+	SentAt      *time.Time `json:"sent_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// KYC statuses, of a user (User.KYCStatus) and of a submission.
+const (
+	KYCStatusPending  = "pending"   // Nothing submitted yet (users only)
+	KYCStatusInReview = "in_review" // Waiting for the provider or an admin
+	KYCStatusApproved = "approved"
+	KYCStatusRejected = "rejected"
+)
+
+// KYC tiers. A user's tier is the highest one approved and sets their trading limits.
+const (
+	KYCTierNone  = 0 // Unverified
+	KYCTierBasic = 1 // Identity details and an identity document
+	KYCTierFull  = 2 // Also an address and proof of address
+)
+
+// KYC document types.
+const (
+	KYCDocPassport       = "passport"
+	KYCDocNationalID     = "national_id"
+	KYCDocDriversLicense = "drivers_license"
+	KYCDocProofOfAddress = "proof_of_address"
+)
+
+// KYCSystemReviewer is recorded as the reviewer of decisions not made by an
+// admin, prefixed to the verifier's name.
+const KYCSystemReviewer = "provider:"
+
+// ErrKYCDocumentsMissing is wrapped by CheckKYCDocuments.
+var ErrKYCDocumentsMissing = errors.New("missing KYC documents")
+
+// IsKYCDocumentType reports whether docType is a known document type.
+func IsKYCDocumentType(docType string) bool {
+	switch docType {
+	case KYCDocPassport, KYCDocNationalID, KYCDocDriversLicense, KYCDocProofOfAddress:
+		return true
+	}
+	return false
+}
+
+// CheckKYCDocuments returns an error naming what is missing if docTypes do
+// not cover what tier requires: an identity document from the basic tier on,
+// and proof of address for the full tier.
+func CheckKYCDocuments(tier int, docTypes []string) error {
+	var identity, address bool
+	for _, t := range docTypes {
+		switch t {
+		case KYCDocPassport, KYCDocNationalID, KYCDocDriversLicense:
+			identity = true
+		case KYCDocProofOfAddress:
+			address = true
+		}
+	}
+	if tier >= KYCTierBasic && !identity {
+		return fmt.Errorf("%w: tier %d needs a passport, national ID or driver's license", ErrKYCDocumentsMissing, tier)
+	}
+	if tier >= KYCTierFull && !address {
+		return fmt.Errorf("%w: tier %d needs a proof of address", ErrKYCDocumentsMissing, tier)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckKYCDocuments(t *testing.T) {
+	cases := []struct {
+		tier  int
+		types []string
+		ok    bool
+	}{
+		{KYCTierBasic, []string{KYCDocPassport}, true},
+		{KYCTierBasic, []string{KYCDocProofOfAddress}, false},
+		{KYCTierFull, []string{KYCDocNationalID}, false},
+		{KYCTierFull, []string{KYCDocDriversLicense, KYCDocProofOfAddress}, true},
+	}
+	for _, tc := range cases {
+		err := CheckKYCDocuments(tc.tier, tc.types)
+		if tc.ok && err != nil {
+			t.Errorf("Tier %d with %v: unexpected error %v", tc.tier, tc.types, err)
+		}
+		if !tc.ok && !errors.Is(err, ErrKYCDocumentsMissing) {
+			t.Errorf("Tier %d with %v: expected ErrKYCDocumentsMissing, got %v", tc.tier, tc.types, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"errors"
+	"math"
+)
+
+// SystemOwner owns the platform's side of every journal.
+const SystemOwner = "system"
+
+// Ledger account types. Every user has one account of each user type; the
+// system owner holds the counterparts for tokens entering and leaving users'
+// hands, so the ledger as a whole always sums to zero.
+const (
+	LedgerAvailable = "available" // Spendable tokens
+	LedgerReserved  = "reserved"  // Tokens held for open buy orders
+	LedgerYield     = "yield"     // Accrued yield
+	LedgerFees      = "fees"      // Fees earned (the platform, or a mesh node operator)
+
+	LedgerExternal  = "external"   // System: tokens deposited from or withdrawn to the chain
+	LedgerYieldPool = "yield_pool" // System: source of yield accruals
+)
+
+// UserLedgerAccounts are the accounts a user's holdings are spread over.
+var UserLedgerAccounts = []string{LedgerAvailable, LedgerReserved, LedgerYield, LedgerFees}
+
+// Journal kinds.
+const (
+	JournalTrade              = "trade"
+	JournalReserve            = "reserve"
+	JournalRelease            = "release"
+	JournalFee                = "fee"
+	JournalYield              = "yield"
+	JournalDeposit            = "deposit"
+	JournalWithdrawal         = "withdrawal"
+	JournalWithdrawalReversal = "withdrawal_reversal"
+)
+
+// ledgerEpsilon absorbs float rounding when checking that a journal balances.
+const ledgerEpsilon = 1e-9
+
+// ErrUnbalancedJournal is returned for a journal whose postings do not sum to zero.
+var ErrUnbalancedJournal = errors.New("journal postings do not balance")
+
+// Posting is one side of a journal: a signed change to an owner's account.
+type Posting struct {
+	Owner   string
+	Account string
+	Amount  float64
+}
+
+// Transfer returns the two postings moving amount from one account to another.
+func Transfer(fromOwner, fromAccount, toOwner, toAccount string, amount float64) []Posting {
+	return []Posting{
+		{Owner: fromOwner, Account: fromAccount, Amount: -amount},
+		{Owner: toOwner, Account: toAccount, Amount: amount},
+	}
+}
+
+// CheckBalanced reports whether postings form a valid journal: at least two
+// postings summing to zero.
+func CheckBalanced(postings []Posting) error {
+	if len(postings) < 2 {
+		return ErrUnbalancedJournal
+	}
+	sum := 0.0
+	for _, p := range postings {
+		sum += p.Amount
+	}
+	if math.Abs(sum) > ledgerEpsilon {
+		return ErrUnbalancedJournal
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "testing"
+
+func TestCheckBalanced(t *testing.T) {
+	if err := CheckBalanced(Transfer("a", LedgerAvailable, "b", LedgerAvailable, 12.5)); err != nil {
+		t.Errorf("Expected a transfer to balance, got %v", err)
+	}
+
+	// A trade with a fee split three ways
+	postings := []Posting{
+		{Owner: "buyer", Account: LedgerAvailable, Amount: -10.3},
+		{Owner: "seller", Account: LedgerAvailable, Amount: 10},
+		{Owner: SystemOwner, Account: LedgerFees, Amount: 0.2},
+		{Owner: "node", Account: LedgerFees, Amount: 0.1},
+	}
+	if err := CheckBalanced(postings); err != nil {
+		t.Errorf("Expected a multi-leg journal to balance, got %v", err)
+	}
+
+	postings[0].Amount = -10
+	if err := CheckBalanced(postings); err != ErrUnbalancedJournal {
+		t.Errorf("Expected an unbalanced journal to be rejected, got %v", err)
+	}
+	if err := CheckBalanced(postings[:1]); err != ErrUnbalancedJournal {
+		t.Errorf("Expected a single posting to be rejected, got %v", err)
+	}
+}
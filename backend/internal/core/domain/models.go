@@ -6,48 +6,148 @@ import (
 
 // User represents a user in the system.
 type User struct {
-	ID                    string    `json:"id"`
-	WalletAddress         string    `json:"wallet_address" gorm:"unique;not null"`
-	Role                  string    `json:"role" gorm:"not null"` // e.g., Donor, Recipient, NetworkNodeOperator
-	Location              string    `json:"location"`
-	CreatedAt             time.Time `json:"created_at"`
-	KYCStatus             string    `json:"kyc_status" gorm:"default:'pending'"`
-	RefreshToken          string    `json:"-" gorm:"index"` // The token is sensitive, don't expose in JSON
-	RefreshTokenExpiresAt time.Time `json:"-"`
+	ID            string    `json:"id"`
+	WalletAddress string    `json:"wallet_address" gorm:"unique;not null"`
+	Role          string    `json:"role" gorm:"not null"` // e.g., Donor, Recipient, NetworkNodeOperator
+	Location      string    `json:"location"`
+	CreatedAt     time.Time `json:"created_at"`
+	KYCStatus     string    `json:"kyc_status" gorm:"default:'pending'"` // pending, in_review, approved, rejected
+	KYCTier       int       `json:"kyc_tier" gorm:"not null;default:0"`  // Highest approved tier
+}
+
+// Session is one refresh token issued to a user. Every refresh rotates the
+// token, creating a new Session in the same family (one family per login, i.e.
+// per device) and marking the old one as replaced. Only the token's hash is
+// stored.
+type Session struct {
+	ID           string     `json:"id"`
+	UserID       string     `json:"user_id" gorm:"index;not null"`
+	FamilyID     string     `json:"family_id" gorm:"index;not null"`
+	TokenHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	UserAgent    string     `json:"user_agent"`
+	IP           string     `json:"ip"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	ReplacedBy   string     `json:"-"` // ID of the session this token was rotated into
+	RevokedAt    *time.Time `json:"-"`
+	RevokeReason string     `json:"-"` // logout, reuse
+}
+
+// SigningKey is an Ed25519 key that access tokens are signed with, named in
+// each token's kid header. A key is published before it starts signing and
+// stays verifiable for an overlap after its successor takes over.
+type SigningKey struct {
+	ID          string     `json:"id"` // JWT kid
+	Algorithm   string     `json:"algorithm"`
+	PublicKey   string     `json:"public_key"` // base64url
+	PrivateKey  string     `json:"-"`          // Seed, encrypted with JWT_SECRET
+	CreatedAt   time.Time  `json:"created_at"`
+	ActivatesAt time.Time  `json:"activates_at"` // When it starts signing
+	ExpiresAt   *time.Time `json:"expires_at"`   // When it stops verifying; nil while it is the newest key
 }
 
 // EnergyOrder represents a buy or sell order in the marketplace.
 type EnergyOrder struct {
-	ID         string    `json:"id"`
-	UserID     string    `json:"user_id" gorm:"not null"`
-	Type       string    `json:"type" gorm:"not null"` // "buy" or "sell"
-	KwhAmount  float64   `json:"kwh_amount" gorm:"not null"`
-	TokenPrice float64   `json:"token_price" gorm:"not null"`
-	Status     string    `json:"status" gorm:"not null"` // e.g., Created, Matched, Executing, Completed, Cancelled
-	CreatedAt  time.Time `json:"created_at"`
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id" gorm:"not null;uniqueIndex:idx_energy_orders_user_client_order_id"`
+	Type        string    `json:"type" gorm:"not null"`                        // "buy" or "sell"
+	Market      string    `json:"market" gorm:"not null;default:'continuous'"` // "continuous" or "day_ahead"
+	Kind        string    `json:"kind" gorm:"not null;default:'limit'"`        // "limit" or "market"
+	TimeInForce string    `json:"time_in_force" gorm:"not null;default:'GTC'"` // GTC, IOC or FOK
+	KwhAmount   float64   `json:"kwh_amount" gorm:"not null"`
+	FilledKwh   float64   `json:"filled_kwh" gorm:"not null;default:0"`
+	TokenPrice  float64   `json:"token_price" gorm:"not null"`        // Limit price; for market orders, derived from MaxSlippage
+	MaxSlippage float64   `json:"max_slippage,omitempty"`             // Market orders only, e.g. 0.05 = 5%
+	QuoteID     string    `json:"quote_id,omitempty"`                 // Signed quote the order was placed against, if any
+	DeviceID    string    `json:"device_id,omitempty"`                // Sell orders: the ESP32 holding the reserved energy
+	FeeRate     float64   `json:"fee_rate" gorm:"not null;default:0"` // Buy orders: highest fee rate, reserved on top of the limit price
+	Status      string    `json:"status" gorm:"not null"`             // See orderTransitions for the allowed status changes
+	Version     int       `json:"version" gorm:"not null;default:0"`  // Incremented on every update, for conditional writes
+	CreatedAt   time.Time `json:"created_at"`
+	PriorityAt  time.Time `json:"priority_at" gorm:"index"` // Time priority within a price; reset when an amendment loses priority
+
+	// Caller-chosen idempotency key; unique per user when set
+	ClientOrderID string `json:"client_order_id,omitempty" gorm:"uniqueIndex:idx_energy_orders_user_client_order_id,where:client_order_id <> ''"`
+
+	ExpiresAt           *time.Time `json:"expires_at,omitempty" gorm:"index"` // Moved to Expired by the sweeper once passed
+	DeliveryWindowStart *time.Time `json:"delivery_window_start,omitempty"`   // Earliest acceptable delivery; nil means any time
+	DeliveryWindowEnd   *time.Time `json:"delivery_window_end,omitempty"`     // Latest acceptable delivery; nil means open-ended
 }
 
 // IoTDevice represents a registered IoT device (ESP32 or Raspberry Pi).
 type IoTDevice struct {
-	ID           string    `json:"id"`
-	OwnerID      string    `json:"owner_id" gorm:"not null"`
-	DeviceType   string    `json:"device_type" gorm:"not null"` // "esp32" or "raspi"
-	Location     string    `json:"location"`
-	BatteryLevel float64   `json:"battery_level"` // 0.0 to 1.0 (State of Charge)
-	LastPing     time.Time `json:"last_ping"`
-	Status       string    `json:"status" gorm:"not null"` // e.g., Online, Offline, Unregistered
+	ID           string         `json:"id"`
+	OwnerID      string         `json:"owner_id" gorm:"not null"`
+	DeviceType   string         `json:"device_type" gorm:"not null"` // "esp32" or "raspi"
+	Location     string         `json:"location"`
+	BatteryLevel float64        `json:"battery_level"`                           // 0.0 to 1.0 (State of Charge)
+	CapacityKwh  float64        `json:"capacity_kwh" gorm:"not null;default:10"` // Usable battery capacity
+	ReservedKwh  float64        `json:"reserved_kwh" gorm:"not null;default:0"`  // Held for open sell orders
+	LastPing     time.Time      `json:"last_ping"`
+	Status       string         `json:"status" gorm:"not null"` // e.g., Online, Offline, Unregistered, Decommissioned
+	Metadata     DeviceMetadata `json:"metadata" gorm:"type:jsonb;not null;default:'{}'"`
+	CreatedAt    time.Time      `json:"created_at"`
+	// FirmwareVersion is the version the device last reported installing
+	FirmwareVersion string `json:"firmware_version,omitempty" gorm:"not null;default:''"`
 }
 
-// Transaction represents a completed energy trade.
+// DeviceEvent is one entry in a device's lifecycle audit trail.
+type DeviceEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	DeviceID  string    `json:"device_id" gorm:"index;not null"`
+	Type      string    `json:"type" gorm:"not null"` // registered, updated, decommissioned, transfer_*
+	ActorID   string    `json:"actor_id"`             // User who caused the event
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DeviceTransfer offers a device to another user, who must accept it before
+// ownership changes.
+type DeviceTransfer struct {
+	ID          string     `json:"id"`
+	DeviceID    string     `json:"device_id" gorm:"index;not null;uniqueIndex:idx_device_transfers_pending,where:status = 'pending'"` // One pending transfer per device
+	FromUserID  string     `json:"from_user_id" gorm:"index;not null"`
+	ToUserID    string     `json:"to_user_id" gorm:"index;not null"`
+	Status      string     `json:"status" gorm:"not null"` // pending, accepted, declined, cancelled
+	Note        string     `json:"note,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+}
+
+// Transaction represents an energy trade: one fill between a buy and a sell order.
 type Transaction struct {
 	ID             string    `json:"id"`
+	BuyOrderID     string    `json:"buy_order_id" gorm:"index"`
+	SellOrderID    string    `json:"sell_order_id" gorm:"index"`
 	DonorID        string    `json:"donor_id" gorm:"not null"`
 	RecipientID    string    `json:"recipient_id" gorm:"not null"`
 	KwhAmount      float64   `json:"kwh_amount" gorm:"not null"`
 	TokenAmount    float64   `json:"token_amount" gorm:"not null"`
 	BlockchainHash string    `json:"blockchain_hash" gorm:"unique"`
-	Status         string    `json:"status" gorm:"not null"` // e.g., Pending, Confirmed, Failed
+	Status         string    `json:"status" gorm:"not null"` // See the Transaction statuses in orders.go
 	Timestamp      time.Time `json:"timestamp"`
+
+	// Overlap of the two orders' delivery windows; nil bounds are unconstrained
+	DeliveryStart *time.Time `json:"delivery_start,omitempty"`
+	DeliveryEnd   *time.Time `json:"delivery_end,omitempty"`
+
+	// Fees charged at match time, on top of TokenAmount for the buyer and out
+	// of it for the seller, and how they were shared
+	BuyerFee    float64 `json:"buyer_fee" gorm:"not null;default:0"`
+	SellerFee   float64 `json:"seller_fee" gorm:"not null;default:0"`
+	PlatformFee float64 `json:"platform_fee" gorm:"not null;default:0"`
+	NodeFee     float64 `json:"node_fee" gorm:"not null;default:0"` // Total passed on to routing nodes, see NodeFee records
+}
+
+// NodeFee is one mesh node's share of the fees on a transaction.
+type NodeFee struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	TransactionID string    `json:"transaction_id" gorm:"index;not null"`
+	NodeID        string    `json:"node_id" gorm:"index;not null"`
+	OperatorID    string    `json:"operator_id" gorm:"not null"`
+	Amount        float64   `json:"amount" gorm:"not null"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 // NetworkNode represents a Raspberry Pi node in the mesh network.
@@ -60,15 +160,21 @@ type NetworkNode struct {
 	Earnings      float64 `json:"earnings"`
 }
 
-// DeviceQualityMetrics stores historical performance data for a donor's device.
+// DeviceQualityMetrics is a donor device's quality score, computed on a
+// schedule from its telemetry and lock command outcomes. Scores are 0-100 and
+// nil when there is not enough data.
 type DeviceQualityMetrics struct {
-	ID                   uint      `json:"id" gorm:"primaryKey"`
-	DeviceID             string    `json:"device_id" gorm:"unique;not null"`
-	SuccessfulDeliveries int       `json:"successful_deliveries"`
-	TotalDeliveries      int       `json:"total_deliveries"`
-	VoltageStability     float64   `json:"voltage_stability"`    // Standard deviation or score (0-100)
-	BatteryHealthScore   float64   `json:"battery_health_score"` // 0-100
-	LastUpdated          time.Time `json:"last_updated"`
+	ID                   uint             `json:"id" gorm:"primaryKey"`
+	DeviceID             string           `json:"device_id" gorm:"unique;not null"`
+	SuccessfulDeliveries int              `json:"successful_deliveries"`
+	TotalDeliveries      int              `json:"total_deliveries"`
+	DeliveryReliability  *float64         `json:"delivery_reliability"`
+	VoltageStability     *float64         `json:"voltage_stability"`
+	BatteryHealthScore   *float64         `json:"battery_health_score"`
+	Score                *float64         `json:"score"` // Weighted composite of the scores above, fed into pricing
+	Breakdown            QualityBreakdown `json:"breakdown" gorm:"type:jsonb;not null;default:'[]'"`
+	WindowStart          time.Time        `json:"window_start"` // Data from here to LastUpdated was scored
+	LastUpdated          time.Time        `json:"last_updated"`
 }
 
 // PricingHistory logs the detailed breakdown of every price calculation.
@@ -80,13 +186,167 @@ type PricingHistory struct {
 	SupplyDemand float64   `json:"f_sd"`
 	SoC          float64   `json:"f_soc"`
 	Distance     float64   `json:"f_dist"`
+	DistanceKm   *float64  `json:"distance_km,omitempty"` // Trade distance f_dist was computed from; nil on older records
 	Time         float64   `json:"f_time"`
 	Quality      float64   `json:"f_quality"`
+	Forecast     float64   `json:"f_forecast"`
+	ForecastSoC  float64   `json:"forecast_soc"` // Lowest predicted SoC over the scarcity horizon
+	Forecasted   bool      `json:"forecasted"`   // ForecastSoC came from the forecast; otherwise it is GridSoC
 	GridSoC      float64   `json:"grid_soc"`
 	TotalDemand  float64   `json:"total_demand"`
 	TotalSupply  float64   `json:"total_supply"`
 }
 
+// PriceBucket is the open, high, low and close of the indicative prices
+// recorded in [BucketStart, BucketStart+interval).
+type PriceBucket struct {
+	BucketStart time.Time
+	Open        float64
+	High        float64
+	Low         float64
+	Close       float64
+	Samples     int
+}
+
+// RoleChangeRequest asks for a user's role to be changed; an admin approves
+// or rejects it.
+type RoleChangeRequest struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id" gorm:"index;not null"`
+	FromRole   string     `json:"from_role" gorm:"not null"`
+	ToRole     string     `json:"to_role" gorm:"not null"`
+	Reason     string     `json:"reason"`
+	Status     string     `json:"status" gorm:"index;not null"` // pending, approved, rejected
+	ReviewedBy string     `json:"reviewed_by,omitempty"`
+	ReviewNote string     `json:"review_note,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// KYCSubmission is a user's request to be verified at a tier. The configured
+// verifier decides it, or leaves it in review for an admin.
+type KYCSubmission struct {
+	ID                string     `json:"id"`
+	UserID            string     `json:"user_id" gorm:"index;not null;uniqueIndex:idx_kyc_submissions_in_review,where:status = 'in_review'"` // One submission in review per user
+	Tier              int        `json:"tier" gorm:"not null"`
+	FullName          string     `json:"full_name" gorm:"not null"`
+	DateOfBirth       string     `json:"date_of_birth" gorm:"not null"` // YYYY-MM-DD
+	Country           string     `json:"country" gorm:"not null"`       // ISO 3166-1 alpha-2
+	Address           string     `json:"address"`
+	Status            string     `json:"status" gorm:"index;not null"` // in_review, approved, rejected
+	Provider          string     `json:"provider"`
+	ProviderDecision  string     `json:"provider_decision"`
+	ProviderReason    string     `json:"provider_reason,omitempty"`
+	ProviderReference string     `json:"provider_reference,omitempty"`
+	ReviewedBy        string     `json:"reviewed_by,omitempty"` // Admin user ID, or provider:<name>
+	ReviewReason      string     `json:"review_reason,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	ReviewedAt        *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// KYCDocument is a file uploaded for KYC, attached to the submission it was
+// submitted with.
+type KYCDocument struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id" gorm:"index;not null"`
+	SubmissionID string    `json:"submission_id,omitempty" gorm:"index"`
+	Type         string    `json:"type" gorm:"not null"` // passport, national_id, drivers_license, proof_of_address
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int       `json:"size"`
+	SHA256       string    `json:"sha256"`
+	Content      []byte    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// KYCStatusChange records every change of a user's KYC status or tier.
+type KYCStatusChange struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       string    `json:"user_id" gorm:"index;not null"`
+	SubmissionID string    `json:"submission_id"`
+	FromStatus   string    `json:"from_status"`
+	ToStatus     string    `json:"to_status"`
+	FromTier     int       `json:"from_tier"`
+	ToTier       int       `json:"to_tier"`
+	ChangedBy    string    `json:"changed_by"` // User ID, or provider:<name>
+	Reason       string    `json:"reason,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Account holds a user's token balance. Reserved is the part of Balance held
+// for open buy orders and cannot be committed to new ones. Both are kept equal
+// to the user's available + reserved and reserved ledger balances, and exist
+// so reservations can be checked with a single conditional update.
+type Account struct {
+	UserID    string    `json:"user_id" gorm:"primaryKey"`
+	Balance   float64   `json:"balance" gorm:"not null;default:0"`
+	Reserved  float64   `json:"reserved" gorm:"not null;default:0"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Withdrawal statuses.
+const (
+	WithdrawalPending  = "pending"  // Debited from the account, awaiting payout on chain
+	WithdrawalPaid     = "paid"     // Paid out; TxHash is the on-chain transfer
+	WithdrawalRejected = "rejected" // Not paid out; the amount was credited back
+)
+
+// Withdrawal is a user's request to move tokens from their available balance
+// to their wallet on chain. The amount leaves the account when it is requested
+// and returns to it if the withdrawal is rejected.
+type Withdrawal struct {
+	ID            string     `json:"id" gorm:"primaryKey"`
+	UserID        string     `json:"user_id" gorm:"index;not null"`
+	Amount        float64    `json:"amount" gorm:"not null"`
+	WalletAddress string     `json:"wallet_address" gorm:"not null"` // Destination, the user's wallet when requested
+	Status        string     `json:"status" gorm:"index;not null"`
+	TxHash        string     `json:"tx_hash,omitempty"`
+	Reason        string     `json:"reason,omitempty"` // Why it was rejected
+	ProcessedBy   string     `json:"processed_by,omitempty"`
+	ProcessedAt   *time.Time `json:"processed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// LedgerEntry is one posting of a double-entry journal. The entries sharing a
+// JournalID sum to zero; an account's balance is the sum of its entries.
+type LedgerEntry struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	JournalID string    `json:"journal_id" gorm:"index;not null"`
+	Kind      string    `json:"kind" gorm:"not null"`                                     // trade, reserve, release, fee, yield, deposit, withdrawal, withdrawal_reversal
+	Reference string    `json:"reference,omitempty" gorm:"index"`                         // Order, transaction or external ID the journal relates to
+	Owner     string    `json:"owner" gorm:"index:idx_ledger_entries_account;not null"`   // User ID, or "system"
+	Account   string    `json:"account" gorm:"index:idx_ledger_entries_account;not null"` // available, reserved, yield, fees, ...
+	Amount    float64   `json:"amount" gorm:"not null"`                                   // Signed; positive increases the account
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// LedgerReconciliation is the latest comparison of a user's ledger holdings
+// with their energy_token balance on chain.
+type LedgerReconciliation struct {
+	UserID         string    `json:"user_id" gorm:"primaryKey"`
+	WalletAddress  string    `json:"wallet_address"`
+	LedgerBalance  float64   `json:"ledger_balance"`
+	OnChainBalance float64   `json:"on_chain_balance"`
+	Difference     float64   `json:"difference"` // on-chain minus ledger
+	Matched        bool      `json:"matched"`
+	CheckedAt      time.Time `json:"checked_at"`
+}
+
+// AuctionResult records the clearing of one hourly day-ahead auction.
+type AuctionResult struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	DeliveryStart  time.Time `json:"delivery_start" gorm:"uniqueIndex;not null"`
+	DeliveryEnd    time.Time `json:"delivery_end" gorm:"not null"`
+	Cleared        bool      `json:"cleared"`         // False if supply and demand did not cross
+	ClearingPrice  float64   `json:"clearing_price"`  // Uniform price paid by every matched bid
+	ReferencePrice float64   `json:"reference_price"` // Dynamic price for the hour, used to pick within the clearing range
+	VolumeKwh      float64   `json:"volume_kwh"`
+	SupplyKwh      float64   `json:"supply_kwh"`
+	DemandKwh      float64   `json:"demand_kwh"`
+	BidCount       int       `json:"bid_count"`
+	ClearedAt      time.Time `json:"cleared_at"`
+}
+
 // YieldRecord tracks the simulated DeFi yield earned by users.
 type YieldRecord struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
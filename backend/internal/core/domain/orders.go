@@ -0,0 +1,217 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Order statuses.
+const (
+	OrderStatusCreated         = "Created"
+	OrderStatusPartiallyFilled = "PartiallyFilled"
+	OrderStatusMatched         = "Matched"
+	OrderStatusExecuting       = "Executing"
+	OrderStatusCompleted       = "Completed"
+	OrderStatusCancelled       = "Cancelled"
+	OrderStatusExpired         = "Expired"
+)
+
+// OpenOrderStatuses are the statuses of orders still resting on the book.
+var OpenOrderStatuses = []string{OrderStatusCreated, OrderStatusPartiallyFilled}
+
+// Transaction (trade) statuses.
+const (
+	TransactionPending   = "Pending"   // Matched and settled off-chain
+	TransactionScheduled = "Scheduled" // Matched for a future delivery window
+	TransactionConfirmed = "Confirmed"
+	TransactionCompleted = "Completed"
+	TransactionFailed    = "Failed"
+)
+
+// ExecutedTransactionStatuses are the statuses of trades that took place, for
+// market data such as candles.
+var ExecutedTransactionStatuses = []string{TransactionPending, TransactionScheduled, TransactionConfirmed, TransactionCompleted}
+
+// orderTransitions is the order state machine: the statuses each status may
+// move to. Open orders may also "move" to their own status when they are
+// amended or partially filled again. Statuses not listed are terminal.
+var orderTransitions = map[string][]string{
+	OrderStatusCreated:         {OrderStatusCreated, OrderStatusPartiallyFilled, OrderStatusMatched, OrderStatusCancelled, OrderStatusExpired},
+	OrderStatusPartiallyFilled: {OrderStatusPartiallyFilled, OrderStatusMatched, OrderStatusCancelled, OrderStatusExpired},
+	OrderStatusMatched:         {OrderStatusExecuting},
+	OrderStatusExecuting:       {OrderStatusCompleted},
+}
+
+// TransitionError is returned for a status change the state machine does not allow.
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("order cannot move from %s to %s", e.From, e.To)
+}
+
+// CanTransition reports whether an order may move from one status to another.
+func CanTransition(from, to string) bool {
+	for _, allowed := range orderTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionSources returns every status from which an order may move to the
+// given status, for use in conditional bulk updates.
+func TransitionSources(to string) []string {
+	var sources []string
+	for _, from := range []string{OrderStatusCreated, OrderStatusPartiallyFilled, OrderStatusMatched, OrderStatusExecuting, OrderStatusCompleted, OrderStatusCancelled, OrderStatusExpired} {
+		if CanTransition(from, to) {
+			sources = append(sources, from)
+		}
+	}
+	return sources
+}
+
+// CheckTransition returns a *TransitionError if the order may not move to the given status.
+func (o EnergyOrder) CheckTransition(to string) error {
+	if !CanTransition(o.Status, to) {
+		return &TransitionError{From: o.Status, To: to}
+	}
+	return nil
+}
+
+// Markets an order can be placed in.
+const (
+	MarketContinuous = "continuous" // Matched on every engine tick
+	MarketDayAhead   = "day_ahead"  // Hourly bids for the next delivery day, cleared by auction at gate closure
+)
+
+// Order kinds.
+const (
+	OrderKindLimit  = "limit"  // Executes at the dynamic price only if it is within TokenPrice
+	OrderKindMarket = "market" // Executes at the dynamic price within a slippage cap of the price at submission
+)
+
+// Time-in-force policies. The matching engine runs periodically, so
+// "immediate" means the first matching pass after the order is placed.
+const (
+	TimeInForceGTC = "GTC" // Good-til-cancelled: rests on the book until filled or cancelled
+	TimeInForceIOC = "IOC" // Immediate-or-cancel: fills what it can in one pass, the rest is cancelled
+	TimeInForceFOK = "FOK" // Fill-or-kill: fills completely in one pass or not at all
+)
+
+// DefaultMaxSlippage is used for market orders that do not specify a slippage cap.
+const DefaultMaxSlippage = 0.05
+
+// kwhEpsilon absorbs floating point error when comparing kWh quantities.
+const kwhEpsilon = 1e-9
+
+// RemainingKwh is the quantity still open on the order.
+func (o EnergyOrder) RemainingKwh() float64 {
+	return o.KwhAmount - o.FilledKwh
+}
+
+// IsFilled reports whether the order has no meaningful quantity left.
+func (o EnergyOrder) IsFilled() bool {
+	return o.RemainingKwh() <= kwhEpsilon
+}
+
+// IsOpen reports whether the order is still resting on the book.
+func (o EnergyOrder) IsOpen() bool {
+	return o.Status == OrderStatusCreated || o.Status == OrderStatusPartiallyFilled
+}
+
+// ReservedTokens is the part of the buyer's balance held for the order: the
+// open remainder at the limit price, plus the most it can be charged in fees.
+func (o EnergyOrder) ReservedTokens() float64 {
+	if o.Type != "buy" || !o.IsOpen() {
+		return 0
+	}
+	return o.RemainingKwh() * o.TokenPrice * (1 + o.FeeRate)
+}
+
+// ReservedKwh is the energy held on the seller's device for the order. Filled
+// energy has still to leave the battery, so the whole order stays reserved
+// until it is delivered (Completed) or withdrawn (Cancelled, Expired).
+func (o EnergyOrder) ReservedKwh() float64 {
+	switch {
+	case o.Type != "sell":
+		return 0
+	case o.Status == OrderStatusCompleted, o.Status == OrderStatusCancelled, o.Status == OrderStatusExpired:
+		return 0
+	}
+	return o.KwhAmount
+}
+
+// IsImmediate reports whether the order must not rest on the book after a matching pass.
+func (o EnergyOrder) IsImmediate() bool {
+	return o.TimeInForce == TimeInForceIOC || o.TimeInForce == TimeInForceFOK
+}
+
+// IsExpired reports whether the order's expiry has passed. Orders without an
+// expiry never expire.
+func (o EnergyOrder) IsExpired(now time.Time) bool {
+	return o.ExpiresAt != nil && !now.Before(*o.ExpiresAt)
+}
+
+// AcceptsDeliveryAt reports whether t falls within the order's delivery window.
+func (o EnergyOrder) AcceptsDeliveryAt(t time.Time) bool {
+	return (o.DeliveryWindowStart == nil || !t.Before(*o.DeliveryWindowStart)) &&
+		(o.DeliveryWindowEnd == nil || t.Before(*o.DeliveryWindowEnd))
+}
+
+// DeliveryOverlap returns the interval in which both orders accept delivery.
+// A missing bound is unbounded on that side, so an order without a delivery
+// window accepts delivery at any time. ok is false if the windows do not
+// overlap; windows that only touch at an endpoint do not overlap.
+func DeliveryOverlap(a, b EnergyOrder) (start, end *time.Time, ok bool) {
+	start = laterOf(a.DeliveryWindowStart, b.DeliveryWindowStart)
+	end = earlierOf(a.DeliveryWindowEnd, b.DeliveryWindowEnd)
+	if start != nil && end != nil && !start.Before(*end) {
+		return nil, nil, false
+	}
+	return start, end, true
+}
+
+func laterOf(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.After(*a)) {
+		return b
+	}
+	return a
+}
+
+func earlierOf(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.Before(*a)) {
+		return b
+	}
+	return a
+}
+
+var (
+	// ErrAmendNotAllowed is returned when amending an order kind or time-in-force that cannot be amended.
+	ErrAmendNotAllowed = errors.New("only GTC limit orders can be amended")
+	// ErrAmendBelowFilled is returned when the new quantity does not exceed what has already filled.
+	ErrAmendBelowFilled = errors.New("kwh_amount must exceed the quantity already filled")
+)
+
+// Amend returns the order with a new limit price and total quantity. Time
+// priority is kept when only the quantity is reduced; changing the price or
+// increasing the quantity moves the order to the back of its price level.
+func (o EnergyOrder) Amend(tokenPrice, kwhAmount float64, now time.Time) (EnergyOrder, error) {
+	if o.Kind != OrderKindLimit || o.TimeInForce != TimeInForceGTC {
+		return o, ErrAmendNotAllowed
+	}
+	if kwhAmount <= o.FilledKwh+kwhEpsilon {
+		return o, ErrAmendBelowFilled
+	}
+
+	if tokenPrice != o.TokenPrice || kwhAmount > o.KwhAmount {
+		o.PriorityAt = now
+	}
+	o.TokenPrice = tokenPrice
+	o.KwhAmount = kwhAmount
+	return o, nil
+}
@@ -0,0 +1,231 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func at(hour int) *time.Time {
+	t := time.Date(2026, 6, 1, hour, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func window(start, end *time.Time) EnergyOrder {
+	return EnergyOrder{DeliveryWindowStart: start, DeliveryWindowEnd: end}
+}
+
+func TestDeliveryOverlap(t *testing.T) {
+	cases := []struct {
+		name      string
+		a, b      EnergyOrder
+		wantOK    bool
+		wantStart *time.Time
+		wantEnd   *time.Time
+	}{
+		{"no windows", window(nil, nil), window(nil, nil), true, nil, nil},
+		{"one unconstrained", window(at(10), at(12)), window(nil, nil), true, at(10), at(12)},
+		{"partial overlap", window(at(10), at(12)), window(at(11), at(14)), true, at(11), at(12)},
+		{"contained", window(at(8), at(18)), window(at(11), at(12)), true, at(11), at(12)},
+		{"open-ended", window(at(10), nil), window(nil, at(12)), true, at(10), at(12)},
+		{"touching", window(at(10), at(11)), window(at(11), at(12)), false, nil, nil},
+		{"disjoint", window(at(10), at(11)), window(at(13), at(14)), false, nil, nil},
+		{"open-ended after end", window(at(15), nil), window(at(10), at(12)), false, nil, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, swap := range []bool{false, true} {
+				a, b := tc.a, tc.b
+				if swap {
+					a, b = b, a
+				}
+				start, end, ok := DeliveryOverlap(a, b)
+				if ok != tc.wantOK {
+					t.Fatalf("Expected ok=%v, got %v", tc.wantOK, ok)
+				}
+				if !sameTime(start, tc.wantStart) || !sameTime(end, tc.wantEnd) {
+					t.Errorf("Expected overlap [%v, %v], got [%v, %v]", tc.wantStart, tc.wantEnd, start, end)
+				}
+			}
+		})
+	}
+}
+
+func TestIsExpired(t *testing.T) {
+	order := EnergyOrder{ExpiresAt: at(12)}
+	if order.IsExpired(*at(11)) {
+		t.Error("Order should not be expired before its expiry")
+	}
+	if !order.IsExpired(*at(12)) {
+		t.Error("Order should be expired at its expiry")
+	}
+	if (EnergyOrder{}).IsExpired(*at(23)) {
+		t.Error("Order without an expiry should never expire")
+	}
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func TestAmendPriority(t *testing.T) {
+	original := EnergyOrder{
+		Kind:        OrderKindLimit,
+		TimeInForce: TimeInForceGTC,
+		KwhAmount:   5,
+		FilledKwh:   1,
+		TokenPrice:  4.0,
+		PriorityAt:  *at(9),
+	}
+	now := *at(10)
+
+	cases := []struct {
+		name         string
+		price, kwh   float64
+		keepPriority bool
+	}{
+		{"size decrease", 4.0, 3, true},
+		{"unchanged", 4.0, 5, true},
+		{"size increase", 4.0, 8, false},
+		{"price change", 4.5, 5, false},
+		{"price change and size decrease", 3.5, 3, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			amended, err := original.Amend(tc.price, tc.kwh, now)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if amended.TokenPrice != tc.price || amended.KwhAmount != tc.kwh {
+				t.Errorf("Expected %f kWh at %f, got %f kWh at %f", tc.kwh, tc.price, amended.KwhAmount, amended.TokenPrice)
+			}
+			if kept := amended.PriorityAt.Equal(original.PriorityAt); kept != tc.keepPriority {
+				t.Errorf("Expected priority kept=%v, got %v", tc.keepPriority, kept)
+			}
+		})
+	}
+}
+
+func TestAmendRejections(t *testing.T) {
+	order := EnergyOrder{Kind: OrderKindLimit, TimeInForce: TimeInForceGTC, KwhAmount: 5, FilledKwh: 2, TokenPrice: 4.0}
+
+	if _, err := order.Amend(4.0, 2, *at(10)); err != ErrAmendBelowFilled {
+		t.Errorf("Expected ErrAmendBelowFilled, got %v", err)
+	}
+
+	for _, o := range []EnergyOrder{
+		{Kind: OrderKindMarket, TimeInForce: TimeInForceIOC, KwhAmount: 5},
+		{Kind: OrderKindLimit, TimeInForce: TimeInForceFOK, KwhAmount: 5},
+	} {
+		if _, err := o.Amend(4.0, 4, *at(10)); err != ErrAmendNotAllowed {
+			t.Errorf("Expected ErrAmendNotAllowed for %s %s, got %v", o.Kind, o.TimeInForce, err)
+		}
+	}
+}
+
+func TestOrderStateMachine(t *testing.T) {
+	allowed := []struct{ from, to string }{
+		{OrderStatusCreated, OrderStatusPartiallyFilled},
+		{OrderStatusCreated, OrderStatusMatched},
+		{OrderStatusCreated, OrderStatusCancelled},
+		{OrderStatusCreated, OrderStatusExpired},
+		{OrderStatusCreated, OrderStatusCreated},
+		{OrderStatusPartiallyFilled, OrderStatusPartiallyFilled},
+		{OrderStatusPartiallyFilled, OrderStatusMatched},
+		{OrderStatusPartiallyFilled, OrderStatusCancelled},
+		{OrderStatusPartiallyFilled, OrderStatusExpired},
+		{OrderStatusMatched, OrderStatusExecuting},
+		{OrderStatusExecuting, OrderStatusCompleted},
+	}
+	forbidden := []struct{ from, to string }{
+		{OrderStatusMatched, OrderStatusCancelled},
+		{OrderStatusMatched, OrderStatusExpired},
+		{OrderStatusPartiallyFilled, OrderStatusCreated},
+		{OrderStatusCreated, OrderStatusExecuting},
+		{OrderStatusExecuting, OrderStatusCancelled},
+		{OrderStatusCancelled, OrderStatusCreated},
+		{OrderStatusCancelled, OrderStatusMatched},
+		{OrderStatusExpired, OrderStatusMatched},
+		{OrderStatusCompleted, OrderStatusCancelled},
+	}
+
+	for _, tr := range allowed {
+		if err := (EnergyOrder{Status: tr.from}).CheckTransition(tr.to); err != nil {
+			t.Errorf("Expected %s -> %s to be allowed, got %v", tr.from, tr.to, err)
+		}
+	}
+	for _, tr := range forbidden {
+		err := (EnergyOrder{Status: tr.from}).CheckTransition(tr.to)
+		if te, ok := err.(*TransitionError); !ok || te.From != tr.from || te.To != tr.to {
+			t.Errorf("Expected %s -> %s to be rejected with a TransitionError, got %v", tr.from, tr.to, err)
+		}
+	}
+}
+
+func TestTransitionSources(t *testing.T) {
+	got := TransitionSources(OrderStatusCancelled)
+	if len(got) != 2 || got[0] != OrderStatusCreated || got[1] != OrderStatusPartiallyFilled {
+		t.Errorf("Expected only open orders to be cancellable, got %v", got)
+	}
+}
+
+func TestReservations(t *testing.T) {
+	buy := EnergyOrder{Type: "buy", KwhAmount: 5, FilledKwh: 2, TokenPrice: 4, Status: OrderStatusPartiallyFilled}
+	if got := buy.ReservedTokens(); got != 12 {
+		t.Errorf("Expected the 3 kWh remainder at 4 to reserve 12 tokens, got %v", got)
+	}
+	if got := buy.ReservedKwh(); got != 0 {
+		t.Errorf("Expected a buy order to reserve no energy, got %v", got)
+	}
+
+	sell := EnergyOrder{Type: "sell", KwhAmount: 5, FilledKwh: 1, TokenPrice: 4, Status: OrderStatusPartiallyFilled}
+	if got := sell.ReservedKwh(); got != 5 {
+		t.Errorf("Expected the filled and open 5 kWh to be reserved, got %v", got)
+	}
+	if got := sell.ReservedTokens(); got != 0 {
+		t.Errorf("Expected a sell order to reserve no tokens, got %v", got)
+	}
+
+	for _, status := range []string{OrderStatusMatched, OrderStatusExecuting} {
+		buy.Status, sell.Status = status, status
+		if buy.ReservedTokens() != 0 || sell.ReservedKwh() != 5 {
+			t.Errorf("Expected a %s order to release the buyer's tokens but keep the seller's energy", status)
+		}
+	}
+	for _, status := range []string{OrderStatusCompleted, OrderStatusCancelled, OrderStatusExpired} {
+		buy.Status, sell.Status = status, status
+		if buy.ReservedTokens() != 0 || sell.ReservedKwh() != 0 {
+			t.Errorf("Expected a %s order to release its reservation", status)
+		}
+	}
+}
+
+func TestDeviceCanDeliver(t *testing.T) {
+	device := IoTDevice{DeviceType: "esp32", Status: DeviceStatusOnline, BatteryLevel: 0.5, CapacityKwh: 10, ReservedKwh: 2}
+	if got := device.AvailableKwh(); got != 3 {
+		t.Fatalf("Expected 3 kWh available, got %v", got)
+	}
+	if !device.CanDeliver(3) || device.CanDeliver(3.5) {
+		t.Error("Expected delivery to be limited to the unreserved stored energy")
+	}
+
+	device.Status = DeviceStatusOffline
+	if device.CanDeliver(1) {
+		t.Error("Expected an offline device not to deliver")
+	}
+	device.Status = DeviceStatusRegistered
+	if !device.CanDeliver(1) {
+		t.Error("Expected a registered device with a reported battery level to deliver")
+	}
+	device.BatteryLevel = 0
+	if device.CanDeliver(0) {
+		t.Error("Expected a registered device without a battery level not to deliver")
+	}
+	device.Status, device.BatteryLevel, device.DeviceType = DeviceStatusOnline, 0.5, "raspi"
+	if device.CanDeliver(1) {
+		t.Error("Expected only ESP32s to deliver")
+	}
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Quality score components.
+const (
+	QualityDeliveryReliability = "delivery_reliability"
+	QualityVoltageStability    = "voltage_stability"
+	QualityBatteryHealth       = "battery_health"
+)
+
+// Lock request statuses, from the device's answer to a lock command.
+const (
+	LockRequestPending  = "pending"
+	LockRequestLocked   = "locked"
+	LockRequestRejected = "rejected"
+	LockRequestTimedOut = "timed_out"
+)
+
+// QualityComponent explains one component of a device's quality score.
+type QualityComponent struct {
+	Name        string             `json:"name"`
+	Score       *float64           `json:"score"`  // 0-100; nil when there was not enough data
+	Weight      float64            `json:"weight"` // Share of the composite score; 0 when Score is nil
+	Inputs      map[string]float64 `json:"inputs"`
+	Explanation string             `json:"explanation"`
+}
+
+// QualityBreakdown lists the components of a quality score, stored as JSON.
+type QualityBreakdown []QualityComponent
+
+// Value implements driver.Valuer.
+func (b QualityBreakdown) Value() (driver.Value, error) {
+	if b == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(b)
+	return string(data), err
+}
+
+// Scan implements sql.Scanner.
+func (b *QualityBreakdown) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*b = QualityBreakdown{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into QualityBreakdown", value)
+	}
+	return json.Unmarshal(data, b)
+}
+
+// DeviceLockRequest records a lock command sent to a device for a fill and the
+// device's answer, which counts towards its delivery reliability.
+type DeviceLockRequest struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	DeviceID    string     `json:"device_id" gorm:"not null;index:idx_lock_requests_device_sent"`
+	OrderID     string     `json:"order_id" gorm:"not null;index"` // The sell order being delivered
+	KwhAmount   float64    `json:"kwh_amount" gorm:"not null"`
+	Status      string     `json:"status" gorm:"not null;index"`
+	SentAt      time.Time  `json:"sent_at" gorm:"not null;index:idx_lock_requests_device_sent"`
+	RespondedAt *time.Time `json:"responded_at"`
+}
@@ -0,0 +1,78 @@
+package domain
+
+// Roles.
+const (
+	RoleRecipient           = "Recipient"
+	RoleDonor               = "Donor"
+	RoleNetworkNodeOperator = "NetworkNodeOperator"
+	RoleAdmin               = "Admin"
+	RoleAuditor             = "Auditor"
+)
+
+// Permissions.
+const (
+	PermMarketRead      = "market:read"      // Order book, prices, quotes, history
+	PermMarketTrade     = "market:trade"     // Place buy orders; cancel and amend own orders
+	PermMarketSell      = "market:sell"      // Place sell orders
+	PermAccountRead     = "account:read"     // Own balances and statements
+	PermAccountWithdraw = "account:withdraw" // Withdraw own tokens to the wallet on chain
+	PermAccountFund     = "account:fund"     // Credit deposits and pay out or reject withdrawals
+	PermDeviceRead      = "device:read"      // Own devices
+	PermDeviceManage    = "device:manage"    // Register and manage own devices
+	PermNodeRead        = "node:read"        // Mesh network nodes
+	PermNodeManage      = "node:manage"      // Register and operate mesh nodes
+	PermAnalyticsRead   = "analytics:read"   // Dashboards, forecasts and pricing simulations
+	PermRoleRequest     = "role:request"     // Ask for a different role
+	PermRoleRead        = "role:read"        // See every user's role change requests
+	PermRoleReview      = "role:review"      // Approve or reject role change requests
+	PermKYCSubmit       = "kyc:submit"       // Upload documents and submit own KYC
+	PermKYCRead         = "kyc:read"         // See every user's KYC submissions and documents
+	PermKYCReview       = "kyc:review"       // Approve or reject KYC submissions
+	PermFirmwareRead    = "firmware:read"    // Firmware registry and update campaigns
+	PermFirmwareManage  = "firmware:manage"  // Register firmware and run update campaigns
+)
+
+// participant is what every trading role may do.
+var participant = []string{
+	PermMarketRead, PermMarketTrade, PermAccountRead, PermAccountWithdraw, PermDeviceRead, PermDeviceManage,
+	PermNodeRead, PermAnalyticsRead, PermRoleRequest, PermKYCSubmit,
+}
+
+// rolePermissions maps each role to the permissions it grants.
+var rolePermissions = map[string][]string{
+	RoleRecipient:           participant,
+	RoleDonor:               append([]string{PermMarketSell}, participant...),
+	RoleNetworkNodeOperator: append([]string{PermMarketSell, PermNodeManage}, participant...),
+	RoleAdmin: append([]string{PermMarketSell, PermNodeManage, PermRoleRead, PermRoleReview, PermKYCRead, PermKYCReview,
+		PermFirmwareRead, PermFirmwareManage, PermAccountFund}, participant...),
+	RoleAuditor: {PermMarketRead, PermAccountRead, PermDeviceRead, PermNodeRead, PermAnalyticsRead, PermRoleRead, PermKYCRead,
+		PermFirmwareRead},
+}
+
+// IsRole reports whether role is one of the defined roles.
+func IsRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Permissions lists the permissions role grants; unknown roles grant none.
+func Permissions(role string) []string {
+	return rolePermissions[role]
+}
+
+// HasPermission reports whether role grants permission.
+func HasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Role change request statuses.
+const (
+	RoleRequestPending  = "pending"
+	RoleRequestApproved = "approved"
+	RoleRequestRejected = "rejected"
+)
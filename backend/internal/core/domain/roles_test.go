@@ -0,0 +1,40 @@
+package domain
+
+import "testing"
+
+func TestHasPermission(t *testing.T) {
+	cases := []struct {
+		role, permission string
+		want             bool
+	}{
+		{RoleRecipient, PermMarketTrade, true},
+		{RoleRecipient, PermMarketSell, false},
+		{RoleDonor, PermMarketSell, true},
+		{RoleDonor, PermNodeManage, false},
+		{RoleNetworkNodeOperator, PermNodeManage, true},
+		{RoleAdmin, PermRoleReview, true},
+		{RoleAuditor, PermRoleRead, true},
+		{RoleAuditor, PermRoleReview, false},
+		{RoleAuditor, PermMarketTrade, false},
+		{RoleRecipient, PermKYCSubmit, true},
+		{RoleRecipient, PermKYCRead, false},
+		{RoleAuditor, PermKYCRead, true},
+		{RoleAuditor, PermKYCReview, false},
+		{RoleAdmin, PermKYCReview, true},
+		{RoleAdmin, PermFirmwareManage, true},
+		{RoleAuditor, PermFirmwareRead, true},
+		{RoleAuditor, PermFirmwareManage, false},
+		{RoleDonor, PermFirmwareRead, false},
+		{RoleRecipient, PermAccountWithdraw, true},
+		{RoleRecipient, PermAccountFund, false},
+		{RoleAuditor, PermAccountWithdraw, false},
+		{RoleAdmin, PermAccountFund, true},
+		{"", PermMarketRead, false},
+		{"Superuser", PermMarketRead, false},
+	}
+	for _, tc := range cases {
+		if got := HasPermission(tc.role, tc.permission); got != tc.want {
+			t.Errorf("HasPermission(%q, %q) = %v, want %v", tc.role, tc.permission, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DeviceConfig is the remotely managed configuration of a device. Unset
+// fields are left to the firmware's defaults.
+type DeviceConfig struct {
+	ReportingIntervalSeconds *int     `json:"reporting_interval_seconds,omitempty"` // How often the device reports status
+	MinReserveSoC            *float64 `json:"min_reserve_soc,omitempty"`            // SoC the device never discharges below, 0.0 to 1.0
+	MaxExportKw              *float64 `json:"max_export_kw,omitempty"`              // Highest discharge power
+}
+
+// Value implements driver.Valuer.
+func (c DeviceConfig) Value() (driver.Value, error) {
+	data, err := json.Marshal(c)
+	return string(data), err
+}
+
+// Scan implements sql.Scanner.
+func (c *DeviceConfig) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*c = DeviceConfig{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into DeviceConfig", value)
+	}
+	return json.Unmarshal(data, c)
+}
+
+// Merge returns c with the fields set in changes replaced.
+func (c DeviceConfig) Merge(changes DeviceConfig) DeviceConfig {
+	if changes.ReportingIntervalSeconds != nil {
+		c.ReportingIntervalSeconds = changes.ReportingIntervalSeconds
+	}
+	if changes.MinReserveSoC != nil {
+		c.MinReserveSoC = changes.MinReserveSoC
+	}
+	if changes.MaxExportKw != nil {
+		c.MaxExportKw = changes.MaxExportKw
+	}
+	return c
+}
+
+// ConfigDrift is a desired setting the device does not report having.
+type ConfigDrift struct {
+	Field    string      `json:"field"`
+	Desired  interface{} `json:"desired"`
+	Reported interface{} `json:"reported"` // nil if the device did not report it
+}
+
+// Drift lists the settings in desired that reported differs on.
+func Drift(desired, reported DeviceConfig) []ConfigDrift {
+	var drift []ConfigDrift
+	add := func(field string, d, r interface{}, differs bool) {
+		if differs {
+			drift = append(drift, ConfigDrift{Field: field, Desired: d, Reported: r})
+		}
+	}
+	if d, r := desired.ReportingIntervalSeconds, reported.ReportingIntervalSeconds; d != nil {
+		add("reporting_interval_seconds", *d, valueOf(r), r == nil || *r != *d)
+	}
+	if d, r := desired.MinReserveSoC, reported.MinReserveSoC; d != nil {
+		add("min_reserve_soc", *d, valueOf(r), r == nil || *r != *d)
+	}
+	if d, r := desired.MaxExportKw, reported.MaxExportKw; d != nil {
+		add("max_export_kw", *d, valueOf(r), r == nil || *r != *d)
+	}
+	return drift
+}
+
+// valueOf dereferences p, keeping nil pointers as an untyped nil for JSON.
+func valueOf[T any](p *T) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+// DeviceShadow holds the configuration the owner wants a device to run
+// (desired) next to the one the device last reported (reported). Every change
+// to desired bumps DesiredVersion; the device echoes the version it applied.
+type DeviceShadow struct {
+	DeviceID        string       `json:"device_id" gorm:"primaryKey"`
+	Desired         DeviceConfig `json:"desired" gorm:"type:jsonb;not null"`
+	DesiredVersion  int64        `json:"desired_version" gorm:"not null;default:0"`
+	DesiredAt       *time.Time   `json:"desired_at,omitempty"`
+	DesiredBy       string       `json:"desired_by,omitempty"`
+	PublishedAt     *time.Time   `json:"published_at,omitempty"` // Last time desired was published to the device
+	Reported        DeviceConfig `json:"reported" gorm:"type:jsonb;not null"`
+	ReportedVersion int64        `json:"reported_version" gorm:"not null;default:0"` // The desired version the device last applied
+	ReportedAt      *time.Time   `json:"reported_at,omitempty"`
+}
+
+// InSync reports whether the device has applied the latest desired
+// configuration and reports every desired setting.
+func (s DeviceShadow) InSync() bool {
+	return s.ReportedVersion >= s.DesiredVersion && len(Drift(s.Desired, s.Reported)) == 0
+}
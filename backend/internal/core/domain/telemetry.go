@@ -0,0 +1,62 @@
+package domain
+
+import "time"
+
+// DeviceTelemetry is one telemetry sample reported by a device. Rows are keyed
+// by (device_id, recorded_at) without a serial ID, so the table can be turned
+// into a TimescaleDB hypertable or range-partitioned on recorded_at as is.
+// Metrics the device did not report are NULL.
+type DeviceTelemetry struct {
+	DeviceID     string    `json:"device_id" gorm:"primaryKey"`
+	RecordedAt   time.Time `json:"recorded_at" gorm:"primaryKey;index"`
+	SoC          *float64  `json:"soc,omitempty" gorm:"column:soc"`                     // 0.0 to 1.0, like IoTDevice.BatteryLevel
+	VoltageV     *float64  `json:"voltage_v,omitempty" gorm:"column:voltage_v"`         // Battery voltage
+	CurrentA     *float64  `json:"current_a,omitempty" gorm:"column:current_a"`         // Positive when charging
+	TemperatureC *float64  `json:"temperature_c,omitempty" gorm:"column:temperature_c"` // Battery temperature
+	EnergyInKwh  *float64  `json:"energy_in_kwh,omitempty"`                             // Cumulative counter since the device was installed
+	EnergyOutKwh *float64  `json:"energy_out_kwh,omitempty"`                            // Cumulative counter since the device was installed
+}
+
+// TableName keeps the table singular: it holds a series, not a set of entities.
+func (DeviceTelemetry) TableName() string {
+	return "device_telemetry"
+}
+
+// DeviceTelemetryHourly is the hourly downsample of DeviceTelemetry, kept long
+// after the raw samples are deleted.
+type DeviceTelemetryHourly struct {
+	DeviceID    string    `json:"device_id" gorm:"primaryKey"`
+	BucketStart time.Time `json:"bucket_start" gorm:"primaryKey;index"`
+	TelemetryAggregate
+}
+
+// TableName keeps the table singular, like DeviceTelemetry.
+func (DeviceTelemetryHourly) TableName() string {
+	return "device_telemetry_hourly"
+}
+
+// TelemetryAggregate summarises the samples in one time bucket. Energy
+// counters hold the highest reading in the bucket.
+type TelemetryAggregate struct {
+	Samples        int      `json:"samples" gorm:"not null"`
+	SoCAvg         *float64 `json:"soc_avg,omitempty" gorm:"column:soc_avg"`
+	SoCMin         *float64 `json:"soc_min,omitempty" gorm:"column:soc_min"`
+	SoCMax         *float64 `json:"soc_max,omitempty" gorm:"column:soc_max"`
+	VoltageAvg     *float64 `json:"voltage_avg,omitempty"`
+	VoltageMin     *float64 `json:"voltage_min,omitempty"`
+	VoltageMax     *float64 `json:"voltage_max,omitempty"`
+	CurrentAvg     *float64 `json:"current_avg,omitempty"`
+	CurrentMin     *float64 `json:"current_min,omitempty"`
+	CurrentMax     *float64 `json:"current_max,omitempty"`
+	TemperatureAvg *float64 `json:"temperature_avg,omitempty"`
+	TemperatureMin *float64 `json:"temperature_min,omitempty"`
+	TemperatureMax *float64 `json:"temperature_max,omitempty"`
+	EnergyInKwh    *float64 `json:"energy_in_kwh,omitempty"`
+	EnergyOutKwh   *float64 `json:"energy_out_kwh,omitempty"`
+}
+
+// TelemetryBucket is a TelemetryAggregate over [BucketStart, BucketStart+resolution).
+type TelemetryBucket struct {
+	BucketStart time.Time `json:"bucket_start"`
+	TelemetryAggregate
+}
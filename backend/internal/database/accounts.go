@@ -0,0 +1,291 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInsufficientBalance is returned when a buyer's unreserved token
+	// balance cannot cover an order.
+	ErrInsufficientBalance = errors.New("insufficient available token balance")
+	// ErrInsufficientEnergy is returned when a seller's device is offline or
+	// does not hold enough unreserved energy for an order.
+	ErrInsufficientEnergy = errors.New("insufficient available energy on device")
+	// ErrDuplicateDeposit is returned when a deposit with the same reference
+	// has already been credited to the user.
+	ErrDuplicateDeposit = errors.New("deposit already credited")
+	// ErrWithdrawalProcessed is returned when a withdrawal is no longer pending.
+	ErrWithdrawalProcessed = errors.New("withdrawal already processed")
+)
+
+// OpenAccount creates an account for userID, depositing openingBalance tokens
+// into it. It does nothing if the user already has one.
+func OpenAccount(tx *gorm.DB, userID string, openingBalance float64) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&domain.Account{UserID: userID, UpdatedAt: time.Now()})
+		if result.Error != nil || result.RowsAffected == 0 || openingBalance <= 0 {
+			return result.Error
+		}
+		return Deposit(tx, userID, openingBalance, "opening_balance")
+	})
+}
+
+// Deposit credits amount tokens brought onto the platform to userID's
+// available balance. reference identifies the deposit, e.g. its on-chain hash;
+// crediting the same reference to the user twice returns ErrDuplicateDeposit.
+func Deposit(tx *gorm.DB, userID string, amount float64, reference string) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := credit(tx, userID, amount); err != nil {
+			return err
+		}
+
+		// The update holds the account's row lock, so a concurrent deposit with
+		// the same reference waits here until this one commits or rolls back
+		var credited int64
+		if err := tx.Model(&domain.LedgerEntry{}).
+			Where("kind = ? AND reference = ? AND owner = ?", domain.JournalDeposit, reference, userID).
+			Count(&credited).Error; err != nil {
+			return err
+		}
+		if credited > 0 {
+			return ErrDuplicateDeposit
+		}
+		return PostJournal(tx, domain.JournalDeposit, reference,
+			domain.Transfer(domain.SystemOwner, domain.LedgerExternal, userID, domain.LedgerAvailable, amount))
+	})
+}
+
+// Withdraw debits amount tokens leaving the platform from userID's available
+// balance, returning ErrInsufficientBalance if it does not cover them.
+func Withdraw(tx *gorm.DB, userID string, amount float64, reference string) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.Account{}).
+			Where("user_id = ? AND balance - reserved >= ?", userID, amount).
+			Updates(map[string]interface{}{"balance": gorm.Expr("balance - ?", amount), "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInsufficientBalance
+		}
+		return PostJournal(tx, domain.JournalWithdrawal, reference,
+			domain.Transfer(userID, domain.LedgerAvailable, domain.SystemOwner, domain.LedgerExternal, amount))
+	})
+}
+
+// RequestWithdrawal debits amount from userID's available balance and records
+// a pending withdrawal of it to walletAddress, returning ErrInsufficientBalance
+// if the balance not reserved for open orders does not cover it.
+func RequestWithdrawal(tx *gorm.DB, userID, walletAddress string, amount float64) (*domain.Withdrawal, error) {
+	withdrawal := domain.Withdrawal{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		Amount:        amount,
+		WalletAddress: walletAddress,
+		Status:        domain.WithdrawalPending,
+		CreatedAt:     time.Now(),
+	}
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		if err := Withdraw(tx, userID, amount, withdrawal.ID); err != nil {
+			return err
+		}
+		return tx.Create(&withdrawal).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &withdrawal, nil
+}
+
+// ProcessWithdrawal marks a pending withdrawal paid, with the hash of the
+// on-chain transfer, or rejected, crediting the amount back to the user's
+// available balance. It returns ErrWithdrawalProcessed if the withdrawal is no
+// longer pending.
+func ProcessWithdrawal(tx *gorm.DB, id, status, txHash, reason, processedBy string) (*domain.Withdrawal, error) {
+	var withdrawal domain.Withdrawal
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&withdrawal, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&domain.Withdrawal{}).
+			Where("id = ? AND status = ?", id, domain.WithdrawalPending).
+			Updates(map[string]interface{}{"status": status, "tx_hash": txHash, "reason": reason, "processed_by": processedBy, "processed_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrWithdrawalProcessed
+		}
+		withdrawal.Status, withdrawal.TxHash, withdrawal.Reason = status, txHash, reason
+		withdrawal.ProcessedBy, withdrawal.ProcessedAt = processedBy, &now
+
+		if status != domain.WithdrawalRejected {
+			return nil
+		}
+		if err := credit(tx, withdrawal.UserID, withdrawal.Amount); err != nil {
+			return err
+		}
+		return PostJournal(tx, domain.JournalWithdrawalReversal, withdrawal.ID,
+			domain.Transfer(domain.SystemOwner, domain.LedgerExternal, withdrawal.UserID, domain.LedgerAvailable, withdrawal.Amount))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &withdrawal, nil
+}
+
+// credit adds amount, or removes it if negative, to userID's account balance,
+// returning gorm.ErrRecordNotFound if the user has no account, so the balance
+// never misses a change the ledger records.
+func credit(tx *gorm.DB, userID string, amount float64) error {
+	result := tx.Model(&domain.Account{}).Where("user_id = ?", userID).
+		Updates(map[string]interface{}{"balance": gorm.Expr("balance + ?", amount), "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// adjustReservations moves the reservations held for an order from what
+// `before` needs to what `after` needs. Increases are conditional on the
+// buyer's unreserved balance or the seller's device covering them, so two
+// orders racing for the same funds cannot both succeed; releases always apply.
+func adjustReservations(tx *gorm.DB, before, after domain.EnergyOrder) error {
+	if delta := after.ReservedTokens() - before.ReservedTokens(); delta != 0 {
+		query := tx.Model(&domain.Account{}).Where("user_id = ?", after.UserID)
+		if delta > 0 {
+			query = query.Where("balance - reserved >= ?", delta)
+		}
+		result := query.Updates(map[string]interface{}{
+			"reserved":   gorm.Expr("reserved + ?", delta),
+			"updated_at": time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 && delta > 0 {
+			return ErrInsufficientBalance
+		}
+
+		// Orders placed before accounts existed have nothing to release
+		if result.RowsAffected > 0 {
+			kind, postings := domain.JournalReserve, domain.Transfer(after.UserID, domain.LedgerAvailable, after.UserID, domain.LedgerReserved, delta)
+			if delta < 0 {
+				kind, postings = domain.JournalRelease, domain.Transfer(after.UserID, domain.LedgerReserved, after.UserID, domain.LedgerAvailable, -delta)
+			}
+			if err := PostJournal(tx, kind, after.ID, postings); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Orders placed before devices were tracked carry no device reservation
+	if after.DeviceID == "" {
+		return nil
+	}
+	if delta := after.ReservedKwh() - before.ReservedKwh(); delta != 0 {
+		query := tx.Model(&domain.IoTDevice{}).Where("id = ?", after.DeviceID)
+		if delta > 0 {
+			query = query.Where("status IN ? AND battery_level * capacity_kwh - reserved_kwh >= ?", domain.SellingDeviceStatuses, delta)
+		}
+		result := query.Update("reserved_kwh", gorm.Expr("GREATEST(reserved_kwh + ?, 0)", delta))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 && delta > 0 {
+			return ErrInsufficientEnergy
+		}
+	}
+	return nil
+}
+
+// SettleTrade moves the cost of a fill from the buyer's balance to the
+// seller's. The buyer's reservation for the filled quantity has already been
+// released by the fill's order transition. reference is the Transaction ID.
+// Either side may predate accounts, so both are opened first; the buyer's
+// balance then goes negative rather than falling out of step with the ledger.
+func SettleTrade(tx *gorm.DB, buyerID, sellerID string, amount float64, reference string) error {
+	for _, userID := range []string{buyerID, sellerID} {
+		if err := OpenAccount(tx, userID, 0); err != nil {
+			return err
+		}
+	}
+	if err := credit(tx, buyerID, -amount); err != nil {
+		return err
+	}
+	if err := credit(tx, sellerID, amount); err != nil {
+		return err
+	}
+	return PostJournal(tx, domain.JournalTrade, reference,
+		domain.Transfer(buyerID, domain.LedgerAvailable, sellerID, domain.LedgerAvailable, amount))
+}
+
+// AccrueYield credits amount of yield to userID from the system yield pool.
+// reference is the Transaction the yield was earned on.
+func AccrueYield(tx *gorm.DB, userID string, amount float64, reference string) error {
+	record := domain.YieldRecord{
+		UserID:    userID,
+		Amount:    amount,
+		Source:    "LiquidityPool_Staking",
+		Timestamp: time.Now(),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return err
+	}
+	return PostJournal(tx, domain.JournalYield, reference,
+		domain.Transfer(domain.SystemOwner, domain.LedgerYieldPool, userID, domain.LedgerYield, amount))
+}
+
+// ChargeFees collects the fees recorded on txn: the buyer's and seller's fees
+// leave their balances (opened by SettleTrade), each routing node's share is credited to the node's
+// earnings and its operator's fees account, and the rest goes to the platform.
+func ChargeFees(tx *gorm.DB, txn domain.Transaction, nodeFees []domain.NodeFee) error {
+	if txn.BuyerFee+txn.SellerFee == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	charges := []struct {
+		userID string
+		fee    float64
+	}{{txn.RecipientID, txn.BuyerFee}, {txn.DonorID, txn.SellerFee}}
+	for _, c := range charges {
+		if err := credit(tx, c.userID, -c.fee); err != nil {
+			return err
+		}
+	}
+
+	postings := []domain.Posting{
+		{Owner: txn.RecipientID, Account: domain.LedgerAvailable, Amount: -txn.BuyerFee},
+		{Owner: txn.DonorID, Account: domain.LedgerAvailable, Amount: -txn.SellerFee},
+		{Owner: domain.SystemOwner, Account: domain.LedgerFees, Amount: txn.PlatformFee},
+	}
+	for i := range nodeFees {
+		nodeFees[i].TransactionID = txn.ID
+		nodeFees[i].CreatedAt = now
+		if err := tx.Model(&domain.NetworkNode{}).Where("id = ?", nodeFees[i].NodeID).
+			Update("earnings", gorm.Expr("earnings + ?", nodeFees[i].Amount)).Error; err != nil {
+			return err
+		}
+		postings = append(postings, domain.Posting{Owner: nodeFees[i].OperatorID, Account: domain.LedgerFees, Amount: nodeFees[i].Amount})
+	}
+	if len(nodeFees) > 0 {
+		if err := tx.Create(&nodeFees).Error; err != nil {
+			return err
+		}
+	}
+	return PostJournal(tx, domain.JournalFee, txn.ID, postings)
+}
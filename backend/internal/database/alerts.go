@@ -0,0 +1,124 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAlertAcknowledged is returned when an alert was already acknowledged.
+var ErrAlertAcknowledged = errors.New("alert already acknowledged")
+
+// RaiseAlert opens alert, or if the device already has an open alert of its
+// type, counts another occurrence and raises its severity if alert's is
+// higher. alert is updated to the stored row. The returned event is
+// AlertEventRaised, AlertEventEscalated, or "" if nothing worth notifying
+// changed.
+func RaiseAlert(tx *gorm.DB, alert *domain.DeviceAlert) (string, error) {
+	var event string
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		var open domain.DeviceAlert
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("device_id = ? AND type = ? AND status = ?", alert.DeviceID, alert.Type, domain.AlertStatusOpen).
+			First(&open).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			alert.ID = uuid.New().String()
+			alert.Status = domain.AlertStatusOpen
+			alert.Occurrences = 1
+			alert.LastSeenAt = alert.CreatedAt
+			if err := tx.Create(alert).Error; err != nil {
+				return err
+			}
+			event = domain.AlertEventRaised
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"occurrences":  gorm.Expr("occurrences + 1"),
+			"last_seen_at": alert.CreatedAt,
+			"value":        alert.Value,
+			"threshold":    alert.Threshold,
+			"message":      alert.Message,
+		}
+		if domain.AlertSeverityRank(alert.Severity) > domain.AlertSeverityRank(open.Severity) {
+			updates["severity"] = alert.Severity
+			event = domain.AlertEventEscalated
+		}
+		if err := tx.Model(&open).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.First(alert, "id = ?", open.ID).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// Opened concurrently; this occurrence joins it
+		return RaiseAlert(tx, alert)
+	}
+	return event, err
+}
+
+// ResolveAlert closes a device's open alert of the given type, returning it,
+// or nil if there was none.
+func ResolveAlert(tx *gorm.DB, deviceID, alertType string, at time.Time) (*domain.DeviceAlert, error) {
+	var resolved []domain.DeviceAlert
+	err := tx.Model(&resolved).
+		Clauses(clause.Returning{}).
+		Where("device_id = ? AND type = ? AND status = ?", deviceID, alertType, domain.AlertStatusOpen).
+		Updates(map[string]interface{}{"status": domain.AlertStatusResolved, "resolved_at": at}).Error
+	if err != nil || len(resolved) == 0 {
+		return nil, err
+	}
+	return &resolved[0], nil
+}
+
+// AcknowledgeAlert records that userID has seen alert. Device-reported alerts
+// have no condition to clear, so acknowledging also resolves them.
+func AcknowledgeAlert(tx *gorm.DB, alert *domain.DeviceAlert, userID string) error {
+	now := time.Now()
+	updates := map[string]interface{}{"acknowledged_at": now, "acknowledged_by": userID}
+	if alert.Source == domain.AlertSourceDevice && alert.Status == domain.AlertStatusOpen {
+		updates["status"] = domain.AlertStatusResolved
+		updates["resolved_at"] = now
+	}
+
+	result := tx.Model(&domain.DeviceAlert{}).Where("id = ? AND acknowledged_at IS NULL", alert.ID).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAlertAcknowledged
+	}
+	return tx.First(alert, "id = ?", alert.ID).Error
+}
+
+// MarkDevicesOffline sets Online ESP32s that have not reported since before
+// the cutoff to Offline and returns them.
+func MarkDevicesOffline(tx *gorm.DB, cutoff time.Time) ([]domain.IoTDevice, error) {
+	var stale []domain.IoTDevice
+	if err := tx.Where("device_type = ? AND status = ? AND last_ping < ?", "esp32", domain.DeviceStatusOnline, cutoff).Find(&stale).Error; err != nil {
+		return nil, err
+	}
+
+	var offline []domain.IoTDevice
+	for _, device := range stale {
+		// Skips devices that reported since they were read
+		result := tx.Model(&domain.IoTDevice{}).
+			Where("id = ? AND status = ? AND last_ping < ?", device.ID, domain.DeviceStatusOnline, cutoff).
+			Update("status", domain.DeviceStatusOffline)
+		if result.Error != nil {
+			return offline, result.Error
+		}
+		if result.RowsAffected > 0 {
+			device.Status = domain.DeviceStatusOffline
+			offline = append(offline, device)
+		}
+	}
+	return offline, nil
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestRaiseEscalateAndResolveAlert(t *testing.T) {
+	connectTestDB(t)
+
+	deviceID := uuid.New().String()
+	t.Cleanup(func() { DB.Delete(&domain.DeviceAlert{}, "device_id = ?", deviceID) })
+
+	raise := func(severity string) (domain.DeviceAlert, string) {
+		t.Helper()
+		alert := domain.DeviceAlert{DeviceID: deviceID, Type: domain.AlertLowSoC, Source: domain.AlertSourceRule, Severity: severity, CreatedAt: time.Now()}
+		event, err := RaiseAlert(DB, &alert)
+		if err != nil {
+			t.Fatalf("RaiseAlert failed: %v", err)
+		}
+		return alert, event
+	}
+
+	first, event := raise(domain.AlertSeverityWarning)
+	if event != domain.AlertEventRaised || first.Occurrences != 1 {
+		t.Fatalf("Expected a new alert, got %q and %+v", event, first)
+	}
+	if again, event := raise(domain.AlertSeverityWarning); event != "" || again.ID != first.ID || again.Occurrences != 2 {
+		t.Errorf("Expected a second occurrence of the same alert, got %q and %+v", event, again)
+	}
+	if escalated, event := raise(domain.AlertSeverityCritical); event != domain.AlertEventEscalated || escalated.Severity != domain.AlertSeverityCritical {
+		t.Errorf("Expected the alert to escalate to critical, got %q and %+v", event, escalated)
+	}
+	if lower, _ := raise(domain.AlertSeverityWarning); lower.Severity != domain.AlertSeverityCritical {
+		t.Errorf("Expected severity never to drop while open, got %s", lower.Severity)
+	}
+
+	resolved, err := ResolveAlert(DB, deviceID, domain.AlertLowSoC, time.Now())
+	if err != nil || resolved == nil || resolved.ID != first.ID || resolved.Status != domain.AlertStatusResolved {
+		t.Fatalf("Expected the alert to be resolved, got %+v (%v)", resolved, err)
+	}
+	if again, err := ResolveAlert(DB, deviceID, domain.AlertLowSoC, time.Now()); err != nil || again != nil {
+		t.Errorf("Expected nothing left to resolve, got %+v (%v)", again, err)
+	}
+
+	reopened, event := raise(domain.AlertSeverityWarning)
+	if event != domain.AlertEventRaised || reopened.ID == first.ID {
+		t.Errorf("Expected a new alert after resolution, got %q and %+v", event, reopened)
+	}
+	if err := AcknowledgeAlert(DB, &reopened, "owner"); err != nil || reopened.AcknowledgedAt == nil || reopened.Status != domain.AlertStatusOpen {
+		t.Errorf("Expected a rule alert to stay open when acknowledged, got %+v (%v)", reopened, err)
+	}
+	if err := AcknowledgeAlert(DB, &reopened, "owner"); !errors.Is(err, ErrAlertAcknowledged) {
+		t.Errorf("Expected ErrAlertAcknowledged, got %v", err)
+	}
+}
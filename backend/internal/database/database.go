@@ -25,7 +25,8 @@ func Connect() (*gorm.DB, error) {
 		dsn = fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable TimeZone=UTC", host, user, password, dbname, port)
 	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// TranslateError maps constraint violations to gorm errors such as gorm.ErrDuplicatedKey
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -40,11 +41,58 @@ func Connect() (*gorm.DB, error) {
 		&domain.DeviceQualityMetrics{},
 		&domain.PricingHistory{},
 		&domain.YieldRecord{},
+		&domain.AuctionResult{},
+		&domain.Account{},
+		&domain.LedgerEntry{},
+		&domain.LedgerReconciliation{},
+		&domain.Withdrawal{},
+		&domain.NodeFee{},
+		&domain.RoleChangeRequest{},
+		&domain.Session{},
+		&domain.SigningKey{},
+		&domain.KYCSubmission{},
+		&domain.KYCDocument{},
+		&domain.KYCStatusChange{},
+		&domain.DeviceEvent{},
+		&domain.DeviceTransfer{},
+		&domain.DeviceTelemetry{},
+		&domain.DeviceTelemetryHourly{},
+		&domain.DeviceLockRequest{},
+		&domain.DeviceAlert{},
+		&domain.AlertWebhook{},
+		&domain.Firmware{},
+		&domain.FirmwareCampaign{},
+		&domain.FirmwareUpdate{},
+		&domain.DeviceShadow{},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to auto-migrate database: %w", err)
 	}
 
+	// Orders created before amendments existed take priority from their creation time
+	if err := db.Exec("UPDATE energy_orders SET priority_at = created_at WHERE priority_at IS NULL").Error; err != nil {
+		return nil, fmt.Errorf("failed to backfill order priority: %w", err)
+	}
+
+	// Devices registered before creation times were recorded get their first ping instead
+	if err := db.Exec("UPDATE iot_devices SET created_at = last_ping WHERE created_at IS NULL").Error; err != nil {
+		return nil, fmt.Errorf("failed to backfill device creation times: %w", err)
+	}
+
+	// Refresh tokens used to be stored in plaintext on users; they now live hashed in sessions
+	for _, column := range []string{"refresh_token", "refresh_token_expires_at"} {
+		if db.Migrator().HasColumn("users", column) {
+			if err := db.Migrator().DropColumn("users", column); err != nil {
+				return nil, fmt.Errorf("failed to drop users.%s: %w", column, err)
+			}
+		}
+	}
+
+	// Users created before roles were enforced may have none
+	if err := db.Exec("UPDATE users SET role = ? WHERE role = ''", domain.RoleRecipient).Error; err != nil {
+		return nil, fmt.Errorf("failed to backfill user roles: %w", err)
+	}
+
 	DB = db
 	fmt.Println("Database connection successful and schema migrated.")
 	return db, nil
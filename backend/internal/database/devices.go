@@ -0,0 +1,179 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrDeviceDecommissioned is returned for a change to a device that was
+	// decommissioned, or has changed hands, since it was read.
+	ErrDeviceDecommissioned = errors.New("device is decommissioned or no longer owned by the user")
+	// ErrTransferPending is returned when the device already has a pending transfer.
+	ErrTransferPending = errors.New("device already has a pending transfer")
+	// ErrTransferClosed is returned when a transfer is no longer pending.
+	ErrTransferClosed = errors.New("device transfer is no longer pending")
+)
+
+// RecordDeviceEvent appends an event to a device's audit trail.
+func RecordDeviceEvent(tx *gorm.DB, deviceID, eventType, actorID, details string) error {
+	return tx.Create(&domain.DeviceEvent{
+		DeviceID:  deviceID,
+		Type:      eventType,
+		ActorID:   actorID,
+		Details:   details,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// UpdateDevice applies updates to a live device owned by ownerID and records
+// the change.
+func UpdateDevice(tx *gorm.DB, deviceID, ownerID string, updates map[string]interface{}, details string) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.IoTDevice{}).
+			Where("id = ? AND owner_id = ? AND status <> ?", deviceID, ownerID, domain.DeviceStatusDecommissioned).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrDeviceDecommissioned
+		}
+		return RecordDeviceEvent(tx, deviceID, domain.DeviceEventUpdated, ownerID, details)
+	})
+}
+
+// DecommissionDevice retires a device owned by ownerID: it cancels the
+// device's pending transfer and the open sell orders delivering from it, all
+// or nothing. It returns the IDs of the cancelled orders, or ErrOrderConflict
+// if one was filled concurrently and the caller should retry.
+func DecommissionDevice(tx *gorm.DB, deviceID, ownerID, reason string) ([]string, error) {
+	var cancelled []string
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.IoTDevice{}).
+			Where("id = ? AND owner_id = ? AND status <> ?", deviceID, ownerID, domain.DeviceStatusDecommissioned).
+			Update("status", domain.DeviceStatusDecommissioned)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrDeviceDecommissioned
+		}
+
+		if err := tx.Model(&domain.DeviceTransfer{}).
+			Where("device_id = ? AND status = ?", deviceID, domain.DeviceTransferPending).
+			Updates(map[string]interface{}{"status": domain.DeviceTransferCancelled, "responded_at": time.Now()}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if cancelled, err = cancelDeviceOrders(tx, deviceID, ownerID); err != nil {
+			return err
+		}
+		return RecordDeviceEvent(tx, deviceID, domain.DeviceEventDecommissioned, ownerID, reason)
+	})
+	return cancelled, err
+}
+
+// CreateDeviceTransfer offers a live device to another user. A pending
+// transfer that has expired is cancelled first.
+func CreateDeviceTransfer(tx *gorm.DB, transfer *domain.DeviceTransfer) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.DeviceTransfer{}).
+			Where("device_id = ? AND status = ? AND expires_at <= ?", transfer.DeviceID, domain.DeviceTransferPending, time.Now()).
+			Updates(map[string]interface{}{"status": domain.DeviceTransferCancelled, "responded_at": time.Now()}).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&domain.IoTDevice{}).
+			Where("id = ? AND owner_id = ? AND status <> ?", transfer.DeviceID, transfer.FromUserID, domain.DeviceStatusDecommissioned).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrDeviceDecommissioned
+		}
+
+		transfer.Status = domain.DeviceTransferPending
+		if err := tx.Create(transfer).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrTransferPending
+			}
+			return err
+		}
+		return RecordDeviceEvent(tx, transfer.DeviceID, domain.DeviceEventTransferRequested, transfer.FromUserID, "to "+transfer.ToUserID)
+	})
+}
+
+// RespondToDeviceTransfer closes a pending transfer with status: the
+// recipient accepts or declines it, the sender cancels it. Acceptance moves
+// the device to the recipient and cancels the sender's open sell orders from
+// it, all or nothing; it returns ErrOrderConflict if one was filled
+// concurrently and the caller should retry.
+func RespondToDeviceTransfer(tx *gorm.DB, transfer *domain.DeviceTransfer, status, actorID string) error {
+	eventType := map[string]string{
+		domain.DeviceTransferAccepted:  domain.DeviceEventTransferAccepted,
+		domain.DeviceTransferDeclined:  domain.DeviceEventTransferDeclined,
+		domain.DeviceTransferCancelled: domain.DeviceEventTransferCancelled,
+	}[status]
+
+	return tx.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		query := tx.Model(&domain.DeviceTransfer{}).Where("id = ? AND status = ?", transfer.ID, domain.DeviceTransferPending)
+		if status == domain.DeviceTransferAccepted {
+			query = query.Where("expires_at > ?", now)
+		}
+		result := query.Updates(map[string]interface{}{"status": status, "responded_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTransferClosed
+		}
+
+		details := ""
+		if status == domain.DeviceTransferAccepted {
+			// Only if the sender still owns the live device
+			result := tx.Model(&domain.IoTDevice{}).
+				Where("id = ? AND owner_id = ? AND status <> ?", transfer.DeviceID, transfer.FromUserID, domain.DeviceStatusDecommissioned).
+				Update("owner_id", transfer.ToUserID)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrDeviceDecommissioned
+			}
+			if _, err := cancelDeviceOrders(tx, transfer.DeviceID, transfer.FromUserID); err != nil {
+				return err
+			}
+			details = "from " + transfer.FromUserID + " to " + transfer.ToUserID
+		}
+
+		transfer.Status = status
+		transfer.RespondedAt = &now
+		return RecordDeviceEvent(tx, transfer.DeviceID, eventType, actorID, details)
+	})
+}
+
+// cancelDeviceOrders cancels userID's open sell orders delivering from
+// deviceID, releasing their energy reservations, and returns their IDs.
+func cancelDeviceOrders(tx *gorm.DB, deviceID, userID string) ([]string, error) {
+	var orders []domain.EnergyOrder
+	if err := tx.Where("device_id = ? AND user_id = ? AND status IN ?", deviceID, userID, domain.OpenOrderStatuses).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(orders))
+	for i := range orders {
+		if err := TransitionOrder(tx, &orders[i], domain.OrderStatusCancelled, nil); err != nil {
+			return nil, err
+		}
+		ids = append(ids, orders[i].ID)
+	}
+	return ids, nil
+}
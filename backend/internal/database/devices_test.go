@@ -0,0 +1,56 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestAcceptingDeviceTransferCancelsSellerOrders(t *testing.T) {
+	connectTestDB(t)
+
+	from, to := "device_from_"+uuid.New().String(), "device_to_"+uuid.New().String()
+	device := domain.IoTDevice{ID: uuid.New().String(), OwnerID: from, DeviceType: "esp32", BatteryLevel: 1, CapacityKwh: 10, Status: domain.DeviceStatusOnline, LastPing: time.Now()}
+	if err := DB.Create(&device).Error; err != nil {
+		t.Fatal(err)
+	}
+	order := createTestOrder(t)
+	DB.Model(&order).Updates(map[string]interface{}{"user_id": from, "device_id": device.ID})
+	t.Cleanup(func() {
+		DB.Delete(&domain.DeviceEvent{}, "device_id = ?", device.ID)
+		DB.Delete(&domain.DeviceTransfer{}, "device_id = ?", device.ID)
+		DB.Delete(&order)
+		DB.Delete(&device)
+	})
+
+	now := time.Now()
+	transfer := domain.DeviceTransfer{ID: uuid.New().String(), DeviceID: device.ID, FromUserID: from, ToUserID: to, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if err := CreateDeviceTransfer(DB, &transfer); err != nil {
+		t.Fatalf("CreateDeviceTransfer failed: %v", err)
+	}
+	second := transfer
+	second.ID = uuid.New().String()
+	if err := CreateDeviceTransfer(DB, &second); !errors.Is(err, ErrTransferPending) {
+		t.Errorf("Expected ErrTransferPending for a second transfer, got %v", err)
+	}
+
+	if err := RespondToDeviceTransfer(DB, &transfer, domain.DeviceTransferAccepted, to); err != nil {
+		t.Fatalf("Accepting failed: %v", err)
+	}
+
+	DB.First(&device, "id = ?", device.ID)
+	DB.First(&order, "id = ?", order.ID)
+	if device.OwnerID != to {
+		t.Errorf("Expected the device to belong to %s, got %s", to, device.OwnerID)
+	}
+	if order.Status != domain.OrderStatusCancelled {
+		t.Errorf("Expected the previous owner's sell order to be cancelled, got %s", order.Status)
+	}
+	if _, err := DecommissionDevice(DB, device.ID, from, ""); !errors.Is(err, ErrDeviceDecommissioned) {
+		t.Errorf("Expected the previous owner not to be able to decommission it, got %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrCampaignChanged is returned when a campaign is no longer in the status a
+// change expected, e.g. because the runner halted it concurrently.
+var ErrCampaignChanged = errors.New("campaign changed concurrently")
+
+// updateRank orders update statuses so reports only move an update forward.
+var updateRank = map[string]int{
+	domain.UpdateSent:        0,
+	domain.UpdateDownloading: 1,
+	domain.UpdateInstalling:  2,
+	domain.UpdateSucceeded:   3,
+	domain.UpdateFailed:      3,
+}
+
+// CreateCampaign stores a campaign with one update per targeted device.
+func CreateCampaign(tx *gorm.DB, campaign *domain.FirmwareCampaign, updates []domain.FirmwareUpdate) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(campaign).Error; err != nil {
+			return err
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(updates, 500).Error
+	})
+}
+
+// TransitionCampaign moves a campaign from one of the from statuses to the
+// to status, applying any other column updates. It returns
+// ErrCampaignChanged if the campaign was not in one of the from statuses.
+func TransitionCampaign(tx *gorm.DB, id string, from []string, to string, updates map[string]interface{}) error {
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["status"] = to
+
+	result := tx.Model(&domain.FirmwareCampaign{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCampaignChanged
+	}
+	return nil
+}
+
+// RunCampaign starts or resumes a campaign in one of the from statuses. The
+// pending updates of its reached stages wait for their devices from at, so
+// time spent as a draft or paused does not skip them.
+func RunCampaign(tx *gorm.DB, id string, from []string, updates map[string]interface{}, at time.Time) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := TransitionCampaign(tx, id, from, domain.CampaignRunning, updates); err != nil {
+			return err
+		}
+		return tx.Model(&domain.FirmwareUpdate{}).
+			Where("campaign_id = ? AND status = ?", id, domain.UpdatePending).
+			Where("stage <= (SELECT current_stage FROM firmware_campaigns WHERE id = ?)", id).
+			Update("updated_at", at).Error
+	})
+}
+
+// CancelCampaign cancels a campaign that has not finished, along with its
+// updates that were not sent yet. Updates devices are working on continue and
+// are still tracked.
+func CancelCampaign(tx *gorm.DB, id string, at time.Time) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		from := []string{domain.CampaignDraft, domain.CampaignRunning, domain.CampaignPaused, domain.CampaignHalted}
+		if err := TransitionCampaign(tx, id, from, domain.CampaignCancelled, map[string]interface{}{"finished_at": at}); err != nil {
+			return err
+		}
+		return tx.Model(&domain.FirmwareUpdate{}).
+			Where("campaign_id = ? AND status = ?", id, domain.UpdatePending).
+			Updates(map[string]interface{}{"status": domain.UpdateCancelled, "completed_at": at, "updated_at": at}).Error
+	})
+}
+
+// AdvanceCampaignStage moves a running campaign from stage to stage + 1. The
+// pending updates of the new stage have their wait for the device to come
+// online counted from at.
+func AdvanceCampaignStage(tx *gorm.DB, id string, stage int, at time.Time) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.FirmwareCampaign{}).
+			Where("id = ? AND status = ? AND current_stage = ?", id, domain.CampaignRunning, stage).
+			Update("current_stage", stage+1)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrCampaignChanged
+		}
+		return tx.Model(&domain.FirmwareUpdate{}).
+			Where("campaign_id = ? AND stage = ? AND status = ?", id, stage+1, domain.UpdatePending).
+			Update("updated_at", at).Error
+	})
+}
+
+// CampaignCounts counts a campaign's updates by stage and status.
+func CampaignCounts(tx *gorm.DB, id string) (map[int]map[string]int, error) {
+	var rows []struct {
+		Stage  int
+		Status string
+		Count  int
+	}
+	err := tx.Model(&domain.FirmwareUpdate{}).
+		Select("stage, status, count(*) AS count").
+		Where("campaign_id = ?", id).
+		Group("stage, status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[int]map[string]int{}
+	for _, row := range rows {
+		if counts[row.Stage] == nil {
+			counts[row.Stage] = map[string]int{}
+		}
+		counts[row.Stage][row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// DispatchableUpdates returns a campaign's pending updates up to and including
+// stage whose devices are Online and have no update in progress.
+func DispatchableUpdates(tx *gorm.DB, campaignID string, stage int) ([]domain.FirmwareUpdate, error) {
+	var updates []domain.FirmwareUpdate
+	err := tx.Where("campaign_id = ? AND stage <= ? AND status = ?", campaignID, stage, domain.UpdatePending).
+		Where("device_id IN (SELECT id FROM iot_devices WHERE status = ?)", domain.DeviceStatusOnline).
+		Where("device_id NOT IN (SELECT device_id FROM firmware_updates WHERE status IN ?)", domain.ActiveUpdateStatuses).
+		Order("stage, id").
+		Find(&updates).Error
+	return updates, err
+}
+
+// MarkUpdateSent claims a pending update for sending, recording the device's
+// current firmware version. It reports false if the update is no longer
+// pending or the device started another update concurrently.
+func MarkUpdateSent(tx *gorm.DB, update *domain.FirmwareUpdate, at time.Time) (bool, error) {
+	var sent bool
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		var device domain.IoTDevice
+		if err := tx.Select("firmware_version").First(&device, "id = ?", update.DeviceID).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&domain.FirmwareUpdate{}).
+			Where("id = ? AND status = ?", update.ID, domain.UpdatePending).
+			Updates(map[string]interface{}{"status": domain.UpdateSent, "sent_at": at, "updated_at": at, "from_version": device.FirmwareVersion})
+		if result.Error != nil {
+			return result.Error
+		}
+		sent = result.RowsAffected > 0
+		return nil
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return false, nil
+	}
+	return sent, err
+}
+
+// UnsendUpdate returns an update to pending after its command could not be
+// published.
+func UnsendUpdate(tx *gorm.DB, id string) error {
+	return tx.Model(&domain.FirmwareUpdate{}).
+		Where("id = ? AND status = ?", id, domain.UpdateSent).
+		Updates(map[string]interface{}{"status": domain.UpdatePending, "sent_at": nil}).Error
+}
+
+// RecordUpdateReport applies a device's progress report to its update. Reports
+// for another device's update, for finished updates, or that would move the
+// update backwards are ignored and return nil. A successful update sets the
+// device's firmware version.
+func RecordUpdateReport(tx *gorm.DB, deviceID, updateID, status string, progress int, message, version string, at time.Time) (*domain.FirmwareUpdate, error) {
+	var recorded *domain.FirmwareUpdate
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		var update domain.FirmwareUpdate
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND device_id = ? AND status IN ?", updateID, deviceID, domain.ActiveUpdateStatuses).
+			First(&update).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if updateRank[status] < updateRank[update.Status] {
+			return nil
+		}
+
+		updates := map[string]interface{}{"status": status, "progress": progress, "updated_at": at}
+		if message != "" {
+			updates["error"] = message
+		}
+		if domain.IsTerminalUpdateStatus(status) {
+			updates["completed_at"] = at
+		}
+		if status == domain.UpdateSucceeded {
+			updates["progress"] = 100
+			if version != "" {
+				if err := tx.Model(&domain.IoTDevice{}).Where("id = ?", deviceID).Update("firmware_version", version).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Model(&update).Updates(updates).Error; err != nil {
+			return err
+		}
+		recorded = &update
+		return tx.First(recorded, "id = ?", update.ID).Error
+	})
+	return recorded, err
+}
+
+// ExpireUpdates finishes a campaign's updates that have waited since before
+// the cutoff: updates devices stopped reporting on time out, and pending
+// updates up to stage whose devices never came online are skipped.
+func ExpireUpdates(tx *gorm.DB, campaignID string, stage int, before, at time.Time) error {
+	err := tx.Model(&domain.FirmwareUpdate{}).
+		Where("campaign_id = ? AND status IN ? AND updated_at < ?", campaignID, domain.ActiveUpdateStatuses, before).
+		Updates(map[string]interface{}{"status": domain.UpdateTimedOut, "completed_at": at, "updated_at": at}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&domain.FirmwareUpdate{}).
+		Where("campaign_id = ? AND stage <= ? AND status = ? AND updated_at < ?", campaignID, stage, domain.UpdatePending, before).
+		Updates(map[string]interface{}{"status": domain.UpdateSkipped, "completed_at": at, "updated_at": at}).Error
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestFirmwareUpdateReportsOnlyMoveForward(t *testing.T) {
+	connectTestDB(t)
+
+	device := domain.IoTDevice{ID: uuid.New().String(), OwnerID: "firmware_owner", DeviceType: "esp32", Status: domain.DeviceStatusOnline, FirmwareVersion: "1.0.0"}
+	campaign := domain.FirmwareCampaign{ID: uuid.New().String(), Name: "test", FirmwareID: uuid.New().String(), Stages: domain.RolloutStages{100}, MaxFailureRate: 0.2, Status: domain.CampaignRunning}
+	update := domain.FirmwareUpdate{ID: uuid.New().String(), CampaignID: campaign.ID, DeviceID: device.ID, Status: domain.UpdatePending, UpdatedAt: time.Now()}
+	if err := DB.Create(&device).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateCampaign(DB, &campaign, []domain.FirmwareUpdate{update}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		DB.Delete(&domain.FirmwareUpdate{}, "campaign_id = ?", campaign.ID)
+		DB.Delete(&campaign)
+		DB.Delete(&device)
+	})
+
+	if sent, err := MarkUpdateSent(DB, &update, time.Now()); err != nil || !sent {
+		t.Fatalf("Expected the update to be claimed, got %v (%v)", sent, err)
+	}
+	if sent, _ := MarkUpdateSent(DB, &update, time.Now()); sent {
+		t.Error("Expected an update to be claimed only once")
+	}
+
+	report := func(status string, progress int) *domain.FirmwareUpdate {
+		t.Helper()
+		recorded, err := RecordUpdateReport(DB, device.ID, update.ID, status, progress, "", "1.1.0", time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return recorded
+	}
+	if got := report(domain.UpdateInstalling, 0); got == nil || got.Status != domain.UpdateInstalling || got.FromVersion != "1.0.0" {
+		t.Fatalf("Expected the update to be installing from 1.0.0, got %+v", got)
+	}
+	if got := report(domain.UpdateDownloading, 90); got != nil {
+		t.Errorf("Expected a late download report to be ignored, got %+v", got)
+	}
+	if got := report(domain.UpdateSucceeded, 0); got == nil || got.Progress != 100 || got.CompletedAt == nil {
+		t.Fatalf("Expected the update to succeed, got %+v", got)
+	}
+	if got := report(domain.UpdateFailed, 0); got != nil {
+		t.Errorf("Expected a finished update to ignore reports, got %+v", got)
+	}
+
+	DB.First(&device, "id = ?", device.ID)
+	if device.FirmwareVersion != "1.1.0" {
+		t.Errorf("Expected the device to run 1.1.0, got %q", device.FirmwareVersion)
+	}
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrKYCReviewed is returned when a KYC submission was already decided.
+	ErrKYCReviewed = errors.New("KYC submission has already been reviewed")
+	// ErrKYCInReview is returned when the user already has a submission in review.
+	ErrKYCInReview = errors.New("a KYC submission is already in review")
+	// ErrKYCDocumentUnavailable is returned when a document to submit does not
+	// exist, belongs to someone else or was already submitted.
+	ErrKYCDocumentUnavailable = errors.New("KYC document not found or already submitted")
+)
+
+// CreateKYCSubmission stores submission in review, attaches documentIDs to
+// it and moves the user to in_review.
+func CreateKYCSubmission(tx *gorm.DB, submission *domain.KYCSubmission, documentIDs []string) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		var user domain.User
+		if err := tx.Where("id = ?", submission.UserID).First(&user).Error; err != nil {
+			return err
+		}
+
+		submission.Status = domain.KYCStatusInReview
+		if err := tx.Create(submission).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrKYCInReview
+			}
+			return err
+		}
+
+		result := tx.Model(&domain.KYCDocument{}).
+			Where("id IN ? AND user_id = ? AND submission_id = ''", documentIDs, submission.UserID).
+			Update("submission_id", submission.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(documentIDs)) {
+			return ErrKYCDocumentUnavailable
+		}
+
+		return setKYCStatus(tx, user, domain.KYCStatusInReview, user.KYCTier, submission.ID, submission.UserID, "")
+	})
+}
+
+// DecideKYCSubmission approves or rejects a submission in review. Approval
+// raises the user's tier to the submission's (never lowering it); either way
+// the user's status becomes the decision.
+func DecideKYCSubmission(tx *gorm.DB, submissionID, status, reviewedBy, reason string) (domain.KYCSubmission, error) {
+	var submission domain.KYCSubmission
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", submissionID).First(&submission).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&domain.KYCSubmission{}).
+			Where("id = ? AND status = ?", submissionID, domain.KYCStatusInReview).
+			Updates(map[string]interface{}{"status": status, "reviewed_by": reviewedBy, "review_reason": reason, "reviewed_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrKYCReviewed
+		}
+		submission.Status = status
+		submission.ReviewedBy = reviewedBy
+		submission.ReviewReason = reason
+		submission.ReviewedAt = &now
+
+		var user domain.User
+		if err := tx.Where("id = ?", submission.UserID).First(&user).Error; err != nil {
+			return err
+		}
+		tier := user.KYCTier
+		if status == domain.KYCStatusApproved && submission.Tier > tier {
+			tier = submission.Tier
+		}
+		return setKYCStatus(tx, user, status, tier, submission.ID, reviewedBy, reason)
+	})
+	return submission, err
+}
+
+// setKYCStatus updates the user's KYC status and tier and records the change.
+func setKYCStatus(tx *gorm.DB, user domain.User, status string, tier int, submissionID, changedBy, reason string) error {
+	if err := tx.Model(&domain.User{}).Where("id = ?", user.ID).
+		Updates(map[string]interface{}{"kyc_status": status, "kyc_tier": tier}).Error; err != nil {
+		return err
+	}
+	return tx.Create(&domain.KYCStatusChange{
+		UserID:       user.ID,
+		SubmissionID: submissionID,
+		FromStatus:   user.KYCStatus,
+		ToStatus:     status,
+		FromTier:     user.KYCTier,
+		ToTier:       tier,
+		ChangedBy:    changedBy,
+		Reason:       reason,
+		CreatedAt:    time.Now(),
+	}).Error
+}
+
+// LockKYCTier locks userID's row until tx ends and returns their KYC tier.
+// Order placement takes this lock before reading TradedNotionalSince, so two
+// orders from one user cannot both pass a daily limit only one fits under.
+func LockKYCTier(tx *gorm.DB, userID string) (int, error) {
+	var user domain.User
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("kyc_tier").Where("id = ?", userID).First(&user).Error
+	return user.KYCTier, err
+}
+
+// TradedNotionalSince sums the notional (kWh x price) of userID's orders
+// created since since, other than excludeOrderID: open orders count in full,
+// closed ones by what was filled.
+func TradedNotionalSince(tx *gorm.DB, userID string, since time.Time, excludeOrderID string) (float64, error) {
+	var total float64
+	err := tx.Model(&domain.EnergyOrder{}).
+		Select("COALESCE(SUM(CASE WHEN status IN ? THEN kwh_amount ELSE filled_kwh END * token_price), 0)", domain.OpenOrderStatuses).
+		Where("user_id = ? AND created_at >= ? AND id <> ?", userID, since, excludeOrderID).
+		Scan(&total).Error
+	return total, err
+}
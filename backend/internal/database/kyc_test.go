@@ -0,0 +1,114 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestKYCSubmissionLifecycle(t *testing.T) {
+	connectTestDB(t)
+
+	user := domain.User{ID: uuid.New().String(), WalletAddress: "kyc_test_" + uuid.New().String(), Role: domain.RoleRecipient, KYCStatus: domain.KYCStatusPending}
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	doc := domain.KYCDocument{ID: uuid.New().String(), UserID: user.ID, Type: domain.KYCDocPassport, Size: 1, CreatedAt: time.Now()}
+	if err := DB.Create(&doc).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		DB.Delete(&domain.KYCStatusChange{}, "user_id = ?", user.ID)
+		DB.Delete(&domain.KYCDocument{}, "user_id = ?", user.ID)
+		DB.Delete(&domain.KYCSubmission{}, "user_id = ?", user.ID)
+		DB.Delete(&user)
+	})
+
+	newSubmission := func() *domain.KYCSubmission {
+		return &domain.KYCSubmission{ID: uuid.New().String(), UserID: user.ID, Tier: domain.KYCTierBasic, FullName: "Test", DateOfBirth: "1990-01-01", Country: "PH", CreatedAt: time.Now()}
+	}
+
+	first := newSubmission()
+	if err := CreateKYCSubmission(DB, first, []string{doc.ID}); err != nil {
+		t.Fatalf("CreateKYCSubmission failed: %v", err)
+	}
+	// One submission in review at a time, and a document is submitted once
+	if err := CreateKYCSubmission(DB, newSubmission(), nil); !errors.Is(err, ErrKYCInReview) {
+		t.Errorf("Expected ErrKYCInReview, got %v", err)
+	}
+
+	if _, err := DecideKYCSubmission(DB, first.ID, domain.KYCStatusApproved, "admin", ""); err != nil {
+		t.Fatalf("Approval failed: %v", err)
+	}
+	if _, err := DecideKYCSubmission(DB, first.ID, domain.KYCStatusRejected, "admin", "late"); !errors.Is(err, ErrKYCReviewed) {
+		t.Errorf("Expected ErrKYCReviewed for a second decision, got %v", err)
+	}
+	if err := CreateKYCSubmission(DB, newSubmission(), []string{doc.ID}); !errors.Is(err, ErrKYCDocumentUnavailable) {
+		t.Errorf("Expected ErrKYCDocumentUnavailable for a resubmitted document, got %v", err)
+	}
+
+	DB.First(&user, "id = ?", user.ID)
+	if user.KYCStatus != domain.KYCStatusApproved || user.KYCTier != domain.KYCTierBasic {
+		t.Errorf("Expected the user approved at tier 1, got %s at tier %d", user.KYCStatus, user.KYCTier)
+	}
+	var changes int64
+	DB.Model(&domain.KYCStatusChange{}).Where("user_id = ?", user.ID).Count(&changes)
+	if changes != 2 {
+		t.Errorf("Expected 2 status changes (in_review, approved), got %d", changes)
+	}
+}
+
+func TestKYCTierLockSerialisesDailyLimit(t *testing.T) {
+	connectTestDB(t)
+
+	user := domain.User{ID: uuid.New().String(), WalletAddress: "kyc_lock_test_" + uuid.New().String(), Role: domain.RoleRecipient, KYCStatus: domain.KYCStatusPending}
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		DB.Delete(&domain.EnergyOrder{}, "user_id = ?", user.ID)
+		DB.Delete(&user)
+	})
+
+	// Each order is 30 tokens against a daily limit of 50, so only one fits
+	const dailyLimit = 50.0
+	place := func() error {
+		return DB.Transaction(func(tx *gorm.DB) error {
+			if _, err := LockKYCTier(tx, user.ID); err != nil {
+				return err
+			}
+			traded, err := TradedNotionalSince(tx, user.ID, time.Now().Add(-24*time.Hour), "")
+			if err != nil {
+				return err
+			}
+			if traded+30 > dailyLimit {
+				return errors.New("daily limit")
+			}
+			now := time.Now()
+			return tx.Create(&domain.EnergyOrder{ID: uuid.New().String(), UserID: user.ID, Type: "buy", KwhAmount: 10, TokenPrice: 3, Status: domain.OrderStatusCreated, CreatedAt: now, PriorityAt: now}).Error
+		})
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = place()
+		}()
+	}
+	wg.Wait()
+
+	var placed int64
+	DB.Model(&domain.EnergyOrder{}).Where("user_id = ?", user.ID).Count(&placed)
+	if placed != 1 || (errs[0] == nil) == (errs[1] == nil) {
+		t.Errorf("Expected exactly one order under the limit, got %d placed and errors %v", placed, errs)
+	}
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PostJournal records postings as one balanced journal of the given kind.
+// Callers post in the same transaction as the change the journal describes.
+func PostJournal(tx *gorm.DB, kind, reference string, postings []domain.Posting) error {
+	if err := domain.CheckBalanced(postings); err != nil {
+		return err
+	}
+
+	journalID := uuid.New().String()
+	now := time.Now()
+	entries := make([]domain.LedgerEntry, 0, len(postings))
+	for _, p := range postings {
+		entries = append(entries, domain.LedgerEntry{
+			JournalID: journalID,
+			Kind:      kind,
+			Reference: reference,
+			Owner:     p.Owner,
+			Account:   p.Account,
+			Amount:    p.Amount,
+			CreatedAt: now,
+		})
+	}
+	return tx.Create(&entries).Error
+}
+
+// LedgerBalances sums owner's entries per account. Accounts without entries
+// are omitted.
+func LedgerBalances(tx *gorm.DB, owner string) (map[string]float64, error) {
+	var rows []struct {
+		Account string
+		Balance float64
+	}
+	err := tx.Model(&domain.LedgerEntry{}).
+		Select("account, SUM(amount) AS balance").
+		Where("owner = ?", owner).
+		Group("account").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		balances[row.Account] = row.Balance
+	}
+	return balances, nil
+}
+
+// LedgerBalanceAt is the balance of an owner's account from entries posted before t.
+func LedgerBalanceAt(tx *gorm.DB, owner, account string, t time.Time) (float64, error) {
+	var balance float64
+	err := tx.Model(&domain.LedgerEntry{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("owner = ? AND account = ? AND created_at < ?", owner, account, t).
+		Scan(&balance).Error
+	return balance, err
+}
+
+// LedgerEntries lists an owner's entries on one account posted in [from, to),
+// oldest first, at most limit of them.
+func LedgerEntries(tx *gorm.DB, owner, account string, from, to time.Time, limit int) ([]domain.LedgerEntry, error) {
+	var entries []domain.LedgerEntry
+	err := tx.Where("owner = ? AND account = ? AND created_at >= ? AND created_at < ?", owner, account, from, to).
+		Order("created_at, id").
+		Limit(limit).
+		Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,176 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestReservationsPostToLedger(t *testing.T) {
+	connectTestDB(t)
+
+	userID := "ledger_test_" + uuid.New().String()
+	if err := OpenAccount(DB, userID, 100); err != nil {
+		t.Fatalf("OpenAccount failed: %v", err)
+	}
+	t.Cleanup(func() {
+		DB.Delete(&domain.Account{}, "user_id = ?", userID)
+		DB.Delete(&domain.LedgerEntry{}, "owner = ?", userID)
+		DB.Delete(&domain.EnergyOrder{}, "user_id = ?", userID)
+	})
+
+	now := time.Now()
+	order := domain.EnergyOrder{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Type:        "buy",
+		Market:      domain.MarketContinuous,
+		Kind:        domain.OrderKindLimit,
+		TimeInForce: domain.TimeInForceGTC,
+		KwhAmount:   10,
+		TokenPrice:  8,
+		Status:      domain.OrderStatusCreated,
+		CreatedAt:   now,
+		PriorityAt:  now,
+	}
+	if err := CreateOrder(DB, &order); err != nil {
+		t.Fatalf("CreateOrder failed: %v", err)
+	}
+
+	// 80 of the 100 tokens are now reserved, so a second order for 40 must fail
+	second := order
+	second.ID = uuid.New().String()
+	second.KwhAmount = 5
+	if err := CreateOrder(DB, &second); err != ErrInsufficientBalance {
+		t.Fatalf("Expected ErrInsufficientBalance, got %v", err)
+	}
+
+	if err := TransitionOrder(DB, &order, domain.OrderStatusPartiallyFilled, map[string]interface{}{"filled_kwh": 4.0}); err != nil {
+		t.Fatalf("Fill failed: %v", err)
+	}
+	if err := TransitionOrder(DB, &order, domain.OrderStatusCancelled, nil); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+
+	balances, err := LedgerBalances(DB, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balances[domain.LedgerAvailable] != 100 || balances[domain.LedgerReserved] != 0 {
+		t.Errorf("Expected every reservation to be released, got %v", balances)
+	}
+
+	var account domain.Account
+	DB.First(&account, "user_id = ?", userID)
+	if account.Balance != 100 || account.Reserved != 0 {
+		t.Errorf("Expected the account to match the ledger, got %+v", account)
+	}
+}
+
+func TestDepositsAndWithdrawals(t *testing.T) {
+	connectTestDB(t)
+
+	userID := "funding_test_" + uuid.New().String()
+	if err := OpenAccount(DB, userID, 100); err != nil {
+		t.Fatalf("OpenAccount failed: %v", err)
+	}
+	t.Cleanup(func() {
+		DB.Delete(&domain.Account{}, "user_id = ?", userID)
+		DB.Delete(&domain.LedgerEntry{}, "owner = ?", userID)
+		DB.Delete(&domain.EnergyOrder{}, "user_id = ?", userID)
+		DB.Delete(&domain.Withdrawal{}, "user_id = ?", userID)
+	})
+
+	now := time.Now()
+	order := domain.EnergyOrder{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Type:        "buy",
+		Market:      domain.MarketContinuous,
+		Kind:        domain.OrderKindLimit,
+		TimeInForce: domain.TimeInForceGTC,
+		KwhAmount:   10,
+		TokenPrice:  8,
+		Status:      domain.OrderStatusCreated,
+		CreatedAt:   now,
+		PriorityAt:  now,
+	}
+	if err := CreateOrder(DB, &order); err != nil {
+		t.Fatalf("CreateOrder failed: %v", err)
+	}
+
+	// 80 of the 100 tokens are reserved, so only 20 can be withdrawn
+	if _, err := RequestWithdrawal(DB, userID, "wallet", 30); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("Expected ErrInsufficientBalance, got %v", err)
+	}
+	withdrawal, err := RequestWithdrawal(DB, userID, "wallet", 20)
+	if err != nil {
+		t.Fatalf("RequestWithdrawal failed: %v", err)
+	}
+
+	if err := Deposit(DB, userID, 50, "tx_"+userID); err != nil {
+		t.Fatalf("Deposit failed: %v", err)
+	}
+	if err := Deposit(DB, userID, 50, "tx_"+userID); !errors.Is(err, ErrDuplicateDeposit) {
+		t.Errorf("Expected the same deposit to be credited once, got %v", err)
+	}
+
+	if _, err := ProcessWithdrawal(DB, withdrawal.ID, domain.WithdrawalRejected, "", "wallet closed", "admin"); err != nil {
+		t.Fatalf("ProcessWithdrawal failed: %v", err)
+	}
+	if _, err := ProcessWithdrawal(DB, withdrawal.ID, domain.WithdrawalPaid, "hash", "", "admin"); !errors.Is(err, ErrWithdrawalProcessed) {
+		t.Errorf("Expected a rejected withdrawal not to be paid, got %v", err)
+	}
+
+	balances, err := LedgerBalances(DB, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balances[domain.LedgerAvailable] != 70 || balances[domain.LedgerReserved] != 80 {
+		t.Errorf("Expected 70 available and 80 reserved, got %v", balances)
+	}
+
+	var account domain.Account
+	DB.First(&account, "user_id = ?", userID)
+	if account.Balance != 150 || account.Reserved != 80 {
+		t.Errorf("Expected the account to match the ledger, got %+v", account)
+	}
+}
+
+func TestSettleTradeKeepsAccountsInStepWithLedger(t *testing.T) {
+	connectTestDB(t)
+
+	// The buyer predates accounts and has none
+	buyerID := "settle_buyer_" + uuid.New().String()
+	sellerID := "settle_seller_" + uuid.New().String()
+	t.Cleanup(func() {
+		DB.Delete(&domain.Account{}, "user_id IN ?", []string{buyerID, sellerID})
+		DB.Delete(&domain.LedgerEntry{}, "owner IN ?", []string{buyerID, sellerID})
+	})
+
+	if err := SettleTrade(DB, buyerID, sellerID, 12, uuid.New().String()); err != nil {
+		t.Fatalf("SettleTrade failed: %v", err)
+	}
+	txn := domain.Transaction{ID: uuid.New().String(), RecipientID: buyerID, DonorID: sellerID, BuyerFee: 1, SellerFee: 0.5, PlatformFee: 1.5}
+	if err := ChargeFees(DB, txn, nil); err != nil {
+		t.Fatalf("ChargeFees failed: %v", err)
+	}
+
+	for userID, want := range map[string]float64{buyerID: -13, sellerID: 11.5} {
+		balances, err := LedgerBalances(DB, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var account domain.Account
+		if err := DB.First(&account, "user_id = ?", userID).Error; err != nil {
+			t.Fatalf("Expected an account for %s: %v", userID, err)
+		}
+		if balances[domain.LedgerAvailable] != want || account.Balance != want {
+			t.Errorf("Expected %s to hold %f in the ledger and the account, got %f and %f", userID, want, balances[domain.LedgerAvailable], account.Balance)
+		}
+	}
+}
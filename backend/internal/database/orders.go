@@ -0,0 +1,106 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"gorm.io/gorm"
+)
+
+// ErrOrderConflict is returned when an order changed between being read and
+// being updated, e.g. the matching engine filled it while its owner cancelled.
+var ErrOrderConflict = errors.New("order was modified concurrently")
+
+// CreateOrder reserves the buyer's tokens or the seller's device energy for
+// a new order and inserts it, in one transaction. It returns
+// ErrInsufficientBalance or ErrInsufficientEnergy if the reservation fails.
+func CreateOrder(tx *gorm.DB, order *domain.EnergyOrder) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := adjustReservations(tx, domain.EnergyOrder{}, *order); err != nil {
+			return err
+		}
+		return tx.Create(order).Error
+	})
+}
+
+// TransitionOrder moves order to status `to`, applying updates in the same
+// statement. The state machine in domain is checked first (returning a
+// *domain.TransitionError), then the write is conditional on the order's
+// version being unchanged since it was read (returning ErrOrderConflict).
+// Token and energy reservations are adjusted to match the order's new state
+// in the same transaction. On success order is updated in place.
+func TransitionOrder(tx *gorm.DB, order *domain.EnergyOrder, to string, updates map[string]interface{}) error {
+	if err := order.CheckTransition(to); err != nil {
+		return err
+	}
+
+	values := map[string]interface{}{"status": to, "version": gorm.Expr("version + 1")}
+	for column, value := range updates {
+		values[column] = value
+	}
+	next := *order
+	next.Status = to
+	next.Version++
+	applyOrderUpdates(&next, updates)
+
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.EnergyOrder{}).Where("id = ? AND version = ?", order.ID, order.Version).Updates(values)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrderConflict
+		}
+		return adjustReservations(tx, *order, next)
+	})
+	if err != nil {
+		return err
+	}
+
+	*order = next
+	return nil
+}
+
+// applyOrderUpdates mirrors the columns TransitionOrder callers write onto the
+// in-memory order, so reservations can be computed from its new state.
+func applyOrderUpdates(order *domain.EnergyOrder, updates map[string]interface{}) {
+	for column, value := range updates {
+		switch column {
+		case "filled_kwh":
+			order.FilledKwh = value.(float64)
+		case "kwh_amount":
+			order.KwhAmount = value.(float64)
+		case "token_price":
+			order.TokenPrice = value.(float64)
+		case "priority_at":
+			order.PriorityAt = value.(time.Time)
+		}
+	}
+}
+
+// ExpireOrders moves every order matching the given condition that the state
+// machine allows to expire to Expired, releasing its reservations, and
+// returns how many were updated. Orders changed concurrently (e.g. filled by
+// the matching engine) are skipped and picked up on the next sweep if still due.
+func ExpireOrders(tx *gorm.DB, query interface{}, args ...interface{}) (int64, error) {
+	var orders []domain.EnergyOrder
+	if err := tx.Where("status IN ?", domain.TransitionSources(domain.OrderStatusExpired)).
+		Where(query, args...).Find(&orders).Error; err != nil {
+		return 0, err
+	}
+
+	var expired int64
+	for i := range orders {
+		err := TransitionOrder(tx, &orders[i], domain.OrderStatusExpired, nil)
+		if errors.Is(err, ErrOrderConflict) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
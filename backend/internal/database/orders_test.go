@@ -0,0 +1,209 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+// connectTestDB connects to the database in TEST_DATABASE_URL, skipping the
+// test if it is not set. The conditional updates under test need Postgres.
+func connectTestDB(t *testing.T) {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	t.Setenv("DATABASE_URL", url)
+	if _, err := Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+}
+
+func createTestOrder(t *testing.T) domain.EnergyOrder {
+	t.Helper()
+	now := time.Now()
+	order := domain.EnergyOrder{
+		ID:          uuid.New().String(),
+		UserID:      "race_test",
+		Type:        "sell",
+		Market:      domain.MarketContinuous,
+		Kind:        domain.OrderKindLimit,
+		TimeInForce: domain.TimeInForceGTC,
+		KwhAmount:   5,
+		TokenPrice:  4.0,
+		Status:      domain.OrderStatusCreated,
+		CreatedAt:   now,
+		PriorityAt:  now,
+	}
+	if err := DB.Create(&order).Error; err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	t.Cleanup(func() { DB.Delete(&domain.EnergyOrder{}, "id = ?", order.ID) })
+	return order
+}
+
+func TestCancelRacesFill(t *testing.T) {
+	connectTestDB(t)
+
+	for i := 0; i < 20; i++ {
+		order := createTestOrder(t)
+
+		// Both sides read the same version, as the API and the matching engine would
+		cancel, fill := order, order
+		var cancelErr, fillErr error
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			cancelErr = TransitionOrder(DB, &cancel, domain.OrderStatusCancelled, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			fillErr = TransitionOrder(DB, &fill, domain.OrderStatusMatched, map[string]interface{}{"filled_kwh": order.KwhAmount})
+		}()
+		wg.Wait()
+
+		if (cancelErr == nil) == (fillErr == nil) {
+			t.Fatalf("Expected exactly one winner, got cancel=%v fill=%v", cancelErr, fillErr)
+		}
+		for _, err := range []error{cancelErr, fillErr} {
+			if err != nil && !errors.Is(err, ErrOrderConflict) {
+				t.Fatalf("Expected the loser to see ErrOrderConflict, got %v", err)
+			}
+		}
+
+		var stored domain.EnergyOrder
+		DB.First(&stored, "id = ?", order.ID)
+		if cancelErr == nil && (stored.Status != domain.OrderStatusCancelled || stored.FilledKwh != 0) {
+			t.Fatalf("Cancel won but order is %s with %f kWh filled", stored.Status, stored.FilledKwh)
+		}
+		if fillErr == nil && (stored.Status != domain.OrderStatusMatched || stored.FilledKwh != order.KwhAmount) {
+			t.Fatalf("Fill won but order is %s with %f kWh filled", stored.Status, stored.FilledKwh)
+		}
+		if stored.Version != order.Version+1 {
+			t.Fatalf("Expected exactly one write, version went from %d to %d", order.Version, stored.Version)
+		}
+	}
+}
+
+func TestTransitionRejectsTerminalOrders(t *testing.T) {
+	connectTestDB(t)
+
+	order := createTestOrder(t)
+	if err := TransitionOrder(DB, &order, domain.OrderStatusMatched, map[string]interface{}{"filled_kwh": order.KwhAmount}); err != nil {
+		t.Fatalf("Fill failed: %v", err)
+	}
+
+	var te *domain.TransitionError
+	if err := TransitionOrder(DB, &order, domain.OrderStatusCancelled, nil); !errors.As(err, &te) {
+		t.Errorf("Expected a TransitionError cancelling a matched order, got %v", err)
+	}
+}
+
+func TestExpiryRacesFill(t *testing.T) {
+	connectTestDB(t)
+
+	for i := 0; i < 20; i++ {
+		order := createTestOrder(t)
+
+		fill := order
+		var expired int64
+		var expireErr, fillErr error
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			expired, expireErr = ExpireOrders(DB, "id = ?", order.ID)
+		}()
+		go func() {
+			defer wg.Done()
+			fillErr = TransitionOrder(DB, &fill, domain.OrderStatusPartiallyFilled, map[string]interface{}{"filled_kwh": 1.0})
+		}()
+		wg.Wait()
+
+		if expireErr != nil {
+			t.Fatalf("Expire failed: %v", expireErr)
+		}
+
+		var stored domain.EnergyOrder
+		DB.First(&stored, "id = ?", order.ID)
+		switch {
+		case fillErr == nil && expired == 1:
+			// Fill landed first and the remainder expired
+			if stored.Status != domain.OrderStatusExpired || stored.FilledKwh != 1.0 || stored.Version != 2 {
+				t.Fatalf("Unexpected state after fill then expiry: %+v", stored)
+			}
+		case errors.Is(fillErr, ErrOrderConflict) && expired == 1:
+			if stored.Status != domain.OrderStatusExpired || stored.FilledKwh != 0 {
+				t.Fatalf("Unexpected state after expiry won: %+v", stored)
+			}
+		case fillErr == nil && expired == 0:
+			// Fill landed between the sweep reading the order and expiring it
+			if stored.Status != domain.OrderStatusPartiallyFilled || stored.FilledKwh != 1.0 || stored.Version != 1 {
+				t.Fatalf("Unexpected state after the sweep skipped a filled order: %+v", stored)
+			}
+		default:
+			t.Fatalf("Unexpected outcome: fill=%v expired=%d", fillErr, expired)
+		}
+	}
+}
+
+func TestFilledSellKeepsDeviceReservation(t *testing.T) {
+	connectTestDB(t)
+
+	device := domain.IoTDevice{ID: uuid.New().String(), OwnerID: "reservation_test", DeviceType: "esp32", BatteryLevel: 0.5, CapacityKwh: 10, Status: domain.DeviceStatusRegistered, LastPing: time.Now()}
+	if err := DB.Create(&device).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	order := domain.EnergyOrder{
+		ID:          uuid.New().String(),
+		UserID:      device.OwnerID,
+		DeviceID:    device.ID,
+		Type:        "sell",
+		Market:      domain.MarketContinuous,
+		Kind:        domain.OrderKindLimit,
+		TimeInForce: domain.TimeInForceGTC,
+		KwhAmount:   5,
+		TokenPrice:  4.0,
+		Status:      domain.OrderStatusCreated,
+		CreatedAt:   now,
+		PriorityAt:  now,
+	}
+	t.Cleanup(func() {
+		DB.Delete(&domain.EnergyOrder{}, "device_id = ?", device.ID)
+		DB.Delete(&device)
+	})
+
+	if err := CreateOrder(DB, &order); err != nil {
+		t.Fatalf("CreateOrder failed: %v", err)
+	}
+	if err := TransitionOrder(DB, &order, domain.OrderStatusMatched, map[string]interface{}{"filled_kwh": order.KwhAmount}); err != nil {
+		t.Fatalf("Fill failed: %v", err)
+	}
+
+	// The 5 kWh sold have not left the battery yet, so they cannot be sold again
+	second := order
+	second.ID = uuid.New().String()
+	second.Status, second.FilledKwh, second.Version = domain.OrderStatusCreated, 0, 0
+	if err := CreateOrder(DB, &second); !errors.Is(err, ErrInsufficientEnergy) {
+		t.Fatalf("Expected ErrInsufficientEnergy for a second sell of the same energy, got %v", err)
+	}
+
+	for _, status := range []string{domain.OrderStatusExecuting, domain.OrderStatusCompleted} {
+		if err := TransitionOrder(DB, &order, status, nil); err != nil {
+			t.Fatalf("Moving to %s failed: %v", status, err)
+		}
+	}
+	DB.First(&device, "id = ?", device.ID)
+	if device.ReservedKwh != 0 {
+		t.Errorf("Expected delivery to release the reservation, got %v kWh reserved", device.ReservedKwh)
+	}
+}
@@ -0,0 +1,25 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"gorm.io/gorm"
+)
+
+// IndicativePriceSeries aggregates the final prices in PricingHistory in
+// [from, to) into buckets of the given width, oldest first. Empty buckets are
+// omitted.
+func IndicativePriceSeries(tx *gorm.DB, from, to time.Time, bucket time.Duration) ([]domain.PriceBucket, error) {
+	var buckets []domain.PriceBucket
+	err := tx.Raw(fmt.Sprintf(
+		"SELECT %s AS bucket_start, "+
+			"(array_agg(final_price ORDER BY timestamp, id))[1] AS open, max(final_price) AS high, min(final_price) AS low, "+
+			"(array_agg(final_price ORDER BY timestamp DESC, id DESC))[1] AS close, count(*) AS samples "+
+			"FROM pricing_histories WHERE timestamp >= ? AND timestamp < ? GROUP BY 1 ORDER BY 1",
+		bucketExpr("timestamp", bucket)),
+		from, to).Scan(&buckets).Error
+	return buckets, err
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func TestIndicativePriceSeries(t *testing.T) {
+	connectTestDB(t)
+
+	// A window no live record falls in
+	base := time.Date(2001, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i, price := range []float64{5.0, 6.0, 4.5, 5.5, 7.0} {
+		offset := time.Duration(i) * 20 * time.Second
+		if i == 4 {
+			offset = 10 * time.Minute
+		}
+		if err := DB.Create(&domain.PricingHistory{Timestamp: base.Add(offset), FinalPrice: price}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		DB.Delete(&domain.PricingHistory{}, "timestamp >= ? AND timestamp < ?", base, base.Add(time.Hour))
+	})
+
+	buckets, err := IndicativePriceSeries(DB, base, base.Add(time.Hour), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 3 {
+		t.Fatalf("Expected 3 buckets (empty minutes omitted), got %+v", buckets)
+	}
+	first := buckets[0]
+	if !first.BucketStart.Equal(base) || first.Open != 5.0 || first.High != 6.0 || first.Low != 4.5 || first.Close != 4.5 || first.Samples != 3 {
+		t.Errorf("Unexpected first bucket: %+v", first)
+	}
+	if !buckets[2].BucketStart.Equal(base.Add(10*time.Minute)) || buckets[2].Close != 7.0 {
+		t.Errorf("Unexpected last bucket: %+v", buckets[2])
+	}
+}
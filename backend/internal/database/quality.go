@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecordLockRequest records a lock command sent to a device.
+func RecordLockRequest(tx *gorm.DB, request *domain.DeviceLockRequest) error {
+	request.Status = domain.LockRequestPending
+	return tx.Create(request).Error
+}
+
+// ResolveLockRequest records a device's answer (locked or rejected) to its
+// most recent pending lock command for orderID. It reports false if there
+// was none, e.g. because the command already timed out.
+func ResolveLockRequest(tx *gorm.DB, deviceID, orderID, status string, at time.Time) (bool, error) {
+	pending := tx.Model(&domain.DeviceLockRequest{}).
+		Select("id").
+		Where("device_id = ? AND order_id = ? AND status = ?", deviceID, orderID, domain.LockRequestPending).
+		Order("sent_at DESC").
+		Limit(1)
+	result := tx.Model(&domain.DeviceLockRequest{}).
+		Where("id = (?) AND status = ?", pending, domain.LockRequestPending).
+		Updates(map[string]interface{}{"status": status, "responded_at": at})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ExpireLockRequests marks lock commands still pending since before the
+// cutoff as timed out.
+func ExpireLockRequests(tx *gorm.DB, before time.Time) (int64, error) {
+	result := tx.Model(&domain.DeviceLockRequest{}).
+		Where("status = ? AND sent_at < ?", domain.LockRequestPending, before).
+		Update("status", domain.LockRequestTimedOut)
+	return result.RowsAffected, result.Error
+}
+
+// LockOutcomes counts a device's lock commands sent since the given time by status.
+func LockOutcomes(tx *gorm.DB, deviceID string, since time.Time) (map[string]int, error) {
+	var rows []struct {
+		Status string
+		Count  int
+	}
+	err := tx.Model(&domain.DeviceLockRequest{}).
+		Select("status, count(*) AS count").
+		Where("device_id = ? AND sent_at >= ?", deviceID, since).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	outcomes := make(map[string]int, len(rows))
+	for _, row := range rows {
+		outcomes[row.Status] = row.Count
+	}
+	return outcomes, nil
+}
+
+// VoltageMoments returns how many voltage readings a device reported since
+// the given time, with their mean and the mean of their squares.
+func VoltageMoments(tx *gorm.DB, deviceID string, since time.Time) (samples int, mean, meanSquare float64, err error) {
+	var m struct {
+		Samples    int
+		Mean       sql.NullFloat64
+		MeanSquare sql.NullFloat64
+	}
+	err = tx.Model(&domain.DeviceTelemetry{}).
+		Select("count(voltage_v) AS samples, avg(voltage_v) AS mean, avg(voltage_v * voltage_v) AS mean_square").
+		Where("device_id = ? AND recorded_at >= ?", deviceID, since).
+		Scan(&m).Error
+	return m.Samples, m.Mean.Float64, m.MeanSquare.Float64, err
+}
+
+// BatteryMovement sums a device's SoC increases and energy_in_kwh increases
+// between consecutive samples since the given time (a counter that went
+// backwards was reset and counts from there), and returns its latest
+// energy_out_kwh reading, if any.
+func BatteryMovement(tx *gorm.DB, deviceID string, since time.Time) (socCharged, energyCharged float64, energyOutTotal *float64, err error) {
+	var m struct {
+		SoCCharged    float64 `gorm:"column:soc_charged"`
+		EnergyCharged float64
+	}
+	err = tx.Raw(`SELECT coalesce(sum(greatest(soc - prev_soc, 0)), 0) AS soc_charged,
+		coalesce(sum(greatest(energy_in_kwh - prev_energy_in, 0)), 0) AS energy_charged
+		FROM (SELECT soc, energy_in_kwh,
+			lag(soc) OVER w AS prev_soc,
+			lag(energy_in_kwh) OVER w AS prev_energy_in
+			FROM device_telemetry WHERE device_id = ? AND recorded_at >= ?
+			WINDOW w AS (ORDER BY recorded_at)) steps
+		WHERE soc IS NOT NULL AND prev_soc IS NOT NULL AND energy_in_kwh IS NOT NULL AND prev_energy_in IS NOT NULL`,
+		deviceID, since).Scan(&m).Error
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	var latest []domain.DeviceTelemetry
+	err = tx.Where("device_id = ? AND energy_out_kwh IS NOT NULL", deviceID).Order("recorded_at DESC").Limit(1).Find(&latest).Error
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if len(latest) > 0 {
+		energyOutTotal = latest[0].EnergyOutKwh
+	}
+	return m.SoCCharged, m.EnergyCharged, energyOutTotal, nil
+}
+
+// SaveQualityMetrics creates or replaces a device's quality metrics.
+func SaveQualityMetrics(tx *gorm.DB, metrics *domain.DeviceQualityMetrics) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "device_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"successful_deliveries", "total_deliveries", "delivery_reliability", "voltage_stability",
+			"battery_health_score", "score", "breakdown", "window_start", "last_updated",
+		}),
+	}).Create(metrics).Error
+}
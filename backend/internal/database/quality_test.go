@@ -0,0 +1,43 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestLockRequestOutcomes(t *testing.T) {
+	connectTestDB(t)
+
+	deviceID := "lock_device_" + uuid.New().String()
+	t.Cleanup(func() { DB.Delete(&domain.DeviceLockRequest{}, "device_id = ?", deviceID) })
+
+	now := time.Now()
+	for i, orderID := range []string{"answered", "ignored"} {
+		request := domain.DeviceLockRequest{DeviceID: deviceID, OrderID: orderID, KwhAmount: 1, SentAt: now.Add(time.Duration(i-10) * time.Minute)}
+		if err := RecordLockRequest(DB, &request); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ok, err := ResolveLockRequest(DB, deviceID, "answered", domain.LockRequestLocked, now); err != nil || !ok {
+		t.Fatalf("Expected the pending request to resolve, got %v %v", ok, err)
+	}
+	if ok, _ := ResolveLockRequest(DB, deviceID, "answered", domain.LockRequestRejected, now); ok {
+		t.Error("Expected a second answer to match no pending request")
+	}
+	if _, err := ExpireLockRequests(DB, now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	outcomes, err := LockOutcomes(DB, deviceID, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcomes[domain.LockRequestLocked] != 1 || outcomes[domain.LockRequestTimedOut] != 1 || outcomes[domain.LockRequestPending] != 0 {
+		t.Errorf("Expected one locked and one timed out request, got %v", outcomes)
+	}
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSessionInvalid is returned for an unknown, expired or revoked refresh token.
+	ErrSessionInvalid = errors.New("invalid refresh token")
+	// ErrSessionReused is returned when a refresh token that was already
+	// rotated is presented again. Its whole family has been revoked.
+	ErrSessionReused = errors.New("refresh token reused")
+)
+
+// Session revocation reasons.
+const (
+	RevokeLogout = "logout"
+	RevokeReuse  = "reuse"
+)
+
+// CreateSession starts a new session family for userID with the given token hash.
+func CreateSession(tx *gorm.DB, userID, tokenHash, userAgent, ip string, ttl time.Duration) (domain.Session, error) {
+	now := time.Now()
+	id := uuid.New().String()
+	session := domain.Session{
+		ID:        id,
+		UserID:    userID,
+		FamilyID:  id,
+		TokenHash: tokenHash,
+		UserAgent: userAgent,
+		IP:        ip,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	return session, tx.Create(&session).Error
+}
+
+// RotateSession exchanges the session holding tokenHash for a new one in the
+// same family holding newHash. If the token had already been rotated, the
+// family is revoked and ErrSessionReused returned along with the reused
+// session: either the legitimate client or an attacker holds a stolen token,
+// and we cannot tell which.
+func RotateSession(tx *gorm.DB, tokenHash, newHash, userAgent, ip string, ttl time.Duration) (domain.Session, error) {
+	var next domain.Session
+	var reused *domain.Session
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		var current domain.Session
+		if err := tx.Where("token_hash = ?", tokenHash).First(&current).Error; err != nil {
+			return ErrSessionInvalid
+		}
+		if current.RevokedAt != nil || !time.Now().Before(current.ExpiresAt) {
+			return ErrSessionInvalid
+		}
+		if current.ReplacedBy != "" {
+			reused = &current
+			return ErrSessionReused
+		}
+
+		now := time.Now()
+		next = domain.Session{
+			ID:        uuid.New().String(),
+			UserID:    current.UserID,
+			FamilyID:  current.FamilyID,
+			TokenHash: newHash,
+			UserAgent: userAgent,
+			IP:        ip,
+			CreatedAt: now,
+			ExpiresAt: now.Add(ttl),
+		}
+
+		// Conditional so two concurrent refreshes with one token cannot both rotate it
+		result := tx.Model(&domain.Session{}).Where("id = ? AND replaced_by = ''", current.ID).Update("replaced_by", next.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = &current
+			return ErrSessionReused
+		}
+		return tx.Create(&next).Error
+	})
+
+	// Revoke outside the rolled-back transaction so the revocation sticks
+	if reused != nil {
+		if err := RevokeFamily(tx, reused.UserID, reused.FamilyID, RevokeReuse); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.Session{}, err
+		}
+		return *reused, err
+	}
+	return next, err
+}
+
+// FindSession returns the live session holding tokenHash.
+func FindSession(tx *gorm.DB, tokenHash string) (domain.Session, error) {
+	var session domain.Session
+	err := tx.Where("token_hash = ? AND replaced_by = '' AND revoked_at IS NULL AND expires_at > ?", tokenHash, time.Now()).First(&session).Error
+	if err != nil {
+		return domain.Session{}, ErrSessionInvalid
+	}
+	return session, nil
+}
+
+// SessionFamilyLive reports whether familyID still has an unrevoked,
+// unexpired session, for checking access tokens without the Redis denylist.
+func SessionFamilyLive(tx *gorm.DB, familyID string) (bool, error) {
+	var live int64
+	err := tx.Model(&domain.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL AND expires_at > ?", familyID, time.Now()).
+		Count(&live).Error
+	return live > 0, err
+}
+
+// RevokeFamily revokes every token in one of userID's session families,
+// returning gorm.ErrRecordNotFound if there was nothing left to revoke.
+func RevokeFamily(tx *gorm.DB, userID, familyID, reason string) error {
+	result := tx.Model(&domain.Session{}).
+		Where("user_id = ? AND family_id = ? AND revoked_at IS NULL", userID, familyID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": reason})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeAllSessions revokes every session userID has.
+func RevokeAllSessions(tx *gorm.DB, userID, reason string) error {
+	return tx.Model(&domain.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": reason}).Error
+}
+
+// ActiveSessions lists the current token of each of userID's live session
+// families, most recently refreshed first.
+func ActiveSessions(tx *gorm.DB, userID string) ([]domain.Session, error) {
+	var sessions []domain.Session
+	err := tx.Where("user_id = ? AND replaced_by = '' AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("created_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	connectTestDB(t)
+
+	userID := "session_test_" + uuid.New().String()
+	t.Cleanup(func() { DB.Delete(&domain.Session{}, "user_id = ?", userID) })
+
+	first, err := CreateSession(DB, userID, uuid.New().String(), "test", "127.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	other, err := CreateSession(DB, userID, uuid.New().String(), "other device", "127.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	secondHash := uuid.New().String()
+	second, err := RotateSession(DB, first.TokenHash, secondHash, "test", "127.0.0.1", time.Hour)
+	if err != nil {
+		t.Fatalf("Rotation failed: %v", err)
+	}
+	if second.FamilyID != first.FamilyID {
+		t.Fatalf("Expected the rotated token to stay in family %s, got %s", first.FamilyID, second.FamilyID)
+	}
+
+	// Replaying the first token revokes the family, including the token it was rotated into
+	if _, err := RotateSession(DB, first.TokenHash, uuid.New().String(), "attacker", "10.0.0.1", time.Hour); !errors.Is(err, ErrSessionReused) {
+		t.Fatalf("Expected ErrSessionReused, got %v", err)
+	}
+	if _, err := RotateSession(DB, secondHash, uuid.New().String(), "test", "127.0.0.1", time.Hour); !errors.Is(err, ErrSessionInvalid) {
+		t.Fatalf("Expected the descendant token to be revoked, got %v", err)
+	}
+
+	// Other devices are unaffected
+	sessions, err := ActiveSessions(DB, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].FamilyID != other.FamilyID {
+		t.Errorf("Expected only the other device's session to survive, got %+v", sessions)
+	}
+
+	// Access tokens are checked against the family when Redis is unavailable
+	if live, err := SessionFamilyLive(DB, first.FamilyID); err != nil || live {
+		t.Errorf("Expected the revoked family not to be live, got %v (%v)", live, err)
+	}
+	if live, err := SessionFamilyLive(DB, other.FamilyID); err != nil || !live {
+		t.Errorf("Expected the other family to be live, got %v (%v)", live, err)
+	}
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// lockShadow returns a device's shadow locked for update, creating an empty
+// one first if it has none.
+func lockShadow(tx *gorm.DB, deviceID string) (domain.DeviceShadow, error) {
+	shadow := domain.DeviceShadow{DeviceID: deviceID}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&shadow).Error; err != nil {
+		return shadow, err
+	}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&shadow, "device_id = ?", deviceID).Error
+	return shadow, err
+}
+
+// SetDesiredConfig merges changes into a device's desired configuration and
+// bumps its version.
+func SetDesiredConfig(tx *gorm.DB, deviceID string, changes domain.DeviceConfig, userID string, at time.Time) (*domain.DeviceShadow, error) {
+	var shadow domain.DeviceShadow
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if shadow, err = lockShadow(tx, deviceID); err != nil {
+			return err
+		}
+		shadow.Desired = shadow.Desired.Merge(changes)
+		shadow.DesiredVersion++
+		shadow.DesiredAt = &at
+		shadow.DesiredBy = userID
+		return tx.Model(&shadow).Select("desired", "desired_version", "desired_at", "desired_by").Updates(&shadow).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &shadow, nil
+}
+
+// MarkConfigPublished records that version of a device's desired configuration
+// was published. It is a no-op if desired has changed since.
+func MarkConfigPublished(tx *gorm.DB, deviceID string, version int64, at time.Time) error {
+	return tx.Model(&domain.DeviceShadow{}).
+		Where("device_id = ? AND desired_version = ?", deviceID, version).
+		Update("published_at", at).Error
+}
+
+// RecordReportedConfig stores the configuration a device reports running and
+// the desired version it last applied. Reports from unknown or decommissioned
+// devices return ErrUnknownDevice.
+func RecordReportedConfig(tx *gorm.DB, deviceID string, version int64, config domain.DeviceConfig, at time.Time) (*domain.DeviceShadow, error) {
+	var shadow domain.DeviceShadow
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		var device domain.IoTDevice
+		err := tx.Select("id").Where("id = ? AND status <> ?", deviceID, domain.DeviceStatusDecommissioned).First(&device).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUnknownDevice
+		}
+		if err != nil {
+			return err
+		}
+
+		if shadow, err = lockShadow(tx, deviceID); err != nil {
+			return err
+		}
+		shadow.Reported = config
+		shadow.ReportedVersion = version
+		shadow.ReportedAt = &at
+		return tx.Model(&shadow).Select("reported", "reported_version", "reported_at").Updates(&shadow).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &shadow, nil
+}
+
+// UnappliedShadows returns the shadows of live devices whose desired
+// configuration the device has not applied and that were last published
+// before the cutoff (or never).
+func UnappliedShadows(tx *gorm.DB, publishedBefore time.Time) ([]domain.DeviceShadow, error) {
+	var shadows []domain.DeviceShadow
+	err := tx.Where("desired_version > reported_version AND (published_at IS NULL OR published_at < ?)", publishedBefore).
+		Where("device_id IN (SELECT id FROM iot_devices WHERE status <> ?)", domain.DeviceStatusDecommissioned).
+		Find(&shadows).Error
+	return shadows, err
+}
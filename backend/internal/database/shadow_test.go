@@ -0,0 +1,56 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestDeviceShadowVersions(t *testing.T) {
+	connectTestDB(t)
+
+	device := domain.IoTDevice{ID: uuid.New().String(), OwnerID: "shadow_owner", DeviceType: "esp32", Status: domain.DeviceStatusOnline}
+	if err := DB.Create(&device).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		DB.Delete(&domain.DeviceShadow{}, "device_id = ?", device.ID)
+		DB.Delete(&device)
+	})
+
+	interval, reserve := 60, 0.2
+	if _, err := SetDesiredConfig(DB, device.ID, domain.DeviceConfig{ReportingIntervalSeconds: &interval}, "shadow_owner", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	shadow, err := SetDesiredConfig(DB, device.ID, domain.DeviceConfig{MinReserveSoC: &reserve}, "shadow_owner", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shadow.DesiredVersion != 2 || shadow.Desired.ReportingIntervalSeconds == nil || *shadow.Desired.MinReserveSoC != 0.2 {
+		t.Fatalf("Expected version 2 with both settings, got %+v", shadow)
+	}
+
+	unapplied, err := UnappliedShadows(DB, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, s := range unapplied {
+		found = found || s.DeviceID == device.ID
+	}
+	if !found {
+		t.Error("Expected the unpublished shadow to be listed as unapplied")
+	}
+
+	shadow, err = RecordReportedConfig(DB, device.ID, 2, shadow.Desired, time.Now())
+	if err != nil || !shadow.InSync() {
+		t.Fatalf("Expected the shadow in sync after the device applied version 2, got %+v (%v)", shadow, err)
+	}
+	if _, err := RecordReportedConfig(DB, "no-such-device", 1, domain.DeviceConfig{}, time.Now()); !errors.Is(err, ErrUnknownDevice) {
+		t.Errorf("Expected ErrUnknownDevice, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"gorm.io/gorm"
+)
+
+// ErrSigningKeyRotated is returned by AddSigningKey when the key it was asked
+// to succeed is no longer the newest, i.e. another replica rotated first.
+var ErrSigningKeyRotated = errors.New("signing key already rotated")
+
+// signingKeyLock is the Postgres advisory lock that serialises key rotation
+// across API replicas.
+const signingKeyLock = 4301
+
+// SigningKeys returns every key that can still verify tokens, in activation order.
+func SigningKeys(tx *gorm.DB) ([]domain.SigningKey, error) {
+	var keys []domain.SigningKey
+	err := tx.Where("expires_at IS NULL OR expires_at > ?", time.Now()).Order("activates_at").Find(&keys).Error
+	return keys, err
+}
+
+// AddSigningKey publishes key as the successor of currentID ("" for the very
+// first key). Every older key stops verifying at retireAt, and keys that have
+// already stopped are deleted.
+func AddSigningKey(tx *gorm.DB, key *domain.SigningKey, currentID string, retireAt time.Time) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", signingKeyLock).Error; err != nil {
+			return err
+		}
+
+		var newest domain.SigningKey
+		err := tx.Where("expires_at IS NULL").Order("activates_at DESC").First(&newest).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if newest.ID != currentID {
+			return ErrSigningKeyRotated
+		}
+
+		if err := tx.Model(&domain.SigningKey{}).Where("expires_at IS NULL").Update("expires_at", retireAt).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("expires_at <= ?", time.Now()).Delete(&domain.SigningKey{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(key).Error
+	})
+}
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUnknownDevice is returned for telemetry from a device that is not registered.
+var ErrUnknownDevice = errors.New("device is not registered")
+
+// telemetryMetrics pairs each averaged DeviceTelemetry column with the prefix
+// of its aggregate columns.
+var telemetryMetrics = []struct{ column, prefix string }{
+	{"soc", "soc"},
+	{"voltage_v", "voltage"},
+	{"current_a", "current"},
+	{"temperature_c", "temperature"},
+}
+
+// RecordTelemetry stores a sample from a live device and, if it is the
+// device's newest report, refreshes its battery level and last ping and marks
+// it Online. When the sample has no SoC, storedKwh (if known) is converted to
+// one using the device's capacity. Duplicate samples are ignored.
+func RecordTelemetry(tx *gorm.DB, sample *domain.DeviceTelemetry, storedKwh *float64) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		var device domain.IoTDevice
+		if err := tx.Where("id = ?", sample.DeviceID).First(&device).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUnknownDevice
+			}
+			return err
+		}
+		if device.IsDecommissioned() {
+			return ErrDeviceDecommissioned
+		}
+
+		if sample.SoC == nil && storedKwh != nil && device.CapacityKwh > 0 {
+			soc := *storedKwh / device.CapacityKwh
+			if soc > 1 {
+				soc = 1
+			}
+			sample.SoC = &soc
+		}
+
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(sample).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"last_ping": sample.RecordedAt,
+			"status": gorm.Expr("CASE WHEN status IN ? THEN ? ELSE status END",
+				[]string{domain.DeviceStatusOffline, domain.DeviceStatusRegistered}, domain.DeviceStatusOnline),
+		}
+		if sample.SoC != nil {
+			updates["battery_level"] = *sample.SoC
+		}
+		return tx.Model(&domain.IoTDevice{}).
+			Where("id = ? AND status <> ? AND (last_ping IS NULL OR last_ping < ?)", device.ID, domain.DeviceStatusDecommissioned, sample.RecordedAt).
+			Updates(updates).Error
+	})
+}
+
+// bucketExpr truncates a timestamp column to a multiple of bucket seconds
+// since the Unix epoch, so buckets align to UTC whatever the session time zone.
+func bucketExpr(column string, bucket time.Duration) string {
+	seconds := int64(bucket / time.Second)
+	return fmt.Sprintf("to_timestamp(floor(extract(epoch FROM %s) / %d) * %d)", column, seconds, seconds)
+}
+
+// rawAggregates selects the TelemetryAggregate columns over raw samples.
+func rawAggregates() string {
+	columns := []string{"count(*) AS samples"}
+	for _, m := range telemetryMetrics {
+		columns = append(columns,
+			fmt.Sprintf("avg(%s) AS %s_avg", m.column, m.prefix),
+			fmt.Sprintf("min(%s) AS %s_min", m.column, m.prefix),
+			fmt.Sprintf("max(%s) AS %s_max", m.column, m.prefix))
+	}
+	columns = append(columns, "max(energy_in_kwh) AS energy_in_kwh", "max(energy_out_kwh) AS energy_out_kwh")
+	return strings.Join(columns, ", ")
+}
+
+// rollupAggregates selects the TelemetryAggregate columns over hourly rollups,
+// weighting each hour's average by its sample count.
+func rollupAggregates() string {
+	columns := []string{"sum(samples) AS samples"}
+	for _, m := range telemetryMetrics {
+		columns = append(columns,
+			fmt.Sprintf("sum(%[1]s_avg * samples) / nullif(sum(CASE WHEN %[1]s_avg IS NOT NULL THEN samples END), 0) AS %[1]s_avg", m.prefix),
+			fmt.Sprintf("min(%[1]s_min) AS %[1]s_min", m.prefix),
+			fmt.Sprintf("max(%[1]s_max) AS %[1]s_max", m.prefix))
+	}
+	columns = append(columns, "max(energy_in_kwh) AS energy_in_kwh", "max(energy_out_kwh) AS energy_out_kwh")
+	return strings.Join(columns, ", ")
+}
+
+// TelemetrySeries aggregates a device's raw samples in [from, to) into buckets
+// of the given width, oldest first. Empty buckets are omitted.
+func TelemetrySeries(tx *gorm.DB, deviceID string, from, to time.Time, bucket time.Duration) ([]domain.TelemetryBucket, error) {
+	var buckets []domain.TelemetryBucket
+	err := tx.Raw(fmt.Sprintf(
+		"SELECT %s AS bucket_start, %s FROM device_telemetry WHERE device_id = ? AND recorded_at >= ? AND recorded_at < ? GROUP BY 1 ORDER BY 1",
+		bucketExpr("recorded_at", bucket), rawAggregates()),
+		deviceID, from, to).Scan(&buckets).Error
+	return buckets, err
+}
+
+// TelemetryRollupSeries is TelemetrySeries over the hourly rollups. The bucket
+// width must be a whole number of hours.
+func TelemetryRollupSeries(tx *gorm.DB, deviceID string, from, to time.Time, bucket time.Duration) ([]domain.TelemetryBucket, error) {
+	var buckets []domain.TelemetryBucket
+	err := tx.Raw(fmt.Sprintf(
+		"SELECT %s AS bucket_start, %s FROM device_telemetry_hourly WHERE device_id = ? AND bucket_start >= ? AND bucket_start < ? GROUP BY 1 ORDER BY 1",
+		bucketExpr("bucket_start", bucket), rollupAggregates()),
+		deviceID, from, to).Scan(&buckets).Error
+	return buckets, err
+}
+
+// TelemetryRolledUntil returns the end of the newest hourly rollup of a
+// device, or the zero time if it has none. Raw samples from then on have not
+// been downsampled yet.
+func TelemetryRolledUntil(tx *gorm.DB, deviceID string) (time.Time, error) {
+	var newest sql.NullTime
+	if err := tx.Model(&domain.DeviceTelemetryHourly{}).Where("device_id = ?", deviceID).Select("max(bucket_start)").Row().Scan(&newest); err != nil {
+		return time.Time{}, err
+	}
+	if !newest.Valid {
+		return time.Time{}, nil
+	}
+	return newest.Time.Add(time.Hour), nil
+}
+
+// LatestTelemetryRollup returns the start of the newest hourly rollup of any
+// device, or the zero time if there are none.
+func LatestTelemetryRollup(tx *gorm.DB) (time.Time, error) {
+	var newest sql.NullTime
+	if err := tx.Model(&domain.DeviceTelemetryHourly{}).Select("max(bucket_start)").Row().Scan(&newest); err != nil {
+		return time.Time{}, err
+	}
+	return newest.Time, nil
+}
+
+// RollupTelemetry (re)computes the hourly rollups of the raw samples in
+// [since, until). Both bounds should be whole hours; recomputing an hour
+// replaces its rollup, so late samples are picked up by the next run that
+// covers their hour.
+func RollupTelemetry(tx *gorm.DB, since, until time.Time) (int64, error) {
+	columns := []string{"samples"}
+	for _, m := range telemetryMetrics {
+		columns = append(columns, m.prefix+"_avg", m.prefix+"_min", m.prefix+"_max")
+	}
+	columns = append(columns, "energy_in_kwh", "energy_out_kwh")
+
+	assignments := make([]string, len(columns))
+	for i, column := range columns {
+		assignments[i] = fmt.Sprintf("%[1]s = EXCLUDED.%[1]s", column)
+	}
+
+	result := tx.Exec(fmt.Sprintf(
+		"INSERT INTO device_telemetry_hourly (device_id, bucket_start, %s) "+
+			"SELECT device_id, %s, %s FROM device_telemetry WHERE recorded_at >= ? AND recorded_at < ? GROUP BY 1, 2 "+
+			"ON CONFLICT (device_id, bucket_start) DO UPDATE SET %s",
+		strings.Join(columns, ", "), bucketExpr("recorded_at", time.Hour), rawAggregates(), strings.Join(assignments, ", ")),
+		since, until)
+	return result.RowsAffected, result.Error
+}
+
+// PruneTelemetry deletes raw samples recorded before rawBefore and hourly
+// rollups that start before rollupBefore.
+func PruneTelemetry(tx *gorm.DB, rawBefore, rollupBefore time.Time) (raw int64, rollups int64, err error) {
+	result := tx.Where("recorded_at < ?", rawBefore).Delete(&domain.DeviceTelemetry{})
+	if result.Error != nil {
+		return 0, 0, result.Error
+	}
+	raw = result.RowsAffected
+
+	result = tx.Where("bucket_start < ?", rollupBefore).Delete(&domain.DeviceTelemetryHourly{})
+	if result.Error != nil {
+		return raw, 0, result.Error
+	}
+	return raw, result.RowsAffected, nil
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestTelemetryRollupMatchesRawSeries(t *testing.T) {
+	connectTestDB(t)
+
+	device := domain.IoTDevice{ID: uuid.New().String(), OwnerID: "telemetry_owner", DeviceType: "esp32", CapacityKwh: 10, Status: domain.DeviceStatusRegistered}
+	if err := DB.Create(&device).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		DB.Delete(&domain.DeviceTelemetry{}, "device_id = ?", device.ID)
+		DB.Delete(&domain.DeviceTelemetryHourly{}, "device_id = ?", device.ID)
+		DB.Delete(&device)
+	})
+
+	hour := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+	for i, stored := range []float64{2, 4, 6} {
+		sample := domain.DeviceTelemetry{DeviceID: device.ID, RecordedAt: hour.Add(time.Duration(i) * 10 * time.Minute)}
+		if err := RecordTelemetry(DB, &sample, &stored); err != nil {
+			t.Fatalf("RecordTelemetry failed: %v", err)
+		}
+	}
+	if err := RecordTelemetry(DB, &domain.DeviceTelemetry{DeviceID: "no-such-device", RecordedAt: hour}, nil); !errors.Is(err, ErrUnknownDevice) {
+		t.Errorf("Expected ErrUnknownDevice, got %v", err)
+	}
+
+	DB.First(&device, "id = ?", device.ID)
+	if device.Status != domain.DeviceStatusOnline || device.BatteryLevel != 0.6 {
+		t.Errorf("Expected the device Online at 60%%, got %s at %g", device.Status, device.BatteryLevel)
+	}
+
+	if _, err := RollupTelemetry(DB, hour, hour.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := TelemetrySeries(DB, device.ID, hour, hour.Add(time.Hour), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rolled, err := TelemetryRollupSeries(DB, device.ID, hour, hour.Add(time.Hour), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 1 || len(rolled) != 1 || raw[0].Samples != 3 || rolled[0].Samples != 3 {
+		t.Fatalf("Expected one bucket of 3 samples from both, got %+v and %+v", raw, rolled)
+	}
+	if *rolled[0].SoCAvg < 0.39 || *rolled[0].SoCAvg > 0.41 || *rolled[0].SoCMax != 0.6 {
+		t.Errorf("Expected an average SoC of 0.4 peaking at 0.6, got %+v", rolled[0].TelemetryAggregate)
+	}
+
+	until, err := TelemetryRolledUntil(DB, device.ID)
+	if err != nil || !until.Equal(hour.Add(time.Hour)) {
+		t.Errorf("Expected the rollups to reach %s, got %s (%v)", hour.Add(time.Hour), until, err)
+	}
+}
@@ -0,0 +1,119 @@
+package feed
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is a message pushed to WebSocket subscribers.
+type Event struct {
+	Type      string      `json:"type"`
+	Data      interface{} `json:"data"`
+	Timestamp string      `json:"timestamp"`
+}
+
+// Hub fans events out to any number of subscribers. Slow subscribers drop
+// events rather than block publishers such as the matching engine.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+// Market carries public market events (prices, circuit breaker state, trades).
+var Market = NewHub()
+
+// NewHub creates an empty hub.
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[chan Event]struct{})}
+}
+
+// Subscribe registers a new subscriber. The returned function must be called to
+// unsubscribe, after which the channel is closed.
+func (h *Hub) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, 16)
+
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers, ch)
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish sends an event of the given type to every subscriber.
+func (h *Hub) Publish(eventType string, data interface{}) {
+	event := Event{
+		Type:      eventType,
+		Data:      data,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// UserHubs keeps one hub per user for private events, created on first
+// subscription and dropped when its last subscriber leaves.
+type UserHubs struct {
+	mu   sync.Mutex
+	hubs map[string]*Hub
+}
+
+// Users carries events private to a user, such as their devices' alerts.
+var Users = NewUserHubs()
+
+// NewUserHubs creates an empty set of per-user hubs.
+func NewUserHubs() *UserHubs {
+	return &UserHubs{hubs: make(map[string]*Hub)}
+}
+
+// Subscribe registers a subscriber to userID's events. The returned function
+// must be called to unsubscribe.
+func (u *UserHubs) Subscribe(userID string) (<-chan Event, func()) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	hub, ok := u.hubs[userID]
+	if !ok {
+		hub = NewHub()
+		u.hubs[userID] = hub
+	}
+	ch, unsubscribe := hub.Subscribe()
+
+	return ch, func() {
+		unsubscribe()
+
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		hub.mu.RLock()
+		empty := len(hub.subscribers) == 0
+		hub.mu.RUnlock()
+		if empty && u.hubs[userID] == hub {
+			delete(u.hubs, userID)
+		}
+	}
+}
+
+// Publish sends an event to userID's subscribers, if any.
+func (u *UserHubs) Publish(userID, eventType string, data interface{}) {
+	u.mu.Lock()
+	hub, ok := u.hubs[userID]
+	u.mu.Unlock()
+
+	if ok {
+		hub.Publish(eventType, data)
+	}
+}
@@ -0,0 +1,81 @@
+package fees
+
+import (
+	"math"
+
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/pricing"
+)
+
+// Breakdown is the fees charged on one fill.
+type Breakdown struct {
+	BuyerFee    float64
+	SellerFee   float64
+	PlatformFee float64
+	// NodeFees holds each routing node's share; Amount and NodeID are set.
+	NodeFees []domain.NodeFee
+}
+
+// Total is everything charged on the fill.
+func (b Breakdown) Total() float64 {
+	return b.BuyerFee + b.SellerFee
+}
+
+// NodeTotal is the part of the fees passed on to nodes.
+func (b Breakdown) NodeTotal() float64 {
+	total := 0.0
+	for _, n := range b.NodeFees {
+		total += n.Amount
+	}
+	return total
+}
+
+// Charge computes the fees on a fill of notional tokens. The buyer's rate is
+// capped at buyReservedRate, the rate their order reserved when it was placed,
+// so the fee is always covered. The node share is split equally across route;
+// with no route the platform keeps the whole fee.
+func (s Schedule) Charge(notional float64, buyIsMaker, auction bool, buyReservedRate float64, route []domain.NetworkNode) Breakdown {
+	buyRate, sellRate := s.Rates(buyIsMaker, auction)
+	buyRate = math.Min(buyRate, buyReservedRate)
+
+	b := Breakdown{
+		BuyerFee:  notional * buyRate,
+		SellerFee: notional * sellRate,
+	}
+	b.PlatformFee = b.Total()
+
+	if len(route) == 0 || s.NodeShare <= 0 {
+		return b
+	}
+	share := b.Total() * s.NodeShare / float64(len(route))
+	for _, node := range route {
+		b.NodeFees = append(b.NodeFees, domain.NodeFee{NodeID: node.ID, OperatorID: node.OperatorID, Amount: share})
+	}
+	b.PlatformFee = b.Total() - b.NodeTotal()
+	return b
+}
+
+// Route returns the nodes assumed to have relayed the MQTT traffic between a
+// donor at from and a recipient at to ("lat,lng"). Nodes do not report which
+// messages they forward, so a node counts as on the route if going through it
+// is at most detourKm longer than the direct path. Nodes or endpoints without
+// a usable location are never on a route.
+func Route(nodes []domain.NetworkNode, from, to string, detourKm float64) []domain.NetworkNode {
+	direct, ok := pricing.DistanceKm(from, to)
+	if !ok {
+		return nil
+	}
+
+	var route []domain.NetworkNode
+	for _, node := range nodes {
+		toNode, ok := pricing.DistanceKm(from, node.Location)
+		if !ok {
+			continue
+		}
+		fromNode, _ := pricing.DistanceKm(node.Location, to)
+		if toNode+fromNode <= direct+detourKm {
+			route = append(route, node)
+		}
+	}
+	return route
+}
@@ -0,0 +1,74 @@
+package fees
+
+import (
+	"math"
+	"testing"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func approx(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestRates(t *testing.T) {
+	flat := Schedule{Model: ModelFlat, Rate: 0.002}
+	if b, s := flat.Rates(true, false); b != 0.002 || s != 0.002 {
+		t.Errorf("Expected the flat rate on both sides, got %v/%v", b, s)
+	}
+
+	mt := Schedule{Model: ModelMakerTaker, MakerRate: 0.001, TakerRate: 0.003}
+	if b, s := mt.Rates(true, false); b != 0.001 || s != 0.003 {
+		t.Errorf("Expected a resting buy to pay the maker rate, got %v/%v", b, s)
+	}
+	if b, s := mt.Rates(false, false); b != 0.003 || s != 0.001 {
+		t.Errorf("Expected an incoming buy to pay the taker rate, got %v/%v", b, s)
+	}
+	if b, s := mt.Rates(false, true); b != 0.001 || s != 0.001 {
+		t.Errorf("Expected auction fills to pay the maker rate, got %v/%v", b, s)
+	}
+	if mt.MaxRate() != 0.003 || flat.MaxRate() != 0.002 {
+		t.Errorf("Unexpected max rates %v, %v", mt.MaxRate(), flat.MaxRate())
+	}
+}
+
+func TestCharge(t *testing.T) {
+	s := Schedule{Model: ModelFlat, Rate: 0.01, NodeShare: 0.5}
+	route := []domain.NetworkNode{{ID: "n1", OperatorID: "op1"}, {ID: "n2", OperatorID: "op2"}}
+
+	b := s.Charge(100, false, false, 0.01, route)
+	if !approx(b.BuyerFee, 1) || !approx(b.SellerFee, 1) {
+		t.Fatalf("Expected 1 token from each side, got %+v", b)
+	}
+	if !approx(b.PlatformFee, 1) || len(b.NodeFees) != 2 || !approx(b.NodeFees[0].Amount, 0.5) || b.NodeFees[1].OperatorID != "op2" {
+		t.Errorf("Expected half the fees split across both nodes, got %+v", b)
+	}
+	if !approx(b.PlatformFee+b.NodeTotal(), b.Total()) {
+		t.Errorf("Expected the split to account for every fee, got %+v", b)
+	}
+
+	// Without a route the platform keeps everything
+	if b := s.Charge(100, false, false, 0.01, nil); !approx(b.PlatformFee, 2) || len(b.NodeFees) != 0 {
+		t.Errorf("Expected the platform to keep the fee, got %+v", b)
+	}
+
+	// A buyer is never charged more than their order reserved
+	if b := s.Charge(100, false, false, 0.004, nil); !approx(b.BuyerFee, 0.4) || !approx(b.SellerFee, 1) {
+		t.Errorf("Expected the buyer fee to be capped, got %+v", b)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	donor, recipient := "28.6139,77.2090", "28.6200,77.2150"
+	nodes := []domain.NetworkNode{
+		{ID: "between", Location: "28.6150,77.2100"},
+		{ID: "far", Location: "28.7041,77.1025"},
+		{ID: "unknown", Location: ""},
+	}
+
+	route := Route(nodes, donor, recipient, 1)
+	if len(route) != 1 || route[0].ID != "between" {
+		t.Errorf("Expected only the node between the parties, got %+v", route)
+	}
+	if route := Route(nodes, "", recipient, 1); route != nil {
+		t.Errorf("Expected no route without a donor location, got %+v", route)
+	}
+}
@@ -0,0 +1,69 @@
+// Package fees computes the trading fees charged at match time and how they
+// are shared between the platform and the mesh nodes that carried the trade.
+package fees
+
+import (
+	"los-tecnicos/backend/internal/config"
+)
+
+// Fee models.
+const (
+	ModelFlat       = "flat"        // Both sides pay Rate
+	ModelMakerTaker = "maker_taker" // The resting order pays MakerRate, the incoming one TakerRate
+)
+
+// Schedule is the fee configuration. Rates are fractions of a fill's notional
+// (kWh x price). Buyers pay their fee on top of the notional; sellers have it
+// deducted from their proceeds.
+type Schedule struct {
+	Model     string
+	Rate      float64
+	MakerRate float64
+	TakerRate float64
+	// NodeShare is the fraction of every fee passed on to the mesh nodes
+	// that routed the trade; the rest goes to the platform.
+	NodeShare float64
+}
+
+// Current is the schedule loaded from the environment at startup.
+var Current = LoadSchedule()
+
+// LoadSchedule reads the fee schedule from FEE_MODEL, FEE_RATE,
+// FEE_MAKER_RATE, FEE_TAKER_RATE and FEE_NODE_SHARE.
+func LoadSchedule() Schedule {
+	return Schedule{
+		Model:     config.GetEnv("FEE_MODEL", ModelFlat),
+		Rate:      config.GetEnvAsFloat("FEE_RATE", 0.002),
+		MakerRate: config.GetEnvAsFloat("FEE_MAKER_RATE", 0.001),
+		TakerRate: config.GetEnvAsFloat("FEE_TAKER_RATE", 0.003),
+		NodeShare: config.GetEnvAsFloat("FEE_NODE_SHARE", 0.3),
+	}
+}
+
+// Rates returns the buyer's and seller's fee rates for a fill. In a
+// uniform-price auction neither side takes liquidity from the other, so
+// under maker/taker both pay the maker rate.
+func (s Schedule) Rates(buyIsMaker, auction bool) (buyRate, sellRate float64) {
+	if s.Model != ModelMakerTaker {
+		return s.Rate, s.Rate
+	}
+	if auction {
+		return s.MakerRate, s.MakerRate
+	}
+	if buyIsMaker {
+		return s.MakerRate, s.TakerRate
+	}
+	return s.TakerRate, s.MakerRate
+}
+
+// MaxRate is the highest rate either side can be charged. Buy orders reserve
+// their fee at this rate when placed.
+func (s Schedule) MaxRate() float64 {
+	if s.Model != ModelMakerTaker {
+		return s.Rate
+	}
+	if s.MakerRate > s.TakerRate {
+		return s.MakerRate
+	}
+	return s.TakerRate
+}
@@ -0,0 +1,205 @@
+package forecast
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+)
+
+const (
+	// TrainingWindow is how much PricingHistory the model learns from.
+	TrainingWindow = 28 * 24 * time.Hour
+	// RefitInterval is how often the model is refitted in the background.
+	RefitInterval = 15 * time.Minute
+	// MinSamples is the number of observations a seasonal bucket needs before it is trusted.
+	MinSamples = 3
+)
+
+// Basis names the profile a prediction was drawn from.
+const (
+	BasisWeekly  = "weekly"  // Same hour on the same weekday
+	BasisDaily   = "daily"   // Same hour on any day
+	BasisOverall = "overall" // Mean of the whole training window
+)
+
+// Point is the predicted community state at a given time.
+type Point struct {
+	Timestamp   time.Time `json:"timestamp"`
+	GridSoC     float64   `json:"grid_soc"`
+	TotalSupply float64   `json:"total_supply"`
+	TotalDemand float64   `json:"total_demand"`
+	Basis       string    `json:"basis"`
+}
+
+type bucket struct {
+	soc, supply, demand float64
+	n                   int
+}
+
+func (b *bucket) add(soc, supply, demand float64, n int) {
+	b.soc += soc
+	b.supply += supply
+	b.demand += demand
+	b.n += n
+}
+
+func (b *bucket) mean() (float64, float64, float64) {
+	n := float64(b.n)
+	return b.soc / n, b.supply / n, b.demand / n
+}
+
+// Model holds seasonal averages of community SoC and order flow learned from
+// PricingHistory. Predictions use the weekly profile where it has enough data,
+// falling back to the daily profile and then the overall mean. Profiles are
+// keyed by UTC weekday and hour, matching the aggregation in SQL.
+type Model struct {
+	weekly   [7 * 24]bucket
+	daily    [24]bucket
+	overall  bucket
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Samples  int       `json:"samples"`
+	FittedAt time.Time `json:"fitted_at"`
+}
+
+// add folds n observations from one UTC weekday and hour, with the given
+// sums and time range, into the model.
+func (m *Model) add(weekday time.Weekday, hour int, soc, supply, demand float64, n int, first, last time.Time) {
+	m.weekly[int(weekday)*24+hour].add(soc, supply, demand, n)
+	m.daily[hour].add(soc, supply, demand, n)
+	m.overall.add(soc, supply, demand, n)
+
+	if m.From.IsZero() || first.Before(m.From) {
+		m.From = first
+	}
+	if last.After(m.To) {
+		m.To = last
+	}
+	m.Samples = m.overall.n
+}
+
+// Fit builds a model from history records.
+func Fit(history []domain.PricingHistory) *Model {
+	m := &Model{FittedAt: time.Now()}
+	for _, h := range history {
+		t := h.Timestamp.UTC()
+		m.add(t.Weekday(), t.Hour(), h.GridSoC, h.TotalSupply, h.TotalDemand, 1, h.Timestamp, h.Timestamp)
+	}
+	return m
+}
+
+// hourlyTotals is PricingHistory summed over one UTC weekday and hour.
+type hourlyTotals struct {
+	DayOfWeek int       `gorm:"column:day_of_week"`
+	HourOfDay int       `gorm:"column:hour_of_day"`
+	SoC       float64   `gorm:"column:soc"`
+	Supply    float64   `gorm:"column:supply"`
+	Demand    float64   `gorm:"column:demand"`
+	Samples   int       `gorm:"column:samples"`
+	First     time.Time `gorm:"column:first"`
+	Last      time.Time `gorm:"column:last"`
+}
+
+// fitTotals builds a model from PricingHistory already summed per weekday and hour.
+func fitTotals(rows []hourlyTotals) *Model {
+	m := &Model{FittedAt: time.Now()}
+	for _, r := range rows {
+		m.add(time.Weekday(r.DayOfWeek), r.HourOfDay, r.SoC, r.Supply, r.Demand, r.Samples, r.First, r.Last)
+	}
+	return m
+}
+
+// Predict returns the expected state at t. ok is false if the model has no data.
+func (m *Model) Predict(t time.Time) (Point, bool) {
+	if m == nil || m.overall.n == 0 {
+		return Point{}, false
+	}
+
+	utc := t.UTC()
+	b, basis := &m.overall, BasisOverall
+	if w := &m.weekly[int(utc.Weekday())*24+utc.Hour()]; w.n >= MinSamples {
+		b, basis = w, BasisWeekly
+	} else if d := &m.daily[utc.Hour()]; d.n >= MinSamples {
+		b, basis = d, BasisDaily
+	}
+
+	soc, supply, demand := b.mean()
+	return Point{Timestamp: t, GridSoC: soc, TotalSupply: supply, TotalDemand: demand, Basis: basis}, true
+}
+
+// Forecast predicts hourly points for the next hours starting at the top of the hour after from.
+func (m *Model) Forecast(from time.Time, hours int) []Point {
+	points := []Point{}
+	start := from.Truncate(time.Hour).Add(time.Hour)
+	for i := 0; i < hours; i++ {
+		if p, ok := m.Predict(start.Add(time.Duration(i) * time.Hour)); ok {
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+// MinSoC returns the lowest predicted community SoC between from and from+horizon.
+func (m *Model) MinSoC(from time.Time, horizon time.Duration) (float64, bool) {
+	points := m.Forecast(from, int(horizon/time.Hour))
+	if len(points) == 0 {
+		return 0, false
+	}
+	min := points[0].GridSoC
+	for _, p := range points[1:] {
+		if p.GridSoC < min {
+			min = p.GridSoC
+		}
+	}
+	return min, true
+}
+
+// current is the last fitted model, replaced by RunRefit.
+var current atomic.Pointer[Model]
+
+// Current returns the last fitted model, or an empty one before the first fit.
+// It never touches the database, so it is safe on the pricing path.
+func Current() *Model {
+	if m := current.Load(); m != nil {
+		return m
+	}
+	return Fit(nil)
+}
+
+// RunRefit starts a background process that refits the model from the
+// training window every RefitInterval.
+func RunRefit() {
+	log.Println("Starting forecast refits...")
+	ticker := time.NewTicker(RefitInterval)
+
+	for ; ; <-ticker.C {
+		if err := refit(time.Now()); err != nil {
+			log.Printf("Forecast: failed to refit: %v", err)
+		}
+	}
+}
+
+// refit sums the PricingHistory in the training window per UTC weekday and
+// hour in SQL, then fits and publishes a model from the totals.
+func refit(now time.Time) error {
+	var rows []hourlyTotals
+	err := database.DB.Model(&domain.PricingHistory{}).
+		Select(`extract(dow FROM timestamp AT TIME ZONE 'UTC')::int AS day_of_week,
+			extract(hour FROM timestamp AT TIME ZONE 'UTC')::int AS hour_of_day,
+			sum(grid_soc) AS soc, sum(total_supply) AS supply, sum(total_demand) AS demand,
+			count(*) AS samples, min(timestamp) AS first, max(timestamp) AS last`).
+		Where("timestamp >= ?", now.Add(-TrainingWindow)).
+		Group("day_of_week, hour_of_day").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	model := fitTotals(rows)
+	current.Store(model)
+	log.Printf("Forecast: refitted on %d pricing history records", model.Samples)
+	return nil
+}
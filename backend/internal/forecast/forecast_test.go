@@ -0,0 +1,117 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+// syntheticHistory produces weeks of hourly samples where batteries are full at
+// midday and drain overnight, with extra demand on Mondays at 19:00.
+func syntheticHistory(weeks int) []domain.PricingHistory {
+	start := time.Date(2026, 1, 5, 0, 0, 0, 0, time.Local) // A Monday
+	var history []domain.PricingHistory
+	for h := 0; h < weeks*7*24; h++ {
+		ts := start.Add(time.Duration(h) * time.Hour)
+		soc := 0.5 + 0.4*math.Cos(float64(ts.Hour()-12)*math.Pi/12)
+		demand := 4.0
+		if ts.Weekday() == time.Monday && ts.Hour() == 19 {
+			demand = 10.0
+		}
+		history = append(history, domain.PricingHistory{Timestamp: ts, GridSoC: soc, TotalSupply: 4, TotalDemand: demand})
+	}
+	return history
+}
+
+func TestPredictUsesSeasonalProfiles(t *testing.T) {
+	model := Fit(syntheticHistory(4))
+
+	if model.Samples != 4*7*24 {
+		t.Fatalf("Expected %d samples, got %d", 4*7*24, model.Samples)
+	}
+
+	monday := time.Date(2026, 3, 2, 19, 0, 0, 0, time.Local)
+	p, ok := model.Predict(monday)
+	if !ok {
+		t.Fatal("Expected a prediction")
+	}
+	if p.Basis != BasisWeekly || p.TotalDemand != 10.0 {
+		t.Errorf("Expected weekly Monday 19:00 demand of 10, got %+v", p)
+	}
+
+	noon, _ := model.Predict(time.Date(2026, 3, 3, 12, 0, 0, 0, time.Local))
+	midnight, _ := model.Predict(time.Date(2026, 3, 3, 0, 0, 0, 0, time.Local))
+	if noon.GridSoC <= midnight.GridSoC {
+		t.Errorf("Expected higher SoC at noon (%f) than midnight (%f)", noon.GridSoC, midnight.GridSoC)
+	}
+}
+
+func TestPredictFallsBackToDailyProfile(t *testing.T) {
+	// Three days only: each weekday bucket has one sample, each hour-of-day three
+	model := Fit(syntheticHistory(4)[:3*24])
+
+	p, ok := model.Predict(time.Date(2026, 3, 6, 12, 0, 0, 0, time.Local)) // A Friday
+	if !ok {
+		t.Fatal("Expected a prediction")
+	}
+	if p.Basis != BasisDaily {
+		t.Errorf("Expected daily basis, got %s", p.Basis)
+	}
+}
+
+func TestMinSoCOverHorizon(t *testing.T) {
+	model := Fit(syntheticHistory(2))
+
+	// From 18:00 the next six hours run through the evening drain
+	minSoC, ok := model.MinSoC(time.Date(2026, 3, 3, 18, 0, 0, 0, time.Local), 6*time.Hour)
+	if !ok {
+		t.Fatal("Expected a forecast")
+	}
+	if math.Abs(minSoC-0.1) > 1e-9 {
+		t.Errorf("Expected midnight minimum of 0.1, got %f", minSoC)
+	}
+
+	if _, ok := Fit(nil).MinSoC(time.Now(), 6*time.Hour); ok {
+		t.Error("Expected no forecast from an empty model")
+	}
+}
+
+func TestFitTotalsMatchesFit(t *testing.T) {
+	history := syntheticHistory(2)
+
+	// Sum per UTC weekday and hour, as the refit query does
+	totals := map[[2]int]*hourlyTotals{}
+	for _, h := range history {
+		ts := h.Timestamp.UTC()
+		key := [2]int{int(ts.Weekday()), ts.Hour()}
+		row, ok := totals[key]
+		if !ok {
+			row = &hourlyTotals{DayOfWeek: key[0], HourOfDay: key[1], First: h.Timestamp}
+			totals[key] = row
+		}
+		row.SoC += h.GridSoC
+		row.Supply += h.TotalSupply
+		row.Demand += h.TotalDemand
+		row.Samples++
+		row.Last = h.Timestamp
+	}
+	var rows []hourlyTotals
+	for _, row := range totals {
+		rows = append(rows, *row)
+	}
+
+	want, got := Fit(history), fitTotals(rows)
+	if got.Samples != want.Samples || !got.From.Equal(want.From) || !got.To.Equal(want.To) {
+		t.Fatalf("Expected %d samples from %s to %s, got %d from %s to %s", want.Samples, want.From, want.To, got.Samples, got.From, got.To)
+	}
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local)
+	gotPoints := got.Forecast(from, 7*24)
+	for i, p := range want.Forecast(from, 7*24) {
+		q := gotPoints[i]
+		if math.Abs(p.GridSoC-q.GridSoC) > 1e-9 || math.Abs(p.TotalDemand-q.TotalDemand) > 1e-9 || p.Basis != q.Basis {
+			t.Fatalf("Predictions differ at %s: %+v vs %+v", p.Timestamp, p, q)
+		}
+	}
+}
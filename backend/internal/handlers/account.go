@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/ledger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BalancesResponse is returned by /account/balances.
+type BalancesResponse struct {
+	UserID    string                       `json:"user_id"`
+	Available float64                      `json:"available"`
+	Reserved  float64                      `json:"reserved"`
+	Yield     float64                      `json:"yield"`
+	Fees      float64                      `json:"fees"`
+	Total     float64                      `json:"total"`
+	OnChain   *domain.LedgerReconciliation `json:"on_chain,omitempty"` // Latest reconciliation, if one has run
+}
+
+// StatementLine is a ledger entry with the account balance after it.
+type StatementLine struct {
+	domain.LedgerEntry
+	Balance float64 `json:"balance"`
+}
+
+// StatementResponse is returned by /account/statement.
+type StatementResponse struct {
+	Account        string          `json:"account"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningBalance float64         `json:"opening_balance"`
+	ClosingBalance float64         `json:"closing_balance"` // After the last line returned
+	Lines          []StatementLine `json:"lines"`
+}
+
+// GetAccountBalances returns the authenticated user's ledger balances.
+func GetAccountBalances(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	userIDStr := userID.(string)
+
+	balances, err := database.LedgerBalances(database.DB, userIDStr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve balances"})
+		return
+	}
+
+	resp := BalancesResponse{
+		UserID:    userIDStr,
+		Available: balances[domain.LedgerAvailable],
+		Reserved:  balances[domain.LedgerReserved],
+		Yield:     balances[domain.LedgerYield],
+		Fees:      balances[domain.LedgerFees],
+		Total:     ledger.Holdings(balances),
+	}
+	var reconciliation domain.LedgerReconciliation
+	if database.DB.First(&reconciliation, "user_id = ?", userIDStr).Error == nil {
+		resp.OnChain = &reconciliation
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetAccountStatement lists the entries posted to one of the authenticated
+// user's accounts in a time range, with running balances.
+func GetAccountStatement(c *gin.Context) {
+	var req AccountStatementRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+	userIDStr := userID.(string)
+
+	account := req.Account
+	if account == "" {
+		account = domain.LedgerAvailable
+	}
+	to := req.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := req.From
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -30)
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'from' must be before 'to'"})
+		return
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = 100
+	}
+
+	opening, err := database.LedgerBalanceAt(database.DB, userIDStr, account, from)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statement"})
+		return
+	}
+	entries, err := database.LedgerEntries(database.DB, userIDStr, account, from, to, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statement"})
+		return
+	}
+
+	resp := StatementResponse{
+		Account:        account,
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		Lines:          statementLines(opening, entries),
+	}
+	resp.ClosingBalance = opening
+	if n := len(resp.Lines); n > 0 {
+		resp.ClosingBalance = resp.Lines[n-1].Balance
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// statementLines pairs each entry with the running balance after it.
+func statementLines(opening float64, entries []domain.LedgerEntry) []StatementLine {
+	lines := make([]StatementLine, 0, len(entries))
+	balance := opening
+	for _, entry := range entries {
+		balance += entry.Amount
+		lines = append(lines, StatementLine{LedgerEntry: entry, Balance: balance})
+	}
+	return lines
+}
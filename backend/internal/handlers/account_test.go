@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"testing"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func TestStatementLines(t *testing.T) {
+	entries := []domain.LedgerEntry{
+		{Kind: domain.JournalReserve, Amount: -20},
+		{Kind: domain.JournalRelease, Amount: 8},
+		{Kind: domain.JournalTrade, Amount: -10},
+	}
+	lines := statementLines(100, entries)
+	want := []float64{80, 88, 78}
+	if len(lines) != len(want) {
+		t.Fatalf("Expected %d lines, got %d", len(want), len(lines))
+	}
+	for i, line := range lines {
+		if line.Balance != want[i] {
+			t.Errorf("Line %d: expected balance %v, got %v", i, want[i], line.Balance)
+		}
+	}
+}
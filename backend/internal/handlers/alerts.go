@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"los-tecnicos/backend/internal/alerts"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/feed"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ownedDevices selects the IDs of the devices userID owns.
+const ownedDevices = "device_id IN (SELECT id FROM iot_devices WHERE owner_id = ?)"
+
+// GetAlerts lists the alerts on the authenticated user's devices, newest first.
+func GetAlerts(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req ListAlertsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 100
+	}
+
+	query := database.DB.Where(ownedDevices, userID.(string))
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.DeviceID != "" {
+		query = query.Where("device_id = ?", req.DeviceID)
+	}
+	if req.Severity != "" {
+		query = query.Where("severity IN ?", severitiesFrom(req.Severity))
+	}
+	if req.Unacknowledged {
+		query = query.Where("acknowledged_at IS NULL")
+	}
+
+	var list []domain.DeviceAlert
+	if err := query.Order("created_at DESC").Limit(req.Limit).Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// severitiesFrom returns severity and every severity above it.
+func severitiesFrom(severity string) []string {
+	var result []string
+	for _, s := range []string{domain.AlertSeverityInfo, domain.AlertSeverityWarning, domain.AlertSeverityCritical} {
+		if domain.AlertSeverityRank(s) >= domain.AlertSeverityRank(severity) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// AcknowledgeAlert records that the authenticated user has seen an alert on
+// one of their devices. Rule alerts stay open until their condition clears;
+// device-reported alerts are resolved by acknowledging them.
+func AcknowledgeAlert(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	userIDStr := userID.(string)
+
+	var alert domain.DeviceAlert
+	if err := database.DB.Where("id = ? AND "+ownedDevices, c.Param("id"), userIDStr).First(&alert).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+
+	if err := database.AcknowledgeAlert(database.DB, &alert, userIDStr); err != nil {
+		if errors.Is(err, database.ErrAlertAcknowledged) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Alert has already been acknowledged"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acknowledge alert"})
+		return
+	}
+
+	alerts.Notify(userIDStr, domain.AlertEventAcknowledged, alert)
+	c.JSON(http.StatusOK, alert)
+}
+
+// GetAlertWebhook returns the authenticated user's alert webhook. The secret
+// is never returned.
+func GetAlertWebhook(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var hook domain.AlertWebhook
+	if err := database.DB.First(&hook, "user_id = ?", userID.(string)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No alert webhook configured"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+// PutAlertWebhook creates or replaces the authenticated user's alert webhook.
+func PutAlertWebhook(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req AlertWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if err := alerts.ValidateWebhookURL(req.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.MinSeverity == "" {
+		req.MinSeverity = domain.AlertSeverityWarning
+	}
+
+	hook := domain.AlertWebhook{UserID: userID.(string), URL: req.URL, Secret: req.Secret, MinSeverity: req.MinSeverity}
+	if err := database.DB.Save(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save alert webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+// DeleteAlertWebhook removes the authenticated user's alert webhook.
+func DeleteAlertWebhook(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	result := database.DB.Delete(&domain.AlertWebhook{}, "user_id = ?", userID.(string))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert webhook"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No alert webhook configured"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert webhook deleted"})
+}
+
+// AlertsWS streams the authenticated user's device alert notifications
+// (device_alert events) as they happen. Browsers cannot set headers on a
+// WebSocket handshake, so the access token may also be passed as the
+// access_token query parameter.
+func AlertsWS(c *gin.Context) {
+	token := c.Query("access_token")
+	if token == "" {
+		token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Access token is missing"})
+		return
+	}
+	claims, problem := parseAccessToken(token)
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": problem})
+		return
+	}
+	if !domain.HasPermission(claims.Role, domain.PermDeviceRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your role (" + claims.Role + ") does not have the " + domain.PermDeviceRead + " permission"})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade connection to WebSocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	events, unsubscribe := feed.Users.Subscribe(claims.UserID)
+	defer unsubscribe()
+
+	if err := conn.WriteJSON(feed.Event{
+		Type:      "connected",
+		Data:      gin.H{"user_id": claims.UserID},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}); err != nil {
+		log.Printf("Error sending alerts welcome message: %v", err)
+		return
+	}
+
+	// The read loop only detects the client closing the connection.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				log.Printf("Error writing alert event: %v", err)
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"los-tecnicos/backend/internal/auction"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validateDayAheadBid checks a day-ahead bid and fills in its delivery window
+// end. It returns an error message, or "" if the bid is valid.
+func validateDayAheadBid(req *CreateOrderRequest, now time.Time) string {
+	switch {
+	case req.Kind != "" && req.Kind != domain.OrderKindLimit:
+		return "Day-ahead bids must be limit orders"
+	case req.TimeInForce != "" && req.TimeInForce != domain.TimeInForceGTC:
+		return "Day-ahead bids are held until gate closure and must be GTC"
+	case req.QuoteID != "":
+		return "Quotes can only be used in the continuous market"
+	case req.ExpiresAt != nil:
+		return "Day-ahead bids expire when their auction clears; expires_at is not supported"
+	case req.TokenPrice == 0:
+		return "token_price is required for day-ahead bids"
+	case req.DeliveryWindowStart == nil:
+		return "delivery_window_start is required for day-ahead bids"
+	}
+
+	start := req.DeliveryWindowStart.UTC()
+	if !start.Truncate(auction.SlotDuration).Equal(start) {
+		return "delivery_window_start must be on the hour"
+	}
+	if open := auction.OpenDeliveryDay(now); !auction.DeliveryDay(start).Equal(open) {
+		return "Bids are currently accepted for delivery on " + open.Format("2006-01-02") + " only"
+	}
+
+	end := start.Add(auction.SlotDuration)
+	if req.DeliveryWindowEnd != nil && !req.DeliveryWindowEnd.Equal(end) {
+		return "Day-ahead bids cover exactly one hour"
+	}
+	req.DeliveryWindowStart, req.DeliveryWindowEnd = &start, &end
+	return ""
+}
+
+// DayAheadResponse describes the day-ahead market for one delivery day.
+type DayAheadResponse struct {
+	DeliveryDay  string                 `json:"delivery_day"`
+	GateClosesAt string                 `json:"gate_closes_at"`
+	GateOpen     bool                   `json:"gate_open"`
+	Results      []domain.AuctionResult `json:"results"`
+}
+
+// GetDayAheadMarket returns the gate status and hourly clearing results for a
+// delivery day (by default, the day currently accepting bids).
+func GetDayAheadMarket(c *gin.Context) {
+	var req DayAheadRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	now := time.Now()
+	day := auction.OpenDeliveryDay(now)
+	if req.Date != "" {
+		parsed, err := time.Parse("2006-01-02", req.Date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+			return
+		}
+		day = auction.DeliveryDay(parsed)
+	}
+
+	results := []domain.AuctionResult{}
+	if err := database.DB.Where("delivery_start >= ? AND delivery_start < ?", day, day.Add(24*time.Hour)).
+		Order("delivery_start asc").Find(&results).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve auction results"})
+		return
+	}
+
+	gate := auction.GateClosure(day)
+	c.JSON(http.StatusOK, DayAheadResponse{
+		DeliveryDay:  day.Format("2006-01-02"),
+		GateClosesAt: gate.Format(time.RFC3339),
+		GateOpen:     day.Equal(auction.OpenDeliveryDay(now)),
+		Results:      results,
+	})
+}
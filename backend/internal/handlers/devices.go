@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/shadow"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// deviceTransferTTL is how long the recipient has to accept a device transfer.
+var deviceTransferTTL = time.Duration(config.GetEnvAsInt("DEVICE_TRANSFER_TTL_HOURS", 72)) * time.Hour
+
+// DecommissionResponse reports a decommissioned device and the sell orders
+// that were cancelled with it.
+type DecommissionResponse struct {
+	Device          domain.IoTDevice `json:"device"`
+	CancelledOrders []string         `json:"cancelled_orders"`
+}
+
+// ownedDevice loads the device in the :id path parameter if the
+// authenticated user owns it, responding 404 otherwise.
+func ownedDevice(c *gin.Context) (*domain.IoTDevice, bool) {
+	userID, _ := c.Get("userID")
+
+	var device domain.IoTDevice
+	if err := database.DB.Where("id = ? AND owner_id = ?", c.Param("id"), userID.(string)).First(&device).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return nil, false
+	}
+	return &device, true
+}
+
+// describeDeviceUpdate summarises the changes req makes to device for the audit trail.
+func describeDeviceUpdate(device domain.IoTDevice, req UpdateDeviceRequest) (map[string]interface{}, string) {
+	updates := map[string]interface{}{}
+	var changes []string
+	if req.Location != nil && *req.Location != device.Location {
+		updates["location"] = *req.Location
+		changes = append(changes, fmt.Sprintf("location %q -> %q", device.Location, *req.Location))
+	}
+	if req.Metadata != nil {
+		updates["metadata"] = req.Metadata
+		changes = append(changes, fmt.Sprintf("metadata replaced (%d keys)", len(req.Metadata)))
+	}
+	return updates, strings.Join(changes, "; ")
+}
+
+// GetDevice returns one of the authenticated user's devices.
+func GetDevice(c *gin.Context) {
+	device, ok := ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+// UpdateDevice changes the location and/or metadata of one of the
+// authenticated user's devices.
+func UpdateDevice(c *gin.Context) {
+	var req UpdateDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	device, ok := ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	updates, details := describeDeviceUpdate(*device, req)
+	if len(updates) == 0 {
+		c.JSON(http.StatusOK, device)
+		return
+	}
+
+	err := database.UpdateDevice(database.DB, device.ID, device.OwnerID, updates, details)
+	if errors.Is(err, database.ErrDeviceDecommissioned) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Device is decommissioned or has changed hands"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update device"})
+		return
+	}
+
+	if req.Location != nil {
+		device.Location = *req.Location
+	}
+	if req.Metadata != nil {
+		device.Metadata = req.Metadata
+	}
+	c.JSON(http.StatusOK, device)
+}
+
+// DecommissionDevice permanently retires one of the authenticated user's
+// devices, cancelling its pending transfer and the open sell orders it was
+// delivering.
+func DecommissionDevice(c *gin.Context) {
+	var req DecommissionDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	device, ok := ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	cancelled, err := database.DecommissionDevice(database.DB, device.ID, device.OwnerID, req.Reason)
+	if errors.Is(err, database.ErrDeviceDecommissioned) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Device is already decommissioned or has changed hands"})
+		return
+	}
+	if errors.Is(err, database.ErrOrderConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A sell order from this device was filled concurrently, retry"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decommission device"})
+		return
+	}
+
+	// Stop the broker handing the device its configuration
+	if err := shadow.Clear(device.ID); err != nil {
+		log.Printf("Failed to clear retained config of device %s: %v", device.ID, err)
+	}
+
+	device.Status = domain.DeviceStatusDecommissioned
+	c.JSON(http.StatusOK, DecommissionResponse{Device: *device, CancelledOrders: cancelled})
+}
+
+// GetDeviceEvents returns the lifecycle audit trail of one of the
+// authenticated user's devices, oldest first.
+func GetDeviceEvents(c *gin.Context) {
+	device, ok := ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	var events []domain.DeviceEvent
+	if err := database.DB.Where("device_id = ?", device.ID).Order("created_at, id").Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve device events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// TransferDevice offers one of the authenticated user's devices to another
+// user, who has DEVICE_TRANSFER_TTL_HOURS to accept it.
+func TransferDevice(c *gin.Context) {
+	var req TransferDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	device, ok := ownedDevice(c)
+	if !ok {
+		return
+	}
+	if device.IsDecommissioned() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Decommissioned devices cannot be transferred"})
+		return
+	}
+
+	var recipient domain.User
+	if err := database.DB.Where("wallet_address = ?", req.ToWalletAddress).First(&recipient).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipient not found"})
+		return
+	}
+	if recipient.ID == device.OwnerID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You already own this device"})
+		return
+	}
+	if !domain.HasPermission(recipient.Role, domain.PermDeviceManage) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The recipient's role (" + recipient.Role + ") cannot own devices"})
+		return
+	}
+
+	now := time.Now()
+	transfer := domain.DeviceTransfer{
+		ID:         uuid.New().String(),
+		DeviceID:   device.ID,
+		FromUserID: device.OwnerID,
+		ToUserID:   recipient.ID,
+		Note:       req.Note,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(deviceTransferTTL),
+	}
+	err := database.CreateDeviceTransfer(database.DB, &transfer)
+	if errors.Is(err, database.ErrTransferPending) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This device already has a pending transfer; cancel it first"})
+		return
+	}
+	if errors.Is(err, database.ErrDeviceDecommissioned) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Device is decommissioned or has changed hands"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create device transfer"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// GetDeviceTransfers lists the device transfers the authenticated user has
+// sent or received, newest first.
+func GetDeviceTransfers(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	userIDStr := userID.(string)
+
+	var transfers []domain.DeviceTransfer
+	if err := database.DB.Where("from_user_id = ? OR to_user_id = ?", userIDStr, userIDStr).Order("created_at DESC").Find(&transfers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve device transfers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfers)
+}
+
+// AcceptDeviceTransfer takes ownership of a device offered to the
+// authenticated user. The sender's open sell orders from it are cancelled.
+func AcceptDeviceTransfer(c *gin.Context) {
+	respondToDeviceTransfer(c, domain.DeviceTransferAccepted)
+}
+
+// DeclineDeviceTransfer refuses a device offered to the authenticated user.
+func DeclineDeviceTransfer(c *gin.Context) {
+	respondToDeviceTransfer(c, domain.DeviceTransferDeclined)
+}
+
+// CancelDeviceTransfer withdraws a device transfer the authenticated user sent.
+func CancelDeviceTransfer(c *gin.Context) {
+	respondToDeviceTransfer(c, domain.DeviceTransferCancelled)
+}
+
+// respondToDeviceTransfer closes the pending transfer in the :id path
+// parameter. Only the recipient may accept or decline it, and only the
+// sender may cancel it.
+func respondToDeviceTransfer(c *gin.Context, status string) {
+	userID, _ := c.Get("userID")
+	userIDStr := userID.(string)
+
+	var transfer domain.DeviceTransfer
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&transfer).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device transfer not found"})
+		return
+	}
+	party := transfer.ToUserID
+	if status == domain.DeviceTransferCancelled {
+		party = transfer.FromUserID
+	}
+	if party != userIDStr {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device transfer not found"})
+		return
+	}
+
+	err := database.RespondToDeviceTransfer(database.DB, &transfer, status, userIDStr)
+	if errors.Is(err, database.ErrTransferClosed) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Transfer is no longer pending or has expired"})
+		return
+	}
+	if errors.Is(err, database.ErrDeviceDecommissioned) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Device was decommissioned or has changed hands since the transfer was offered"})
+		return
+	}
+	if errors.Is(err, database.ErrOrderConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A sell order from this device was filled concurrently, retry"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update device transfer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func TestDescribeDeviceUpdate(t *testing.T) {
+	device := domain.IoTDevice{Location: "28.61,77.20", Metadata: domain.DeviceMetadata{"model": "v1"}}
+
+	same := "28.61,77.20"
+	if updates, _ := describeDeviceUpdate(device, UpdateDeviceRequest{Location: &same}); len(updates) != 0 {
+		t.Errorf("Expected an unchanged location to be no update, got %v", updates)
+	}
+
+	moved := "28.70,77.10"
+	updates, details := describeDeviceUpdate(device, UpdateDeviceRequest{Location: &moved, Metadata: domain.DeviceMetadata{}})
+	if updates["location"] != moved || updates["metadata"] == nil {
+		t.Errorf("Expected location and metadata updates, got %v", updates)
+	}
+	if !strings.Contains(details, `"28.61,77.20" -> "28.70,77.10"`) || !strings.Contains(details, "metadata replaced (0 keys)") {
+		t.Errorf("Expected the details to describe both changes, got %q", details)
+	}
+}
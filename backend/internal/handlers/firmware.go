@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/ota"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FirmwareCampaignResponse is a campaign with its release and the progress of
+// its updates.
+type FirmwareCampaignResponse struct {
+	domain.FirmwareCampaign
+	Firmware    domain.Firmware        `json:"firmware"`
+	Counts      map[int]map[string]int `json:"counts"` // Updates by stage and status
+	FailureRate float64                `json:"failure_rate"`
+}
+
+// RegisterFirmware adds a release to the firmware registry after checking its
+// signature against the release key.
+func RegisterFirmware(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req RegisterFirmwareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if !strings.HasPrefix(req.URL, "https://") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Firmware must be served over https"})
+		return
+	}
+	sha := strings.ToLower(req.SHA256)
+	if err := ota.VerifyRelease(sha, req.Signature); err != nil {
+		if errors.Is(err, ota.ErrNoReleaseKey) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	firmware := domain.Firmware{
+		ID:        uuid.New().String(),
+		Version:   req.Version,
+		URL:       req.URL,
+		SHA256:    sha,
+		Size:      req.Size,
+		Signature: req.Signature,
+		Notes:     req.Notes,
+		CreatedBy: userID.(string),
+		CreatedAt: time.Now(),
+	}
+	if err := database.DB.Create(&firmware).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Firmware version " + req.Version + " is already registered"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register firmware"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, firmware)
+}
+
+// ListFirmware lists the firmware registry, newest first.
+func ListFirmware(c *gin.Context) {
+	var releases []domain.Firmware
+	if err := database.DB.Order("created_at DESC").Find(&releases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve firmware"})
+		return
+	}
+
+	c.JSON(http.StatusOK, releases)
+}
+
+// GetFirmwareSigningKey returns the public key update manifests are signed
+// with, for building into the firmware.
+func GetFirmwareSigningKey(c *gin.Context) {
+	if !ota.CanSign() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": ota.ErrNoSigningKey.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"algorithm": "Ed25519", "public_key": ota.PublicKey()})
+}
+
+// CreateFirmwareCampaign creates a draft campaign rolling a release out to the
+// live ESP32s in a group or list. Devices already running the release are left out.
+func CreateFirmwareCampaign(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req CreateFirmwareCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.TargetGroup == "" && len(req.DeviceIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Give a target_group or device_ids"})
+		return
+	}
+	stages := domain.RolloutStages(req.Stages)
+	if len(stages) == 0 {
+		stages = ota.DefaultStages
+	}
+	if err := ota.ValidateStages(stages); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	maxFailureRate := ota.DefaultMaxFailureRate
+	if req.MaxFailureRate != nil {
+		maxFailureRate = *req.MaxFailureRate
+	}
+
+	var firmware domain.Firmware
+	if err := database.DB.First(&firmware, "id = ?", req.FirmwareID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Firmware not found"})
+		return
+	}
+
+	query := database.DB.Model(&domain.IoTDevice{}).
+		Where("device_type = ? AND status <> ? AND firmware_version <> ?", "esp32", domain.DeviceStatusDecommissioned, firmware.Version)
+	switch {
+	case req.TargetGroup != "" && len(req.DeviceIDs) > 0:
+		query = query.Where("(metadata->>? = ? OR id IN ?)", domain.MetadataGroup, req.TargetGroup, req.DeviceIDs)
+	case req.TargetGroup != "":
+		query = query.Where("metadata->>? = ?", domain.MetadataGroup, req.TargetGroup)
+	default:
+		query = query.Where("id IN ?", req.DeviceIDs)
+	}
+	var deviceIDs []string
+	if err := query.Pluck("id", &deviceIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to select devices"})
+		return
+	}
+	if len(deviceIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No live ESP32 matches the target, or all already run this version"})
+		return
+	}
+
+	now := time.Now()
+	campaign := domain.FirmwareCampaign{
+		ID:             uuid.New().String(),
+		Name:           req.Name,
+		FirmwareID:     firmware.ID,
+		TargetGroup:    req.TargetGroup,
+		Stages:         stages,
+		MaxFailureRate: maxFailureRate,
+		Status:         domain.CampaignDraft,
+		CreatedBy:      userID.(string),
+		CreatedAt:      now,
+	}
+	updates := make([]domain.FirmwareUpdate, 0, len(deviceIDs))
+	for deviceID, stage := range ota.AssignStages(campaign.ID, deviceIDs, stages) {
+		updates = append(updates, domain.FirmwareUpdate{
+			ID:         uuid.New().String(),
+			CampaignID: campaign.ID,
+			DeviceID:   deviceID,
+			Stage:      stage,
+			Status:     domain.UpdatePending,
+			UpdatedAt:  now,
+		})
+	}
+	if err := database.CreateCampaign(database.DB, &campaign, updates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create campaign"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, campaign)
+}
+
+// ListFirmwareCampaigns lists firmware campaigns, newest first.
+func ListFirmwareCampaigns(c *gin.Context) {
+	var campaigns []domain.FirmwareCampaign
+	if err := database.DB.Order("created_at DESC").Find(&campaigns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve campaigns"})
+		return
+	}
+
+	c.JSON(http.StatusOK, campaigns)
+}
+
+// GetFirmwareCampaign returns a campaign with its update counts and the
+// failure rate the halt threshold is checked against.
+func GetFirmwareCampaign(c *gin.Context) {
+	var resp FirmwareCampaignResponse
+	if err := database.DB.First(&resp.FirmwareCampaign, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+		return
+	}
+	if err := database.DB.First(&resp.Firmware, "id = ?", resp.FirmwareID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve firmware"})
+		return
+	}
+	counts, err := database.CampaignCounts(database.DB, resp.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count updates"})
+		return
+	}
+	resp.Counts = counts
+	resp.FailureRate = ota.ProgressUpTo(counts, resp.CurrentStage).FailureRate()
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetFirmwareCampaignUpdates lists a campaign's per-device updates.
+func GetFirmwareCampaignUpdates(c *gin.Context) {
+	var req ListFirmwareUpdatesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	query := database.DB.Where("campaign_id = ?", c.Param("id"))
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.Stage != nil {
+		query = query.Where("stage = ?", *req.Stage)
+	}
+	var updates []domain.FirmwareUpdate
+	if err := query.Order("stage, device_id").Find(&updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve updates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, updates)
+}
+
+// StartFirmwareCampaign starts a draft campaign. The runner sends its first
+// stage's updates on its next round.
+func StartFirmwareCampaign(c *gin.Context) {
+	if !ota.CanSign() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": ota.ErrNoSigningKey.Error()})
+		return
+	}
+	now := time.Now()
+	err := database.RunCampaign(database.DB, c.Param("id"), []string{domain.CampaignDraft}, map[string]interface{}{"started_at": now}, now)
+	respondFirmwareCampaign(c, err)
+}
+
+// PauseFirmwareCampaign stops a running campaign sending updates. Updates
+// already sent are still tracked.
+func PauseFirmwareCampaign(c *gin.Context) {
+	err := database.TransitionCampaign(database.DB, c.Param("id"), []string{domain.CampaignRunning}, domain.CampaignPaused, nil)
+	respondFirmwareCampaign(c, err)
+}
+
+// ResumeFirmwareCampaign restarts a paused or halted campaign, optionally
+// with a new failure rate limit.
+func ResumeFirmwareCampaign(c *gin.Context) {
+	var req ResumeFirmwareCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if !ota.CanSign() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": ota.ErrNoSigningKey.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{"halt_reason": ""}
+	if req.MaxFailureRate != nil {
+		updates["max_failure_rate"] = *req.MaxFailureRate
+	}
+	err := database.RunCampaign(database.DB, c.Param("id"), []string{domain.CampaignPaused, domain.CampaignHalted}, updates, time.Now())
+	respondFirmwareCampaign(c, err)
+}
+
+// CancelFirmwareCampaign ends a campaign that has not finished. Its updates
+// not yet sent are cancelled.
+func CancelFirmwareCampaign(c *gin.Context) {
+	err := database.CancelCampaign(database.DB, c.Param("id"), time.Now())
+	respondFirmwareCampaign(c, err)
+}
+
+// respondFirmwareCampaign responds with the campaign in the :id path
+// parameter after a change to it, or with why the change failed.
+func respondFirmwareCampaign(c *gin.Context, err error) {
+	var campaign domain.FirmwareCampaign
+	if findErr := database.DB.First(&campaign, "id = ?", c.Param("id")).Error; findErr != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+		return
+	}
+	if errors.Is(err, database.ErrCampaignChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Campaign is " + campaign.Status})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update campaign"})
+		return
+	}
+
+	c.JSON(http.StatusOK, campaign)
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RequestWithdrawal debits tokens from the authenticated user's available
+// balance for payout to their wallet. Tokens reserved for open buy orders
+// cannot be withdrawn.
+func RequestWithdrawal(c *gin.Context) {
+	var req WithdrawalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+	userIDStr := userID.(string)
+
+	var user domain.User
+	if err := database.DB.Where("id = ?", userIDStr).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	withdrawal, err := database.RequestWithdrawal(database.DB, user.ID, user.WalletAddress, req.Amount)
+	if errors.Is(err, database.ErrInsufficientBalance) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient available token balance for this withdrawal"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request withdrawal"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, withdrawal)
+}
+
+// GetWithdrawals lists the authenticated user's withdrawals, newest first.
+func GetWithdrawals(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var withdrawals []domain.Withdrawal
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&withdrawals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve withdrawals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, withdrawals)
+}
+
+// CreditDeposit credits tokens received for the user in the :user_id path
+// parameter, e.g. an on-chain transfer to the platform. A reference can only
+// be credited to a user once, so retries are safe.
+func CreditDeposit(c *gin.Context) {
+	var req DepositRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	var user domain.User
+	if err := database.DB.Where("id = ?", c.Param("user_id")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Users created before accounts existed get one on their first deposit
+		if err := database.OpenAccount(tx, user.ID, 0); err != nil {
+			return err
+		}
+		return database.Deposit(tx, user.ID, req.Amount, req.Reference)
+	})
+	if errors.Is(err, database.ErrDuplicateDeposit) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This deposit has already been credited"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to credit deposit"})
+		return
+	}
+
+	var account domain.Account
+	database.DB.First(&account, "user_id = ?", user.ID)
+	c.JSON(http.StatusCreated, account)
+}
+
+// ListWithdrawals lists every user's withdrawals in a status, oldest first.
+func ListWithdrawals(c *gin.Context) {
+	var req ListWithdrawalsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	status := req.Status
+	if status == "" {
+		status = domain.WithdrawalPending
+	}
+
+	var withdrawals []domain.Withdrawal
+	if err := database.DB.Where("status = ?", status).Order("created_at").Find(&withdrawals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve withdrawals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, withdrawals)
+}
+
+// PayWithdrawal marks a pending withdrawal paid out; the hash of the on-chain
+// transfer is required.
+func PayWithdrawal(c *gin.Context) {
+	processWithdrawal(c, domain.WithdrawalPaid)
+}
+
+// RejectWithdrawal rejects a pending withdrawal and credits the amount back to
+// the user's available balance; a reason is required.
+func RejectWithdrawal(c *gin.Context) {
+	processWithdrawal(c, domain.WithdrawalRejected)
+}
+
+// processWithdrawal moves the withdrawal in the :id path parameter to status.
+func processWithdrawal(c *gin.Context, status string) {
+	var req ProcessWithdrawalRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if status == domain.WithdrawalPaid && req.TxHash == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The transaction hash of the payout is required"})
+		return
+	}
+	if status == domain.WithdrawalRejected && req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to reject a withdrawal"})
+		return
+	}
+	processorID, _ := c.Get("userID")
+
+	withdrawal, err := database.ProcessWithdrawal(database.DB, c.Param("id"), status, req.TxHash, req.Reason, processorID.(string))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Withdrawal not found"})
+		return
+	}
+	if errors.Is(err, database.ErrWithdrawalProcessed) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Withdrawal was already processed"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process withdrawal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, withdrawal)
+}
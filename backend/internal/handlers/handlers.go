@@ -5,15 +5,20 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
-	"database/sql"
+	"los-tecnicos/backend/internal/auction"
+	"los-tecnicos/backend/internal/auth"
 	"los-tecnicos/backend/internal/cache"
+	"los-tecnicos/backend/internal/candles"
 	"los-tecnicos/backend/internal/config"
 	"los-tecnicos/backend/internal/core/domain"
 	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/feed"
+	"los-tecnicos/backend/internal/fees"
 	"los-tecnicos/backend/internal/matching"
 	"los-tecnicos/backend/internal/pricing"
 
@@ -24,18 +29,34 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/stellar/go/keypair"
 	"github.com/stellar/go/strkey"
+	"gorm.io/gorm"
 )
 
 // The message that the frontend is expected to sign.
 const challengeMessage = "los-tecnicos-auth"
 
-// In a real app, load this from a secure config
-var jwtSecret = []byte(config.GetEnv("JWT_SECRET", "a-very-secret-key"))
+// quoteSecret signs price quotes so they can be honoured without server-side
+// storage. The default is only accepted in development, see CheckQuoteSecret.
+var quoteSecret = []byte(config.GetEnv("QUOTE_SECRET", pricing.DefaultQuoteSecret))
+
+// quoteTTL is how long a buyer has to place an order against a quote.
+var quoteTTL = time.Duration(config.GetEnvAsInt("QUOTE_TTL_SECONDS", 30)) * time.Second
+
+// adminWallets lists the wallets (comma-separated ADMIN_WALLETS) that sign up
+// as Admin, so the first admin does not need anyone to approve them.
+var adminWallets = parseWalletList(config.GetEnv("ADMIN_WALLETS", ""))
+
+// openingBalance is the token balance credited to every new account.
+var openingBalance = config.GetEnvAsFloat("ACCOUNT_OPENING_BALANCE", 0)
+
+// orderTTL is how long an order without an expiry or delivery window stays on the book.
+var orderTTL = time.Duration(config.GetEnvAsInt("ORDER_TTL_HOURS", 24)) * time.Hour
 
 // Claims defines the structure of the JWT claims.
 type Claims struct {
-	UserID string `json:"user_id"`
-	Role   string `json:"role"`
+	UserID    string `json:"user_id"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid,omitempty"` // Session family the token was issued under
 	jwt.RegisteredClaims
 }
 
@@ -84,15 +105,26 @@ func SignUp(c *gin.Context) {
 		return
 	}
 
+	role := domain.RoleRecipient
+	if adminWallets[req.WalletAddress] {
+		role = domain.RoleAdmin
+	}
+
 	newUser := domain.User{
 		ID:            req.WalletAddress,
 		WalletAddress: req.WalletAddress,
-		Role:          "Recipient", // Default role
+		Role:          role, // Other roles go through /roles/request
 		CreatedAt:     time.Now(),
 		KYCStatus:     "pending",
 	}
 
-	if err := database.DB.Create(&newUser).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newUser).Error; err != nil {
+			return err
+		}
+		return database.OpenAccount(tx, newUser.ID, openingBalance)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
@@ -141,62 +173,77 @@ func Login(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deserialize cached user"})
 			return
 		}
-	} else if err == redis.Nil {
-		// Cache miss, fetch from DB
+	} else {
+		if err != redis.Nil {
+			// Log error but proceed to DB (Fail-safe for Redis downtime)
+			log.Printf("Warning: Redis error on Get (continuing to DB): %v", err)
+		}
 		if err := database.DB.Where("wallet_address = ?", req.WalletAddress).First(&user).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found. Please sign up first."})
 			return
 		}
-	} else {
-		// Log error but proceed to DB (Fail-safe for Redis downtime)
-		log.Printf("Warning: Redis error on Get (continuing to DB): %v", err)
+		userJSON, _ := json.Marshal(user)
+		if err := cache.Rdb.Set(context.Background(), userCacheKey, userJSON, 1*time.Hour).Err(); err != nil {
+			log.Printf("Failed to update user cache for %s: %v", user.ID, err)
+		}
 	}
 
-	// 3. Generate Access Token (short-lived)
-	accessToken, err := createAccessToken(&user)
+	// 3. Start a new session (one per device) with a rotating refresh token
+	refreshToken, tokenHash, err := newRefreshToken()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
 		return
 	}
-
-	// 4. Generate and store Refresh Token (long-lived)
-	refreshToken := uuid.New().String()
-	user.RefreshToken = refreshToken
-	user.RefreshTokenExpiresAt = time.Now().Add(7 * 24 * time.Hour)
-
-	if err := database.DB.Save(&user).Error; err != nil {
+	session, err := database.CreateSession(database.DB, user.ID, tokenHash, c.Request.UserAgent(), c.ClientIP(), refreshTokenTTL)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store refresh token"})
 		return
 	}
 
-	// 5. Update cache with the new user state
-	userJSON, _ := json.Marshal(user)
-	if err := cache.Rdb.Set(context.Background(), userCacheKey, userJSON, 1*time.Hour).Err(); err != nil {
-		log.Printf("Failed to update user cache for %s: %v", user.ID, err)
+	// 4. Generate Access Token (short-lived)
+	accessToken, err := createAccessToken(&user, session.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
+		return
 	}
 
-	// 6. Return both tokens
+	// 5. Return both tokens
 	c.JSON(http.StatusOK, gin.H{
 		"access_token":  accessToken,
 		"refresh_token": refreshToken,
 	})
 }
 
-func createAccessToken(user *domain.User) (string, error) {
-	expirationTime := time.Now().Add(15 * time.Minute) // 15-minute access token
+// createAccessToken generates a new JWT access token for a user, tied to
+// the session family it was issued under.
+func createAccessToken(user *domain.User, sessionID string) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID: user.ID,
-		Role:   user.Role,
+		UserID:    user.ID,
+		Role:      user.Role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			ID:        uuid.New().String(), // jti, for the denylist
+			Issuer:    auth.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(auth.AccessTokenTTL)),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return auth.Keys.Sign(claims)
 }
 
-// RefreshToken generates a new access token from a valid refresh token.
+// JWKS publishes the public keys access tokens are verified with, so other
+// services can verify our tokens. Responses may be cached for five minutes,
+// well within the time a new key is published before it signs.
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, auth.Keys.JWKS(time.Now()))
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token. The presented token is single use: presenting it again
+// revokes every token descended from the same login.
 func RefreshToken(c *gin.Context) {
 	var req RefreshTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -204,24 +251,46 @@ func RefreshToken(c *gin.Context) {
 		return
 	}
 
-	var user domain.User
-	if err := database.DB.Where("refresh_token = ?", req.RefreshToken).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+	refreshToken, newHash, err := newRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		return
+	}
+	session, err := database.RotateSession(database.DB, hashRefreshToken(req.RefreshToken), newHash, c.Request.UserAgent(), c.ClientIP(), refreshTokenTTL)
+	if errors.Is(err, database.ErrSessionReused) {
+		log.Printf("Refresh token reuse detected; session %s revoked", session.FamilyID)
+		if err := auth.RevokeSession(session.FamilyID); err != nil {
+			log.Printf("Warning: Failed to denylist access tokens of session %s: %v", session.FamilyID, err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token was already used; the session has been revoked, please log in again"})
+		return
+	}
+	if errors.Is(err, database.ErrSessionInvalid) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
 		return
 	}
 
-	if time.Now().After(user.RefreshTokenExpiresAt) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has expired"})
+	// Re-read the user so role changes apply from this token on
+	var user domain.User
+	if err := database.DB.Where("id = ?", session.UserID).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 		return
 	}
 
-	accessToken, err := createAccessToken(&user)
+	accessToken, err := createAccessToken(&user, session.FamilyID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"access_token": accessToken})
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	})
 }
 
 // Me returns the profile of the currently authenticated user.
@@ -243,10 +312,10 @@ func Me(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-// GetMarketOrders retrieves all open energy orders.
+// GetMarketOrders retrieves all open (including partially filled) energy orders.
 func GetMarketOrders(c *gin.Context) {
 	var orders []domain.EnergyOrder
-	if err := database.DB.Where("status = ?", "Created").Find(&orders).Error; err != nil {
+	if err := database.DB.Where("status IN ?", domain.OpenOrderStatuses).Find(&orders).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve orders"})
 		return
 	}
@@ -267,56 +336,244 @@ func CreateOrder(c *gin.Context) {
 	userIDStr := userID.(string)
 	userRoleStr := userRole.(string)
 
-	// If user is new, assign role based on first action
-	if userRoleStr == "" {
-		newRole := "Recipient"
-		if req.Type == "sell" {
-			newRole = "Donor"
+	// Retries carrying the same client order ID get the original order back
+	clientOrderID, err := resolveClientOrderID(req.ClientOrderID, c.GetHeader(IdempotencyKeyHeader))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(clientOrderID) > 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "client_order_id must be at most 64 characters"})
+		return
+	}
+	if clientOrderID != "" {
+		existing, err := findIdempotentOrder(userIDStr, clientOrderID)
+		if errors.Is(err, errIdempotencyInFlight) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A request with this client_order_id is still in progress, retry shortly"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up client_order_id"})
+			return
+		}
+		if existing != nil {
+			replayOrder(c, existing, req)
+			return
 		}
-		if err := database.DB.Model(&domain.User{}).Where("id = ?", userIDStr).Update("role", newRole).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign user role"})
+		if !reserveIdempotencyKey(userIDStr, clientOrderID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A request with this client_order_id is still in progress, retry shortly"})
 			return
 		}
-		// Update local variable for subsequent checks
-		userRoleStr = newRole
 	}
+	// Free the reservation if the order is rejected, so the client can retry
+	created := false
+	defer func() {
+		if clientOrderID != "" && !created {
+			releaseIdempotencyKey(userIDStr, clientOrderID)
+		}
+	}()
 
-	// A user must be a Donor to create a sell order.
-	if req.Type == "sell" && userRoleStr != "Donor" && userRoleStr != "NetworkNodeOperator" {
-		log.Printf("DEBUG: CreateOrder denied. Type=%s, Role=%s, ID=%s", req.Type, userRoleStr, userIDStr)
-		// Let's be lenient and auto-update the role if they are just a Recipient
-		if userRoleStr == "Recipient" {
-			if err := database.DB.Model(&domain.User{}).Where("id = ?", userIDStr).Update("role", "Donor").Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role to Donor"})
-				return
+	// Selling needs an approved Donor or NetworkNodeOperator role, see /roles/request
+	if req.Type == "sell" && !domain.HasPermission(userRoleStr, domain.PermMarketSell) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your role (" + userRoleStr + ") cannot create sell orders; request the Donor role"})
+		return
+	}
+
+	now := time.Now()
+	market := req.Market
+	if market == "" {
+		market = domain.MarketContinuous
+	}
+	if market == domain.MarketDayAhead {
+		if msg := validateDayAheadBid(&req, now); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+
+	if req.DeliveryWindowStart != nil && req.DeliveryWindowEnd != nil && !req.DeliveryWindowEnd.After(*req.DeliveryWindowStart) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "delivery_window_end must be after delivery_window_start"})
+		return
+	}
+	if req.DeliveryWindowEnd != nil && !req.DeliveryWindowEnd.After(now) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delivery window has already ended"})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	// Energy is perishable: every order expires, at the latest when its delivery window closes
+	expiresAt := now.Add(orderTTL)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	} else if req.DeliveryWindowEnd != nil {
+		expiresAt = *req.DeliveryWindowEnd
+	}
+	if req.DeliveryWindowEnd != nil && req.DeliveryWindowEnd.Before(expiresAt) {
+		expiresAt = *req.DeliveryWindowEnd
+	}
+
+	kind := req.Kind
+	if kind == "" {
+		kind = domain.OrderKindLimit
+	}
+	timeInForce := req.TimeInForce
+	if timeInForce == "" {
+		timeInForce = domain.TimeInForceGTC
+		if kind == domain.OrderKindMarket {
+			timeInForce = domain.TimeInForceIOC
+		}
+	}
+
+	tokenPrice := req.TokenPrice
+	maxSlippage := 0.0
+	switch {
+	case kind == domain.OrderKindMarket:
+		if req.QuoteID != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Quotes can only be used with limit orders"})
+			return
+		}
+		// A market order resting on the book would fill later at an arbitrary price
+		if timeInForce == domain.TimeInForceGTC {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Market orders must be IOC or FOK"})
+			return
+		}
+		maxSlippage = req.MaxSlippage
+		if maxSlippage == 0 {
+			maxSlippage = domain.DefaultMaxSlippage
+		}
+		limit, err := matching.MarketOrderLimit(userIDStr, req.Type, req.KwhAmount, maxSlippage)
+		if err == matching.ErrNoLiquidity {
+			c.JSON(http.StatusConflict, gin.H{"error": "No counterparty orders available for a market order"})
+			return
+		}
+		if err == matching.ErrTradingHalted {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Trading is halted, market orders are not accepted"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price market order"})
+			return
+		}
+		tokenPrice = limit
+	case req.QuoteID == "" && req.TokenPrice == 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token_price is required for limit orders"})
+		return
+	case req.MaxSlippage != 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_slippage only applies to market orders"})
+		return
+	}
+
+	if req.QuoteID != "" {
+		quote, err := pricing.VerifyQuote(req.QuoteID, quoteSecret, time.Now())
+		if err == pricing.ErrQuoteExpired {
+			c.JSON(http.StatusConflict, gin.H{"error": "Quote has expired, request a new one"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quote"})
+			return
+		}
+		if req.Type != "buy" || quote.UserID != userIDStr || quote.KwhAmount != req.KwhAmount {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Quote does not match this order"})
+			return
+		}
+		// A quote prices a single order
+		if !redeemQuote(quote, req.QuoteID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Quote has already been used, request a new one"})
+			return
+		}
+		defer func() {
+			if !created {
+				releaseQuote(quote)
 			}
-			// Update local variable
-			userRoleStr = "Donor"
-		} else {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Only Donors or Network Node Operators can create sell orders. Your role is: " + userRoleStr})
+		}()
+		// The quoted worst-level price becomes the order's limit
+		tokenPrice = quote.Price
+	}
+
+	// Sellers deliver from one of their own ESP32s, which must hold the energy;
+	// buyers reserve the highest fee they could be charged
+	deviceID := ""
+	feeRate := 0.0
+	if req.Type == "buy" {
+		feeRate = fees.Current.MaxRate()
+	}
+	if req.Type == "sell" {
+		device, msg := sellerDevice(userIDStr, req.DeviceID, req.KwhAmount)
+		if device == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 			return
 		}
+		deviceID = device.ID
+	} else if req.DeviceID != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "device_id only applies to sell orders"})
+		return
 	}
 
 	newOrder := domain.EnergyOrder{
-		ID:         uuid.New().String(),
-		UserID:     userIDStr,
-		Type:       req.Type,
-		KwhAmount:  req.KwhAmount,
-		TokenPrice: req.TokenPrice,
-		Status:     "Created",
-		CreatedAt:  time.Now(),
+		ID:          uuid.New().String(),
+		UserID:      userIDStr,
+		Type:        req.Type,
+		Market:      market,
+		Kind:        kind,
+		TimeInForce: timeInForce,
+		KwhAmount:   req.KwhAmount,
+		TokenPrice:  tokenPrice,
+		MaxSlippage: maxSlippage,
+		QuoteID:     req.QuoteID,
+		DeviceID:    deviceID,
+		FeeRate:     feeRate,
+		Status:      domain.OrderStatusCreated,
+		CreatedAt:   now,
+		PriorityAt:  now,
+
+		ClientOrderID: clientOrderID,
+
+		ExpiresAt:           &expiresAt,
+		DeliveryWindowStart: req.DeliveryWindowStart,
+		DeliveryWindowEnd:   req.DeliveryWindowEnd,
 	}
 
-	if err := database.DB.Create(&newOrder).Error; err != nil {
+	// How much a user may trade depends on their KYC tier, see /kyc
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkKYCLimits(tx, userIDStr, newOrder.KwhAmount*newOrder.TokenPrice, ""); err != nil {
+			return err
+		}
+		return database.CreateOrder(tx, &newOrder)
+	})
+	if err != nil {
+		if isKYCLimitError(err) {
+			respondKYCLimitError(c, err)
+			return
+		}
+		if errors.Is(err, database.ErrInsufficientBalance) || errors.Is(err, database.ErrInsufficientEnergy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reservationErrorMessage(err)})
+			return
+		}
+		// A concurrent retry won the unique index; answer with its order
+		if errors.Is(err, gorm.ErrDuplicatedKey) && clientOrderID != "" {
+			var existing domain.EnergyOrder
+			if database.DB.Where("user_id = ? AND client_order_id = ?", userIDStr, clientOrderID).First(&existing).Error == nil {
+				replayOrder(c, &existing, req)
+				return
+			}
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
 		return
 	}
+	created = true
+	if clientOrderID != "" {
+		recordIdempotencyKey(userIDStr, clientOrderID, newOrder.ID)
+	}
 
 	c.JSON(http.StatusCreated, newOrder)
 }
 
-// CancelOrder cancels an energy order if the user is the owner.
+// CancelOrder cancels an energy order (or the unfilled remainder of a partially
+// filled one) if the user is the owner.
 func CancelOrder(c *gin.Context) {
 	var req CancelOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -338,19 +595,119 @@ func CancelOrder(c *gin.Context) {
 		return
 	}
 
-	if order.Status != "Created" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only open orders can be cancelled"})
+	// Day-ahead bids are committed once the gate closes
+	if order.Market == domain.MarketDayAhead && order.DeliveryWindowStart != nil && !time.Now().Before(auction.GateClosure(*order.DeliveryWindowStart)) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The day-ahead gate has closed for this bid"})
 		return
 	}
 
-	if err := database.DB.Model(&order).Update("status", "Cancelled").Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel order"})
+	if err := database.TransitionOrder(database.DB, &order, domain.OrderStatusCancelled, nil); err != nil {
+		respondOrderUpdateError(c, err, "Failed to cancel order")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Order cancelled successfully"})
 }
 
+// AmendOrder changes the limit price and/or total quantity of an open GTC limit
+// order in place. Reducing the quantity keeps the order's time priority;
+// changing the price or increasing the quantity loses it. The update only
+// applies if the order is unchanged since it was read, so an amendment racing
+// a fill or cancellation fails with a conflict instead of overwriting it.
+func AmendOrder(c *gin.Context) {
+	var req AmendOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.TokenPrice == nil && req.KwhAmount == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide token_price and/or kwh_amount"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	userIDStr := userID.(string)
+
+	var order domain.EnergyOrder
+	if err := database.DB.Where("id = ?", req.OrderID).First(&order).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	if order.UserID != userIDStr {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to amend this order"})
+		return
+	}
+
+	// Amending keeps the status; the state machine only allows that for open orders
+	if err := order.CheckTransition(order.Status); err != nil {
+		respondOrderUpdateError(c, err, "Failed to amend order")
+		return
+	}
+
+	if order.Market == domain.MarketDayAhead && order.DeliveryWindowStart != nil && !time.Now().Before(auction.GateClosure(*order.DeliveryWindowStart)) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The day-ahead gate has closed for this bid"})
+		return
+	}
+
+	tokenPrice, kwhAmount := order.TokenPrice, order.KwhAmount
+	if req.TokenPrice != nil {
+		tokenPrice = *req.TokenPrice
+	}
+	if req.KwhAmount != nil {
+		kwhAmount = *req.KwhAmount
+	}
+
+	amended, err := order.Amend(tokenPrice, kwhAmount, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Growing an order counts against the KYC trading limits; shrinking it always works
+		if amended.KwhAmount*amended.TokenPrice > order.KwhAmount*order.TokenPrice {
+			if err := checkKYCLimits(tx, userIDStr, amended.KwhAmount*amended.TokenPrice, order.ID); err != nil {
+				return err
+			}
+		}
+
+		// Growing a buy or sell order reserves the extra tokens or energy
+		return database.TransitionOrder(tx, &order, order.Status, map[string]interface{}{
+			"token_price": amended.TokenPrice,
+			"kwh_amount":  amended.KwhAmount,
+			"priority_at": amended.PriorityAt,
+		})
+	})
+	if isKYCLimitError(err) {
+		respondKYCLimitError(c, err)
+		return
+	}
+	if err != nil {
+		respondOrderUpdateError(c, err, "Failed to amend order")
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// respondOrderUpdateError maps a failed order update to a response: 409 for
+// a state machine violation or a concurrent modification, 400 if the change
+// cannot be reserved, 500 otherwise.
+func respondOrderUpdateError(c *gin.Context, err error, message string) {
+	var transitionErr *domain.TransitionError
+	switch {
+	case errors.As(err, &transitionErr):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order is " + transitionErr.From + " and can no longer be changed"})
+	case errors.Is(err, database.ErrOrderConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order was filled or changed concurrently, fetch it and retry"})
+	case errors.Is(err, database.ErrInsufficientBalance), errors.Is(err, database.ErrInsufficientEnergy):
+		c.JSON(http.StatusBadRequest, gin.H{"error": reservationErrorMessage(err)})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
 // GetRegisteredDevices lists all IoT devices owned by the authenticated user.
 func GetRegisteredDevices(c *gin.Context) {
 	userID, exists := c.Get("userID")
@@ -383,16 +740,27 @@ func RegisterDevice(c *gin.Context) {
 		return
 	}
 
+	now := time.Now()
 	newDevice := domain.IoTDevice{
-		ID:         uuid.New().String(),
-		OwnerID:    userID.(string),
-		DeviceType: req.DeviceType,
-		Location:   req.Location,
-		LastPing:   time.Now(), // Set initial ping time
-		Status:     "registered",
+		ID:           uuid.New().String(),
+		OwnerID:      userID.(string),
+		DeviceType:   req.DeviceType,
+		Location:     req.Location,
+		BatteryLevel: req.BatteryLevel,
+		CapacityKwh:  req.CapacityKwh,
+		LastPing:     now, // Set initial ping time
+		Status:       domain.DeviceStatusRegistered,
+		Metadata:     req.Metadata,
+		CreatedAt:    now,
 	}
 
-	if err := database.DB.Create(&newDevice).Error; err != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newDevice).Error; err != nil {
+			return err
+		}
+		return database.RecordDeviceEvent(tx, newDevice.ID, domain.DeviceEventRegistered, newDevice.OwnerID, newDevice.DeviceType+" at "+newDevice.Location)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register device"})
 		return
 	}
@@ -411,7 +779,8 @@ func GetActiveNodes(c *gin.Context) {
 	c.JSON(http.StatusOK, nodes)
 }
 
-// RegisterNode registers a new network node for the authenticated user.
+// RegisterNode registers a new network node for the authenticated user, who
+// must already hold a role with the node:manage permission.
 func RegisterNode(c *gin.Context) {
 	var req RegisterNodeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -435,27 +804,11 @@ func RegisterNode(c *gin.Context) {
 		Earnings:      0.0,
 	}
 
-	// Use a transaction to ensure both node creation and user role update succeed
-	tx := database.DB.Begin()
-
-	if err := tx.Create(&newNode).Error; err != nil {
-		tx.Rollback()
+	if err := database.DB.Create(&newNode).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register network node"})
 		return
 	}
 
-	// Update user role to be a network node operator
-	if err := tx.Model(&domain.User{}).Where("id = ?", userIDStr).Update("role", "NetworkNodeOperator").Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role"})
-		return
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
-		return
-	}
-
 	c.JSON(http.StatusCreated, newNode)
 }
 
@@ -471,7 +824,6 @@ type DashboardStats struct {
 // GetAnalyticsDashboard retrieves real-time market analytics.
 func GetAnalyticsDashboard(c *gin.Context) {
 	stats := DashboardStats{}
-	var totalKwh sql.NullFloat64
 
 	// Concurrently fetch stats from the database
 	errChan := make(chan error, 5)
@@ -486,23 +838,21 @@ func GetAnalyticsDashboard(c *gin.Context) {
 		errChan <- database.DB.Model(&domain.NetworkNode{}).Count(&stats.TotalNetworkNodes).Error
 	}()
 	go func() {
-		errChan <- database.DB.Model(&domain.EnergyOrder{}).Where("status = ?", "Created").Count(&stats.ActiveOrders).Error
+		errChan <- database.DB.Model(&domain.EnergyOrder{}).Where("status IN ?", domain.OpenOrderStatuses).Count(&stats.ActiveOrders).Error
 	}()
 	go func() {
 		// Sum of kwh_amount for completed transactions
-		errChan <- database.DB.Model(&domain.Transaction{}).Where("status = ?", "Completed").Select("sum(kwh_amount)").Row().Scan(&totalKwh)
+		errChan <- database.DB.Model(&domain.Transaction{}).Where("status = ?", "Completed").Select("COALESCE(SUM(kwh_amount), 0)").Scan(&stats.TotalEnergyTraded).Error
 	}()
 
 	// Wait for all goroutines to finish and check for errors
 	for i := 0; i < 5; i++ {
-		if err := <-errChan; err != nil && err != sql.ErrNoRows {
+		if err := <-errChan; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve dashboard stats"})
 			return
 		}
 	}
 
-	stats.TotalEnergyTraded = totalKwh.Float64 // Will be 0.0 if no completed transactions
-
 	c.JSON(http.StatusOK, stats)
 }
 
@@ -532,6 +882,8 @@ var upgrader = websocket.Upgrader{
 }
 
 // MarketDataWS handles WebSocket connections for real-time market data.
+// Clients receive a market_status event after every matching tick, including
+// the circuit breaker state.
 func MarketDataWS(c *gin.Context) {
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -542,32 +894,57 @@ func MarketDataWS(c *gin.Context) {
 
 	log.Println("Client connected to market data WebSocket.")
 
-	// Simple welcome message
+	events, unsubscribe := feed.Market.Subscribe()
+	defer unsubscribe()
+
+	// Simple welcome message, followed by the current breaker state
 	err = conn.WriteMessage(websocket.TextMessage, []byte("Connected to real-time market data feed."))
 	if err != nil {
 		log.Printf("Error sending welcome message: %v", err)
 		return
 	}
+	if err := conn.WriteJSON(feed.Event{
+		Type:      "circuit_breaker",
+		Data:      pricing.Breaker.State(time.Now()),
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}); err != nil {
+		log.Printf("Error sending breaker state: %v", err)
+		return
+	}
+
+	// The read loop only detects the client closing the connection.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				log.Printf("Client disconnected: %v", err)
+				return
+			}
+		}
+	}()
 
-	// For now, we will just keep the connection open and listen for a close message.
-	// In the future, this is where we would broadcast market data.
 	for {
-		// We can read messages from the client if needed, but for a broadcast-only WS, we might not.
-		// This read is mainly to detect if the client has closed the connection.
-		if _, _, err := conn.ReadMessage(); err != nil {
-			log.Printf("Client disconnected: %v", err)
-			break
+		select {
+		case event := <-events:
+			if err := conn.WriteJSON(event); err != nil {
+				log.Printf("Error writing market event: %v", err)
+				return
+			}
+		case <-closed:
+			return
 		}
 	}
 }
 
 // MarketPriceResponse defines the structure for the market price response.
 type MarketPriceResponse struct {
-	Price     float64            `json:"price"`
-	Supply    int64              `json:"supply"`
-	Demand    int64              `json:"demand"`
-	Timestamp string             `json:"timestamp"`
-	Breakdown map[string]float64 `json:"breakdown"`
+	Price          float64              `json:"price"`
+	Supply         int64                `json:"supply"`
+	Demand         int64                `json:"demand"`
+	Timestamp      string               `json:"timestamp"`
+	Breakdown      map[string]float64   `json:"breakdown"`
+	CircuitBreaker pricing.BreakerState `json:"circuit_breaker"`
 }
 
 // GetMarketPrice calculates the current estimated market price using the matching engine's logic.
@@ -576,8 +953,8 @@ func GetMarketPrice(c *gin.Context) {
 	var buyOrdersCount int64
 
 	// Get Supply and Demand
-	database.DB.Model(&domain.EnergyOrder{}).Where("type = ? AND status = ?", "sell", "Created").Count(&sellOrdersCount)
-	database.DB.Model(&domain.EnergyOrder{}).Where("type = ? AND status = ?", "buy", "Created").Count(&buyOrdersCount)
+	database.DB.Model(&domain.EnergyOrder{}).Where("type = ? AND market = ? AND status IN ?", "sell", domain.MarketContinuous, domain.OpenOrderStatuses).Count(&sellOrdersCount)
+	database.DB.Model(&domain.EnergyOrder{}).Where("type = ? AND market = ? AND status IN ?", "buy", domain.MarketContinuous, domain.OpenOrderStatuses).Count(&buyOrdersCount)
 
 	supplyVol := float64(sellOrdersCount)
 	demandVol := float64(buyOrdersCount)
@@ -588,32 +965,104 @@ func GetMarketPrice(c *gin.Context) {
 	var lowestSellOrder domain.EnergyOrder
 	var basePrice float64
 
-	if err := database.DB.Where("type = ? AND status = ?", "sell", "Created").Order("token_price asc").First(&lowestSellOrder).Error; err == nil {
+	if err := database.DB.Where("type = ? AND market = ? AND status IN ?", "sell", domain.MarketContinuous, domain.OpenOrderStatuses).Order("token_price asc").First(&lowestSellOrder).Error; err == nil {
 		basePrice = lowestSellOrder.TokenPrice
 	} else {
 		// Fallback if no sell orders exist
 		basePrice = 0.50 // Default base price
 	}
 
-	// Calculate Dynamic Price
-	// We create dummy orders for calculation
-	dummyBuy := domain.EnergyOrder{}
-	dummySell := domain.EnergyOrder{UserID: "user_a", TokenPrice: basePrice} // Use 'user_a' from simulation for quality variance
-
+	// Price a neutral trade (neighbouring seller, average quality); reads are
+	// not recorded in the pricing history
 	pe := pricing.NewPricingEngine()
 	pe.Config.BasePrice = basePrice
 
-	dynamicPrice, breakdown, _ := pe.CalculateDynamicPrice(dummyBuy, dummySell, supplyVol, demandVol, socAvg, 1.0)
+	dynamicPrice, breakdown := pe.ReferencePrice(supplyVol, demandVol, socAvg)
+
+	// Report the price the matching engine would actually use after damping
+	breaker := pricing.Breaker.State(time.Now())
+	dynamicPrice *= breaker.DampingRatio()
 
 	// Debug log to see fluctuations
 	log.Printf("Price Pre-calc: SoC=%.2f, Quality=%.2f, Final=%.2f", socAvg, breakdown["f_quality"], dynamicPrice)
 
 	c.JSON(http.StatusOK, MarketPriceResponse{
-		Price:     dynamicPrice,
-		Supply:    sellOrdersCount,
-		Demand:    buyOrdersCount,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-		Breakdown: breakdown,
+		Price:          dynamicPrice,
+		Supply:         sellOrdersCount,
+		Demand:         buyOrdersCount,
+		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+		Breakdown:      breakdown,
+		CircuitBreaker: breaker,
+	})
+}
+
+// MarketQuoteResponse is the priced walk of the book returned to a prospective buyer.
+type MarketQuoteResponse struct {
+	QuoteID   string `json:"quote_id"`
+	ExpiresAt string `json:"expires_at"`
+	pricing.BookQuote
+}
+
+// GetMarketQuote prices a buy of the requested size against the current sell
+// orders, optionally restricted to a counterparty or a single order, and returns
+// a signed quote that CreateOrder will honour until it expires.
+func GetMarketQuote(c *gin.Context) {
+	var req MarketQuoteRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	userIDStr := userID.(string)
+
+	query := database.DB.Where("type = ? AND market = ? AND status IN ? AND user_id <> ?", "sell", domain.MarketContinuous, domain.OpenOrderStatuses, userIDStr)
+	if req.Counterparty != "" {
+		query = query.Where("user_id = ?", req.Counterparty)
+	}
+	if req.OrderID != "" {
+		query = query.Where("id = ?", req.OrderID)
+	}
+
+	var sells []domain.EnergyOrder
+	if err := query.Find(&sells).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve orders"})
+		return
+	}
+	if len(sells) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No matching sell orders available"})
+		return
+	}
+
+	// Same market inputs as the matching engine
+	supplyVol, demandVol, socAvg := matching.CurrentMarketState()
+
+	breaker := pricing.Breaker.State(time.Now())
+	if breaker.Halted {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Trading is halted: " + breaker.Reason, "halted_until": breaker.HaltedUntil})
+		return
+	}
+
+	pe := pricing.NewPricingEngine()
+	book := pe.QuoteBook(req.KwhAmount, sells, supplyVol, demandVol, socAvg, breaker.DampingRatio())
+
+	expiresAt := time.Now().Add(quoteTTL)
+	quoteID, err := pricing.SignQuote(pricing.Quote{
+		ID:        uuid.New().String(),
+		UserID:    userIDStr,
+		KwhAmount: req.KwhAmount,
+		Price:     book.WorstPrice,
+		ExpiresAt: expiresAt.Unix(),
+	}, quoteSecret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign quote"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MarketQuoteResponse{
+		QuoteID:   quoteID,
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+		BookQuote: book,
 	})
 }
 
@@ -648,3 +1097,87 @@ func GetMarketHistory(c *gin.Context) {
 
 	c.JSON(http.StatusOK, history)
 }
+
+// MarketCandlesResponse defines the structure for the /market/candles response.
+type MarketCandlesResponse struct {
+	Source   string           `json:"source"`
+	Interval string           `json:"interval"`
+	From     string           `json:"from"`
+	To       string           `json:"to"`
+	Candles  []candles.Candle `json:"candles"`
+}
+
+// GetMarketCandles returns OHLCV candles for the requested interval and range,
+// built from executed trades or, with source=indicative, from PricingHistory.
+func GetMarketCandles(c *gin.Context) {
+	var req MarketCandlesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	interval, err := candles.ParseInterval(req.Interval)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	to := req.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := req.From
+	if from.IsZero() {
+		from = to.Add(-100 * interval)
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'from' must be before 'to'"})
+		return
+	}
+	if to.Sub(from)/interval > candles.MaxCandles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Range is too large for this interval"})
+		return
+	}
+
+	source := req.Source
+	if source == "" {
+		source = "trades"
+	}
+
+	var bars []candles.Candle
+	if source == "indicative" {
+		buckets, err := database.IndicativePriceSeries(database.DB, from, to, interval)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pricing history"})
+			return
+		}
+		bars = candles.FromBuckets(buckets, interval)
+	} else {
+		var transactions []domain.Transaction
+		if err := database.DB.Where("timestamp >= ? AND timestamp < ? AND status IN ?", from, to, domain.ExecutedTransactionStatuses).Find(&transactions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transactions"})
+			return
+		}
+		var samples []candles.Sample
+		for _, txn := range transactions {
+			if txn.KwhAmount <= 0 {
+				continue
+			}
+			samples = append(samples, candles.Sample{
+				Time:   txn.Timestamp,
+				Price:  txn.TokenAmount / txn.KwhAmount,
+				Kwh:    txn.KwhAmount,
+				Tokens: txn.TokenAmount,
+			})
+		}
+		bars = candles.Aggregate(samples, interval)
+	}
+
+	c.JSON(http.StatusOK, MarketCandlesResponse{
+		Source:   source,
+		Interval: req.Interval,
+		From:     from.UTC().Format(time.RFC3339),
+		To:       to.UTC().Format(time.RFC3339),
+		Candles:  bars,
+	})
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"los-tecnicos/backend/internal/cache"
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// IdempotencyKeyHeader may carry the client order ID instead of the request body.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on responses that return an existing order.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// idempotencyTTL is how long a client order ID is answered from Redis. Older
+// keys are still found in Postgres, whose unique index is the final guard.
+var idempotencyTTL = time.Duration(config.GetEnvAsInt("IDEMPOTENCY_TTL_HOURS", 24)) * time.Hour
+
+// idempotencyLockTTL bounds how long an in-flight request holds its key.
+const idempotencyLockTTL = 30 * time.Second
+
+// idempotencyPending marks a key whose order is still being created.
+const idempotencyPending = "pending"
+
+var (
+	errClientOrderIDMismatch = errors.New("client_order_id and Idempotency-Key header differ")
+	errIdempotencyInFlight   = errors.New("a request with this key is still in progress")
+)
+
+// resolveClientOrderID returns the client order ID from the body or the
+// Idempotency-Key header. Both may be given only if they agree.
+func resolveClientOrderID(body, header string) (string, error) {
+	if body != "" && header != "" && body != header {
+		return "", errClientOrderIDMismatch
+	}
+	if body != "" {
+		return body, nil
+	}
+	return header, nil
+}
+
+func idempotencyCacheKey(userID, clientOrderID string) string {
+	return "idempotency:order:" + userID + ":" + clientOrderID
+}
+
+// findIdempotentOrder returns the order already created by the user with this
+// client order ID, or nil if there is none. Redis is checked first; on a miss
+// or a Redis failure the lookup falls back to Postgres.
+func findIdempotentOrder(userID, clientOrderID string) (*domain.EnergyOrder, error) {
+	ctx := context.Background()
+	query := database.DB.Where("user_id = ? AND client_order_id = ?", userID, clientOrderID)
+
+	cached, err := cache.Rdb.Get(ctx, idempotencyCacheKey(userID, clientOrderID)).Result()
+	switch {
+	case err == nil && cached == idempotencyPending:
+		return nil, errIdempotencyInFlight
+	case err == nil:
+		query = database.DB.Where("id = ? AND user_id = ?", cached, userID)
+	case err != redis.Nil:
+		log.Printf("Warning: Redis error on idempotency lookup (continuing to DB): %v", err)
+	}
+
+	var order domain.EnergyOrder
+	if err := query.First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// reserveIdempotencyKey marks the key as in flight so that a concurrent retry
+// waits rather than racing to create a second order. It returns false if the
+// key is already held. If Redis is unavailable the reservation is skipped and
+// the unique index alone prevents duplicates.
+func reserveIdempotencyKey(userID, clientOrderID string) bool {
+	ok, err := cache.Rdb.SetNX(context.Background(), idempotencyCacheKey(userID, clientOrderID), idempotencyPending, idempotencyLockTTL).Result()
+	if err != nil {
+		log.Printf("Warning: Redis error reserving idempotency key: %v", err)
+		return true
+	}
+	return ok
+}
+
+// recordIdempotencyKey maps the key to the created order for the idempotency window.
+func recordIdempotencyKey(userID, clientOrderID, orderID string) {
+	if err := cache.Rdb.Set(context.Background(), idempotencyCacheKey(userID, clientOrderID), orderID, idempotencyTTL).Err(); err != nil {
+		log.Printf("Warning: Redis error recording idempotency key: %v", err)
+	}
+}
+
+// releaseIdempotencyKey frees a reservation after a failed creation so the client can retry.
+func releaseIdempotencyKey(userID, clientOrderID string) {
+	if err := cache.Rdb.Del(context.Background(), idempotencyCacheKey(userID, clientOrderID)).Err(); err != nil {
+		log.Printf("Warning: Redis error releasing idempotency key: %v", err)
+	}
+}
+
+// replayOrder answers a retried creation with the original order, provided the
+// retry describes the same order.
+func replayOrder(c *gin.Context, order *domain.EnergyOrder, req CreateOrderRequest) {
+	if order.Type != req.Type || order.KwhAmount != req.KwhAmount {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "client_order_id was already used for a different order"})
+		return
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.JSON(http.StatusOK, order)
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import "testing"
+
+func TestResolveClientOrderID(t *testing.T) {
+	cases := []struct {
+		body, header string
+		want         string
+		wantErr      bool
+	}{
+		{"", "", "", false},
+		{"abc", "", "abc", false},
+		{"", "abc", "abc", false},
+		{"abc", "abc", "abc", false},
+		{"abc", "xyz", "", true},
+	}
+	for _, tc := range cases {
+		got, err := resolveClientOrderID(tc.body, tc.header)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("resolveClientOrderID(%q, %q) = %q, %v", tc.body, tc.header, got, err)
+		}
+	}
+}
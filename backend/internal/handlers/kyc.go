@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/kyc"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxKYCDocumentBytes is the largest KYC document accepted, from KYC_MAX_DOCUMENT_KB.
+var maxKYCDocumentBytes = config.GetEnvAsInt("KYC_MAX_DOCUMENT_KB", 5*1024) * 1024
+
+// kycVerifyTimeout bounds how long a submission waits for the verifier.
+const kycVerifyTimeout = 30 * time.Second
+
+// kycDocumentTypes are the file types accepted, as sniffed from the content.
+var kycDocumentTypes = map[string]bool{"image/jpeg": true, "image/png": true, "application/pdf": true}
+
+// KYCStatusResponse describes the authenticated user's KYC standing.
+type KYCStatusResponse struct {
+	Status      string                `json:"status"`
+	Tier        int                   `json:"tier"`
+	Limits      kyc.Limits            `json:"limits"`
+	TradedToday float64               `json:"traded_today"` // Notional counted against the daily limit
+	Submission  *domain.KYCSubmission `json:"submission,omitempty"`
+}
+
+// KYCSubmissionResponse is a submission with the documents submitted with it.
+type KYCSubmissionResponse struct {
+	domain.KYCSubmission
+	Documents []domain.KYCDocument `json:"documents"`
+}
+
+// decodeKYCDocument decodes base64 content and returns it with its sniffed
+// content type, or a message saying why it is not acceptable.
+func decodeKYCDocument(content string) ([]byte, string, string) {
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, "", "content must be base64 encoded"
+	}
+	if len(data) == 0 {
+		return nil, "", "Document is empty"
+	}
+	if len(data) > maxKYCDocumentBytes {
+		return nil, "", "Document is larger than the limit"
+	}
+	contentType := http.DetectContentType(data)
+	if !kycDocumentTypes[contentType] {
+		return nil, "", "Documents must be JPEG, PNG or PDF files"
+	}
+	return data, contentType, ""
+}
+
+// UploadKYCDocument stores a document for the authenticated user to submit
+// with a KYC submission.
+func UploadKYCDocument(c *gin.Context) {
+	var req UploadKYCDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	data, contentType, msg := decodeKYCDocument(req.Content)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	sum := sha256.Sum256(data)
+	doc := domain.KYCDocument{
+		ID:          uuid.New().String(),
+		UserID:      userID.(string),
+		Type:        req.Type,
+		FileName:    req.FileName,
+		ContentType: contentType,
+		Size:        len(data),
+		SHA256:      hex.EncodeToString(sum[:]),
+		Content:     data,
+		CreatedAt:   time.Now(),
+	}
+	if err := database.DB.Create(&doc).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store document"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, doc)
+}
+
+// SubmitKYC submits the authenticated user's details and uploaded documents
+// for verification at a tier. The verifier approves or rejects it at once, or
+// leaves it in the admin review queue.
+func SubmitKYC(c *gin.Context) {
+	var req SubmitKYCRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.Tier >= domain.KYCTierFull && req.Address == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "address is required for tier 2"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	userIDStr := userID.(string)
+
+	var user domain.User
+	if err := database.DB.Where("id = ?", userIDStr).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User profile not found"})
+		return
+	}
+	if user.KYCTier >= req.Tier {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You are already verified at this tier"})
+		return
+	}
+
+	documentIDs := uniqueStrings(req.DocumentIDs)
+	var documents []domain.KYCDocument
+	if err := database.DB.Omit("content").Where("id IN ? AND user_id = ? AND submission_id = ''", documentIDs, userIDStr).Find(&documents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load documents"})
+		return
+	}
+	if len(documents) != len(documentIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Documents must be your own uploads not yet submitted"})
+		return
+	}
+	types := make([]string, 0, len(documents))
+	for _, doc := range documents {
+		types = append(types, doc.Type)
+	}
+	if err := domain.CheckKYCDocuments(req.Tier, types); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	submission := domain.KYCSubmission{
+		ID:          uuid.New().String(),
+		UserID:      userIDStr,
+		Tier:        req.Tier,
+		FullName:    req.FullName,
+		DateOfBirth: req.DateOfBirth,
+		Country:     req.Country,
+		Address:     req.Address,
+		Provider:    kyc.Current.Name(),
+		CreatedAt:   time.Now(),
+	}
+
+	// A provider failure leaves the submission to an admin rather than failing it
+	ctx, cancel := context.WithTimeout(c.Request.Context(), kycVerifyTimeout)
+	result, err := kyc.Current.Verify(ctx, submission, documents)
+	cancel()
+	if err != nil {
+		log.Printf("KYC provider %s failed on submission %s: %v", submission.Provider, submission.ID, err)
+		result = kyc.Result{Decision: kyc.DecisionReview, Reason: "Provider unavailable"}
+	}
+	submission.ProviderDecision = result.Decision
+	submission.ProviderReason = result.Reason
+	submission.ProviderReference = result.Reference
+
+	err = database.CreateKYCSubmission(database.DB, &submission, documentIDs)
+	if errors.Is(err, database.ErrKYCInReview) {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have a KYC submission in review"})
+		return
+	}
+	if errors.Is(err, database.ErrKYCDocumentUnavailable) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A document was submitted concurrently, upload it again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit KYC"})
+		return
+	}
+
+	status := ""
+	switch result.Decision {
+	case kyc.DecisionApprove:
+		status = domain.KYCStatusApproved
+	case kyc.DecisionReject:
+		status = domain.KYCStatusRejected
+	}
+	if status != "" {
+		decided, err := database.DecideKYCSubmission(database.DB, submission.ID, status, domain.KYCSystemReviewer+submission.Provider, result.Reason)
+		if err != nil {
+			log.Printf("Failed to record the %s decision on KYC submission %s: %v", submission.Provider, submission.ID, err)
+		} else {
+			submission = decided
+		}
+	}
+
+	c.JSON(http.StatusCreated, submission)
+}
+
+// GetKYC returns the authenticated user's KYC status, tier, limits and latest submission.
+func GetKYC(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	userIDStr := userID.(string)
+
+	var user domain.User
+	if err := database.DB.Where("id = ?", userIDStr).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User profile not found"})
+		return
+	}
+	traded, err := database.TradedNotionalSince(database.DB, userIDStr, time.Now().Add(-24*time.Hour), "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute trading volume"})
+		return
+	}
+
+	resp := KYCStatusResponse{Status: user.KYCStatus, Tier: user.KYCTier, Limits: kyc.TierLimits(user.KYCTier), TradedToday: traded}
+	var latest domain.KYCSubmission
+	if err := database.DB.Where("user_id = ?", userIDStr).Order("created_at DESC").First(&latest).Error; err == nil {
+		resp.Submission = &latest
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetKYCHistory lists every change of the authenticated user's KYC status, newest first.
+func GetKYCHistory(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var changes []domain.KYCStatusChange
+	if err := database.DB.Where("user_id = ?", userID.(string)).Order("created_at DESC, id DESC").Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve KYC history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+// ListKYCSubmissions is the admin review queue: every submission with a
+// status (default in_review), oldest first.
+func ListKYCSubmissions(c *gin.Context) {
+	var req ListKYCSubmissionsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	status := req.Status
+	if status == "" {
+		status = domain.KYCStatusInReview
+	}
+
+	var submissions []domain.KYCSubmission
+	if err := database.DB.Where("status = ?", status).Order("created_at").Find(&submissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve KYC submissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, submissions)
+}
+
+// GetKYCSubmission returns a submission and its documents' metadata.
+func GetKYCSubmission(c *gin.Context) {
+	var resp KYCSubmissionResponse
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&resp.KYCSubmission).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "KYC submission not found"})
+		return
+	}
+	if err := database.DB.Omit("content").Where("submission_id = ?", resp.ID).Order("created_at").Find(&resp.Documents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve documents"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetKYCDocument serves a document's file to a reviewer. Every access is logged.
+func GetKYCDocument(c *gin.Context) {
+	reviewerID, _ := c.Get("userID")
+
+	var doc domain.KYCDocument
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&doc).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+	log.Printf("KYC document %s of user %s viewed by %v", doc.ID, doc.UserID, reviewerID)
+
+	c.Header("Content-Disposition", "attachment")
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, doc.ContentType, doc.Content)
+}
+
+// ApproveKYCSubmission approves a submission in review, raising the user's tier.
+func ApproveKYCSubmission(c *gin.Context) {
+	reviewKYCSubmission(c, domain.KYCStatusApproved)
+}
+
+// RejectKYCSubmission rejects a submission in review; a reason is required.
+func RejectKYCSubmission(c *gin.Context) {
+	reviewKYCSubmission(c, domain.KYCStatusRejected)
+}
+
+// reviewKYCSubmission decides the submission in the :id path parameter.
+// Admins cannot review their own submissions.
+func reviewKYCSubmission(c *gin.Context, status string) {
+	var req ReviewKYCRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if status == domain.KYCStatusRejected && req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to reject a submission"})
+		return
+	}
+
+	reviewerID, _ := c.Get("userID")
+	reviewerIDStr := reviewerID.(string)
+
+	var submission domain.KYCSubmission
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&submission).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "KYC submission not found"})
+		return
+	}
+	if submission.UserID == reviewerIDStr {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot review your own KYC submission"})
+		return
+	}
+
+	decided, err := database.DecideKYCSubmission(database.DB, submission.ID, status, reviewerIDStr, req.Reason)
+	if errors.Is(err, database.ErrKYCReviewed) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Submission was already reviewed"})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "KYC submission not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review KYC submission"})
+		return
+	}
+
+	c.JSON(http.StatusOK, decided)
+}
+
+// checkKYCLimits returns kyc.ErrOrderLimit or kyc.ErrDailyLimit if an order
+// of notional would exceed the limits of userID's KYC tier. excludeOrderID is
+// an order being amended, whose new notional is the one checked. It locks the
+// user's row, so it must run in the transaction that writes the order.
+func checkKYCLimits(tx *gorm.DB, userID string, notional float64, excludeOrderID string) error {
+	tier, err := database.LockKYCTier(tx, userID)
+	if err != nil {
+		return err
+	}
+	traded, err := database.TradedNotionalSince(tx, userID, time.Now().Add(-24*time.Hour), excludeOrderID)
+	if err != nil {
+		return err
+	}
+	return kyc.TierLimits(tier).Check(notional, traded)
+}
+
+// isKYCLimitError reports whether err is a limit returned by checkKYCLimits.
+func isKYCLimitError(err error) bool {
+	return errors.Is(err, kyc.ErrOrderLimit) || errors.Is(err, kyc.ErrDailyLimit)
+}
+
+// respondKYCLimitError responds 403 to a limit returned by checkKYCLimits.
+func respondKYCLimitError(c *gin.Context, err error) {
+	c.JSON(http.StatusForbidden, gin.H{"error": "Trading limit reached: " + err.Error() + "; complete KYC at a higher tier to raise it, see /kyc"})
+}
+
+// uniqueStrings returns values without duplicates, in their original order.
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestDecodeKYCDocument(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 16)...)
+	data, contentType, msg := decodeKYCDocument(base64.StdEncoding.EncodeToString(png))
+	if msg != "" || contentType != "image/png" || len(data) != len(png) {
+		t.Errorf("Expected a PNG to be accepted, got %q, %q", contentType, msg)
+	}
+
+	for name, content := range map[string]string{
+		"not base64": "%%%",
+		"empty":      "",
+		"text":       base64.StdEncoding.EncodeToString([]byte("just some text")),
+		"too large":  base64.StdEncoding.EncodeToString(append([]byte("%PDF-"), make([]byte, maxKYCDocumentBytes)...)),
+	} {
+		if _, _, msg := decodeKYCDocument(content); msg == "" {
+			t.Errorf("%s: expected the document to be refused", name)
+		}
+	}
+}
@@ -7,10 +7,12 @@ import (
 	"strings"
 	"time"
 
+	"los-tecnicos/backend/internal/auth"
 	"los-tecnicos/backend/internal/cache"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 // AuthMiddleware validates the JWT and sets user info in the context.
@@ -28,21 +30,66 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		tokenString := parts[1]
-		claims := &Claims{}
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-
-		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		claims, problem := parseAccessToken(parts[1])
+		if claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": problem})
 			return
 		}
 
 		// Set user info in the context for subsequent handlers
 		c.Set("userID", claims.UserID)
 		c.Set("userRole", claims.Role)
+		c.Set("sessionID", claims.SessionID)
+
+		c.Next()
+	}
+}
+
+// parseAccessToken verifies an access token and checks it has not been
+// revoked. On failure it returns nil and the reason to report to the client.
+func parseAccessToken(tokenString string) (*Claims, string) {
+	claims := &Claims{}
+	if err := auth.Keys.Parse(tokenString, claims); err != nil {
+		return nil, "Invalid or expired token"
+	}
+
+	revoked, err := auth.IsRevoked(claims.ID, claims.SessionID)
+	if err != nil {
+		log.Printf("Warning: Token denylist check failed (Redis down?): %v", err)
+		revoked = !sessionLive(claims.SessionID)
+	}
+	if revoked {
+		return nil, "Token has been revoked"
+	}
+	return claims, ""
+}
+
+// sessionLive checks the session an access token was issued under in the
+// database, for when the denylist is unavailable. It fails closed: tokens
+// without a session, or whose session cannot be checked, are not live.
+func sessionLive(sid string) bool {
+	if sid == "" {
+		return false
+	}
+	live, err := database.SessionFamilyLive(database.DB, sid)
+	if err != nil {
+		log.Printf("Warning: Session check for access token failed: %v", err)
+		return false
+	}
+	return live
+}
+
+// RequirePermission rejects requests whose role (set by AuthMiddleware) does
+// not grant every one of permissions.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("userRole")
+		for _, permission := range permissions {
+			if !domain.HasPermission(role, permission) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Your role (" + role + ") does not have the " + permission + " permission"})
+				return
+			}
+		}
 
 		c.Next()
 	}
@@ -100,8 +147,9 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Idempotency-Key")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", IdempotentReplayedHeader)
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/cache"
+	"los-tecnicos/backend/internal/core/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// preflight sends a CORS preflight for method and path through CORSMiddleware.
+func preflight(method, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORSMiddleware())
+	req := httptest.NewRequest(http.MethodOptions, path, nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Access-Control-Request-Method", method)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSAllowsIdempotencyKey(t *testing.T) {
+	w := preflight(http.MethodPost, "/api/v1/market/order/create")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 for a preflight, got %d", w.Code)
+	}
+	if allowed := w.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(allowed, IdempotencyKeyHeader) {
+		t.Errorf("Expected %s to be an allowed header, got %q", IdempotencyKeyHeader, allowed)
+	}
+	if exposed := w.Header().Get("Access-Control-Expose-Headers"); !strings.Contains(exposed, IdempotentReplayedHeader) {
+		t.Errorf("Expected %s to be exposed, got %q", IdempotentReplayedHeader, exposed)
+	}
+}
+
+func TestCORSPreflightAllowsEveryRouteMethod(t *testing.T) {
+	var routes []string
+	for route := range routePermissions {
+		routes = append(routes, route)
+	}
+	for route := range publicRoutes {
+		routes = append(routes, route)
+	}
+
+	for _, route := range routes {
+		method, path, _ := strings.Cut(route, " ")
+		w := preflight(method, strings.Replace(path, ":id", "x", 1))
+		if w.Code != http.StatusNoContent {
+			t.Errorf("Preflight for %s: expected 204, got %d", route, w.Code)
+		}
+		if allowed := w.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(allowed, method) {
+			t.Errorf("Preflight for %s: %s is not in the allowed methods %q", route, method, allowed)
+		}
+	}
+}
+
+func TestAccessTokenFailsClosedWithoutDenylist(t *testing.T) {
+	setupTestRouter(t)
+	// Unlike the test router's, this Redis cannot answer the denylist lookup
+	cache.Rdb = redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 50 * time.Millisecond})
+
+	if claims, reason := parseAccessToken(tokenFor(t, domain.RoleRecipient)); claims != nil || reason != "Token has been revoked" {
+		t.Errorf("Expected a token that cannot be checked to be rejected, got %v %q", claims, reason)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/forecast"
+	"los-tecnicos/backend/internal/pricing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SimulatePricing replays stored PricingHistory inputs under the current
+// governance parameters and a candidate PricingConfig so the community council
+// can see the effect of a proposal before voting on it.
+func SimulatePricing(c *gin.Context) {
+	var req PricingSimulationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	current := pricing.FetchGovernanceParams()
+
+	// Unspecified parameters keep their current value
+	proposed := current
+	if req.BasePrice != nil {
+		proposed.BasePrice = *req.BasePrice
+	}
+	if req.Alpha != nil {
+		proposed.Alpha = *req.Alpha
+	}
+	if req.Beta != nil {
+		proposed.Beta = *req.Beta
+	}
+	if req.Gamma != nil {
+		proposed.Gamma = *req.Gamma
+	}
+	if req.Delta != nil {
+		proposed.Delta = *req.Delta
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = 1000
+	}
+
+	query := database.DB.Model(&domain.PricingHistory{})
+	if !req.From.IsZero() {
+		query = query.Where("timestamp >= ?", req.From)
+	}
+	if !req.To.IsZero() {
+		query = query.Where("timestamp <= ?", req.To)
+	}
+
+	// Most recent records, replayed in chronological order
+	var history []domain.PricingHistory
+	if err := query.Order("timestamp desc").Limit(limit).Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pricing history"})
+		return
+	}
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+
+	c.JSON(http.StatusOK, pricing.ReplayHistory(current, proposed, history))
+}
+
+// ForecastResponse defines the structure for the /analytics/forecast response.
+type ForecastResponse struct {
+	GeneratedAt string           `json:"generated_at"`
+	Model       *forecast.Model  `json:"model"`
+	Points      []forecast.Point `json:"points"`
+}
+
+// GetForecast returns hourly predictions of community SoC, supply and demand
+// learned from the daily and weekly profiles in PricingHistory.
+func GetForecast(c *gin.Context) {
+	var req ForecastRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.Hours == 0 {
+		req.Hours = 24
+	}
+
+	model := forecast.Current()
+	if model.Samples == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not enough pricing history to forecast"})
+		return
+	}
+
+	now := time.Now()
+	c.JSON(http.StatusOK, ForecastResponse{
+		GeneratedAt: now.UTC().Format(time.RFC3339),
+		Model:       model,
+		Points:      model.Forecast(now, req.Hours),
+	})
+}
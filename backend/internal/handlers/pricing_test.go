@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func TestSimulatePricingRejectsOutOfRangeParameters(t *testing.T) {
+	router := setupTestRouter(t)
+	for _, body := range []string{`{"alpha": -0.1}`, `{"alpha": 3}`, `{"beta": 6}`, `{"gamma": 1.5}`, `{"delta": -1}`, `{"delta": 10}`} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/analytics/pricing/simulate", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+tokenFor(t, domain.RoleAdmin))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+}
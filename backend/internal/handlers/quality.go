@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDeviceQuality returns the latest quality score of one of the
+// authenticated user's devices, with the breakdown it was computed from.
+func GetDeviceQuality(c *gin.Context) {
+	device, ok := ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	var metrics domain.DeviceQualityMetrics
+	if err := database.DB.Where("device_id = ?", device.ID).First(&metrics).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device has not been scored yet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"los-tecnicos/backend/internal/cache"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/pricing"
+)
+
+// CheckQuoteSecret refuses to run with the default QUOTE_SECRET unless
+// APP_ENV is development.
+func CheckQuoteSecret() error {
+	return pricing.CheckQuoteSecret(quoteSecret)
+}
+
+func quoteRedemptionKey(quote *pricing.Quote) string {
+	return "quote:redeemed:" + quote.ID
+}
+
+// redeemQuote claims a quote for one order, returning false if another order
+// already claimed it. The claim is held in Redis until the quote expires, after
+// which the quote is refused anyway. If Redis is unavailable, orders already
+// placed with the quote are looked up in Postgres instead.
+func redeemQuote(quote *pricing.Quote, token string) bool {
+	ttl := time.Until(time.Unix(quote.ExpiresAt, 0)) + time.Minute
+	ok, err := cache.Rdb.SetNX(context.Background(), quoteRedemptionKey(quote), "1", ttl).Result()
+	if err == nil {
+		return ok
+	}
+	log.Printf("Warning: Redis error redeeming quote (continuing to DB): %v", err)
+
+	var used int64
+	if err := database.DB.Model(&domain.EnergyOrder{}).Where("quote_id = ?", token).Count(&used).Error; err != nil {
+		log.Printf("Warning: could not check quote redemption: %v", err)
+		return false
+	}
+	return used == 0
+}
+
+// releaseQuote frees a quote whose order was not created, so the buyer can
+// retry with it before it expires.
+func releaseQuote(quote *pricing.Quote) {
+	if err := cache.Rdb.Del(context.Background(), quoteRedemptionKey(quote)).Err(); err != nil {
+		log.Printf("Warning: Redis error releasing quote: %v", err)
+	}
+}
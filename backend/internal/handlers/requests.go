@@ -1,5 +1,11 @@
 package handlers
 
+import (
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
 // SignUpRequest defines the structure for the /auth/signup request.
 type SignUpRequest struct {
 	WalletAddress string `json:"wallet_address" binding:"required"`
@@ -7,219 +13,254 @@ type SignUpRequest struct {
 }
 
 // LoginRequest defines the structure for the /auth/login request.
-
 type LoginRequest struct {
-
 	WalletAddress string `json:"wallet_address" binding:"required"`
-
 	Signature     string `json:"signature" binding:"required"` // Base64 encoded signature
-
 }
 
-
-
 // RegisterDeviceRequest defines the structure for the /iot/device/register request.
-
-
-
 type RegisterDeviceRequest struct {
-
-
-
-	DeviceType string `json:"device_type" binding:"required,oneof=esp32 raspi"`
-
-
-
-	Location   string `json:"location" binding:"required"`
-
-
-
+	DeviceType   string                `json:"device_type" binding:"required,oneof=esp32 raspi"`
+	Location     string                `json:"location" binding:"required"`
+	CapacityKwh  float64               `json:"capacity_kwh" binding:"omitempty,gt=0,max=1000"`
+	BatteryLevel float64               `json:"battery_level" binding:"omitempty,gte=0,lte=1"` // State of charge until the device sends telemetry
+	Metadata     domain.DeviceMetadata `json:"metadata" binding:"omitempty,max=20,dive,keys,min=1,max=64,endkeys,max=500"`
 }
 
+// UpdateDeviceRequest defines the structure for PATCH /iot/device/:id. Omitted fields are unchanged.
+type UpdateDeviceRequest struct {
+	Location *string               `json:"location" binding:"omitempty,min=1,max=200"`
+	Metadata domain.DeviceMetadata `json:"metadata" binding:"omitempty,max=20,dive,keys,min=1,max=64,endkeys,max=500"` // Replaces the existing metadata
+}
 
+// DecommissionDeviceRequest defines the structure for the /iot/device/:id/decommission request.
+type DecommissionDeviceRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
 
+// TransferDeviceRequest defines the structure for the /iot/device/:id/transfer request.
+type TransferDeviceRequest struct {
+	ToWalletAddress string `json:"to_wallet_address" binding:"required"`
+	Note            string `json:"note" binding:"max=500"`
+}
 
-
-
+// DeviceTelemetryRequest defines the query parameters for /iot/device/:id/telemetry.
+type DeviceTelemetryRequest struct {
+	Resolution string    `form:"resolution" binding:"omitempty,oneof=1m 5m 15m 1h 1d"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
 
 // RegisterNodeRequest defines the structure for the /network/node/register request.
-
-
-
-
-
-
-
 type RegisterNodeRequest struct {
-
-
-
-
-
-
-
 	Location string `json:"location" binding:"required"`
-
-
-
-
-
-
-
 }
 
-
-
-
-
-
-
-
-
-
-
-
-
-
-
 // CreateOrderRequest defines the structure for the /market/order/create request.
-
-
-
-
-
-
-
 type CreateOrderRequest struct {
-
-
-
-
-
-
-
-	Type       string  `json:"type" binding:"required,oneof=buy sell"`
-
-
-
-
-
-
-
-	KwhAmount  float64 `json:"kwh_amount" binding:"required,gt=0"`
-
-
-
-
-
-
-
-	TokenPrice float64 `json:"token_price" binding:"required,gt=0"`
-
-
-
-
-
-
-
+	Type        string  `json:"type" binding:"required,oneof=buy sell"`
+	Market      string  `json:"market" binding:"omitempty,oneof=continuous day_ahead"` // Defaults to continuous
+	Kind        string  `json:"kind" binding:"omitempty,oneof=limit market"`           // Defaults to limit
+	TimeInForce string  `json:"time_in_force" binding:"omitempty,oneof=GTC IOC FOK"`   // Defaults to GTC (IOC for market orders)
+	KwhAmount   float64 `json:"kwh_amount" binding:"required,gt=0"`
+	TokenPrice  float64 `json:"token_price" binding:"omitempty,gt=0"`       // Required for limit orders without a quote
+	MaxSlippage float64 `json:"max_slippage" binding:"omitempty,gt=0,lt=1"` // Market orders only, defaults to 0.05
+	QuoteID     string  `json:"quote_id"`                                   // Optional signed quote from /market/quote; overrides token_price
+	DeviceID    string  `json:"device_id"`                                  // Sell orders: delivering ESP32; defaults to the one with the most available energy
+
+	ClientOrderID       string     `json:"client_order_id"`       // Optional idempotency key (or the Idempotency-Key header), at most 64 characters
+	ExpiresAt           *time.Time `json:"expires_at"`            // RFC3339; defaults to the delivery window end, else ORDER_TTL_HOURS
+	DeliveryWindowStart *time.Time `json:"delivery_window_start"` // RFC3339; omitted means any time
+	DeliveryWindowEnd   *time.Time `json:"delivery_window_end"`   // RFC3339; omitted means open-ended
 }
 
-
-
-
-
-
-
-
-
-
-
-
-
-
+// AmendOrderRequest defines the structure for the /market/order/amend request.
+// Omitted fields keep their current value.
+type AmendOrderRequest struct {
+	OrderID    string   `json:"order_id" binding:"required"`
+	TokenPrice *float64 `json:"token_price" binding:"omitempty,gt=0"` // New limit price
+	KwhAmount  *float64 `json:"kwh_amount" binding:"omitempty,gt=0"`  // New total quantity, including any filled part
+}
 
 // CancelOrderRequest defines the structure for the /market/order/cancel request.
-
-
-
-
-
-
-
 type CancelOrderRequest struct {
-
-
-
-
-
-
-
 	OrderID string `json:"order_id" binding:"required"`
-
-
-
-
-
-
-
 }
 
-
-
-
-
-
-
-
-
-
-
-
-
-
-
 // RefreshTokenRequest defines the structure for the /auth/refresh request.
-
-
-
-
-
-
-
 type RefreshTokenRequest struct {
-
-
-
-
-
-
-
 	RefreshToken string `json:"refresh_token" binding:"required"`
+}
 
+// MarketQuoteRequest defines the query parameters for the /market/quote request.
+type MarketQuoteRequest struct {
+	KwhAmount    float64 `form:"kwh_amount" binding:"required,gt=0"`
+	Counterparty string  `form:"counterparty"` // Optional seller user ID
+	OrderID      string  `form:"order_id"`     // Optional specific sell order
+}
 
+// PricingSimulationRequest defines the candidate parameters for the /analytics/pricing/simulate request.
+// Omitted parameters keep their current governance value.
+type PricingSimulationRequest struct {
+	BasePrice *float64  `json:"base_price" binding:"omitempty,gt=0"`
+	Alpha     *float64  `json:"alpha" binding:"omitempty,gte=0,lte=2"`
+	Beta      *float64  `json:"beta" binding:"omitempty,gte=0,lte=5"`
+	Gamma     *float64  `json:"gamma" binding:"omitempty,gte=0,lte=1"`
+	Delta     *float64  `json:"delta" binding:"omitempty,gte=0,lte=5"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Limit     int       `json:"limit" binding:"omitempty,gt=0,lte=10000"`
+}
 
+// ForecastRequest defines the query parameters for the /analytics/forecast request.
+type ForecastRequest struct {
+	Hours int `form:"hours" binding:"omitempty,gt=0,lte=168"`
+}
 
+// MarketCandlesRequest defines the query parameters for the /market/candles request.
+type MarketCandlesRequest struct {
+	Interval string    `form:"interval" binding:"required,oneof=1m 5m 1h 1d"`
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Source   string    `form:"source" binding:"omitempty,oneof=trades indicative"`
+}
 
-
-
+// DayAheadRequest defines the query parameters for /market/day-ahead.
+type DayAheadRequest struct {
+	Date string `form:"date"` // YYYY-MM-DD delivery day; defaults to the day currently accepting bids
 }
 
+// AccountStatementRequest defines the query parameters for /account/statement.
+type AccountStatementRequest struct {
+	Account string    `form:"account" binding:"omitempty,oneof=available reserved yield fees"` // Defaults to available
+	From    time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`                    // Defaults to 30 days before to
+	To      time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`                      // Defaults to now
+	Limit   int       `form:"limit" binding:"omitempty,min=1,max=1000"`                        // Defaults to 100
+}
 
+// WithdrawalRequest defines the structure for the /account/withdrawals request.
+type WithdrawalRequest struct {
+	Amount float64 `json:"amount" binding:"required,gt=0"`
+}
 
+// DepositRequest defines the structure for the /admin/accounts/:user_id/deposits request.
+type DepositRequest struct {
+	Amount    float64 `json:"amount" binding:"required,gt=0"`
+	Reference string  `json:"reference" binding:"required,max=200"` // The on-chain transfer or other external ID of the deposit
+}
 
+// ListWithdrawalsRequest defines the query parameters for /admin/withdrawals.
+type ListWithdrawalsRequest struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending paid rejected"` // Defaults to pending
+}
 
+// ProcessWithdrawalRequest defines the structure for paying out or rejecting a withdrawal.
+type ProcessWithdrawalRequest struct {
+	TxHash string `json:"tx_hash" binding:"max=200"` // Required to mark it paid
+	Reason string `json:"reason" binding:"max=500"`  // Required to reject it
+}
 
+// RoleChangeRequestBody defines the structure for the /roles/request request.
+type RoleChangeRequestBody struct {
+	Role   string `json:"role" binding:"required,oneof=Recipient Donor NetworkNodeOperator Admin Auditor"`
+	Reason string `json:"reason" binding:"max=500"`
+}
 
+// ReviewRoleRequest defines the structure for approving or rejecting a role change.
+type ReviewRoleRequest struct {
+	Note string `json:"note" binding:"max=500"`
+}
 
+// ListRoleRequestsRequest defines the query parameters for /admin/role-requests.
+type ListRoleRequestsRequest struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending approved rejected"` // Defaults to pending
+}
 
+// LogoutRequest defines the structure for the /auth/logout request.
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+	All          bool   `json:"all"` // Also end every other session of the user
+}
 
+// UploadKYCDocumentRequest defines the structure for the /kyc/documents request.
+type UploadKYCDocumentRequest struct {
+	Type     string `json:"type" binding:"required,oneof=passport national_id drivers_license proof_of_address"`
+	FileName string `json:"file_name" binding:"max=255"`
+	Content  string `json:"content" binding:"required"` // Base64; JPEG, PNG or PDF
+}
 
+// SubmitKYCRequest defines the structure for the /kyc/submissions request.
+type SubmitKYCRequest struct {
+	Tier        int      `json:"tier" binding:"required,oneof=1 2"`
+	FullName    string   `json:"full_name" binding:"required,max=200"`
+	DateOfBirth string   `json:"date_of_birth" binding:"required,datetime=2006-01-02"`
+	Country     string   `json:"country" binding:"required,iso3166_1_alpha2"`
+	Address     string   `json:"address" binding:"max=500"` // Required for tier 2
+	DocumentIDs []string `json:"document_ids" binding:"required,min=1,max=10,dive,required"`
+}
 
+// ReviewKYCRequest defines the structure for approving or rejecting a KYC submission.
+type ReviewKYCRequest struct {
+	Reason string `json:"reason" binding:"max=500"` // Required to reject
+}
 
+// ListKYCSubmissionsRequest defines the query parameters for /admin/kyc/submissions.
+type ListKYCSubmissionsRequest struct {
+	Status string `form:"status" binding:"omitempty,oneof=in_review approved rejected"` // Defaults to in_review
+}
 
+// ListAlertsRequest defines the query parameters for /iot/alerts.
+type ListAlertsRequest struct {
+	Status         string `form:"status" binding:"omitempty,oneof=open resolved"`
+	DeviceID       string `form:"device_id"`
+	Severity       string `form:"severity" binding:"omitempty,oneof=info warning critical"` // This severity or higher
+	Unacknowledged bool   `form:"unacknowledged"`
+	Limit          int    `form:"limit" binding:"omitempty,min=1,max=500"` // Defaults to 100
+}
 
+// AlertWebhookRequest defines the structure for the /iot/alert-webhook request.
+type AlertWebhookRequest struct {
+	URL         string `json:"url" binding:"required,url,max=2048"`
+	Secret      string `json:"secret" binding:"required,min=16,max=256"`
+	MinSeverity string `json:"min_severity" binding:"omitempty,oneof=info warning critical"` // Defaults to warning
+}
 
+// RegisterFirmwareRequest defines the structure for the /admin/firmware request.
+type RegisterFirmwareRequest struct {
+	Version   string `json:"version" binding:"required,max=64"`
+	URL       string `json:"url" binding:"required,url,max=2048"`
+	SHA256    string `json:"sha256" binding:"required,len=64,hexadecimal"`
+	Size      int64  `json:"size" binding:"required,min=1"`
+	Signature string `json:"signature" binding:"required,base64"` // Ed25519 signature of the SHA-256 digest by the release key
+	Notes     string `json:"notes" binding:"max=2000"`
+}
 
+// CreateFirmwareCampaignRequest defines the structure for the /admin/firmware/campaigns request.
+// Devices are those in TargetGroup (the "group" metadata key) or listed in DeviceIDs.
+type CreateFirmwareCampaignRequest struct {
+	Name           string   `json:"name" binding:"required,max=200"`
+	FirmwareID     string   `json:"firmware_id" binding:"required"`
+	TargetGroup    string   `json:"target_group" binding:"max=100"`
+	DeviceIDs      []string `json:"device_ids" binding:"max=10000,dive,required"`
+	Stages         []int    `json:"stages"`                                           // Cumulative percentages; defaults to 5, 25, 100
+	MaxFailureRate *float64 `json:"max_failure_rate" binding:"omitempty,min=0,max=1"` // Defaults to FIRMWARE_MAX_FAILURE_RATE
+}
 
+// ResumeFirmwareCampaignRequest defines the structure for resuming a paused or halted campaign.
+type ResumeFirmwareCampaignRequest struct {
+	MaxFailureRate *float64 `json:"max_failure_rate" binding:"omitempty,min=0,max=1"` // Raise to resume a halted campaign that would halt again
+}
 
+// ListFirmwareUpdatesRequest defines the query parameters for /admin/firmware/campaigns/:id/updates.
+type ListFirmwareUpdatesRequest struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending sent downloading installing succeeded failed timed_out skipped cancelled"`
+	Stage  *int   `form:"stage" binding:"omitempty,min=0"`
+}
 
+// UpdateDeviceConfigRequest defines the structure for the /iot/device/:id/config request.
+// Only the settings given change.
+type UpdateDeviceConfigRequest struct {
+	ReportingIntervalSeconds *int     `json:"reporting_interval_seconds"`
+	MinReserveSoC            *float64 `json:"min_reserve_soc"`
+	MaxExportKw              *float64 `json:"max_export_kw"`
+}
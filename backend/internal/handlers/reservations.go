@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+)
+
+// sellerDevice finds the device a sell order for kwh will deliver from: the
+// requested one if deviceID is set, otherwise the seller's ESP32 with the most
+// available energy. It returns nil and the reason if no device qualifies.
+func sellerDevice(userID, deviceID string, kwh float64) (*domain.IoTDevice, string) {
+	query := database.DB.Where("owner_id = ? AND device_type = ?", userID, "esp32")
+	if deviceID != "" {
+		query = query.Where("id = ?", deviceID)
+	}
+	var devices []domain.IoTDevice
+	if err := query.Find(&devices).Error; err != nil {
+		return nil, "Failed to look up your devices"
+	}
+	if len(devices) == 0 {
+		if deviceID != "" {
+			return nil, "device_id is not one of your ESP32 devices"
+		}
+		return nil, "Sell orders require a registered ESP32 device"
+	}
+
+	device := bestDevice(devices)
+	if !device.CanSell() {
+		return nil, "Device " + device.ID + " is not online and has not reported a battery level"
+	}
+	if !device.CanDeliver(kwh) {
+		return nil, fmt.Sprintf("Device %s has only %.3f kWh available", device.ID, device.AvailableKwh())
+	}
+	return device, ""
+}
+
+// bestDevice picks the device able to sell with the most available energy,
+// falling back to the first device if none can so the caller can report why.
+func bestDevice(devices []domain.IoTDevice) *domain.IoTDevice {
+	var best *domain.IoTDevice
+	for i := range devices {
+		d := &devices[i]
+		if !d.CanSell() {
+			continue
+		}
+		if best == nil || d.AvailableKwh() > best.AvailableKwh() {
+			best = d
+		}
+	}
+	if best == nil {
+		return &devices[0]
+	}
+	return best
+}
+
+// reservationErrorMessage explains a failed token or energy reservation.
+func reservationErrorMessage(err error) string {
+	if errors.Is(err, database.ErrInsufficientBalance) {
+		return "Insufficient available token balance for this order"
+	}
+	return "Insufficient available energy on the delivering device for this order"
+}
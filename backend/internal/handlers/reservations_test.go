@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"testing"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func TestBestDevice(t *testing.T) {
+	devices := []domain.IoTDevice{
+		{ID: "offline_full", Status: domain.DeviceStatusOffline, BatteryLevel: 1, CapacityKwh: 10},
+		{ID: "online_low", Status: domain.DeviceStatusOnline, BatteryLevel: 0.5, CapacityKwh: 10, ReservedKwh: 4},
+		{ID: "online_high", Status: domain.DeviceStatusOnline, BatteryLevel: 0.4, CapacityKwh: 10},
+	}
+	if got := bestDevice(devices); got.ID != "online_high" {
+		t.Errorf("Expected the online device with the most available energy, got %s", got.ID)
+	}
+
+	if got := bestDevice(devices[:1]); got.ID != "offline_full" {
+		t.Errorf("Expected the only device back when none is online, got %s", got.ID)
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"los-tecnicos/backend/internal/cache"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// errRequestReviewed is returned when a role change request was already decided.
+var errRequestReviewed = errors.New("role change request has already been reviewed")
+
+// parseWalletList splits a comma-separated list of wallet addresses into a set.
+func parseWalletList(list string) map[string]bool {
+	wallets := make(map[string]bool)
+	for _, wallet := range strings.Split(list, ",") {
+		if wallet = strings.TrimSpace(wallet); wallet != "" {
+			wallets[wallet] = true
+		}
+	}
+	return wallets
+}
+
+// RequestRoleChange asks for the authenticated user's role to be changed. A
+// user can have one pending request at a time; the new role applies from the
+// first access token issued after an admin approves it.
+func RequestRoleChange(c *gin.Context) {
+	var req RoleChangeRequestBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	userIDStr := userID.(string)
+
+	var user domain.User
+	if err := database.DB.Where("id = ?", userIDStr).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User profile not found"})
+		return
+	}
+	if user.Role == req.Role {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You already have the " + req.Role + " role"})
+		return
+	}
+
+	var pending int64
+	if err := database.DB.Model(&domain.RoleChangeRequest{}).Where("user_id = ? AND status = ?", userIDStr, domain.RoleRequestPending).Count(&pending).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing requests"})
+		return
+	}
+	if pending > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have a pending role change request"})
+		return
+	}
+
+	request := domain.RoleChangeRequest{
+		ID:        uuid.New().String(),
+		UserID:    userIDStr,
+		FromRole:  user.Role,
+		ToRole:    req.Role,
+		Reason:    req.Reason,
+		Status:    domain.RoleRequestPending,
+		CreatedAt: time.Now(),
+	}
+	if err := database.DB.Create(&request).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role change request"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, request)
+}
+
+// GetMyRoleRequests lists the authenticated user's role change requests.
+func GetMyRoleRequests(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var requests []domain.RoleChangeRequest
+	if err := database.DB.Where("user_id = ?", userID.(string)).Order("created_at DESC").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve role change requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// ListRoleRequests lists every user's role change requests with a status.
+func ListRoleRequests(c *gin.Context) {
+	var req ListRoleRequestsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	status := req.Status
+	if status == "" {
+		status = domain.RoleRequestPending
+	}
+
+	var requests []domain.RoleChangeRequest
+	if err := database.DB.Where("status = ?", status).Order("created_at").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve role change requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// ApproveRoleRequest grants a pending role change.
+func ApproveRoleRequest(c *gin.Context) {
+	reviewRoleRequest(c, domain.RoleRequestApproved)
+}
+
+// RejectRoleRequest declines a pending role change.
+func RejectRoleRequest(c *gin.Context) {
+	reviewRoleRequest(c, domain.RoleRequestRejected)
+}
+
+// reviewRoleRequest decides the request in the :id path parameter. Approval
+// changes the user's role in the same transaction, and only if their role is
+// still the one the request was made from. Admins cannot review their own
+// requests.
+func reviewRoleRequest(c *gin.Context, status string) {
+	var req ReviewRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	reviewerID, _ := c.Get("userID")
+	reviewerIDStr := reviewerID.(string)
+
+	var request domain.RoleChangeRequest
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&request).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role change request not found"})
+		return
+	}
+	if request.UserID == reviewerIDStr {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot review your own role change request"})
+		return
+	}
+
+	now := time.Now()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.RoleChangeRequest{}).
+			Where("id = ? AND status = ?", request.ID, domain.RoleRequestPending).
+			Updates(map[string]interface{}{"status": status, "reviewed_by": reviewerIDStr, "review_note": req.Note, "reviewed_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRequestReviewed
+		}
+		if status != domain.RoleRequestApproved {
+			return nil
+		}
+
+		result = tx.Model(&domain.User{}).Where("id = ? AND role = ?", request.UserID, request.FromRole).Update("role", request.ToRole)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRequestReviewed
+		}
+		return nil
+	})
+	if errors.Is(err, errRequestReviewed) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Request was already reviewed or the user's role has changed since"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review role change request"})
+		return
+	}
+
+	// Login caches users by wallet; drop the entry so the next token carries the new role
+	if status == domain.RoleRequestApproved {
+		var user domain.User
+		if database.DB.Select("wallet_address").Where("id = ?", request.UserID).First(&user).Error == nil {
+			if err := cache.Rdb.Del(context.Background(), "user:"+user.WalletAddress).Err(); err != nil {
+				log.Printf("Failed to invalidate user cache for %s: %v", request.UserID, err)
+			}
+		}
+	}
+
+	request.Status = status
+	request.ReviewedBy = reviewerIDStr
+	request.ReviewNote = req.Note
+	request.ReviewedAt = &now
+	c.JSON(http.StatusOK, request)
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"los-tecnicos/backend/internal/core/domain"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -8,6 +10,11 @@ import (
 func SetupRoutes(router *gin.Engine) {
 	// WebSocket endpoint
 	router.GET("/ws/market", MarketDataWS)
+	// Authenticates itself, as browsers cannot send headers on the handshake
+	router.GET("/ws/alerts", AlertsWS)
+
+	// Public keys access tokens are verified with
+	router.GET("/.well-known/jwks.json", JWKS)
 
 	// Group routes under /api/v1
 	v1 := router.Group("/api/v1")
@@ -18,43 +25,126 @@ func SetupRoutes(router *gin.Engine) {
 			auth.POST("/signup", SignUp)
 			auth.POST("/login", Login)
 			auth.POST("/refresh", RefreshToken)
-			// Protected auth route to get current user
+			auth.POST("/logout", Logout)
+			// Protected auth routes for the current user
 			auth.GET("/me", AuthMiddleware(), Me)
+			auth.GET("/sessions", AuthMiddleware(), GetSessions)
+			auth.DELETE("/sessions/:id", AuthMiddleware(), RevokeSession)
 		}
 
-		// Protected routes
+		// Protected routes; each also requires a permission granted by the user's role
 		protected := v1.Group("/")
 		protected.Use(AuthMiddleware())
 		{
 			// Market routes
 			market := protected.Group("/market")
 			{
-				market.GET("/orders", GetMarketOrders)
-				market.POST("/order/create", CreateOrder)
-				market.POST("/order/cancel", CancelOrder)
-				market.GET("/price", GetMarketPrice)
-				market.GET("/history", GetMarketHistory)
+				market.GET("/orders", RequirePermission(domain.PermMarketRead), GetMarketOrders)
+				market.POST("/order/create", RequirePermission(domain.PermMarketTrade), CreateOrder)
+				market.POST("/order/cancel", RequirePermission(domain.PermMarketTrade), CancelOrder)
+				market.POST("/order/amend", RequirePermission(domain.PermMarketTrade), AmendOrder)
+				market.GET("/price", RequirePermission(domain.PermMarketRead), GetMarketPrice)
+				market.GET("/quote", RequirePermission(domain.PermMarketTrade), GetMarketQuote)
+				market.GET("/history", RequirePermission(domain.PermMarketRead), GetMarketHistory)
+				market.GET("/candles", RequirePermission(domain.PermMarketRead), GetMarketCandles)
+				market.GET("/day-ahead", RequirePermission(domain.PermMarketRead), GetDayAheadMarket)
+			}
+
+			// Account routes
+			account := protected.Group("/account")
+			account.Use(RequirePermission(domain.PermAccountRead))
+			{
+				account.GET("/balances", GetAccountBalances)
+				account.GET("/statement", GetAccountStatement)
+				account.GET("/withdrawals", GetWithdrawals)
+				account.POST("/withdrawals", RequirePermission(domain.PermAccountWithdraw), RequestWithdrawal)
 			}
 
 			// IoT routes
 			iot := protected.Group("/iot")
 			{
-				iot.GET("/devices", GetRegisteredDevices)
-				iot.POST("/device/register", RegisterDevice)
+				iot.GET("/devices", RequirePermission(domain.PermDeviceRead), GetRegisteredDevices)
+				iot.POST("/device/register", RequirePermission(domain.PermDeviceManage), RegisterDevice)
+				iot.GET("/device/:id", RequirePermission(domain.PermDeviceRead), GetDevice)
+				iot.PATCH("/device/:id", RequirePermission(domain.PermDeviceManage), UpdateDevice)
+				iot.GET("/device/:id/events", RequirePermission(domain.PermDeviceRead), GetDeviceEvents)
+				iot.GET("/device/:id/telemetry", RequirePermission(domain.PermDeviceRead), GetDeviceTelemetry)
+				iot.GET("/device/:id/quality", RequirePermission(domain.PermDeviceRead), GetDeviceQuality)
+				iot.GET("/device/:id/config", RequirePermission(domain.PermDeviceRead), GetDeviceConfig)
+				iot.PATCH("/device/:id/config", RequirePermission(domain.PermDeviceManage), UpdateDeviceConfig)
+				iot.GET("/config/drift", RequirePermission(domain.PermDeviceRead), GetConfigDrift)
+				iot.POST("/device/:id/decommission", RequirePermission(domain.PermDeviceManage), DecommissionDevice)
+				iot.POST("/device/:id/transfer", RequirePermission(domain.PermDeviceManage), TransferDevice)
+				iot.GET("/transfers", RequirePermission(domain.PermDeviceRead), GetDeviceTransfers)
+				iot.POST("/transfers/:id/accept", RequirePermission(domain.PermDeviceManage), AcceptDeviceTransfer)
+				iot.POST("/transfers/:id/decline", RequirePermission(domain.PermDeviceManage), DeclineDeviceTransfer)
+				iot.POST("/transfers/:id/cancel", RequirePermission(domain.PermDeviceManage), CancelDeviceTransfer)
+				iot.GET("/alerts", RequirePermission(domain.PermDeviceRead), GetAlerts)
+				iot.POST("/alerts/:id/acknowledge", RequirePermission(domain.PermDeviceManage), AcknowledgeAlert)
+				iot.GET("/alert-webhook", RequirePermission(domain.PermDeviceRead), GetAlertWebhook)
+				iot.PUT("/alert-webhook", RequirePermission(domain.PermDeviceManage), PutAlertWebhook)
+				iot.DELETE("/alert-webhook", RequirePermission(domain.PermDeviceManage), DeleteAlertWebhook)
 			}
 
 			// Network routes
 			network := protected.Group("/network")
 			{
-				network.GET("/nodes", GetActiveNodes)
-				network.POST("/node/register", RegisterNode)
+				network.GET("/nodes", RequirePermission(domain.PermNodeRead), GetActiveNodes)
+				network.POST("/node/register", RequirePermission(domain.PermNodeManage), RegisterNode)
 			}
 
 			// Analytics routes
 			analytics := protected.Group("/analytics")
+			analytics.Use(RequirePermission(domain.PermAnalyticsRead))
 			{
 				analytics.GET("/dashboard", GetAnalyticsDashboard)
 				analytics.GET("/transactions", GetUserTransactions)
+				analytics.POST("/pricing/simulate", SimulatePricing)
+				analytics.GET("/forecast", GetForecast)
+			}
+
+			// Role change routes
+			roles := protected.Group("/roles")
+			{
+				roles.POST("/request", RequirePermission(domain.PermRoleRequest), RequestRoleChange)
+				roles.GET("/requests", RequirePermission(domain.PermRoleRequest), GetMyRoleRequests)
+			}
+
+			// KYC routes
+			kycRoutes := protected.Group("/kyc")
+			{
+				kycRoutes.GET("", RequirePermission(domain.PermAccountRead), GetKYC)
+				kycRoutes.GET("/history", RequirePermission(domain.PermAccountRead), GetKYCHistory)
+				kycRoutes.POST("/documents", RequirePermission(domain.PermKYCSubmit), UploadKYCDocument)
+				kycRoutes.POST("/submissions", RequirePermission(domain.PermKYCSubmit), SubmitKYC)
+			}
+
+			// Admin routes
+			admin := protected.Group("/admin")
+			{
+				admin.GET("/role-requests", RequirePermission(domain.PermRoleRead), ListRoleRequests)
+				admin.POST("/role-requests/:id/approve", RequirePermission(domain.PermRoleReview), ApproveRoleRequest)
+				admin.POST("/role-requests/:id/reject", RequirePermission(domain.PermRoleReview), RejectRoleRequest)
+				admin.GET("/kyc/submissions", RequirePermission(domain.PermKYCRead), ListKYCSubmissions)
+				admin.GET("/kyc/submissions/:id", RequirePermission(domain.PermKYCRead), GetKYCSubmission)
+				admin.GET("/kyc/documents/:id", RequirePermission(domain.PermKYCRead), GetKYCDocument)
+				admin.POST("/kyc/submissions/:id/approve", RequirePermission(domain.PermKYCReview), ApproveKYCSubmission)
+				admin.POST("/kyc/submissions/:id/reject", RequirePermission(domain.PermKYCReview), RejectKYCSubmission)
+				admin.POST("/accounts/:user_id/deposits", RequirePermission(domain.PermAccountFund), CreditDeposit)
+				admin.GET("/withdrawals", RequirePermission(domain.PermAccountFund), ListWithdrawals)
+				admin.POST("/withdrawals/:id/pay", RequirePermission(domain.PermAccountFund), PayWithdrawal)
+				admin.POST("/withdrawals/:id/reject", RequirePermission(domain.PermAccountFund), RejectWithdrawal)
+				admin.GET("/firmware", RequirePermission(domain.PermFirmwareRead), ListFirmware)
+				admin.POST("/firmware", RequirePermission(domain.PermFirmwareManage), RegisterFirmware)
+				admin.GET("/firmware/signing-key", RequirePermission(domain.PermFirmwareRead), GetFirmwareSigningKey)
+				admin.GET("/firmware/campaigns", RequirePermission(domain.PermFirmwareRead), ListFirmwareCampaigns)
+				admin.POST("/firmware/campaigns", RequirePermission(domain.PermFirmwareManage), CreateFirmwareCampaign)
+				admin.GET("/firmware/campaigns/:id", RequirePermission(domain.PermFirmwareRead), GetFirmwareCampaign)
+				admin.GET("/firmware/campaigns/:id/updates", RequirePermission(domain.PermFirmwareRead), GetFirmwareCampaignUpdates)
+				admin.POST("/firmware/campaigns/:id/start", RequirePermission(domain.PermFirmwareManage), StartFirmwareCampaign)
+				admin.POST("/firmware/campaigns/:id/pause", RequirePermission(domain.PermFirmwareManage), PauseFirmwareCampaign)
+				admin.POST("/firmware/campaigns/:id/resume", RequirePermission(domain.PermFirmwareManage), ResumeFirmwareCampaign)
+				admin.POST("/firmware/campaigns/:id/cancel", RequirePermission(domain.PermFirmwareManage), CancelFirmwareCampaign)
 			}
 		}
 	}
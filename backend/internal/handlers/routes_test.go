@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/auth"
+	"los-tecnicos/backend/internal/cache"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// routePermissions is the permission each protected route must require.
+var routePermissions = map[string]string{
+	"GET /api/v1/market/orders":                        domain.PermMarketRead,
+	"POST /api/v1/market/order/create":                 domain.PermMarketTrade,
+	"POST /api/v1/market/order/cancel":                 domain.PermMarketTrade,
+	"POST /api/v1/market/order/amend":                  domain.PermMarketTrade,
+	"GET /api/v1/market/price":                         domain.PermMarketRead,
+	"GET /api/v1/market/quote":                         domain.PermMarketTrade,
+	"GET /api/v1/market/history":                       domain.PermMarketRead,
+	"GET /api/v1/market/candles":                       domain.PermMarketRead,
+	"GET /api/v1/market/day-ahead":                     domain.PermMarketRead,
+	"GET /api/v1/account/balances":                     domain.PermAccountRead,
+	"GET /api/v1/account/statement":                    domain.PermAccountRead,
+	"GET /api/v1/account/withdrawals":                  domain.PermAccountRead,
+	"POST /api/v1/account/withdrawals":                 domain.PermAccountWithdraw,
+	"GET /api/v1/iot/devices":                          domain.PermDeviceRead,
+	"POST /api/v1/iot/device/register":                 domain.PermDeviceManage,
+	"GET /api/v1/iot/device/:id":                       domain.PermDeviceRead,
+	"PATCH /api/v1/iot/device/:id":                     domain.PermDeviceManage,
+	"GET /api/v1/iot/device/:id/events":                domain.PermDeviceRead,
+	"GET /api/v1/iot/device/:id/telemetry":             domain.PermDeviceRead,
+	"GET /api/v1/iot/device/:id/quality":               domain.PermDeviceRead,
+	"GET /api/v1/iot/device/:id/config":                domain.PermDeviceRead,
+	"PATCH /api/v1/iot/device/:id/config":              domain.PermDeviceManage,
+	"GET /api/v1/iot/config/drift":                     domain.PermDeviceRead,
+	"POST /api/v1/iot/device/:id/decommission":         domain.PermDeviceManage,
+	"POST /api/v1/iot/device/:id/transfer":             domain.PermDeviceManage,
+	"GET /api/v1/iot/transfers":                        domain.PermDeviceRead,
+	"POST /api/v1/iot/transfers/:id/accept":            domain.PermDeviceManage,
+	"POST /api/v1/iot/transfers/:id/decline":           domain.PermDeviceManage,
+	"POST /api/v1/iot/transfers/:id/cancel":            domain.PermDeviceManage,
+	"GET /api/v1/iot/alerts":                           domain.PermDeviceRead,
+	"POST /api/v1/iot/alerts/:id/acknowledge":          domain.PermDeviceManage,
+	"GET /api/v1/iot/alert-webhook":                    domain.PermDeviceRead,
+	"PUT /api/v1/iot/alert-webhook":                    domain.PermDeviceManage,
+	"DELETE /api/v1/iot/alert-webhook":                 domain.PermDeviceManage,
+	"GET /api/v1/network/nodes":                        domain.PermNodeRead,
+	"POST /api/v1/network/node/register":               domain.PermNodeManage,
+	"GET /api/v1/analytics/dashboard":                  domain.PermAnalyticsRead,
+	"GET /api/v1/analytics/transactions":               domain.PermAnalyticsRead,
+	"POST /api/v1/analytics/pricing/simulate":          domain.PermAnalyticsRead,
+	"GET /api/v1/analytics/forecast":                   domain.PermAnalyticsRead,
+	"POST /api/v1/roles/request":                       domain.PermRoleRequest,
+	"GET /api/v1/roles/requests":                       domain.PermRoleRequest,
+	"GET /api/v1/admin/role-requests":                  domain.PermRoleRead,
+	"POST /api/v1/admin/role-requests/:id/approve":     domain.PermRoleReview,
+	"POST /api/v1/admin/role-requests/:id/reject":      domain.PermRoleReview,
+	"GET /api/v1/kyc":                                  domain.PermAccountRead,
+	"GET /api/v1/kyc/history":                          domain.PermAccountRead,
+	"POST /api/v1/kyc/documents":                       domain.PermKYCSubmit,
+	"POST /api/v1/kyc/submissions":                     domain.PermKYCSubmit,
+	"GET /api/v1/admin/kyc/submissions":                domain.PermKYCRead,
+	"GET /api/v1/admin/kyc/submissions/:id":            domain.PermKYCRead,
+	"GET /api/v1/admin/kyc/documents/:id":              domain.PermKYCRead,
+	"POST /api/v1/admin/kyc/submissions/:id/approve":   domain.PermKYCReview,
+	"POST /api/v1/admin/kyc/submissions/:id/reject":    domain.PermKYCReview,
+	"POST /api/v1/admin/accounts/:user_id/deposits":    domain.PermAccountFund,
+	"GET /api/v1/admin/withdrawals":                    domain.PermAccountFund,
+	"POST /api/v1/admin/withdrawals/:id/pay":           domain.PermAccountFund,
+	"POST /api/v1/admin/withdrawals/:id/reject":        domain.PermAccountFund,
+	"GET /api/v1/admin/firmware":                       domain.PermFirmwareRead,
+	"POST /api/v1/admin/firmware":                      domain.PermFirmwareManage,
+	"GET /api/v1/admin/firmware/signing-key":           domain.PermFirmwareRead,
+	"GET /api/v1/admin/firmware/campaigns":             domain.PermFirmwareRead,
+	"POST /api/v1/admin/firmware/campaigns":            domain.PermFirmwareManage,
+	"GET /api/v1/admin/firmware/campaigns/:id":         domain.PermFirmwareRead,
+	"GET /api/v1/admin/firmware/campaigns/:id/updates": domain.PermFirmwareRead,
+	"POST /api/v1/admin/firmware/campaigns/:id/start":  domain.PermFirmwareManage,
+	"POST /api/v1/admin/firmware/campaigns/:id/pause":  domain.PermFirmwareManage,
+	"POST /api/v1/admin/firmware/campaigns/:id/resume": domain.PermFirmwareManage,
+	"POST /api/v1/admin/firmware/campaigns/:id/cancel": domain.PermFirmwareManage,
+}
+
+// publicRoutes need no permission (only, for /auth/me, a valid token).
+var publicRoutes = map[string]bool{
+	"GET /ws/market":                   true,
+	"GET /ws/alerts":                   true, // Checks the token itself
+	"GET /.well-known/jwks.json":       true,
+	"POST /api/v1/auth/signup":         true,
+	"POST /api/v1/auth/login":          true,
+	"POST /api/v1/auth/refresh":        true,
+	"POST /api/v1/auth/logout":         true,
+	"GET /api/v1/auth/me":              true,
+	"GET /api/v1/auth/sessions":        true,
+	"DELETE /api/v1/auth/sessions/:id": true,
+}
+
+var allRoles = []string{domain.RoleRecipient, domain.RoleDonor, domain.RoleNetworkNodeOperator, domain.RoleAdmin, domain.RoleAuditor, ""}
+
+// setupTestRouter builds the API router against a dry-run database and an
+// unreachable Redis, so requests that pass the middleware reach their handler
+// without touching real storage.
+func setupTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1 sslmode=disable"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("Failed to open dry-run database: %v", err)
+	}
+	prevDB, prevRdb := database.DB, cache.Rdb
+	database.DB = db
+	cache.Rdb = redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 50 * time.Millisecond})
+	cache.Rdb.AddHook(emptyDenylist{})
+	t.Cleanup(func() { database.DB, cache.Rdb = prevDB, prevRdb })
+
+	key, err := auth.NewKey(time.Now())
+	if err != nil {
+		t.Fatalf("Failed to generate signing key: %v", err)
+	}
+	auth.Keys.Replace([]auth.Key{key})
+
+	router := gin.New()
+	router.Use(gin.Recovery())
+	SetupRoutes(router)
+	return router
+}
+
+// emptyDenylist answers the token denylist's EXISTS lookups with "not
+// revoked", so authentication works while every other Redis call fails.
+type emptyDenylist struct{}
+
+func (emptyDenylist) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (emptyDenylist) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if exists, ok := cmd.(*redis.IntCmd); ok && cmd.Name() == "exists" {
+			exists.SetVal(0)
+			return nil
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (emptyDenylist) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func tokenFor(t *testing.T, role string) string {
+	t.Helper()
+	token, err := createAccessToken(&domain.User{ID: "route_test_user", Role: role}, "")
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	return token
+}
+
+func TestEveryRouteIsClassified(t *testing.T) {
+	for _, route := range setupTestRouter(t).Routes() {
+		key := route.Method + " " + route.Path
+		if _, ok := routePermissions[key]; !ok && !publicRoutes[key] {
+			t.Errorf("Route %s has no expected permission in routePermissions", key)
+		}
+	}
+}
+
+func TestProtectedRoutesRequireAuthentication(t *testing.T) {
+	router := setupTestRouter(t)
+	for route := range routePermissions {
+		method, path, _ := strings.Cut(route, " ")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, strings.Replace(path, ":id", "x", 1), nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s without a token: expected 401, got %d", route, w.Code)
+		}
+	}
+}
+
+func TestProtectedRoutesEnforcePermissions(t *testing.T) {
+	router := setupTestRouter(t)
+	for route, permission := range routePermissions {
+		method, path, _ := strings.Cut(route, " ")
+		for _, role := range allRoles {
+			req := httptest.NewRequest(method, strings.Replace(path, ":id", "x", 1), strings.NewReader("{}"))
+			req.Header.Set("Authorization", "Bearer "+tokenFor(t, role))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			allowed := domain.HasPermission(role, permission)
+			if !allowed && w.Code != http.StatusForbidden {
+				t.Errorf("%s as %q: expected 403, got %d", route, role, w.Code)
+			}
+			if allowed && (w.Code == http.StatusForbidden || w.Code == http.StatusUnauthorized) {
+				t.Errorf("%s as %q: expected the handler to run, got %d: %s", route, role, w.Code, w.Body.String())
+			}
+		}
+	}
+}
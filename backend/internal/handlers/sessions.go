@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"los-tecnicos/backend/internal/auth"
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// refreshTokenTTL is how long an unused refresh token stays valid. Each
+// refresh issues a new token with a fresh TTL.
+var refreshTokenTTL = time.Duration(config.GetEnvAsInt("REFRESH_TOKEN_TTL_HOURS", 7*24)) * time.Hour
+
+// newRefreshToken returns a random refresh token and the hash to store for it.
+func newRefreshToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashRefreshToken(token), nil
+}
+
+// hashRefreshToken is the form a refresh token is stored and looked up in.
+// Tokens are 256 random bits, so a fast unsalted hash is enough.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SessionResponse describes one of the user's sessions.
+type SessionResponse struct {
+	ID         string    `json:"id"` // Session family ID
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	LastUsedAt time.Time `json:"last_used_at"` // When the current refresh token was issued
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // The session the request's access token belongs to
+}
+
+// Logout ends the session the refresh token belongs to, or with all set,
+// every session of its user, and denylists the access tokens issued under
+// them, as well as the access token sent with the request, if any.
+func Logout(c *gin.Context) {
+	var req LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	session, err := database.FindSession(database.DB, hashRefreshToken(req.RefreshToken))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	families := []string{session.FamilyID}
+	if req.All {
+		active, err := database.ActiveSessions(database.DB, session.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+		families = families[:0]
+		for _, s := range active {
+			families = append(families, s.FamilyID)
+		}
+		err = database.RevokeAllSessions(database.DB, session.UserID, database.RevokeLogout)
+	} else {
+		err = database.RevokeFamily(database.DB, session.UserID, session.FamilyID, database.RevokeLogout)
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	revokeAccessTokens(families...)
+	revokePresentedToken(c)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// GetSessions lists the authenticated user's active sessions.
+func GetSessions(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	currentID := c.GetString("sessionID")
+
+	sessions, err := database.ActiveSessions(database.DB, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sessions"})
+		return
+	}
+
+	resp := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, SessionResponse{
+			ID:         s.FamilyID,
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			LastUsedAt: s.CreatedAt,
+			ExpiresAt:  s.ExpiresAt,
+			Current:    s.FamilyID == currentID,
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// RevokeSession ends one of the authenticated user's sessions by ID, e.g. a
+// lost device.
+func RevokeSession(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	err := database.RevokeFamily(database.DB, userID.(string), c.Param("id"), database.RevokeLogout)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	revokeAccessTokens(c.Param("id"))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// revokeAccessTokens denylists the access tokens issued under the given
+// session families. The sessions themselves are already revoked, so a Redis
+// failure only leaves those access tokens usable until they expire; it is logged.
+func revokeAccessTokens(familyIDs ...string) {
+	for _, id := range familyIDs {
+		if err := auth.RevokeSession(id); err != nil {
+			log.Printf("Warning: Failed to denylist access tokens of session %s: %v", id, err)
+		}
+	}
+}
+
+// revokePresentedToken denylists the access token in the request's
+// Authorization header, if it carries a valid one.
+func revokePresentedToken(c *gin.Context) {
+	tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return
+	}
+	claims := &Claims{}
+	if err := auth.Keys.Parse(tokenString, claims); err != nil {
+		return
+	}
+	if err := auth.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+		log.Printf("Warning: Failed to denylist access token %s: %v", claims.ID, err)
+	}
+}
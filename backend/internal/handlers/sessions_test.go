@@ -0,0 +1,18 @@
+package handlers
+
+import "testing"
+
+func TestNewRefreshToken(t *testing.T) {
+	token, hash, err := newRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != hashRefreshToken(token) || hash == token {
+		t.Error("Expected the stored hash to be derived from, and differ from, the token")
+	}
+
+	again, _, _ := newRefreshToken()
+	if again == token {
+		t.Error("Expected every refresh token to be unique")
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/shadow"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceConfigResponse is a device's shadow with how far its reported
+// configuration is from the desired one.
+type DeviceConfigResponse struct {
+	domain.DeviceShadow
+	Applied bool                 `json:"applied"` // The device reports the latest desired version
+	InSync  bool                 `json:"in_sync"` // Applied, and every desired setting reported as desired
+	Drift   []domain.ConfigDrift `json:"drift"`
+}
+
+func newDeviceConfigResponse(s domain.DeviceShadow) DeviceConfigResponse {
+	drift := domain.Drift(s.Desired, s.Reported)
+	if drift == nil {
+		drift = []domain.ConfigDrift{}
+	}
+	return DeviceConfigResponse{
+		DeviceShadow: s,
+		Applied:      s.ReportedVersion >= s.DesiredVersion,
+		InSync:       s.InSync(),
+		Drift:        drift,
+	}
+}
+
+// GetDeviceConfig returns the desired and reported configuration of one of
+// the authenticated user's devices and where they differ.
+func GetDeviceConfig(c *gin.Context) {
+	device, ok := ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	s := domain.DeviceShadow{DeviceID: device.ID}
+	if err := database.DB.Where("device_id = ?", device.ID).Limit(1).Find(&s).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve device config"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newDeviceConfigResponse(s))
+}
+
+// UpdateDeviceConfig changes the desired configuration of one of the
+// authenticated user's devices and publishes it to the device.
+func UpdateDeviceConfig(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req UpdateDeviceConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	changes := domain.DeviceConfig{
+		ReportingIntervalSeconds: req.ReportingIntervalSeconds,
+		MinReserveSoC:            req.MinReserveSoC,
+		MaxExportKw:              req.MaxExportKw,
+	}
+	if changes == (domain.DeviceConfig{}) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No settings to change"})
+		return
+	}
+	if err := shadow.Validate(changes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	device, ok := ownedDevice(c)
+	if !ok {
+		return
+	}
+	if device.IsDecommissioned() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Device is decommissioned"})
+		return
+	}
+
+	s, err := shadow.Set(device.ID, changes, userID.(string))
+	if err != nil {
+		log.Printf("Error setting config of device %s: %v", device.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update device config"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newDeviceConfigResponse(*s))
+}
+
+// GetConfigDrift lists the authenticated user's live devices whose reported
+// configuration is not yet, or not, the desired one.
+func GetConfigDrift(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var shadows []domain.DeviceShadow
+	err := database.DB.
+		Where("device_id IN (SELECT id FROM iot_devices WHERE owner_id = ? AND status <> ?)", userID.(string), domain.DeviceStatusDecommissioned).
+		Where("desired_version > 0").
+		Order("device_id").
+		Find(&shadows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve device configs"})
+		return
+	}
+
+	drifted := []DeviceConfigResponse{}
+	for _, s := range shadows {
+		if !s.InSync() {
+			drifted = append(drifted, newDeviceConfigResponse(s))
+		}
+	}
+
+	c.JSON(http.StatusOK, drifted)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/telemetry"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceTelemetryResponse defines the structure for the /iot/device/:id/telemetry response.
+type DeviceTelemetryResponse struct {
+	DeviceID   string            `json:"device_id"`
+	Resolution string            `json:"resolution"`
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	Points     []telemetry.Point `json:"points"`
+}
+
+// GetDeviceTelemetry returns the telemetry of one of the authenticated user's
+// devices aggregated at the requested resolution. Ranges reaching back past
+// TELEMETRY_RAW_RETENTION_DAYS are served from the hourly rollups, so only
+// resolutions of 1h and coarser are available there.
+func GetDeviceTelemetry(c *gin.Context) {
+	var req DeviceTelemetryRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if req.Resolution == "" {
+		req.Resolution = "5m"
+	}
+	resolution, err := telemetry.ParseResolution(req.Resolution)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	to := req.To
+	if to.IsZero() {
+		to = now
+	}
+	from := req.From
+	if from.IsZero() {
+		from = to.Add(-100 * resolution)
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'from' must be before 'to'"})
+		return
+	}
+	if to.Sub(from)/resolution > telemetry.MaxPoints {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Range is too large for this resolution"})
+		return
+	}
+	rawHorizon := now.Add(-telemetry.RawRetention)
+	if from.Before(rawHorizon) && resolution < time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Resolutions finer than 1h are only kept for the last %d days", int(telemetry.RawRetention.Hours()/24))})
+		return
+	}
+
+	device, ok := ownedDevice(c)
+	if !ok {
+		return
+	}
+
+	buckets, err := deviceTelemetryBuckets(device.ID, from, to, resolution, rawHorizon)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve telemetry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, DeviceTelemetryResponse{
+		DeviceID:   device.ID,
+		Resolution: req.Resolution,
+		From:       from.UTC().Format(time.RFC3339),
+		To:         to.UTC().Format(time.RFC3339),
+		Points:     telemetry.Points(buckets, resolution),
+	})
+}
+
+// deviceTelemetryBuckets aggregates a device's telemetry in [from, to). Raw
+// samples are used where they have not been downsampled yet, and hourly
+// rollups before that when the range starts past the raw horizon.
+func deviceTelemetryBuckets(deviceID string, from, to time.Time, resolution time.Duration, rawHorizon time.Time) ([]domain.TelemetryBucket, error) {
+	if !from.Before(rawHorizon) {
+		return database.TelemetrySeries(database.DB, deviceID, from, to, resolution)
+	}
+
+	split, err := database.TelemetryRolledUntil(database.DB, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if split.Before(from) {
+		split = from
+	}
+	if split.After(to) {
+		split = to
+	}
+
+	var older, recent []domain.TelemetryBucket
+	if from.Before(split) {
+		if older, err = database.TelemetryRollupSeries(database.DB, deviceID, from, split, resolution); err != nil {
+			return nil, err
+		}
+	}
+	if split.Before(to) {
+		if recent, err = database.TelemetrySeries(database.DB, deviceID, split, to, resolution); err != nil {
+			return nil, err
+		}
+	}
+	return telemetry.Merge(older, recent), nil
+}
@@ -0,0 +1,69 @@
+package kyc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func TestMockVerifier(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	passport := domain.KYCDocument{ID: "doc", Type: domain.KYCDocPassport, Size: 100}
+
+	cases := []struct {
+		name        string
+		submission  domain.KYCSubmission
+		documents   []domain.KYCDocument
+		autoApprove bool
+		want        string
+	}{
+		{"valid goes to review", domain.KYCSubmission{Tier: 1, DateOfBirth: "1990-05-01"}, []domain.KYCDocument{passport}, false, DecisionReview},
+		{"valid auto-approved", domain.KYCSubmission{Tier: 1, DateOfBirth: "1990-05-01"}, []domain.KYCDocument{passport}, true, DecisionApprove},
+		{"under age", domain.KYCSubmission{Tier: 1, DateOfBirth: "2008-06-02"}, []domain.KYCDocument{passport}, true, DecisionReject},
+		{"bad date", domain.KYCSubmission{Tier: 1, DateOfBirth: "1990-13-01"}, []domain.KYCDocument{passport}, true, DecisionReject},
+		{"missing proof of address", domain.KYCSubmission{Tier: 2, DateOfBirth: "1990-05-01", Address: "1 Main St"}, []domain.KYCDocument{passport}, true, DecisionReject},
+		{"empty document", domain.KYCSubmission{Tier: 1, DateOfBirth: "1990-05-01"}, []domain.KYCDocument{{ID: "empty", Type: domain.KYCDocPassport}}, true, DecisionReject},
+	}
+	for _, tc := range cases {
+		verifier := &MockVerifier{AutoApprove: tc.autoApprove, MinimumAge: 18, now: func() time.Time { return now }}
+		result, err := verifier.Verify(context.Background(), tc.submission, tc.documents)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if result.Decision != tc.want {
+			t.Errorf("%s: expected %s, got %s (%s)", tc.name, tc.want, result.Decision, result.Reason)
+		}
+	}
+}
+
+func TestProviders(t *testing.T) {
+	if _, err := New("does-not-exist"); err == nil {
+		t.Error("Expected an unknown provider to be refused")
+	}
+	Register("test", func() Verifier { return &MockVerifier{AutoApprove: true} })
+	if v, err := New("test"); err != nil || v.Name() != "mock" {
+		t.Errorf("Expected the registered provider, got %v, %v", v, err)
+	}
+}
+
+func TestLimitsCheck(t *testing.T) {
+	limits := Limits{MaxOrderNotional: 50, MaxDailyNotional: 200}
+	if err := limits.Check(50, 150); err != nil {
+		t.Errorf("Expected an order at both limits to pass, got %v", err)
+	}
+	if err := limits.Check(51, 0); !errors.Is(err, ErrOrderLimit) {
+		t.Errorf("Expected ErrOrderLimit, got %v", err)
+	}
+	if err := limits.Check(40, 170); !errors.Is(err, ErrDailyLimit) {
+		t.Errorf("Expected ErrDailyLimit, got %v", err)
+	}
+	if err := (Limits{}).Check(1e9, 1e9); err != nil {
+		t.Errorf("Expected zero limits to be unlimited, got %v", err)
+	}
+	if TierLimits(99) != TierLimits(domain.KYCTierNone) {
+		t.Error("Expected an unknown tier to get the unverified limits")
+	}
+}
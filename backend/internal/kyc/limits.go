@@ -0,0 +1,61 @@
+package kyc
+
+import (
+	"errors"
+	"fmt"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+var (
+	// ErrOrderLimit is returned for an order larger than the user's tier allows.
+	ErrOrderLimit = errors.New("order exceeds the KYC tier's per-order limit")
+	// ErrDailyLimit is returned when an order would take the user's trading
+	// over their tier's 24-hour limit.
+	ErrDailyLimit = errors.New("order exceeds the KYC tier's daily limit")
+)
+
+// Limits caps the token notional (kWh x price) a user may trade. Zero means unlimited.
+type Limits struct {
+	MaxOrderNotional float64 `json:"max_order_notional"`
+	MaxDailyNotional float64 `json:"max_daily_notional"`
+}
+
+// tierLimits are the limits of each tier, from KYC_TIER<n>_MAX_ORDER and
+// KYC_TIER<n>_MAX_DAILY.
+var tierLimits = map[int]Limits{
+	domain.KYCTierNone: {
+		MaxOrderNotional: config.GetEnvAsFloat("KYC_TIER0_MAX_ORDER", 50),
+		MaxDailyNotional: config.GetEnvAsFloat("KYC_TIER0_MAX_DAILY", 200),
+	},
+	domain.KYCTierBasic: {
+		MaxOrderNotional: config.GetEnvAsFloat("KYC_TIER1_MAX_ORDER", 1000),
+		MaxDailyNotional: config.GetEnvAsFloat("KYC_TIER1_MAX_DAILY", 5000),
+	},
+	domain.KYCTierFull: {
+		MaxOrderNotional: config.GetEnvAsFloat("KYC_TIER2_MAX_ORDER", 0),
+		MaxDailyNotional: config.GetEnvAsFloat("KYC_TIER2_MAX_DAILY", 0),
+	},
+}
+
+// TierLimits returns the limits of tier. Unknown tiers get the unverified limits.
+func TierLimits(tier int) Limits {
+	if limits, ok := tierLimits[tier]; ok {
+		return limits
+	}
+	return tierLimits[domain.KYCTierNone]
+}
+
+// Check returns ErrOrderLimit or ErrDailyLimit (wrapped with the limit) if an
+// order of notional, on top of the dailyNotional already traded in the last
+// 24 hours, is more than l allows.
+func (l Limits) Check(notional, dailyNotional float64) error {
+	if l.MaxOrderNotional > 0 && notional > l.MaxOrderNotional {
+		return fmt.Errorf("%w of %.2f tokens", ErrOrderLimit, l.MaxOrderNotional)
+	}
+	if l.MaxDailyNotional > 0 && dailyNotional+notional > l.MaxDailyNotional {
+		return fmt.Errorf("%w of %.2f tokens (%.2f used)", ErrDailyLimit, l.MaxDailyNotional, dailyNotional)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package kyc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+// MockVerifier is a local provider for development and tests. It rejects
+// submissions that are plainly invalid and, unless AutoApprove is set
+// (KYC_MOCK_AUTO_APPROVE=true), leaves the rest to an admin.
+type MockVerifier struct {
+	AutoApprove bool
+	MinimumAge  int
+	now         func() time.Time
+}
+
+// NewMockVerifier returns the mock provider configured from the environment.
+func NewMockVerifier() *MockVerifier {
+	return &MockVerifier{
+		AutoApprove: config.GetEnv("KYC_MOCK_AUTO_APPROVE", "false") == "true",
+		MinimumAge:  config.GetEnvAsInt("KYC_MINIMUM_AGE", 18),
+		now:         time.Now,
+	}
+}
+
+// Name implements Verifier.
+func (m *MockVerifier) Name() string {
+	return "mock"
+}
+
+// Verify implements Verifier.
+func (m *MockVerifier) Verify(ctx context.Context, submission domain.KYCSubmission, documents []domain.KYCDocument) (Result, error) {
+	reference := "mock-" + submission.ID
+
+	born, err := time.Parse("2006-01-02", submission.DateOfBirth)
+	if err != nil {
+		return Result{Decision: DecisionReject, Reason: "Date of birth is not a valid date", Reference: reference}, nil
+	}
+	if born.AddDate(m.MinimumAge, 0, 0).After(m.now()) {
+		return Result{Decision: DecisionReject, Reason: "Applicant is under the minimum age", Reference: reference}, nil
+	}
+
+	types := make([]string, 0, len(documents))
+	for _, doc := range documents {
+		if doc.Size == 0 {
+			return Result{Decision: DecisionReject, Reason: "Document " + doc.ID + " is empty", Reference: reference}, nil
+		}
+		types = append(types, doc.Type)
+	}
+	if err := domain.CheckKYCDocuments(submission.Tier, types); err != nil {
+		if errors.Is(err, domain.ErrKYCDocumentsMissing) {
+			return Result{Decision: DecisionReject, Reason: err.Error(), Reference: reference}, nil
+		}
+		return Result{}, err
+	}
+	if submission.Tier >= domain.KYCTierFull && submission.Address == "" {
+		return Result{Decision: DecisionReject, Reason: "An address is required for the full tier", Reference: reference}, nil
+	}
+
+	if m.AutoApprove {
+		return Result{Decision: DecisionApprove, Reason: "Approved automatically by the mock provider", Reference: reference}, nil
+	}
+	return Result{Decision: DecisionReview, Reference: reference}, nil
+}
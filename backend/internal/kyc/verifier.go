@@ -0,0 +1,71 @@
+// Package kyc verifies users' identities through a pluggable provider and
+// sets trading limits by KYC tier.
+package kyc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+// Decisions a verifier can reach.
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+	DecisionReview  = "review" // Leave it to an admin
+)
+
+// Result is a verifier's decision on a submission.
+type Result struct {
+	Decision  string
+	Reason    string
+	Reference string // The provider's ID for the check, if any
+}
+
+// Verifier checks a KYC submission and its documents. Implementations for
+// external providers register themselves with Register.
+type Verifier interface {
+	Name() string
+	Verify(ctx context.Context, submission domain.KYCSubmission, documents []domain.KYCDocument) (Result, error)
+}
+
+// providers maps provider names to constructors.
+var providers = map[string]func() Verifier{
+	"mock": func() Verifier { return NewMockVerifier() },
+}
+
+// Register makes a verifier available under name for KYC_PROVIDER.
+func Register(name string, factory func() Verifier) {
+	providers[name] = factory
+}
+
+// New returns the verifier registered under name.
+func New(name string) (Verifier, error) {
+	factory, ok := providers[name]
+	if !ok {
+		names := make([]string, 0, len(providers))
+		for n := range providers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown KYC provider %q (available: %v)", name, names)
+	}
+	return factory(), nil
+}
+
+// Current is the verifier selected by KYC_PROVIDER (default mock), set by
+// Init at startup.
+var Current Verifier = NewMockVerifier()
+
+// Init selects the verifier named by KYC_PROVIDER.
+func Init() error {
+	verifier, err := New(config.GetEnv("KYC_PROVIDER", "mock"))
+	if err != nil {
+		return err
+	}
+	Current = verifier
+	return nil
+}
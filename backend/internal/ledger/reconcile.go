@@ -0,0 +1,120 @@
+// Package ledger checks the internal double-entry ledger against itself and
+// against users' energy_token balances on chain.
+package ledger
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"los-tecnicos/backend/internal/blockchain"
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+
+	"gorm.io/gorm/clause"
+)
+
+// reconcileInterval is how often RunReconciliation compares balances.
+var reconcileInterval = time.Duration(config.GetEnvAsInt("LEDGER_RECONCILE_MINUTES", 60)) * time.Minute
+
+// tolerance is the largest difference still treated as a match, to absorb
+// float rounding and the token's 7 decimal places.
+const tolerance = 1e-6
+
+// RunReconciliation starts a background process that reconciles the ledger
+// every LEDGER_RECONCILE_MINUTES.
+func RunReconciliation(sorobanClient *blockchain.SorobanClient) {
+	log.Println("Starting ledger reconciliation...")
+	ticker := time.NewTicker(reconcileInterval)
+
+	for range ticker.C {
+		if err := Reconcile(sorobanClient); err != nil {
+			log.Printf("Error reconciling ledger: %v", err)
+		}
+	}
+}
+
+// Reconcile checks that the ledger balances, that every account's cached
+// balance matches its ledger entries, and, if ENERGY_TOKEN_CONTRACT_ID is set,
+// records how each user's ledger holdings compare with their on-chain balance.
+func Reconcile(sorobanClient *blockchain.SorobanClient) error {
+	var total float64
+	if err := database.DB.Model(&domain.LedgerEntry{}).Select("COALESCE(SUM(amount), 0)").Scan(&total).Error; err != nil {
+		return err
+	}
+	if math.Abs(total) > tolerance {
+		log.Printf("LEDGER: entries sum to %.7f instead of 0", total)
+	}
+
+	var users []domain.User
+	if err := database.DB.Find(&users).Error; err != nil {
+		return err
+	}
+	contractID := config.GetEnv("ENERGY_TOKEN_CONTRACT_ID", "")
+	if contractID == "" {
+		log.Println("Skipping on-chain reconciliation: ENERGY_TOKEN_CONTRACT_ID is not set.")
+	}
+
+	for _, user := range users {
+		balances, err := database.LedgerBalances(database.DB, user.ID)
+		if err != nil {
+			return err
+		}
+		checkAccountCache(user.ID, balances)
+
+		if contractID == "" || user.WalletAddress == "" {
+			continue
+		}
+		onChain, err := sorobanClient.TokenBalance(contractID, user.WalletAddress)
+		if err != nil {
+			log.Printf("LEDGER: could not read on-chain balance for %s: %v", user.ID, err)
+			continue
+		}
+		result := compare(user, Holdings(balances), onChain, time.Now())
+		if !result.Matched {
+			log.Printf("LEDGER: %s holds %.7f on chain but %.7f in the ledger", user.ID, result.OnChainBalance, result.LedgerBalance)
+		}
+		if err := database.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&result).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Holdings is a user's total across their ledger accounts.
+func Holdings(balances map[string]float64) float64 {
+	total := 0.0
+	for _, account := range domain.UserLedgerAccounts {
+		total += balances[account]
+	}
+	return total
+}
+
+// compare builds the reconciliation record for one user.
+func compare(user domain.User, ledgerBalance, onChain float64, now time.Time) domain.LedgerReconciliation {
+	difference := onChain - ledgerBalance
+	return domain.LedgerReconciliation{
+		UserID:         user.ID,
+		WalletAddress:  user.WalletAddress,
+		LedgerBalance:  ledgerBalance,
+		OnChainBalance: onChain,
+		Difference:     difference,
+		Matched:        math.Abs(difference) <= tolerance,
+		CheckedAt:      now,
+	}
+}
+
+// checkAccountCache logs any difference between a user's Account row and
+// their ledger balances.
+func checkAccountCache(userID string, balances map[string]float64) {
+	var account domain.Account
+	if err := database.DB.First(&account, "user_id = ?", userID).Error; err != nil {
+		return
+	}
+	ledgerBalance := balances[domain.LedgerAvailable] + balances[domain.LedgerReserved]
+	if math.Abs(account.Balance-ledgerBalance) > tolerance || math.Abs(account.Reserved-balances[domain.LedgerReserved]) > tolerance {
+		log.Printf("LEDGER: account %s has balance %.7f/reserved %.7f but the ledger says %.7f/%.7f",
+			userID, account.Balance, account.Reserved, ledgerBalance, balances[domain.LedgerReserved])
+	}
+}
@@ -0,0 +1,34 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func TestHoldings(t *testing.T) {
+	balances := map[string]float64{
+		domain.LedgerAvailable: 10,
+		domain.LedgerReserved:  5,
+		domain.LedgerYield:     0.25,
+		domain.LedgerFees:      1,
+		domain.LedgerExternal:  -100, // system account, never held by users
+	}
+	if got := Holdings(balances); got != 16.25 {
+		t.Errorf("Expected 16.25, got %v", got)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	user := domain.User{ID: "u", WalletAddress: "G..."}
+	now := time.Now()
+
+	if r := compare(user, 16.25, 16.25+1e-9, now); !r.Matched {
+		t.Errorf("Expected rounding noise to match, got %+v", r)
+	}
+	r := compare(user, 16.25, 10, now)
+	if r.Matched || r.Difference != -6.25 {
+		t.Errorf("Expected a 6.25 shortfall on chain, got %+v", r)
+	}
+}
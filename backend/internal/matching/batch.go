@@ -0,0 +1,117 @@
+package matching
+
+import (
+	"log"
+	"time"
+
+	"los-tecnicos/backend/internal/auction"
+	"los-tecnicos/backend/internal/blockchain"
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/feed"
+	"los-tecnicos/backend/internal/pricing"
+)
+
+// Matching modes for the periodic tick.
+const (
+	ModeContinuous = "continuous" // Greedy price-time pairing at per-pair dynamic prices
+	ModeBatch      = "batch"      // Frequent batch auction at one uniform price per tick
+)
+
+// Mode selects how each tick matches the continuous market.
+var Mode = config.GetEnv("MATCHING_MODE", ModeContinuous)
+
+// BatchAuctionEvent is published on the market feed after every batch auction.
+type BatchAuctionEvent struct {
+	Cleared   bool    `json:"cleared"`
+	Price     float64 `json:"price"`
+	VolumeKwh float64 `json:"volume_kwh"`
+	SupplyKwh float64 `json:"supply_kwh"`
+	DemandKwh float64 `json:"demand_kwh"`
+	Orders    int     `json:"orders"`
+}
+
+// matchBatch clears every eligible order on the book together in one
+// uniform-price call auction, so the outcome does not depend on the order in
+// which orders arrived within the interval. Only orders whose delivery window
+// is open now take part; the others wait for their window.
+func matchBatch(sorobanClient *blockchain.SorobanClient, sells, buys []domain.EnergyOrder, socAvg float64, breaker pricing.BreakerState) {
+	now := time.Now()
+
+	var eligibleSells, eligibleBuys []domain.EnergyOrder
+	for _, o := range sells {
+		if o.AcceptsDeliveryAt(now) && proveSellerCapacity(o, socAvg) {
+			eligibleSells = append(eligibleSells, o)
+		}
+	}
+	for _, o := range buys {
+		if o.AcceptsDeliveryAt(now) {
+			eligibleBuys = append(eligibleBuys, o)
+		}
+	}
+
+	// The breaker's damped reference price picks the uniform price within the clearing range
+	plan, result := planBatch(eligibleSells, eligibleBuys, breaker.DampedPrice)
+
+	feed.Market.Publish("batch_auction", BatchAuctionEvent{
+		Cleared:   result.Cleared,
+		Price:     result.Price,
+		VolumeKwh: result.VolumeKwh,
+		SupplyKwh: result.SupplyKwh,
+		DemandKwh: result.DemandKwh,
+		Orders:    len(eligibleSells) + len(eligibleBuys),
+	})
+	if len(plan.Fills) == 0 && len(plan.Cancel) == 0 {
+		return
+	}
+
+	transactions, err := executePlan(plan, nil)
+	if err != nil {
+		log.Printf("Error processing batch auction: %v", err)
+		return
+	}
+	if result.Cleared {
+		log.Printf("Batch auction cleared %f kWh at %f", result.VolumeKwh, result.Price)
+	}
+	settleFills(sorobanClient, plan.Fills, transactions)
+
+	for _, order := range plan.Cancel {
+		log.Printf("Cancelled unfilled %s remainder of order %s (%f kWh)", order.TimeInForce, order.ID, order.RemainingKwh())
+	}
+}
+
+// planBatch clears sells against buys with auction.Clear and turns the
+// allocations into a Plan at the uniform price. Time-in-force is enforced as
+// in planMatches: FOK orders that would be left partially filled are withdrawn
+// and the auction is re-run without them, and every IOC or FOK order with
+// quantity left over is cancelled.
+func planBatch(sells, buys []domain.EnergyOrder, reference float64) (Plan, auction.Result) {
+	excluded := make(map[string]bool)
+	participating := func(orders []domain.EnergyOrder) []domain.EnergyOrder {
+		var result []domain.EnergyOrder
+		for _, o := range orders {
+			if !excluded[o.ID] {
+				result = append(result, o)
+			}
+		}
+		return result
+	}
+
+	orders := append(append([]domain.EnergyOrder{}, sells...), buys...)
+
+	var result auction.Result
+	var fills []Fill
+	for {
+		result = auction.Clear(participating(sells), participating(buys), reference)
+		fills = nil
+		for _, a := range result.Allocations {
+			start, end, _ := domain.DeliveryOverlap(a.Buy, a.Sell)
+			fills = append(fills, Fill{Buy: a.Buy, Sell: a.Sell, Kwh: a.Kwh, Price: result.Price, DeliveryStart: start, DeliveryEnd: end})
+		}
+		if !withdrawPartialFOK(orders, filledByOrder(fills), excluded) {
+			break
+		}
+	}
+
+	return Plan{Fills: fills, Cancel: unfilledImmediate(orders, filledByOrder(fills)), Auction: true}, result
+}
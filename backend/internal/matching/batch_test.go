@@ -0,0 +1,95 @@
+package matching
+
+import (
+	"math"
+	"testing"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func TestPlanBatchUniformPrice(t *testing.T) {
+	sells := []domain.EnergyOrder{
+		order("s1", "user_a", "sell", domain.TimeInForceGTC, 3, 2.0, 0),
+		order("s2", "user_c", "sell", domain.TimeInForceGTC, 2, 4.0, 1),
+	}
+	buys := []domain.EnergyOrder{
+		order("b1", "user_b", "buy", domain.TimeInForceGTC, 2, 7.0, 2),
+		order("b2", "user_d", "buy", domain.TimeInForceGTC, 3, 5.0, 3),
+	}
+
+	plan, result := planBatch(sells, buys, 4.5)
+
+	if !result.Cleared || math.Abs(result.VolumeKwh-5) > kwhEpsilon {
+		t.Fatalf("Expected 5 kWh to clear, got %+v", result)
+	}
+	for _, f := range plan.Fills {
+		if f.Price != 4.5 {
+			t.Errorf("Expected every fill at the uniform price 4.5, got %f", f.Price)
+		}
+	}
+}
+
+func TestPlanBatchIsOrderIndependent(t *testing.T) {
+	early := order("early", "user_b", "buy", domain.TimeInForceGTC, 2, 5.0, 0)
+	late := order("late", "user_d", "buy", domain.TimeInForceGTC, 2, 6.0, 4)
+	sells := []domain.EnergyOrder{order("s", "user_a", "sell", domain.TimeInForceGTC, 2, 3.0, 1)}
+
+	// A higher bid wins regardless of the order the orders arrive in
+	for _, buys := range [][]domain.EnergyOrder{{early, late}, {late, early}} {
+		plan, _ := planBatch(sells, buys, 4.0)
+		if filledKwh(plan, "late") != 2 || filledKwh(plan, "early") != 0 {
+			t.Errorf("Expected the higher bid to fill, got %+v", filledByOrder(plan.Fills))
+		}
+	}
+}
+
+func TestPlanBatchTimeInForce(t *testing.T) {
+	sells := []domain.EnergyOrder{order("s", "user_a", "sell", domain.TimeInForceGTC, 3, 3.0, 0)}
+	buys := []domain.EnergyOrder{
+		order("fok", "user_b", "buy", domain.TimeInForceFOK, 5, 7.0, 1),
+		order("ioc", "user_c", "buy", domain.TimeInForceIOC, 4, 6.0, 2),
+	}
+
+	plan, result := planBatch(sells, buys, 4.0)
+
+	if filledKwh(plan, "fok") != 0 || !cancelled(plan, "fok") {
+		t.Error("Expected the unfillable FOK buy to be killed")
+	}
+	if filledKwh(plan, "ioc") != 3 || !cancelled(plan, "ioc") {
+		t.Errorf("Expected the IOC buy to take 3 kWh and cancel the rest, got %f", filledKwh(plan, "ioc"))
+	}
+	if cancelled(plan, "s") {
+		t.Error("Resting GTC sell must not be cancelled")
+	}
+	if result.Price < 3.0 || result.Price > 6.0 {
+		t.Errorf("Clearing price %f outside the matched limits", result.Price)
+	}
+}
+
+func TestPlanBatchSkipsSelfTrades(t *testing.T) {
+	sells := []domain.EnergyOrder{
+		order("own_sell", "user_a", "sell", domain.TimeInForceGTC, 2, 2.0, 0),
+		order("other_sell", "user_c", "sell", domain.TimeInForceGTC, 2, 4.0, 1),
+	}
+	buys := []domain.EnergyOrder{
+		order("own_buy", "user_a", "buy", domain.TimeInForceGTC, 2, 7.0, 2),
+		order("other_buy", "user_b", "buy", domain.TimeInForceGTC, 2, 5.0, 3),
+	}
+
+	// As in continuous mode, user_a's bid and ask each trade with someone else
+	plan, _ := planBatch(sells, buys, 4.5)
+	for _, f := range plan.Fills {
+		if f.Buy.UserID == f.Sell.UserID {
+			t.Errorf("Fill %s->%s is a self-trade", f.Sell.ID, f.Buy.ID)
+		}
+	}
+	if filledKwh(plan, "own_sell") != 2 || filledKwh(plan, "own_buy") != 2 {
+		t.Errorf("Expected both of user_a's orders to fill against other users, got %+v", filledByOrder(plan.Fills))
+	}
+
+	// Alone on both sides, nothing trades
+	plan, result := planBatch(sells[:1], buys[:1], 4.5)
+	if result.Cleared || len(plan.Fills) != 0 {
+		t.Errorf("Expected no self-trade, got %+v", plan.Fills)
+	}
+}
@@ -0,0 +1,113 @@
+package matching
+
+import (
+	"log"
+	"time"
+
+	"los-tecnicos/backend/internal/auction"
+	"los-tecnicos/backend/internal/blockchain"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/feed"
+	"los-tecnicos/backend/internal/forecast"
+	"los-tecnicos/backend/internal/pricing"
+
+	"gorm.io/gorm"
+)
+
+// RunDayAheadMarket starts a background process that clears the day-ahead
+// auctions once their gate has closed. Each delivery hour is cleared once;
+// hours that already have an AuctionResult are skipped, so a restart after
+// gate closure resumes where it stopped.
+func RunDayAheadMarket(sorobanClient *blockchain.SorobanClient) {
+	log.Println("Starting day-ahead market...")
+	ticker := time.NewTicker(time.Minute)
+
+	for range ticker.C {
+		clearDayAhead(sorobanClient, time.Now())
+	}
+}
+
+func clearDayAhead(sorobanClient *blockchain.SorobanClient, now time.Time) {
+	day := auction.LastClosedDeliveryDay(now)
+
+	var cleared []time.Time
+	if err := database.DB.Model(&domain.AuctionResult{}).
+		Where("delivery_start >= ? AND delivery_start < ?", day, day.Add(24*time.Hour)).
+		Pluck("delivery_start", &cleared).Error; err != nil {
+		log.Printf("Error loading day-ahead results: %v", err)
+		return
+	}
+	done := make(map[int64]bool, len(cleared))
+	for _, t := range cleared {
+		done[t.Unix()] = true
+	}
+
+	for _, slot := range auction.Slots(day) {
+		if done[slot.Unix()] {
+			continue
+		}
+		if err := clearSlot(sorobanClient, slot); err != nil {
+			log.Printf("Error clearing day-ahead auction for %s: %v", slot.Format(time.RFC3339), err)
+			return // Retried on the next tick
+		}
+	}
+}
+
+// clearSlot runs the uniform-price auction for one delivery hour, settles the
+// matched bids as scheduled transactions and expires the rest.
+func clearSlot(sorobanClient *blockchain.SorobanClient, slot time.Time) error {
+	var sells, buys []domain.EnergyOrder
+	if err := database.DB.Where("type = ? AND market = ? AND status IN ? AND delivery_window_start = ?", "sell", domain.MarketDayAhead, domain.OpenOrderStatuses, slot).Find(&sells).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Where("type = ? AND market = ? AND status IN ? AND delivery_window_start = ?", "buy", domain.MarketDayAhead, domain.OpenOrderStatuses, slot).Find(&buys).Error; err != nil {
+		return err
+	}
+
+	// Price the hour from its forecast community state where available
+	socAvg := GetCommunitySoC()
+	if point, ok := forecast.Current().Predict(slot); ok {
+		socAvg = point.GridSoC
+	}
+	reference, _ := pricing.NewPricingEngine().ReferencePriceAt(slot, float64(len(sells)), float64(len(buys)), socAvg)
+
+	result := auction.Clear(sells, buys, reference)
+
+	end := slot.Add(auction.SlotDuration)
+	plan := Plan{Auction: true}
+	for _, a := range result.Allocations {
+		plan.Fills = append(plan.Fills, Fill{Buy: a.Buy, Sell: a.Sell, Kwh: a.Kwh, Price: result.Price, DeliveryStart: &slot, DeliveryEnd: &end})
+	}
+
+	record := domain.AuctionResult{
+		DeliveryStart:  slot,
+		DeliveryEnd:    end,
+		Cleared:        result.Cleared,
+		ClearingPrice:  result.Price,
+		ReferencePrice: reference,
+		VolumeKwh:      result.VolumeKwh,
+		SupplyKwh:      result.SupplyKwh,
+		DemandKwh:      result.DemandKwh,
+		BidCount:       len(sells) + len(buys),
+		ClearedAt:      time.Now(),
+	}
+
+	transactions, err := executePlan(plan, func(tx *gorm.DB) error {
+		// Bids (or remainders) not matched at gate closure cannot trade any more
+		if _, err := database.ExpireOrders(tx, "market = ? AND delivery_window_start = ?", domain.MarketDayAhead, slot); err != nil {
+			return err
+		}
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.Cleared {
+		log.Printf("Day-ahead %s cleared at %f for %f kWh", slot.Format(time.RFC3339), result.Price, result.VolumeKwh)
+	}
+	feed.Market.Publish("auction_result", record)
+	settleFills(sorobanClient, plan.Fills, transactions)
+	return nil
+}
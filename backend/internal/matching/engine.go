@@ -1,16 +1,19 @@
 package matching
 
 import (
+	"fmt"
 	"log"
 	"time"
 
 	"los-tecnicos/backend/internal/blockchain"
 	"los-tecnicos/backend/internal/core/domain"
 	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/feed"
 	"los-tecnicos/backend/internal/mqtt"
 	"los-tecnicos/backend/internal/pricing"
 	"los-tecnicos/backend/internal/zk"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -25,156 +28,320 @@ func RunMatchingEngine(sorobanClient *blockchain.SorobanClient) {
 }
 
 func matchOrders(sorobanClient *blockchain.SorobanClient) {
+	// Expire stale orders first so they cannot match between sweeper runs
+	if _, err := ExpireOrders(time.Now()); err != nil {
+		log.Printf("Error expiring orders: %v", err)
+	}
+
 	var openSellOrders []domain.EnergyOrder
 	var openBuyOrders []domain.EnergyOrder
 
-	// Fetch open sell orders, lowest price first
-	database.DB.Where("type = ? AND status = ?", "sell", "Created").Order("token_price asc").Find(&openSellOrders)
-
-	// Fetch open buy orders, highest price first
-	database.DB.Where("type = ? AND status = ?", "buy", "Created").Order("token_price desc").Find(&openBuyOrders)
+	// Fetch open sell orders, lowest price first (earliest priority first within a price)
+	database.DB.Where("type = ? AND market = ? AND status IN ?", "sell", domain.MarketContinuous, domain.OpenOrderStatuses).Order("token_price asc, priority_at asc").Find(&openSellOrders)
 
-	if len(openSellOrders) == 0 || len(openBuyOrders) == 0 {
-		return // Nothing to match
-	}
+	// Fetch open buy orders, highest price first (earliest priority first within a price)
+	database.DB.Where("type = ? AND market = ? AND status IN ?", "buy", domain.MarketContinuous, domain.OpenOrderStatuses).Order("token_price desc, priority_at asc").Find(&openBuyOrders)
 
 	// Calculate Market Variables for Dynamic Pricing
 	supplyVol := float64(len(openSellOrders))
 	demandVol := float64(len(openBuyOrders))
 	socAvg := GetCommunitySoC()
 
+	// Feed the circuit breaker every tick, even with an empty book, so the halt
+	// state and damped price stay current for the API and WebSocket feed.
+	breaker := observeMarket(supplyVol, demandVol, socAvg)
+	if breaker.Halted {
+		log.Printf("Trading halted until %s: %s", breaker.HaltedUntil, breaker.Reason)
+		return
+	}
+
+	// A one-sided book still gets a pass: it cannot trade, but any IOC/FOK
+	// orders on it must be cancelled.
+	if len(openSellOrders) == 0 && len(openBuyOrders) == 0 {
+		return // Nothing to match
+	}
+
 	log.Printf("Market State: Supply=%f, Demand=%f, SoC_avg=%f", supplyVol, demandVol, socAvg)
 
-	// Simple matching logic: Iterate through sell orders and find a matching buy order
-	for _, sellOrder := range openSellOrders {
-		for _, buyOrder := range openBuyOrders {
-			// Check if orders are still available (not matched in this same loop)
-			if sellOrder.Status != "Created" || buyOrder.Status != "Created" {
-				continue
-			}
+	if Mode == ModeBatch {
+		matchBatch(sorobanClient, openSellOrders, openBuyOrders, socAvg, breaker)
+		return
+	}
+
+	pe := pricing.NewPricingEngine()
+	damping := breaker.DampingRatio()
+	prices := make(map[string]float64)
+	provenSellers := make(map[string]bool)
 
-			// Calculate Dynamic Price
+	priceFn := func(buyOrder, sellOrder domain.EnergyOrder) (float64, bool) {
+		key := buyOrder.ID + "|" + sellOrder.ID
+		dynamicPrice, seen := prices[key]
+		if !seen {
 			// For distance, we'd need user locations. For now assuming distance = 1 (neighbor).
-			// Base price is the Seller's asking price.
-			pe := pricing.NewPricingEngine()
-			dynamicPrice, _, err := pe.CalculateDynamicPrice(buyOrder, sellOrder, supplyVol, demandVol, socAvg, 1.0)
+			price, _, err := pe.CalculateDynamicPrice(buyOrder, sellOrder, supplyVol, demandVol, socAvg, pricing.DefaultDistanceKm)
 			if err != nil {
 				log.Printf("Error calculating price: %v", err)
-				continue
+				return 0, false
+			}
+			// Apply the breaker's smoothing to the market-wide component of the price
+			dynamicPrice = price * damping
+			prices[key] = dynamicPrice
+		}
+
+		// Only prove battery capacity for sellers that would actually trade
+		if buyOrder.TokenPrice < dynamicPrice || dynamicPrice < sellOrder.TokenPrice {
+			return dynamicPrice, true
+		}
+		proven, checked := provenSellers[sellOrder.ID]
+		if !checked {
+			proven = proveSellerCapacity(sellOrder, socAvg)
+			provenSellers[sellOrder.ID] = proven
+		}
+		return dynamicPrice, proven
+	}
+
+	plan := planMatches(openSellOrders, openBuyOrders, priceFn)
+	if len(plan.Fills) == 0 && len(plan.Cancel) == 0 {
+		return
+	}
+
+	transactions, err := executePlan(plan, nil)
+	if err != nil {
+		log.Printf("Error processing matches: %v", err)
+		return
+	}
+	settleFills(sorobanClient, plan.Fills, transactions)
+
+	for _, order := range plan.Cancel {
+		log.Printf("Cancelled unfilled %s remainder of order %s (%f kWh)", order.TimeInForce, order.ID, order.RemainingKwh())
+	}
+}
+
+// settleFills publishes committed fills, asks the donor's device to lock the
+// energy and triggers on-chain settlement.
+func settleFills(sorobanClient *blockchain.SorobanClient, fills []Fill, transactions []domain.Transaction) {
+	for i, fill := range fills {
+		log.Printf("Match found! Buy: %s, Sell: %s, kWh: %f", fill.Buy.ID, fill.Sell.ID, fill.Kwh)
+		log.Printf("Settlement Price: %f (Dynamic) vs %f (Ask)", fill.Price, fill.Sell.TokenPrice)
+
+		feed.Market.Publish("trade", TradeEvent{
+			Price:     fill.Price,
+			KwhAmount: fill.Kwh,
+			Timestamp: transactions[i].Timestamp.UTC().Format(time.RFC3339),
+		})
+
+		// 4. COORDINATION: Send lock command to the device delivering the sell
+		// order (orders from before devices were tracked use any live ESP32 of the donor)
+		var device domain.IoTDevice
+		query := database.DB.Where("id = ?", fill.Sell.DeviceID)
+		if fill.Sell.DeviceID == "" {
+			query = database.DB.Where("owner_id = ? AND device_type = ? AND status <> ?", fill.Sell.UserID, "esp32", domain.DeviceStatusDecommissioned)
+		}
+		if err := query.First(&device).Error; err == nil {
+			log.Printf("Sending lock command to device: %s", device.ID)
+			if err := mqtt.SendLockCommand(device.ID, fill.Sell.ID, fill.Kwh); err != nil {
+				log.Printf("Failed to send lock command to device %s: %v", device.ID, err)
+			} else if err := database.RecordLockRequest(database.DB, &domain.DeviceLockRequest{
+				DeviceID:  device.ID,
+				OrderID:   fill.Sell.ID,
+				KwhAmount: fill.Kwh,
+				SentAt:    time.Now(),
+			}); err != nil {
+				// Only costs the device a data point in its delivery reliability
+				log.Printf("Failed to record lock command to device %s: %v", device.ID, err)
+			}
+		} else {
+			log.Printf("No ESP32 device found for donor %s, skipping IoT lock simulation", fill.Sell.UserID)
+		}
+
+		// Trigger blockchain execution (asynchronously)
+		// Note: Real Soroban implementation would need to authorize this specific amount.
+		go sorobanClient.HandleTradeExecution(fill.Buy)
+	}
+}
+
+// proveSellerCapacity runs the simulated ZK range proof for a seller.
+func proveSellerCapacity(sellOrder domain.EnergyOrder, socAvg float64) bool {
+	// --- ZK PRIVACY CHECK (Simulated Device Logic) ---
+	// The Seller provides a ZK Proof that their battery > 20% without revealing it.
+	// 1. Seller creates proof (Simulated here as if coming from device)
+	// In a real system, the 'device' sends this proof attached to the order.
+
+	// Use the new Ristretto255 implementation
+	zkCommitment, err := zk.NewPedersenCommitment(int64(socAvg * 100)) // Using Avg SoC as proxy for seller's real soc
+	if err != nil {
+		log.Printf("ZK Setup Failed for seller %s: %v", sellOrder.UserID, err)
+		return false
+	}
+
+	proof, err := zkCommitment.GenerateRangeProof(20) // Requirement: > 20% charge
+	if err != nil {
+		log.Printf("ZK Proof Generation Failed for seller %s: %v", sellOrder.UserID, err)
+		return false // Skip if they can't prove battery health
+	}
+
+	// 2. Matching Engine Verifies the Proof
+	if !zk.VerifyRangeProof(proof) {
+		log.Printf("ZK Proof Verification FAILED for seller %s. Rejecting match.", sellOrder.UserID)
+		return false
+	}
+	log.Printf(">>> ZK PRIVACY: Seller %s proved Battery > 20%% with Commitment %s", sellOrder.UserID, proof.CommitmentStr)
+	return true
+}
+
+// executePlan persists a matching pass in a single database transaction: order
+// fill quantities and statuses, one Transaction per fill with its token
+// settlement and fees, yield accrual, and the cancellation of IOC/FOK remainders. Order
+// updates go through the order state machine and are conditional on the
+// order's version, so a concurrent cancellation or amendment rolls back the
+// whole pass and the orders are re-planned on the next tick. finalize, if not nil, runs last in the same
+// database transaction.
+//
+// Fills delivered in a future window are recorded as Scheduled, others as Pending.
+func executePlan(plan Plan, finalize func(tx *gorm.DB) error) ([]domain.Transaction, error) {
+	var transactions []domain.Transaction
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Candidate routing nodes for fee sharing
+		var nodes []domain.NetworkNode
+		if err := tx.Find(&nodes).Error; err != nil {
+			return err
+		}
+
+		// Orders as updated so far in this pass, keyed by ID
+		current := make(map[string]*domain.EnergyOrder)
+		latest := func(order domain.EnergyOrder) *domain.EnergyOrder {
+			if o, ok := current[order.ID]; ok {
+				return o
 			}
+			current[order.ID] = &order
+			return &order
+		}
+
+		for _, fill := range plan.Fills {
+			// Update orders
+			for _, order := range []*domain.EnergyOrder{latest(fill.Buy), latest(fill.Sell)} {
+				filled := order.FilledKwh + fill.Kwh
+				status := domain.OrderStatusPartiallyFilled
+				if filled >= order.KwhAmount-kwhEpsilon {
+					status = domain.OrderStatusMatched
+				}
 
-			// Price condition: buyer is willing to pay at least the dynamic price (or the seller's price if calc fails)
-			// We effectively use the dynamic price as the settlement price.
-			// However, if dynamic price > buyer's limit, the trade might not happen unless we treat buy limit as soft?
-			// For this MVP, let's say if Dynamic Price <= Buyer's Limit, we execute AT Dynamic Price.
-			if buyOrder.TokenPrice >= dynamicPrice {
-				// Quantity condition: for simplicity, we match the exact amount for now
-				if buyOrder.KwhAmount == sellOrder.KwhAmount {
-
-					// --- ZK PRIVACY CHECK (Simulated Device Logic) ---
-					// The Seller provides a ZK Proof that their battery > 20% without revealing it.
-					// 1. Seller creates proof (Simulated here as if coming from device)
-					// In a real system, the 'device' sends this proof attached to the order.
-
-					// Use the new Ristretto255 implementation
-					zkCommitment, err := zk.NewPedersenCommitment(int64(socAvg * 100)) // Using Avg SoC as proxy for seller's real soc
-					if err != nil {
-						log.Printf("ZK Setup Failed for seller %s: %v", sellOrder.UserID, err)
-						continue
-					}
-
-					proof, err := zkCommitment.GenerateRangeProof(20) // Requirement: > 20% charge
-					if err != nil {
-						log.Printf("ZK Proof Generation Failed for seller %s: %v", sellOrder.UserID, err)
-						continue // Skip if they can't prove battery health
-					}
-
-					// 2. Matching Engine Verifies the Proof
-					if !zk.VerifyRangeProof(proof) {
-						log.Printf("ZK Proof Verification FAILED for seller %s. Rejecting match.", sellOrder.UserID)
-						continue
-					}
-					log.Printf(">>> ZK PRIVACY: Seller %s proved Battery > 20%% with Commitment %s", sellOrder.UserID, proof.CommitmentStr)
-					// ------------------------------------
-
-					log.Printf("Match found! Buy: %s, Sell: %s", buyOrder.ID, sellOrder.ID)
-					log.Printf("Settlement Price: %f (Dynamic) vs %f (Ask)", dynamicPrice, sellOrder.TokenPrice)
-
-					// 3. Match found - Execute Transaction
-					dbErr := database.DB.Transaction(func(tx *gorm.DB) error {
-						// Update orders
-						if err := tx.Model(&buyOrder).Update("status", "Matched").Error; err != nil {
-							return err
-						}
-						if err := tx.Model(&sellOrder).Update("status", "Matched").Error; err != nil {
-							return err
-						}
-
-						// Create transaction record
-						transaction := domain.Transaction{
-							ID:             "txn_" + buyOrder.ID, // Simple TXN id for now
-							DonorID:        sellOrder.UserID,
-							RecipientID:    buyOrder.UserID,
-							KwhAmount:      buyOrder.KwhAmount,
-							TokenAmount:    buyOrder.KwhAmount * dynamicPrice, // Trade happens at DYNAMIC price
-							BlockchainHash: "pending_" + "txn_" + buyOrder.ID,
-							Status:         "Pending",
-							Timestamp:      time.Now(),
-						}
-						if err := tx.Create(&transaction).Error; err != nil {
-							return err
-						}
-
-						// --- DEFI YIELD ACCRUAL (Persistence) ---
-						// If the order sat for a while, they earned yield.
-						// Simulating "Instant" yield for the demo.
-						yieldAmount := buyOrder.KwhAmount * dynamicPrice * 0.05 / 365
-						yieldRecord := domain.YieldRecord{
-							UserID:    sellOrder.UserID,
-							Amount:    yieldAmount,
-							Source:    "LiquidityPool_Staking",
-							Timestamp: time.Now(),
-						}
-						if err := tx.Create(&yieldRecord).Error; err != nil {
-							log.Printf("Failed to persist yield: %v", err)
-							// Don't fail the trade for yield error, just log it
-						}
-						log.Printf(">>> DEFI: Persisted Yield Record of %.6f XLM for User %s", yieldAmount, sellOrder.UserID)
-						// ----------------------------------------
-
-						return nil
-					})
-
-					if dbErr != nil {
-						log.Printf("Error processing match: %v", dbErr)
-						continue
-					}
-
-					// 4. COORDINATION: Send lock command to donor's IoT device
-					var device domain.IoTDevice
-					if err := database.DB.Where("owner_id = ? AND device_type = ?", sellOrder.UserID, "esp32").First(&device).Error; err == nil {
-						log.Printf("Sending lock command to device: %s", device.ID)
-						mqtt.SendLockCommand(device.ID, sellOrder.ID, sellOrder.KwhAmount)
-					} else {
-						log.Printf("No ESP32 device found for donor %s, skipping IoT lock simulation", sellOrder.UserID)
-					}
-
-					// Trigger blockchain execution (asynchronously)
-					// Note: Real Soroban implementation would need to authorize this specific amount.
-					go sorobanClient.HandleTradeExecution(buyOrder)
-
-					// Break inner loop to move to next sell order
-					break
+				// Fails if the order was cancelled or amended since it was read
+				if err := database.TransitionOrder(tx, order, status, map[string]interface{}{"filled_kwh": filled}); err != nil {
+					return fmt.Errorf("order %s: %w", order.ID, err)
 				}
 			}
+
+			// Create transaction record
+			txnID := "txn_" + uuid.New().String()
+			status := domain.TransactionPending
+			if fill.DeliveryStart != nil && fill.DeliveryStart.After(time.Now()) {
+				status = domain.TransactionScheduled
+			}
+			transaction := domain.Transaction{
+				ID:             txnID,
+				BuyOrderID:     fill.Buy.ID,
+				SellOrderID:    fill.Sell.ID,
+				DonorID:        fill.Sell.UserID,
+				RecipientID:    fill.Buy.UserID,
+				KwhAmount:      fill.Kwh,
+				TokenAmount:    fill.Kwh * fill.Price, // Trade happens at DYNAMIC price
+				BlockchainHash: "pending_" + txnID,
+				Status:         status,
+				Timestamp:      time.Now(),
+				DeliveryStart:  fill.DeliveryStart,
+				DeliveryEnd:    fill.DeliveryEnd,
+			}
+			charge := tradeFees(tx, fill, plan.Auction, nodes)
+			transaction.BuyerFee = charge.BuyerFee
+			transaction.SellerFee = charge.SellerFee
+			transaction.PlatformFee = charge.PlatformFee
+			transaction.NodeFee = charge.NodeTotal()
+			if err := tx.Create(&transaction).Error; err != nil {
+				return err
+			}
+			transactions = append(transactions, transaction)
+
+			// The fill released the buyer's reservation; now pay the seller and collect fees
+			if err := database.SettleTrade(tx, fill.Buy.UserID, fill.Sell.UserID, transaction.TokenAmount, txnID); err != nil {
+				return err
+			}
+			if err := database.ChargeFees(tx, transaction, charge.NodeFees); err != nil {
+				return fmt.Errorf("fees: %w", err)
+			}
+
+			// --- DEFI YIELD ACCRUAL (Persistence) ---
+			// If the order sat for a while, they earned yield.
+			// Simulating "Instant" yield for the demo.
+			yieldAmount := fill.Kwh * fill.Price * 0.05 / 365
+			if err := database.AccrueYield(tx, fill.Sell.UserID, yieldAmount, txnID); err != nil {
+				return fmt.Errorf("yield accrual: %w", err)
+			}
+			log.Printf(">>> DEFI: Persisted Yield Record of %.6f XLM for User %s", yieldAmount, fill.Sell.UserID)
+			// ----------------------------------------
+		}
+
+		// Cancel whatever IOC/FOK quantity could not be filled in this pass
+		for _, order := range plan.Cancel {
+			if err := database.TransitionOrder(tx, latest(order), domain.OrderStatusCancelled, nil); err != nil {
+				return fmt.Errorf("order %s: %w", order.ID, err)
+			}
 		}
+
+		if finalize != nil {
+			return finalize(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return transactions, nil
+}
+
+// TradeEvent is published on the market feed for every fill.
+type TradeEvent struct {
+	Price     float64 `json:"price"`
+	KwhAmount float64 `json:"kwh_amount"`
+	Timestamp string  `json:"timestamp"`
+}
+
+// MarketStatus is published on the market feed after every matching tick.
+type MarketStatus struct {
+	Price          float64              `json:"price"`
+	Supply         float64              `json:"supply"`
+	Demand         float64              `json:"demand"`
+	GridSoC        float64              `json:"grid_soc"`
+	CircuitBreaker pricing.BreakerState `json:"circuit_breaker"`
+}
+
+// observeMarket prices a reference trade for the current market state, feeds it
+// to the circuit breaker and publishes the result on the market feed.
+func observeMarket(supplyVol, demandVol, socAvg float64) pricing.BreakerState {
+	pe := pricing.NewPricingEngine()
+	refPrice, breakdown := pe.ReferencePrice(supplyVol, demandVol, socAvg)
+
+	// A one-sided book drives F_sd to its floor without any trade being possible,
+	// so only a two-sided market can count towards a halt.
+	boundHit := pricing.MultiplierAtBounds(breakdown) && supplyVol > 0 && demandVol > 0
+	state := pricing.Breaker.Observe(refPrice, boundHit, time.Now())
+
+	feed.Market.Publish("market_status", MarketStatus{
+		Price:          state.DampedPrice,
+		Supply:         supplyVol,
+		Demand:         demandVol,
+		GridSoC:        socAvg,
+		CircuitBreaker: state,
+	})
+	return state
 }
 
-// GetCommunitySoC calculates the average battery level of all registered devices
+// GetCommunitySoC calculates the average battery level of all registered
+// devices that have not been decommissioned
 func GetCommunitySoC() float64 {
 	var devices []domain.IoTDevice
-	if err := database.DB.Find(&devices).Error; err != nil {
+	if err := database.DB.Where("status <> ?", domain.DeviceStatusDecommissioned).Find(&devices).Error; err != nil {
 		log.Printf("Error fetching devices for SoC: %v", err)
 		return 0.5 // Default to 50% on error
 	}
@@ -0,0 +1,39 @@
+package matching
+
+import (
+	"log"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/database"
+)
+
+// sweepInterval is how often RunOrderSweeper expires stale orders.
+var sweepInterval = time.Duration(config.GetEnvAsInt("ORDER_SWEEP_SECONDS", 30)) * time.Second
+
+// RunOrderSweeper starts a background process that moves open orders past
+// their expiry to Expired.
+func RunOrderSweeper() {
+	log.Println("Starting order expiry sweeper...")
+	ticker := time.NewTicker(sweepInterval)
+
+	for range ticker.C {
+		if _, err := ExpireOrders(time.Now()); err != nil {
+			log.Printf("Error expiring orders: %v", err)
+		}
+	}
+}
+
+// ExpireOrders marks every open order whose expiry is at or before now as
+// Expired and returns how many were updated. Partially filled orders keep
+// their fills; only the remainder expires.
+func ExpireOrders(now time.Time) (int64, error) {
+	expired, err := database.ExpireOrders(database.DB, "expires_at <= ?", now)
+	if err != nil {
+		return 0, err
+	}
+	if expired > 0 {
+		log.Printf("Expired %d stale orders", expired)
+	}
+	return expired, nil
+}
@@ -0,0 +1,34 @@
+package matching
+
+import (
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/fees"
+
+	"gorm.io/gorm"
+)
+
+// routeDetourKm is how far off the direct donor-recipient path a mesh node
+// may be and still be credited with routing a trade.
+var routeDetourKm = config.GetEnvAsFloat("FEE_ROUTE_DETOUR_KM", 1.0)
+
+// tradeFees computes the fees on a fill under the current schedule. In
+// continuous matching the order with the earlier time priority is the maker.
+// Energy flows from the seller's delivering device (or, failing that, the
+// seller's own location) to the buyer's location.
+func tradeFees(tx *gorm.DB, fill Fill, auction bool, nodes []domain.NetworkNode) fees.Breakdown {
+	var buyer, seller domain.User
+	tx.Select("location").First(&buyer, "id = ?", fill.Buy.UserID)
+	tx.Select("location").First(&seller, "id = ?", fill.Sell.UserID)
+	from := seller.Location
+	if fill.Sell.DeviceID != "" {
+		var device domain.IoTDevice
+		if tx.Select("location").First(&device, "id = ?", fill.Sell.DeviceID).Error == nil && device.Location != "" {
+			from = device.Location
+		}
+	}
+
+	route := fees.Route(nodes, from, buyer.Location, routeDetourKm)
+	buyIsMaker := fill.Buy.PriorityAt.Before(fill.Sell.PriorityAt)
+	return fees.Current.Charge(fill.Kwh*fill.Price, buyIsMaker, auction, fill.Buy.FeeRate, route)
+}
@@ -0,0 +1,78 @@
+package matching
+
+import (
+	"errors"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/pricing"
+)
+
+var (
+	// ErrNoLiquidity is returned when a market order has no counterparty to price against.
+	ErrNoLiquidity = errors.New("no counterparty orders available")
+	// ErrTradingHalted is returned while the circuit breaker has suspended trading.
+	ErrTradingHalted = errors.New("trading is halted")
+)
+
+// CurrentMarketState returns the open continuous-market sell and buy order counts (the supply and
+// demand inputs to dynamic pricing) and the community SoC.
+func CurrentMarketState() (supplyVol, demandVol, socAvg float64) {
+	var sellOrdersCount, buyOrdersCount int64
+	database.DB.Model(&domain.EnergyOrder{}).Where("type = ? AND market = ? AND status IN ?", "sell", domain.MarketContinuous, domain.OpenOrderStatuses).Count(&sellOrdersCount)
+	database.DB.Model(&domain.EnergyOrder{}).Where("type = ? AND market = ? AND status IN ?", "buy", domain.MarketContinuous, domain.OpenOrderStatuses).Count(&buyOrdersCount)
+	return float64(sellOrdersCount), float64(buyOrdersCount), GetCommunitySoC()
+}
+
+// MarketOrderLimit converts a market order into the worst price it accepts: the
+// dynamic price currently available to the user, moved against them by the
+// slippage cap (see marketBuyLimit for buys). The matching engine then treats
+// it like any other limit.
+func MarketOrderLimit(userID, orderType string, kwh, slippage float64) (float64, error) {
+	breaker := pricing.Breaker.State(time.Now())
+	if breaker.Halted {
+		return 0, ErrTradingHalted
+	}
+
+	counterType := "sell"
+	if orderType == "sell" {
+		counterType = "buy"
+	}
+	var counterparties []domain.EnergyOrder
+	if err := database.DB.Where("type = ? AND market = ? AND status IN ? AND user_id <> ?", counterType, domain.MarketContinuous, domain.OpenOrderStatuses, userID).Find(&counterparties).Error; err != nil {
+		return 0, err
+	}
+	if len(counterparties) == 0 {
+		return 0, ErrNoLiquidity
+	}
+
+	supplyVol, demandVol, socAvg := CurrentMarketState()
+	pe := pricing.NewPricingEngine()
+
+	if orderType == "buy" {
+		book := pe.QuoteBook(kwh, counterparties, supplyVol, demandVol, socAvg, breaker.DampingRatio())
+		return marketBuyLimit(book, slippage), nil
+	}
+
+	// For a seller, the dynamic price is the same whichever buyer takes the order
+	seller := domain.EnergyOrder{UserID: userID}
+	best, _ := pe.EstimateDynamicPrice(seller, supplyVol, demandVol, socAvg, pricing.DefaultDistanceKm)
+	return slippageLimit(orderType, best*breaker.DampingRatio(), slippage), nil
+}
+
+// marketBuyLimit caps a market buy at the size-weighted average price of the
+// book levels it would fill, moved up by slippage. Capping from the best level
+// alone would let a large order clear only the cheapest sellers.
+func marketBuyLimit(book pricing.BookQuote, slippage float64) float64 {
+	return slippageLimit("buy", book.AveragePrice, slippage)
+}
+
+// slippageLimit moves the best available price against the order by slippage:
+// up for a buyer's ceiling, down for a seller's floor.
+func slippageLimit(orderType string, best, slippage float64) float64 {
+	if orderType == "buy" {
+		return best * (1 + slippage)
+	}
+	return best * (1 - slippage)
+}
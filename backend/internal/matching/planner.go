@@ -0,0 +1,136 @@
+package matching
+
+import (
+	"math"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+// kwhEpsilon absorbs floating point error when comparing kWh quantities.
+const kwhEpsilon = 1e-9
+
+// PriceFunc returns the settlement price for a buy/sell pair. ok is false if
+// the pair cannot be priced (or is otherwise ineligible) in this pass.
+type PriceFunc func(buy, sell domain.EnergyOrder) (price float64, ok bool)
+
+// Fill is a planned execution of Kwh between one buy and one sell order,
+// delivered within the overlap of their delivery windows.
+type Fill struct {
+	Buy           domain.EnergyOrder
+	Sell          domain.EnergyOrder
+	Kwh           float64
+	Price         float64
+	DeliveryStart *time.Time
+	DeliveryEnd   *time.Time
+}
+
+// Plan is the outcome of one matching pass.
+type Plan struct {
+	Fills []Fill
+	// Cancel lists IOC and FOK orders whose unfilled remainder must be cancelled.
+	Cancel []domain.EnergyOrder
+	// Auction is set for uniform-price auctions, where neither side of a fill
+	// is the maker.
+	Auction bool
+}
+
+// planMatches pairs orders using price-time priority. sells must be sorted by
+// ascending price and buys by descending price, each by PriorityAt within a
+// price. A pair whose delivery windows overlap trades at price(buy, sell) if
+// that is within both limits; quantities may be partially filled across
+// several counterparties.
+//
+// Time-in-force is enforced on top of the greedy allocation: any FOK order that
+// would be left partially filled is withdrawn and the allocation is repeated
+// without it, so FOK orders trade in full or not at all. After the pass, every
+// IOC or FOK order with quantity left over is returned in Plan.Cancel.
+func planMatches(sells, buys []domain.EnergyOrder, price PriceFunc) Plan {
+	excluded := make(map[string]bool)
+	orders := append(append([]domain.EnergyOrder{}, sells...), buys...)
+
+	var fills []Fill
+	for {
+		fills = allocate(sells, buys, excluded, price)
+		if !withdrawPartialFOK(orders, filledByOrder(fills), excluded) {
+			break
+		}
+	}
+
+	return Plan{Fills: fills, Cancel: unfilledImmediate(orders, filledByOrder(fills))}
+}
+
+// withdrawPartialFOK adds every FOK order left partially filled to excluded and
+// reports whether any was added, in which case the allocation must be re-run.
+func withdrawPartialFOK(orders []domain.EnergyOrder, filled map[string]float64, excluded map[string]bool) bool {
+	withdrawn := false
+	for _, o := range orders {
+		if o.TimeInForce == domain.TimeInForceFOK && !excluded[o.ID] && filled[o.ID] < o.RemainingKwh()-kwhEpsilon {
+			excluded[o.ID] = true
+			withdrawn = true
+		}
+	}
+	return withdrawn
+}
+
+// unfilledImmediate returns the IOC and FOK orders with quantity left over.
+func unfilledImmediate(orders []domain.EnergyOrder, filled map[string]float64) []domain.EnergyOrder {
+	var cancel []domain.EnergyOrder
+	for _, o := range orders {
+		if o.IsImmediate() && filled[o.ID] < o.RemainingKwh()-kwhEpsilon {
+			cancel = append(cancel, o)
+		}
+	}
+	return cancel
+}
+
+// allocate greedily fills each sell order against the best eligible buys.
+func allocate(sells, buys []domain.EnergyOrder, excluded map[string]bool, price PriceFunc) []Fill {
+	remaining := make(map[string]float64, len(sells)+len(buys))
+	for _, o := range sells {
+		remaining[o.ID] = o.RemainingKwh()
+	}
+	for _, o := range buys {
+		remaining[o.ID] = o.RemainingKwh()
+	}
+
+	var fills []Fill
+	for _, sell := range sells {
+		if excluded[sell.ID] {
+			continue
+		}
+		for _, buy := range buys {
+			if remaining[sell.ID] <= kwhEpsilon {
+				break
+			}
+			if excluded[buy.ID] || remaining[buy.ID] <= kwhEpsilon || buy.UserID == sell.UserID {
+				continue
+			}
+
+			start, end, overlaps := domain.DeliveryOverlap(buy, sell)
+			if !overlaps {
+				continue
+			}
+
+			p, ok := price(buy, sell)
+			if !ok || buy.TokenPrice < p || p < sell.TokenPrice {
+				continue
+			}
+
+			qty := math.Min(remaining[sell.ID], remaining[buy.ID])
+			remaining[sell.ID] -= qty
+			remaining[buy.ID] -= qty
+			fills = append(fills, Fill{Buy: buy, Sell: sell, Kwh: qty, Price: p, DeliveryStart: start, DeliveryEnd: end})
+		}
+	}
+	return fills
+}
+
+func filledByOrder(fills []Fill) map[string]float64 {
+	filled := make(map[string]float64)
+	for _, f := range fills {
+		filled[f.Buy.ID] += f.Kwh
+		filled[f.Sell.ID] += f.Kwh
+	}
+	return filled
+}
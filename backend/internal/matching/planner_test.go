@@ -0,0 +1,245 @@
+package matching
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/pricing"
+)
+
+var testEpoch = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func order(id, userID, orderType, tif string, kwh, price float64, age int) domain.EnergyOrder {
+	return domain.EnergyOrder{
+		ID:          id,
+		UserID:      userID,
+		Type:        orderType,
+		Kind:        domain.OrderKindLimit,
+		TimeInForce: tif,
+		KwhAmount:   kwh,
+		TokenPrice:  price,
+		Status:      domain.OrderStatusCreated,
+		CreatedAt:   testEpoch.Add(time.Duration(age) * time.Second),
+		PriorityAt:  testEpoch.Add(time.Duration(age) * time.Second),
+	}
+}
+
+// flatPrice settles every pair at the same dynamic price.
+func flatPrice(p float64) PriceFunc {
+	return func(buy, sell domain.EnergyOrder) (float64, bool) { return p, true }
+}
+
+func filledKwh(plan Plan, id string) float64 {
+	return filledByOrder(plan.Fills)[id]
+}
+
+func cancelled(plan Plan, id string) bool {
+	for _, o := range plan.Cancel {
+		if o.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPlanTimeInForceMatrix(t *testing.T) {
+	tifs := []string{domain.TimeInForceGTC, domain.TimeInForceIOC, domain.TimeInForceFOK}
+	liquidity := map[string]float64{"full": 5, "partial": 3, "none": 0}
+
+	for _, side := range []string{"buy", "sell"} {
+		for _, tif := range tifs {
+			for name, available := range liquidity {
+				t.Run(side+"/"+tif+"/"+name, func(t *testing.T) {
+					// The order under test wants 5 kWh; the resting GTC counterparty offers `available`
+					var sells, buys []domain.EnergyOrder
+					if side == "buy" {
+						buys = []domain.EnergyOrder{order("taker", "user_b", "buy", tif, 5, 6.0, 1)}
+						if available > 0 {
+							sells = []domain.EnergyOrder{order("maker", "user_a", "sell", domain.TimeInForceGTC, available, 4.0, 0)}
+						}
+					} else {
+						sells = []domain.EnergyOrder{order("taker", "user_a", "sell", tif, 5, 4.0, 1)}
+						if available > 0 {
+							buys = []domain.EnergyOrder{order("maker", "user_b", "buy", domain.TimeInForceGTC, available, 6.0, 0)}
+						}
+					}
+
+					plan := planMatches(sells, buys, flatPrice(5.0))
+
+					wantFilled := available
+					if tif == domain.TimeInForceFOK && available < 5 {
+						wantFilled = 0 // Fill-or-kill never partially fills
+					}
+					if got := filledKwh(plan, "taker"); math.Abs(got-wantFilled) > kwhEpsilon {
+						t.Errorf("Expected %f kWh filled, got %f", wantFilled, got)
+					}
+
+					wantCancelled := tif != domain.TimeInForceGTC && available < 5
+					if got := cancelled(plan, "taker"); got != wantCancelled {
+						t.Errorf("Expected cancelled=%v, got %v", wantCancelled, got)
+					}
+					if cancelled(plan, "maker") {
+						t.Error("Resting GTC counterparty must never be cancelled")
+					}
+					for _, f := range plan.Fills {
+						if f.Price != 5.0 {
+							t.Errorf("Expected fills at the dynamic price 5.0, got %f", f.Price)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestPlanFOKFillsAcrossSeveralCounterparties(t *testing.T) {
+	sells := []domain.EnergyOrder{
+		order("s1", "user_a", "sell", domain.TimeInForceGTC, 3, 4.0, 0),
+		order("s2", "user_c", "sell", domain.TimeInForceGTC, 2, 4.5, 1),
+	}
+	buys := []domain.EnergyOrder{order("b1", "user_b", "buy", domain.TimeInForceFOK, 5, 6.0, 2)}
+
+	plan := planMatches(sells, buys, flatPrice(5.0))
+
+	if len(plan.Fills) != 2 || filledKwh(plan, "b1") != 5 || len(plan.Cancel) != 0 {
+		t.Errorf("Expected FOK buy to fill 3+2 kWh, got %+v", plan)
+	}
+}
+
+func TestPlanWithdrawnFOKFreesLiquidity(t *testing.T) {
+	sells := []domain.EnergyOrder{order("s1", "user_a", "sell", domain.TimeInForceGTC, 4, 4.0, 0)}
+	buys := []domain.EnergyOrder{
+		order("fok", "user_b", "buy", domain.TimeInForceFOK, 10, 7.0, 1), // Best price but too large
+		order("gtc", "user_c", "buy", domain.TimeInForceGTC, 3, 6.0, 2),
+	}
+
+	plan := planMatches(sells, buys, flatPrice(5.0))
+
+	if filledKwh(plan, "fok") != 0 || !cancelled(plan, "fok") {
+		t.Error("Expected the oversized FOK buy to be killed")
+	}
+	if filledKwh(plan, "gtc") != 3 {
+		t.Errorf("Expected the GTC buy to take 3 kWh once the FOK was withdrawn, got %f", filledKwh(plan, "gtc"))
+	}
+}
+
+func TestPlanRespectsBothLimits(t *testing.T) {
+	cases := []struct {
+		name      string
+		buyLimit  float64
+		sellLimit float64
+		wantFill  bool
+	}{
+		{"crosses", 6.0, 4.0, true},
+		{"buyer limit below dynamic price", 4.9, 4.0, false},
+		{"seller limit above dynamic price", 6.0, 5.1, false},
+		{"both at dynamic price", 5.0, 5.0, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sells := []domain.EnergyOrder{order("s", "user_a", "sell", domain.TimeInForceGTC, 1, tc.sellLimit, 0)}
+			buys := []domain.EnergyOrder{order("b", "user_b", "buy", domain.TimeInForceGTC, 1, tc.buyLimit, 1)}
+
+			plan := planMatches(sells, buys, flatPrice(5.0))
+			if got := len(plan.Fills) == 1; got != tc.wantFill {
+				t.Errorf("Expected fill=%v, got %v", tc.wantFill, got)
+			}
+		})
+	}
+}
+
+func TestPlanPriceTimePriority(t *testing.T) {
+	sells := []domain.EnergyOrder{order("s", "user_a", "sell", domain.TimeInForceGTC, 2, 4.0, 0)}
+	buys := []domain.EnergyOrder{
+		order("high", "user_b", "buy", domain.TimeInForceGTC, 1, 7.0, 3),
+		order("old", "user_c", "buy", domain.TimeInForceGTC, 1, 6.0, 1),
+		order("new", "user_d", "buy", domain.TimeInForceGTC, 1, 6.0, 2),
+	}
+
+	plan := planMatches(sells, buys, flatPrice(5.0))
+
+	if filledKwh(plan, "high") != 1 || filledKwh(plan, "old") != 1 || filledKwh(plan, "new") != 0 {
+		t.Errorf("Expected best price then oldest to fill, got %+v", filledByOrder(plan.Fills))
+	}
+}
+
+func TestPlanPartiallyFilledOrdersUseRemainingQuantity(t *testing.T) {
+	sell := order("s", "user_a", "sell", domain.TimeInForceGTC, 5, 4.0, 0)
+	sell.FilledKwh = 4
+	sell.Status = domain.OrderStatusPartiallyFilled
+	buys := []domain.EnergyOrder{order("b", "user_b", "buy", domain.TimeInForceGTC, 3, 6.0, 1)}
+
+	plan := planMatches([]domain.EnergyOrder{sell}, buys, flatPrice(5.0))
+
+	if len(plan.Fills) != 1 || plan.Fills[0].Kwh != 1 {
+		t.Errorf("Expected a 1 kWh fill from the remaining quantity, got %+v", plan.Fills)
+	}
+}
+
+func TestPlanSkipsSelfTradesAndUnpricedPairs(t *testing.T) {
+	sells := []domain.EnergyOrder{
+		order("own", "user_a", "sell", domain.TimeInForceGTC, 1, 4.0, 0),
+		order("unpriced", "user_c", "sell", domain.TimeInForceGTC, 1, 4.0, 1),
+	}
+	buys := []domain.EnergyOrder{order("b", "user_a", "buy", domain.TimeInForceGTC, 1, 6.0, 2)}
+	buys = append(buys, order("b2", "user_b", "buy", domain.TimeInForceGTC, 1, 6.0, 3))
+
+	priceFn := func(buy, sell domain.EnergyOrder) (float64, bool) {
+		return 5.0, sell.ID != "unpriced"
+	}
+	plan := planMatches(sells, buys, priceFn)
+
+	if len(plan.Fills) != 1 || plan.Fills[0].Buy.ID != "b2" || plan.Fills[0].Sell.ID != "own" {
+		t.Errorf("Expected only own->b2 to trade, got %+v", plan.Fills)
+	}
+}
+
+func TestSlippageLimit(t *testing.T) {
+	// Market buys cap the price above the best offer; market sells floor it below the best bid
+	if got := slippageLimit("buy", 5.0, 0.05); math.Abs(got-5.25) > 1e-9 {
+		t.Errorf("Expected buy ceiling 5.25, got %f", got)
+	}
+	if got := slippageLimit("sell", 5.0, 0.05); math.Abs(got-4.75) > 1e-9 {
+		t.Errorf("Expected sell floor 4.75, got %f", got)
+	}
+}
+
+func TestMarketBuyLimitUsesFillWeightedPrice(t *testing.T) {
+	// 2 kWh at 5 and 2 kWh at 6 average 5.5, so the cap is 5% above that, not above 5
+	book := pricing.BookQuote{
+		KwhRequested: 4,
+		KwhFillable:  4,
+		AveragePrice: 5.5,
+		Levels:       []pricing.QuoteLevel{{KwhFilled: 2, Price: 5.0}, {KwhFilled: 2, Price: 6.0}},
+	}
+	if got := marketBuyLimit(book, 0.05); math.Abs(got-5.775) > 1e-9 {
+		t.Errorf("Expected buy ceiling 5.775, got %f", got)
+	}
+}
+
+func TestPlanOnlyPairsOverlappingDeliveryWindows(t *testing.T) {
+	hour := func(h int) *time.Time {
+		t := testEpoch.Add(time.Duration(h) * time.Hour)
+		return &t
+	}
+
+	morning := order("morning", "user_a", "sell", domain.TimeInForceGTC, 1, 4.0, 0)
+	morning.DeliveryWindowStart, morning.DeliveryWindowEnd = hour(1), hour(3)
+	evening := order("evening", "user_c", "sell", domain.TimeInForceGTC, 1, 3.0, 1)
+	evening.DeliveryWindowStart, evening.DeliveryWindowEnd = hour(8), hour(10)
+
+	buy := order("b", "user_b", "buy", domain.TimeInForceGTC, 2, 6.0, 2)
+	buy.DeliveryWindowStart, buy.DeliveryWindowEnd = hour(2), hour(6)
+
+	plan := planMatches([]domain.EnergyOrder{evening, morning}, []domain.EnergyOrder{buy}, flatPrice(5.0))
+
+	if len(plan.Fills) != 1 || plan.Fills[0].Sell.ID != "morning" {
+		t.Fatalf("Expected only the overlapping morning sell to trade, got %+v", plan.Fills)
+	}
+	fill := plan.Fills[0]
+	if !fill.DeliveryStart.Equal(*hour(2)) || !fill.DeliveryEnd.Equal(*hour(3)) {
+		t.Errorf("Expected delivery in the overlap [+2h, +3h], got [%v, %v]", fill.DeliveryStart, fill.DeliveryEnd)
+	}
+}
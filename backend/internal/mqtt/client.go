@@ -12,6 +12,15 @@ import (
 
 var Client mqtt.Client
 
+// handlers maps topic filters registered with Handle to their handlers.
+var handlers = map[string]func(topic string, payload []byte){}
+
+// Handle registers a handler for messages on a topic filter. It must be
+// called before Connect; the filter is subscribed on every (re)connection.
+func Handle(topic string, handler func(topic string, payload []byte)) {
+	handlers[topic] = handler
+}
+
 // defaultMessageHandler is called for any message received that doesn't have a specific handler.
 var defaultMessageHandler mqtt.MessageHandler = func(client mqtt.Client, msg mqtt.Message) {
 	log.Printf("Received unhandled message on topic: %s\nMessage: %s\n", msg.Topic(), msg.Payload())
@@ -58,9 +67,7 @@ func subscribeToTopics(client mqtt.Client) {
 	// Topics from the prompt
 	topics := []string{
 		"energy/donor/+/status",
-		"energy/donor/+/lock/response",
 		"energy/recipient/+/status",
-		"energy/device/+/alert",
 		"energy/transfer/+/status",
 	}
 
@@ -71,6 +78,17 @@ func subscribeToTopics(client mqtt.Client) {
 			log.Printf("Subscribed to topic: %s", topic)
 		}
 	}
+
+	for topic, handler := range handlers {
+		callback := func(client mqtt.Client, msg mqtt.Message) {
+			handler(msg.Topic(), msg.Payload())
+		}
+		if token := client.Subscribe(topic, 1, callback); token.Wait() && token.Error() != nil {
+			log.Printf("Failed to subscribe to topic %s: %v", topic, token.Error())
+		} else {
+			log.Printf("Subscribed to topic: %s", topic)
+		}
+	}
 }
 
 // SendLockCommand sends an energy lock request to a donor's ESP32.
@@ -86,3 +104,27 @@ func SendLockCommand(deviceID string, orderID string, kwh float64) error {
 	token.Wait()
 	return token.Error()
 }
+
+// SendFirmwareCommand sends a signed firmware update command to a device.
+func SendFirmwareCommand(deviceID string, payload []byte) error {
+	if Client == nil || !Client.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
+	}
+
+	token := Client.Publish(fmt.Sprintf("energy/device/%s/ota", deviceID), 1, false, payload)
+	token.Wait()
+	return token.Error()
+}
+
+// SendDesiredConfig publishes a device's desired configuration as a retained
+// message, so the device receives it whenever it (re)connects. An empty
+// payload clears the retained message.
+func SendDesiredConfig(deviceID string, payload []byte) error {
+	if Client == nil || !Client.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
+	}
+
+	token := Client.Publish(fmt.Sprintf("energy/device/%s/config/desired", deviceID), 1, true, payload)
+	token.Wait()
+	return token.Error()
+}
@@ -0,0 +1,107 @@
+package ota
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/mqtt"
+)
+
+// campaignInterval is how often running campaigns are advanced.
+var campaignInterval = time.Duration(config.GetEnvAsInt("FIRMWARE_CAMPAIGN_INTERVAL_SECONDS", 30)) * time.Second
+
+// RunCampaigns starts a background process that drives every running
+// campaign: it times out silent updates, halts campaigns failing too often,
+// advances finished stages and sends updates to devices that are online.
+func RunCampaigns() {
+	log.Println("Starting firmware campaign runner...")
+	ticker := time.NewTicker(campaignInterval)
+
+	for range ticker.C {
+		if err := ProcessCampaigns(time.Now()); err != nil {
+			log.Printf("Error processing firmware campaigns: %v", err)
+		}
+	}
+}
+
+// ProcessCampaigns runs one round of every running campaign.
+func ProcessCampaigns(now time.Time) error {
+	var campaigns []domain.FirmwareCampaign
+	if err := database.DB.Where("status = ?", domain.CampaignRunning).Find(&campaigns).Error; err != nil {
+		return err
+	}
+	for _, campaign := range campaigns {
+		if err := processCampaign(campaign, now); err != nil && !errors.Is(err, database.ErrCampaignChanged) {
+			log.Printf("Error processing firmware campaign %s: %v", campaign.ID, err)
+		}
+	}
+	return nil
+}
+
+func processCampaign(campaign domain.FirmwareCampaign, now time.Time) error {
+	if err := database.ExpireUpdates(database.DB, campaign.ID, campaign.CurrentStage, now.Add(-updateTimeout), now); err != nil {
+		return err
+	}
+
+	counts, err := database.CampaignCounts(database.DB, campaign.ID)
+	if err != nil {
+		return err
+	}
+	decision, reason := decide(campaign, ProgressUpTo(counts, campaign.CurrentStage))
+	switch decision {
+	case decisionHalt:
+		log.Printf("Halting firmware campaign %s: %s", campaign.ID, reason)
+		return database.TransitionCampaign(database.DB, campaign.ID, []string{domain.CampaignRunning}, domain.CampaignHalted, map[string]interface{}{"halt_reason": reason})
+	case decisionComplete:
+		log.Printf("Firmware campaign %s completed", campaign.ID)
+		return database.TransitionCampaign(database.DB, campaign.ID, []string{domain.CampaignRunning}, domain.CampaignCompleted, map[string]interface{}{"finished_at": now})
+	case decisionAdvance:
+		if err := database.AdvanceCampaignStage(database.DB, campaign.ID, campaign.CurrentStage, now); err != nil {
+			return err
+		}
+		campaign.CurrentStage++
+		log.Printf("Firmware campaign %s advanced to stage %d of %d", campaign.ID, campaign.CurrentStage+1, len(campaign.Stages))
+	}
+
+	return dispatch(campaign, now)
+}
+
+// dispatch sends the campaign's updates that are due to devices that are
+// online and not busy with another update.
+func dispatch(campaign domain.FirmwareCampaign, now time.Time) error {
+	updates, err := database.DispatchableUpdates(database.DB, campaign.ID, campaign.CurrentStage)
+	if err != nil || len(updates) == 0 {
+		return err
+	}
+
+	var firmware domain.Firmware
+	if err := database.DB.First(&firmware, "id = ?", campaign.FirmwareID).Error; err != nil {
+		return err
+	}
+
+	for _, update := range updates {
+		payload, err := BuildCommand(update, firmware, now)
+		if err != nil {
+			return err
+		}
+		sent, err := database.MarkUpdateSent(database.DB, &update, now)
+		if err != nil {
+			log.Printf("Error claiming firmware update %s: %v", update.ID, err)
+			continue
+		}
+		if !sent {
+			continue
+		}
+		if err := mqtt.SendFirmwareCommand(update.DeviceID, payload); err != nil {
+			log.Printf("Failed to send firmware update %s to device %s: %v", update.ID, update.DeviceID, err)
+			if err := database.UnsendUpdate(database.DB, update.ID); err != nil {
+				log.Printf("Error returning firmware update %s to pending: %v", update.ID, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package ota
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+// Manifest tells a device which firmware to install. It names the device and
+// expires, so a captured command cannot be replayed to another device or later.
+type Manifest struct {
+	UpdateID          string    `json:"update_id"`
+	CampaignID        string    `json:"campaign_id"`
+	DeviceID          string    `json:"device_id"`
+	Version           string    `json:"version"`
+	URL               string    `json:"url"`
+	SHA256            string    `json:"sha256"`
+	Size              int64     `json:"size"`
+	FirmwareSignature string    `json:"firmware_signature"` // The release key's signature of the binary's digest
+	IssuedAt          time.Time `json:"issued_at"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// Command is the payload published on energy/device/{id}/ota. Manifest holds
+// the exact JSON bytes that were signed, so the device can verify them
+// without re-encoding.
+type Command struct {
+	Manifest  string `json:"manifest"`
+	Signature string `json:"signature"` // Base64 Ed25519 signature of Manifest
+}
+
+// BuildCommand signs the manifest for an update and returns the command payload.
+func BuildCommand(update domain.FirmwareUpdate, firmware domain.Firmware, now time.Time) ([]byte, error) {
+	if signingKey == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	manifest, err := json.Marshal(Manifest{
+		UpdateID:          update.ID,
+		CampaignID:        update.CampaignID,
+		DeviceID:          update.DeviceID,
+		Version:           firmware.Version,
+		URL:               firmware.URL,
+		SHA256:            firmware.SHA256,
+		Size:              firmware.Size,
+		FirmwareSignature: firmware.Signature,
+		IssuedAt:          now.UTC(),
+		ExpiresAt:         now.Add(updateTimeout).UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(Command{
+		Manifest:  string(manifest),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(signingKey, manifest)),
+	})
+}
@@ -0,0 +1,166 @@
+package ota
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func TestValidateStages(t *testing.T) {
+	for _, ok := range []domain.RolloutStages{{100}, {5, 25, 100}, {1, 2, 3, 100}} {
+		if err := ValidateStages(ok); err != nil {
+			t.Errorf("Expected %v to be valid, got %v", ok, err)
+		}
+	}
+	for _, bad := range []domain.RolloutStages{{}, {50}, {25, 25, 100}, {50, 10, 100}, {0, 100}, {5, 150}} {
+		if err := ValidateStages(bad); err == nil {
+			t.Errorf("Expected %v to be rejected", bad)
+		}
+	}
+}
+
+func TestAssignStages(t *testing.T) {
+	ids := make([]string, 40)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("device-%02d", i)
+	}
+
+	assigned := AssignStages("campaign-1", ids, domain.RolloutStages{5, 25, 100})
+	perStage := map[int]int{}
+	for _, stage := range assigned {
+		perStage[stage]++
+	}
+	if len(assigned) != 40 || perStage[0] != 2 || perStage[1] != 8 || perStage[2] != 30 {
+		t.Errorf("Expected 2, 8 and 30 devices per stage, got %v", perStage)
+	}
+
+	again := AssignStages("campaign-1", ids, domain.RolloutStages{5, 25, 100})
+	for id, stage := range assigned {
+		if again[id] != stage {
+			t.Fatalf("Expected the same assignment for the same campaign, %s moved from %d to %d", id, stage, again[id])
+		}
+	}
+
+	// A single device still goes in the first stage
+	if one := AssignStages("campaign-1", []string{"only"}, domain.RolloutStages{5, 100}); one["only"] != 0 {
+		t.Errorf("Expected a lone device in the canary stage, got stage %d", one["only"])
+	}
+}
+
+func TestDecide(t *testing.T) {
+	campaign := domain.FirmwareCampaign{Stages: domain.RolloutStages{10, 100}, MaxFailureRate: 0.2}
+
+	cases := []struct {
+		name     string
+		stage    int
+		progress Progress
+		want     string
+	}{
+		{"in progress", 0, Progress{Waiting: 3, Succeeded: 1}, decisionWait},
+		{"too few finished to judge mid-stage", 0, Progress{Waiting: 5, Failed: 1}, decisionWait},
+		{"failing mid-stage", 0, Progress{Waiting: 5, Succeeded: 1, Failed: 2}, decisionHalt},
+		{"finished stage failing", 0, Progress{Succeeded: 1, Failed: 1}, decisionHalt},
+		{"finished stage healthy", 0, Progress{Succeeded: 9, Failed: 1}, decisionAdvance},
+		{"empty stage", 0, Progress{}, decisionAdvance},
+		{"last stage done", 1, Progress{Succeeded: 10}, decisionComplete},
+	}
+	for _, tc := range cases {
+		campaign.CurrentStage = tc.stage
+		if got, reason := decide(campaign, tc.progress); got != tc.want {
+			t.Errorf("%s: expected %s, got %s (%s)", tc.name, tc.want, got, reason)
+		}
+	}
+}
+
+func TestProgressUpTo(t *testing.T) {
+	counts := map[int]map[string]int{
+		0: {domain.UpdateSucceeded: 3, domain.UpdateTimedOut: 1, domain.UpdateSkipped: 2},
+		1: {domain.UpdateFailed: 1, domain.UpdateDownloading: 1, domain.UpdatePending: 4},
+		2: {domain.UpdatePending: 10},
+	}
+	p := ProgressUpTo(counts, 1)
+	if p != (Progress{Waiting: 5, Succeeded: 3, Failed: 2}) {
+		t.Errorf("Unexpected progress %+v", p)
+	}
+	if p.FailureRate() != 0.4 {
+		t.Errorf("Expected a failure rate of 0.4, got %g", p.FailureRate())
+	}
+}
+
+func TestBuildCommandIsVerifiable(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	signingKey = private
+	t.Cleanup(func() { signingKey = nil })
+
+	now := time.Now()
+	update := domain.FirmwareUpdate{ID: "update-1", CampaignID: "campaign-1", DeviceID: "device-1"}
+	firmware := domain.Firmware{Version: "1.2.0", URL: "https://example.com/fw.bin", SHA256: "ab", Size: 1024, Signature: "sig"}
+	payload, err := BuildCommand(update, firmware, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cmd Command
+	if err := json.Unmarshal(payload, &cmd); err != nil {
+		t.Fatal(err)
+	}
+	sig, _ := base64.StdEncoding.DecodeString(cmd.Signature)
+	if !ed25519.Verify(public, []byte(cmd.Manifest), sig) {
+		t.Fatal("Expected the manifest signature to verify")
+	}
+	var manifest Manifest
+	if err := json.Unmarshal([]byte(cmd.Manifest), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.DeviceID != "device-1" || manifest.Version != "1.2.0" || !manifest.ExpiresAt.After(now) {
+		t.Errorf("Unexpected manifest %+v", manifest)
+	}
+}
+
+func TestVerifyRelease(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+	public, private, _ := ed25519.GenerateKey(nil)
+	digest := sha256.Sum256([]byte("firmware image"))
+	sha := hex.EncodeToString(digest[:])
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(private, digest[:]))
+
+	releaseKey = nil
+	if err := VerifyRelease(sha, signature); err != ErrNoReleaseKey {
+		t.Errorf("Expected ErrNoReleaseKey in production without a key, got %v", err)
+	}
+
+	releaseKey = public
+	t.Cleanup(func() { releaseKey = nil })
+	if err := VerifyRelease(sha, signature); err != nil {
+		t.Errorf("Expected the release signature to verify, got %v", err)
+	}
+	other := sha256.Sum256([]byte("tampered image"))
+	if err := VerifyRelease(hex.EncodeToString(other[:]), signature); err == nil {
+		t.Error("Expected a signature over another digest to be rejected")
+	}
+}
+
+func TestParseStatusReport(t *testing.T) {
+	deviceID, report, err := parseStatusReport("energy/device/dev-1/ota/status", []byte(`{"update_id":"u1","status":"downloading","progress":40}`))
+	if err != nil || deviceID != "dev-1" || report.UpdateID != "u1" || report.Progress != 40 {
+		t.Fatalf("Unexpected report from %s: %+v (%v)", deviceID, report, err)
+	}
+
+	for _, tc := range []struct{ topic, payload string }{
+		{"energy/device/dev-1/status", `{"update_id":"u1","status":"downloading"}`},
+		{"energy/device/dev-1/ota/status", `{"status":"downloading"}`},
+		{"energy/device/dev-1/ota/status", `{"update_id":"u1","status":"sent"}`},
+		{"energy/device/dev-1/ota/status", `{"update_id":"u1","status":"installing","progress":140}`},
+	} {
+		if _, _, err := parseStatusReport(tc.topic, []byte(tc.payload)); err == nil {
+			t.Errorf("Expected %s on %s to be rejected", tc.payload, tc.topic)
+		}
+	}
+}
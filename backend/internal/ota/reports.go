@@ -0,0 +1,73 @@
+package ota
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/mqtt"
+)
+
+// StatusTopic carries devices' progress reports on firmware updates.
+const StatusTopic = "energy/device/+/ota/status"
+
+// statusReport is the JSON body of a progress report.
+type statusReport struct {
+	UpdateID string `json:"update_id"`
+	Status   string `json:"status"`   // downloading, installing, succeeded or failed
+	Progress int    `json:"progress"` // Percent
+	Error    string `json:"error"`
+	Version  string `json:"version"` // Running version, on success
+}
+
+// Subscribe registers the progress report handler with the MQTT client. It
+// must be called before mqtt.Connect.
+func Subscribe() {
+	mqtt.Handle(StatusTopic, HandleStatusReport)
+}
+
+// HandleStatusReport records a device's progress on a firmware update.
+func HandleStatusReport(topic string, payload []byte) {
+	deviceID, report, err := parseStatusReport(topic, payload)
+	if err != nil {
+		log.Printf("Dropping firmware status report on %s: %v", topic, err)
+		return
+	}
+
+	update, err := database.RecordUpdateReport(database.DB, deviceID, report.UpdateID, report.Status, report.Progress, report.Error, report.Version, time.Now())
+	if err != nil {
+		log.Printf("Error recording firmware status from device %s: %v", deviceID, err)
+		return
+	}
+	if update == nil {
+		log.Printf("Firmware status from device %s for update %s matches no update in progress", deviceID, report.UpdateID)
+	}
+}
+
+func parseStatusReport(topic string, payload []byte) (string, statusReport, error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 5 || parts[2] == "" || parts[3] != "ota" || parts[4] != "status" {
+		return "", statusReport{}, fmt.Errorf("not a firmware status topic")
+	}
+
+	var report statusReport
+	if err := json.Unmarshal(payload, &report); err != nil {
+		return "", statusReport{}, fmt.Errorf("invalid firmware status: %w", err)
+	}
+	if report.UpdateID == "" {
+		return "", statusReport{}, fmt.Errorf("firmware status has no update_id")
+	}
+	switch report.Status {
+	case domain.UpdateDownloading, domain.UpdateInstalling, domain.UpdateSucceeded, domain.UpdateFailed:
+	default:
+		return "", statusReport{}, fmt.Errorf("unknown firmware status %q", report.Status)
+	}
+	if report.Progress < 0 || report.Progress > 100 {
+		return "", statusReport{}, fmt.Errorf("progress %d is not a percentage", report.Progress)
+	}
+	return parts[2], report, nil
+}
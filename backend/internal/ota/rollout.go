@@ -0,0 +1,131 @@
+package ota
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+var (
+	// updateTimeout is how long a device may go without reporting on an update,
+	// and how long a pending update waits for its device to come online.
+	updateTimeout = time.Duration(config.GetEnvAsInt("FIRMWARE_UPDATE_TIMEOUT_MINUTES", 30)) * time.Minute
+	// DefaultMaxFailureRate applies to campaigns created without one.
+	DefaultMaxFailureRate = config.GetEnvAsFloat("FIRMWARE_MAX_FAILURE_RATE", 0.2)
+	// haltMinFinished is how many updates must have finished before a stage
+	// still in progress can be halted; a finished stage is judged on any number.
+	haltMinFinished = config.GetEnvAsInt("FIRMWARE_HALT_MIN_FINISHED", 3)
+)
+
+// DefaultStages is the rollout of campaigns created without stages: a 5%
+// canary, a quarter of the fleet, then everyone.
+var DefaultStages = domain.RolloutStages{5, 25, 100}
+
+// ValidateStages checks that stages are increasing percentages ending at 100.
+func ValidateStages(stages domain.RolloutStages) error {
+	if len(stages) == 0 || len(stages) > 10 {
+		return fmt.Errorf("stages must list 1 to 10 percentages")
+	}
+	previous := 0
+	for _, pct := range stages {
+		if pct <= previous || pct > 100 {
+			return fmt.Errorf("stages must be increasing percentages between 1 and 100")
+		}
+		previous = pct
+	}
+	if previous != 100 {
+		return fmt.Errorf("the last stage must be 100")
+	}
+	return nil
+}
+
+// AssignStages spreads devices over the stages. Stage i takes the devices
+// that bring the total to stages[i] percent, rounded up. Devices are ordered
+// by a hash of the campaign and device IDs, so each campaign canaries on a
+// different, but reproducible, set of devices.
+func AssignStages(campaignID string, deviceIDs []string, stages domain.RolloutStages) map[string]int {
+	ordered := append([]string(nil), deviceIDs...)
+	key := func(id string) string {
+		sum := sha256.Sum256([]byte(campaignID + "/" + id))
+		return string(sum[:])
+	}
+	sort.Slice(ordered, func(i, j int) bool { return key(ordered[i]) < key(ordered[j]) })
+
+	assigned := make(map[string]int, len(ordered))
+	next := 0
+	for stage, pct := range stages {
+		end := int(math.Ceil(float64(len(ordered)) * float64(pct) / 100))
+		for ; next < end; next++ {
+			assigned[ordered[next]] = stage
+		}
+	}
+	return assigned
+}
+
+// Progress summarises a campaign's updates up to its current stage.
+type Progress struct {
+	Waiting   int // Pending or in progress
+	Succeeded int
+	Failed    int // Failed or timed out
+}
+
+// FailureRate is the share of finished updates that failed.
+func (p Progress) FailureRate() float64 {
+	if p.Succeeded+p.Failed == 0 {
+		return 0
+	}
+	return float64(p.Failed) / float64(p.Succeeded+p.Failed)
+}
+
+// ProgressUpTo summarises the updates of stages up to and including stage.
+// Skipped and cancelled updates are left out: they never reached the device.
+func ProgressUpTo(counts map[int]map[string]int, stage int) Progress {
+	var p Progress
+	for s, byStatus := range counts {
+		if s > stage {
+			continue
+		}
+		for status, n := range byStatus {
+			switch status {
+			case domain.UpdateSucceeded:
+				p.Succeeded += n
+			case domain.UpdateFailed, domain.UpdateTimedOut:
+				p.Failed += n
+			case domain.UpdateSkipped, domain.UpdateCancelled:
+			default:
+				p.Waiting += n
+			}
+		}
+	}
+	return p
+}
+
+// Campaign runner decisions.
+const (
+	decisionWait     = "wait"
+	decisionHalt     = "halt"
+	decisionAdvance  = "advance"
+	decisionComplete = "complete"
+)
+
+// decide what a running campaign should do next given its progress.
+func decide(campaign domain.FirmwareCampaign, p Progress) (string, string) {
+	finished := p.Succeeded + p.Failed
+	stageDone := p.Waiting == 0
+	if finished > 0 && (stageDone || finished >= haltMinFinished) && p.FailureRate() > campaign.MaxFailureRate {
+		return decisionHalt, fmt.Sprintf("%d of %d finished updates failed (%.0f%%, limit %.0f%%) by stage %d",
+			p.Failed, finished, p.FailureRate()*100, campaign.MaxFailureRate*100, campaign.CurrentStage+1)
+	}
+	if !stageDone {
+		return decisionWait, ""
+	}
+	if campaign.CurrentStage >= len(campaign.Stages)-1 {
+		return decisionComplete, ""
+	}
+	return decisionAdvance, ""
+}
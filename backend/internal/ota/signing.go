@@ -0,0 +1,107 @@
+package ota
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	"los-tecnicos/backend/internal/config"
+)
+
+// Errors returned when a key needed for OTA is not configured.
+var (
+	ErrNoSigningKey = errors.New("FIRMWARE_SIGNING_KEY is not configured")
+	ErrNoReleaseKey = errors.New("FIRMWARE_RELEASE_PUBLIC_KEY is not configured")
+)
+
+// signingKey signs update manifests; devices verify them with its public half,
+// which is built into the firmware. releaseKey is the public half of the key
+// release builds are signed with.
+var (
+	signingKey ed25519.PrivateKey
+	releaseKey ed25519.PublicKey
+)
+
+// Init loads FIRMWARE_SIGNING_KEY (a base64 Ed25519 seed) and
+// FIRMWARE_RELEASE_PUBLIC_KEY (a base64 Ed25519 public key). Without them,
+// campaigns cannot start and firmware cannot be registered, except in
+// development where a throwaway signing key is generated and release
+// signatures are not checked.
+func Init() error {
+	seed, err := decodeKey("FIRMWARE_SIGNING_KEY", ed25519.SeedSize)
+	if err != nil {
+		return err
+	}
+	switch {
+	case seed != nil:
+		signingKey = ed25519.NewKeyFromSeed(seed)
+	case config.IsDevelopment():
+		_, signingKey, err = ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		log.Printf("Warning: FIRMWARE_SIGNING_KEY is not set; signing update manifests with a throwaway key (public key %s)", PublicKey())
+	default:
+		log.Printf("Warning: %v; firmware campaigns cannot start", ErrNoSigningKey)
+	}
+
+	public, err := decodeKey("FIRMWARE_RELEASE_PUBLIC_KEY", ed25519.PublicKeySize)
+	if err != nil {
+		return err
+	}
+	releaseKey = public
+	return nil
+}
+
+func decodeKey(name string, size int) ([]byte, error) {
+	raw := config.GetEnv(name, "")
+	if raw == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil || len(key) != size {
+		return nil, fmt.Errorf("%s must be %d bytes, base64 encoded", name, size)
+	}
+	return key, nil
+}
+
+// PublicKey returns the base64 public key update manifests are verified with,
+// or "" if there is no signing key.
+func PublicKey() string {
+	if signingKey == nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(signingKey.Public().(ed25519.PublicKey))
+}
+
+// CanSign reports whether update manifests can be signed.
+func CanSign() bool {
+	return signingKey != nil
+}
+
+// VerifyRelease checks a release's signature of its SHA-256 digest (hex)
+// against FIRMWARE_RELEASE_PUBLIC_KEY.
+func VerifyRelease(sha256Hex, signature string) error {
+	digest, err := hex.DecodeString(sha256Hex)
+	if err != nil || len(digest) != 32 {
+		return fmt.Errorf("sha256 must be 64 hex characters")
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("signature must be a base64 Ed25519 signature")
+	}
+	if releaseKey == nil {
+		if config.IsDevelopment() {
+			return nil
+		}
+		return ErrNoReleaseKey
+	}
+	if !ed25519.Verify(releaseKey, digest, sig) {
+		return fmt.Errorf("signature does not match the release key")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package pricing
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+)
+
+// BreakerConfig controls volatility damping and trading halts.
+type BreakerConfig struct {
+	MaxChangePct   float64       // Max reference price move per interval (0.10 = 10%, 0 disables)
+	SmoothingAlpha float64       // EWMA weight of the newest price (1 disables smoothing)
+	BoundHitLimit  int           // Consecutive multiplier-bound hits that trigger a halt (0 disables)
+	HaltDuration   time.Duration // How long trading stays halted
+}
+
+// BreakerState is the externally visible state of the circuit breaker.
+type BreakerState struct {
+	Halted            bool    `json:"halted"`
+	HaltedUntil       string  `json:"halted_until,omitempty"`
+	Reason            string  `json:"reason,omitempty"`
+	ConsecutiveBounds int     `json:"consecutive_bound_hits"`
+	RawPrice          float64 `json:"raw_price"`
+	DampedPrice       float64 `json:"damped_price"`
+}
+
+// DampingRatio is the factor that maps a raw dynamic price to its damped value.
+func (s BreakerState) DampingRatio() float64 {
+	if s.RawPrice <= 0 || s.DampedPrice <= 0 {
+		return 1.0
+	}
+	return s.DampedPrice / s.RawPrice
+}
+
+// CircuitBreaker smooths the market reference price and halts trading when the
+// pricing multiplier is pinned at its bounds for several intervals in a row.
+type CircuitBreaker struct {
+	mu          sync.Mutex
+	cfg         BreakerConfig
+	ewma        float64
+	lastDamped  float64
+	lastRaw     float64
+	boundHits   int
+	haltedUntil time.Time
+	reason      string
+}
+
+// Breaker is the process-wide breaker fed by the matching engine.
+var Breaker = NewCircuitBreaker(LoadBreakerConfig())
+
+// LoadBreakerConfig reads the breaker settings from the environment.
+func LoadBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		MaxChangePct:   config.GetEnvAsFloat("PRICE_MAX_CHANGE_PCT", 0.10),
+		SmoothingAlpha: config.GetEnvAsFloat("PRICE_EWMA_ALPHA", 0.3),
+		BoundHitLimit:  config.GetEnvAsInt("PRICE_BOUND_HIT_LIMIT", 3),
+		HaltDuration:   time.Duration(config.GetEnvAsInt("PRICE_HALT_SECONDS", 60)) * time.Second,
+	}
+}
+
+// NewCircuitBreaker creates a breaker with the given configuration.
+func NewCircuitBreaker(cfg BreakerConfig) *CircuitBreaker {
+	if cfg.SmoothingAlpha <= 0 || cfg.SmoothingAlpha > 1 {
+		cfg.SmoothingAlpha = 1
+	}
+	return &CircuitBreaker{cfg: cfg}
+}
+
+// Observe feeds one interval's raw reference price into the breaker. boundHit
+// reports whether the pricing multiplier was clamped at its min or max. The
+// returned state carries the damped price, or Halted if trading is suspended.
+func (b *CircuitBreaker) Observe(raw float64, boundHit bool, now time.Time) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastRaw = raw
+	if now.Before(b.haltedUntil) {
+		return b.stateLocked(now)
+	}
+
+	if boundHit {
+		b.boundHits++
+	} else {
+		b.boundHits = 0
+	}
+	if b.cfg.BoundHitLimit > 0 && b.boundHits >= b.cfg.BoundHitLimit {
+		b.haltedUntil = now.Add(b.cfg.HaltDuration)
+		b.reason = "Price multiplier pinned at its bounds"
+		b.boundHits = 0
+		return b.stateLocked(now)
+	}
+
+	if b.lastDamped == 0 {
+		b.ewma = raw
+		b.lastDamped = raw
+		return b.stateLocked(now)
+	}
+
+	b.ewma = b.cfg.SmoothingAlpha*raw + (1-b.cfg.SmoothingAlpha)*b.ewma
+
+	damped := b.ewma
+	if b.cfg.MaxChangePct > 0 {
+		lower := b.lastDamped * (1 - b.cfg.MaxChangePct)
+		upper := b.lastDamped * (1 + b.cfg.MaxChangePct)
+		damped = math.Max(lower, math.Min(upper, damped))
+	}
+	b.lastDamped = damped
+
+	return b.stateLocked(now)
+}
+
+// State returns the current breaker state without feeding a new price.
+func (b *CircuitBreaker) State(now time.Time) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stateLocked(now)
+}
+
+func (b *CircuitBreaker) stateLocked(now time.Time) BreakerState {
+	state := BreakerState{
+		ConsecutiveBounds: b.boundHits,
+		RawPrice:          b.lastRaw,
+		DampedPrice:       b.lastDamped,
+	}
+	if now.Before(b.haltedUntil) {
+		state.Halted = true
+		state.HaltedUntil = b.haltedUntil.UTC().Format(time.RFC3339)
+		state.Reason = b.reason
+	}
+	return state
+}
@@ -0,0 +1,64 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBreakerCapsPerIntervalChange(t *testing.T) {
+	b := NewCircuitBreaker(BreakerConfig{MaxChangePct: 0.10, SmoothingAlpha: 1})
+	now := time.Now()
+
+	b.Observe(5.0, false, now)
+	state := b.Observe(10.0, false, now.Add(5*time.Second))
+
+	if math.Abs(state.DampedPrice-5.5) > 1e-9 {
+		t.Errorf("Expected jump to be capped at 5.5, got %f", state.DampedPrice)
+	}
+	if math.Abs(state.DampingRatio()-0.55) > 1e-9 {
+		t.Errorf("Expected damping ratio 0.55, got %f", state.DampingRatio())
+	}
+}
+
+func TestBreakerSmoothsWithEWMA(t *testing.T) {
+	b := NewCircuitBreaker(BreakerConfig{SmoothingAlpha: 0.5})
+	now := time.Now()
+
+	b.Observe(4.0, false, now)
+	state := b.Observe(8.0, false, now)
+	if state.DampedPrice != 6.0 {
+		t.Errorf("Expected EWMA of 6.0, got %f", state.DampedPrice)
+	}
+	state = b.Observe(8.0, false, now)
+	if state.DampedPrice != 7.0 {
+		t.Errorf("Expected EWMA of 7.0, got %f", state.DampedPrice)
+	}
+}
+
+func TestBreakerHaltsOnRepeatedBoundHits(t *testing.T) {
+	b := NewCircuitBreaker(BreakerConfig{SmoothingAlpha: 1, BoundHitLimit: 3, HaltDuration: time.Minute})
+	now := time.Now()
+
+	b.Observe(25.0, true, now)
+	b.Observe(5.0, false, now) // Streak broken
+	b.Observe(25.0, true, now)
+	if state := b.Observe(25.0, true, now); state.Halted {
+		t.Fatal("Halted before reaching the bound hit limit")
+	}
+
+	state := b.Observe(25.0, true, now)
+	if !state.Halted || state.Reason == "" {
+		t.Fatalf("Expected halt after 3 consecutive bound hits, got %+v", state)
+	}
+
+	// Still halted inside the window, regardless of input
+	if state := b.Observe(5.0, false, now.Add(30*time.Second)); !state.Halted {
+		t.Error("Expected breaker to remain halted")
+	}
+
+	// Resumes after the halt duration
+	if state := b.State(now.Add(61 * time.Second)); state.Halted {
+		t.Error("Expected breaker to resume after halt duration")
+	}
+}
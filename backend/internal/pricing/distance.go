@@ -0,0 +1,53 @@
+package pricing
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultDistanceKm is the trade distance the dynamic price assumes, which
+// treats every trade as being between direct neighbours.
+const DefaultDistanceKm = 1.0
+
+const earthRadiusKm = 6371.0
+
+// DistanceKm computes the great-circle (haversine) distance between two
+// "lat,lng" strings. ok is false if either location cannot be parsed.
+func DistanceKm(a, b string) (float64, bool) {
+	lat1, lng1, ok := parseLatLng(a)
+	if !ok {
+		return 0, false
+	}
+	lat2, lng2, ok := parseLatLng(b)
+	if !ok {
+		return 0, false
+	}
+
+	dLat := toRadians(lat2 - lat1)
+	dLng := toRadians(lng2 - lng1)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h)), true
+}
+
+func parseLatLng(location string) (float64, float64, bool) {
+	parts := strings.Split(location, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+	lng, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return 0, 0, false
+	}
+	return lat, lng, true
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -4,8 +4,10 @@ import (
 	"math"
 	"time"
 
+	"los-tecnicos/backend/internal/config"
 	"los-tecnicos/backend/internal/core/domain"
 	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/forecast"
 )
 
 // PricingEngine handles the calculation of the real-time energy price.
@@ -15,12 +17,22 @@ type PricingEngine struct {
 
 // PricingConfig holds the dynamic factors that can be changed via Governance.
 type PricingConfig struct {
-	BasePrice float64 // Default 5.0 XLM
-	Alpha     float64 // Supply/Demand Coefficient (default 0.2)
-	Beta      float64 // SoC Scarcity Coefficient (default 0.5)
-	Gamma     float64 // Distance Penalty Coefficient (default 0.2)
+	BasePrice float64 `json:"base_price"` // Default 5.0 XLM
+	Alpha     float64 `json:"alpha"`      // Supply/Demand Coefficient (default 0.2)
+	Beta      float64 `json:"beta"`       // SoC Scarcity Coefficient (default 0.5)
+	Gamma     float64 `json:"gamma"`      // Distance Penalty Coefficient (default 0.2)
+	Delta     float64 `json:"delta"`      // Forecast Scarcity Coefficient (default 0, disabled)
 }
 
+// Bounds applied to the combined multiplier of all factors.
+const (
+	MinMultiplier = 0.5
+	MaxMultiplier = 5.0
+)
+
+// ScarcityHorizon is how far ahead the forecast factor looks for a SoC dip.
+const ScarcityHorizon = 6 * time.Hour
+
 // NewPricingEngine creates a new instance of the pricing engine.
 func NewPricingEngine() *PricingEngine {
 	// 1. Fetch "Live" params from the Governance Simulation
@@ -40,6 +52,7 @@ func FetchGovernanceParams() PricingConfig {
 		Alpha:     0.2,
 		Beta:      0.5,
 		Gamma:     0.2, // Default penalty
+		Delta:     config.GetEnvAsFloat("PRICING_FORECAST_DELTA", 0.0),
 	}
 
 	// CHECK: Is there an active "Lower Distance Penalty" proposal that passed?
@@ -49,47 +62,91 @@ func FetchGovernanceParams() PricingConfig {
 	return config
 }
 
-// CalculateDynamicPrice determines the final price per kWh based on 6 factors.
+// CalculateDynamicPrice determines the final price per kWh based on 6 factors,
+// plus the forecast scarcity factor when Delta is enabled.
 func (pe *PricingEngine) CalculateDynamicPrice(
 	buyOrder domain.EnergyOrder,
 	sellOrder domain.EnergyOrder,
 	supplyVol, demandVol, socAvg, distance float64,
 ) (float64, map[string]float64, error) {
 
+	now := time.Now()
+	forecastSoC, forecasted := pe.forecast(now, socAvg)
+	finalPrice, breakdown := pe.priceAt(now, supplyVol, demandVol, socAvg, forecastSoC, distance, pe.getQualityFactor(sellOrder))
+
+	// Log history (async)
+	go pe.logHistory(breakdown, socAvg, supplyVol, demandVol, distance, forecasted)
+
+	return finalPrice, breakdown, nil
+}
+
+// EstimateDynamicPrice computes the same price as CalculateDynamicPrice without
+// recording it in PricingHistory. Quotes use it so that browsing the book does
+// not pollute the history of executed price calculations.
+func (pe *PricingEngine) EstimateDynamicPrice(
+	sellOrder domain.EnergyOrder,
+	supplyVol, demandVol, socAvg, distance float64,
+) (float64, map[string]float64) {
+	now := time.Now()
+	return pe.priceAt(now, supplyVol, demandVol, socAvg, pe.forecastSoC(now, socAvg), distance, pe.getQualityFactor(sellOrder))
+}
+
+// ReferencePrice prices a neutral trade (neighbouring seller, average quality)
+// for the current market state. It is the signal fed to the circuit breaker.
+func (pe *PricingEngine) ReferencePrice(supplyVol, demandVol, socAvg float64) (float64, map[string]float64) {
+	return pe.ReferencePriceAt(time.Now(), supplyVol, demandVol, socAvg)
+}
+
+// ReferencePriceAt prices a neutral trade delivered at t, using the time-of-day
+// factor and forecast scarcity for that instant. Day-ahead auctions use it to
+// price each delivery hour.
+func (pe *PricingEngine) ReferencePriceAt(t time.Time, supplyVol, demandVol, socAvg float64) (float64, map[string]float64) {
+	return pe.priceAt(t, supplyVol, demandVol, socAvg, pe.forecastSoC(t, socAvg), DefaultDistanceKm, 1.0)
+}
+
+// priceAt combines the factors for a given instant and seller quality factor.
+func (pe *PricingEngine) priceAt(t time.Time, supplyVol, demandVol, socAvg, forecastSoC, distance, fQuality float64) (float64, map[string]float64) {
 	// Factors
 	fSD := pe.getSupplyDemandFactor(supplyVol, demandVol)
 	fSoC := pe.getSoCFactor(socAvg)
 	fDist := pe.getDistanceFactor(distance)
-	fTime := pe.getTimeOfDayFactor(time.Now())
-	fQuality := pe.getQualityFactor(sellOrder.UserID)
+	fTime := pe.getTimeOfDayFactor(t)
+	fForecast := pe.getForecastFactor(socAvg, forecastSoC)
 
 	// Total Multiplier
-	totalMultiplier := fSD * fSoC * fDist * fTime * fQuality
+	totalMultiplier := fSD * fSoC * fDist * fTime * fQuality * fForecast
 
 	// Bounds Checking (0.5x to 5.0x)
-	if totalMultiplier < 0.5 {
-		totalMultiplier = 0.5
+	if totalMultiplier < MinMultiplier {
+		totalMultiplier = MinMultiplier
 	}
-	if totalMultiplier > 5.0 {
-		totalMultiplier = 5.0
+	if totalMultiplier > MaxMultiplier {
+		totalMultiplier = MaxMultiplier
 	}
 
 	finalPrice := pe.Config.BasePrice * totalMultiplier
 
 	breakdown := map[string]float64{
-		"base_price":  pe.Config.BasePrice,
-		"f_sd":        fSD,
-		"f_soc":       fSoC,
-		"f_dist":      fDist,
-		"f_time":      fTime,
-		"f_quality":   fQuality,
-		"final_price": finalPrice,
+		"base_price":   pe.Config.BasePrice,
+		"f_sd":         fSD,
+		"f_soc":        fSoC,
+		"f_dist":       fDist,
+		"f_time":       fTime,
+		"f_quality":    fQuality,
+		"f_forecast":   fForecast,
+		"forecast_soc": forecastSoC,
+		"multiplier":   totalMultiplier,
+		"final_price":  finalPrice,
 	}
 
-	// Log history (async)
-	go pe.logHistory(breakdown, socAvg, supplyVol, demandVol)
+	return finalPrice, breakdown
+}
 
-	return finalPrice, breakdown, nil
+// MultiplierAtBounds reports whether a price breakdown was clamped at the
+// minimum or maximum multiplier.
+func MultiplierAtBounds(breakdown map[string]float64) bool {
+	m := breakdown["multiplier"]
+	return m <= MinMultiplier || m >= MaxMultiplier
 }
 
 // 1. Supply-Demand Factor: F_sd = 1 + α * ln(Demand / Supply)
@@ -140,39 +197,63 @@ func (pe *PricingEngine) getTimeOfDayFactor(t time.Time) float64 {
 	return 1.0
 }
 
-// 5. Quality Factor: F_quality = 1 + η * Q_score (using simple mock or DB lookup)
-func (pe *PricingEngine) getQualityFactor(userID string) float64 {
-	// In a real system, we'd query DeviceQualityMetrics
-	// For now, let's look up the user/device or default to 1.0
-	// We will implement a quick DB lookup
-
-	var device domain.IoTDevice
-	if err := database.DB.Where("owner_id = ?", userID).First(&device).Error; err != nil {
-		return 1.0 // Default if no device found
+// 5. Quality Factor: F_quality = 1 + η * Q_score, where Q_score is the
+// composite quality score (0-1) of the device delivering the sell order, as
+// computed by the quality package. Unscored devices are neutral.
+func (pe *PricingEngine) getQualityFactor(sellOrder domain.EnergyOrder) float64 {
+	// Orders from before devices were tracked use any live ESP32 of the seller
+	deviceID := sellOrder.DeviceID
+	if deviceID == "" {
+		var device domain.IoTDevice
+		if err := database.DB.Where("owner_id = ? AND device_type = ? AND status <> ?", sellOrder.UserID, "esp32", domain.DeviceStatusDecommissioned).First(&device).Error; err != nil {
+			return 1.0 // Default if no device found
+		}
+		deviceID = device.ID
 	}
 
 	var metrics domain.DeviceQualityMetrics
-	if err := database.DB.Where("device_id = ?", device.ID).First(&metrics).Error; err != nil {
+	if err := database.DB.Where("device_id = ?", deviceID).First(&metrics).Error; err != nil {
 		return 1.0 // Default if no metrics
 	}
+	return qualityFactor(metrics.Score)
+}
 
-	// Normalize Score (assuming HealthScore is 0-100)
-	// Example: High health = lower price? Or higher price for premium?
-	// User Requirement: "Donor Reliability" - usually higher quality = higher price (premium supplier)?
-	// Formula: F_quality = 1 + η * Q_score
-	// If Q_score is high (reliable), price is higher?
-	// ACTUALLY: Usually better quality -> Premium price.
+// qualityFactor maps a 0-100 quality score to a price multiplier: reliable
+// suppliers earn a premium of up to η.
+func qualityFactor(score *float64) float64 {
 	const eta = 0.1 // Coefficient
+	if score == nil {
+		return 1.0
+	}
+	return 1.0 + eta*math.Min(1, math.Max(0, *score/100))
+}
 
-	// Composite score (0-1)
-	qScore := (float64(metrics.SuccessfulDeliveries)/float64(metrics.TotalDeliveries+1)*0.4 +
-		metrics.VoltageStability/100.0*0.3 +
-		metrics.BatteryHealthScore/100.0*0.3)
+// 6. Forecast Factor: F_forecast = 1 + δ * max(0, SoC_avg - SoC_forecast_min)
+// Prices in an expected dip in community SoC over the next few hours so that
+// energy is conserved ahead of it rather than sold cheaply now.
+func (pe *PricingEngine) getForecastFactor(socAvg, forecastSoC float64) float64 {
+	return 1.0 + pe.Config.Delta*math.Max(0, socAvg-forecastSoC)
+}
 
-	return 1.0 + eta*qScore
+// forecastSoC returns the lowest community SoC predicted within ScarcityHorizon,
+// or the current SoC when forecasting is disabled or has no data.
+func (pe *PricingEngine) forecastSoC(now time.Time, socAvg float64) float64 {
+	soc, _ := pe.forecast(now, socAvg)
+	return soc
+}
+
+// forecast is forecastSoC, also reporting whether the SoC was predicted.
+func (pe *PricingEngine) forecast(now time.Time, socAvg float64) (float64, bool) {
+	if pe.Config.Delta == 0 {
+		return socAvg, false
+	}
+	if minSoC, ok := forecast.Current().MinSoC(now, ScarcityHorizon); ok {
+		return minSoC, true
+	}
+	return socAvg, false
 }
 
-func (pe *PricingEngine) logHistory(factors map[string]float64, soc, supply, demand float64) {
+func (pe *PricingEngine) logHistory(factors map[string]float64, soc, supply, demand, distance float64, forecasted bool) {
 	record := domain.PricingHistory{
 		Timestamp:    time.Now(),
 		BasePrice:    factors["base_price"],
@@ -180,8 +261,12 @@ func (pe *PricingEngine) logHistory(factors map[string]float64, soc, supply, dem
 		SupplyDemand: factors["f_sd"],
 		SoC:          factors["f_soc"],
 		Distance:     factors["f_dist"],
+		DistanceKm:   &distance,
 		Time:         factors["f_time"],
 		Quality:      factors["f_quality"],
+		Forecast:     factors["f_forecast"],
+		ForecastSoC:  factors["forecast_soc"],
+		Forecasted:   forecasted,
 		GridSoC:      soc,
 		TotalDemand:  demand,
 		TotalSupply:  supply,
@@ -0,0 +1,168 @@
+package pricing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+// DefaultQuoteSecret is the QUOTE_SECRET used when none is configured. It is
+// public, so CheckQuoteSecret only accepts it in development.
+const DefaultQuoteSecret = "a-very-secret-quote-key"
+
+var (
+	// ErrInvalidQuote is returned when a quote ID is malformed or its signature does not match.
+	ErrInvalidQuote = errors.New("invalid quote")
+	// ErrQuoteExpired is returned when a correctly signed quote is past its expiry.
+	ErrQuoteExpired = errors.New("quote has expired")
+	// ErrDefaultQuoteSecret is returned by CheckQuoteSecret outside development
+	// when QUOTE_SECRET is unset, empty or the default.
+	ErrDefaultQuoteSecret = errors.New("QUOTE_SECRET must be set to a private value outside development (APP_ENV=development)")
+)
+
+// CheckQuoteSecret refuses the default quote secret unless APP_ENV is
+// development: anyone with it could sign a quote at any price.
+func CheckQuoteSecret(secret []byte) error {
+	if config.IsDevelopment() {
+		return nil
+	}
+	if len(secret) == 0 || string(secret) == DefaultQuoteSecret {
+		return ErrDefaultQuoteSecret
+	}
+	return nil
+}
+
+// Quote is the signed commitment handed to a buyer. An order that references it
+// is placed with Price as its limit, which the matching engine then honours.
+type Quote struct {
+	ID        string  `json:"id"`
+	UserID    string  `json:"user_id"`
+	KwhAmount float64 `json:"kwh_amount"`
+	Price     float64 `json:"price"` // Worst level price, used as the order's limit
+	ExpiresAt int64   `json:"expires_at"`
+}
+
+// QuoteLevel is the expected fill against a single resting sell order.
+type QuoteLevel struct {
+	OrderID      string             `json:"order_id"`
+	SellerID     string             `json:"seller_id"`
+	KwhAvailable float64            `json:"kwh_available"`
+	KwhFilled    float64            `json:"kwh_filled"`
+	Price        float64            `json:"price"`
+	DistanceKm   float64            `json:"distance_km"`
+	Breakdown    map[string]float64 `json:"breakdown"`
+	priorityAt   time.Time
+}
+
+// BookQuote summarises how a buy of a given size would fill against the book.
+type BookQuote struct {
+	KwhRequested float64      `json:"kwh_requested"`
+	KwhFillable  float64      `json:"kwh_fillable"`
+	AveragePrice float64      `json:"average_price"`
+	WorstPrice   float64      `json:"worst_price"`
+	TotalCost    float64      `json:"total_cost"`
+	Levels       []QuoteLevel `json:"levels"`
+}
+
+// QuoteBook prices every candidate sell order for the buyer using the same
+// inputs as the matching engine and walks the levels cheapest first. damping is
+// the circuit breaker's current ratio between damped and raw prices.
+func (pe *PricingEngine) QuoteBook(kwh float64, sells []domain.EnergyOrder, supplyVol, demandVol, socAvg, damping float64) BookQuote {
+	levels := make([]QuoteLevel, 0, len(sells))
+	for _, sell := range sells {
+		distance := DefaultDistanceKm
+		price, breakdown := pe.EstimateDynamicPrice(sell, supplyVol, demandVol, socAvg, distance)
+		levels = append(levels, QuoteLevel{
+			OrderID:      sell.ID,
+			SellerID:     sell.UserID,
+			KwhAvailable: sell.RemainingKwh(),
+			Price:        price * damping,
+			DistanceKm:   distance,
+			Breakdown:    breakdown,
+			priorityAt:   sell.PriorityAt,
+		})
+	}
+
+	return fillLevels(levels, kwh)
+}
+
+// fillLevels sorts levels by price (then time priority) and fills kwh against them.
+func fillLevels(levels []QuoteLevel, kwh float64) BookQuote {
+	sort.SliceStable(levels, func(i, j int) bool {
+		if levels[i].Price != levels[j].Price {
+			return levels[i].Price < levels[j].Price
+		}
+		return levels[i].priorityAt.Before(levels[j].priorityAt)
+	})
+
+	quote := BookQuote{KwhRequested: kwh, Levels: []QuoteLevel{}}
+	remaining := kwh
+	for _, level := range levels {
+		if remaining <= 0 {
+			break
+		}
+		level.KwhFilled = math.Min(remaining, level.KwhAvailable)
+		remaining -= level.KwhFilled
+
+		quote.KwhFillable += level.KwhFilled
+		quote.TotalCost += level.KwhFilled * level.Price
+		quote.WorstPrice = level.Price
+		quote.Levels = append(quote.Levels, level)
+	}
+
+	if quote.KwhFillable > 0 {
+		quote.AveragePrice = quote.TotalCost / quote.KwhFillable
+	}
+	return quote
+}
+
+// SignQuote serialises the quote and appends an HMAC-SHA256 signature, producing
+// an opaque "<payload>.<signature>" token that can be verified without storage.
+func SignQuote(q Quote, secret []byte) (string, error) {
+	payload, err := json.Marshal(q)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + quoteSignature(encoded, secret), nil
+}
+
+// VerifyQuote checks the signature and expiry of a token produced by SignQuote.
+func VerifyQuote(token string, secret []byte, now time.Time) (*Quote, error) {
+	encoded, sig, found := strings.Cut(token, ".")
+	if !found {
+		return nil, ErrInvalidQuote
+	}
+	if !hmac.Equal([]byte(sig), []byte(quoteSignature(encoded, secret))) {
+		return nil, ErrInvalidQuote
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidQuote
+	}
+	var q Quote
+	if err := json.Unmarshal(payload, &q); err != nil {
+		return nil, ErrInvalidQuote
+	}
+
+	if now.Unix() > q.ExpiresAt {
+		return &q, ErrQuoteExpired
+	}
+	return &q, nil
+}
+
+func quoteSignature(encoded string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,116 @@
+package pricing
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerifyQuote(t *testing.T) {
+	secret := []byte("test-secret")
+	now := time.Now()
+	q := Quote{ID: "q1", UserID: "user_b", KwhAmount: 3, Price: 6.2, ExpiresAt: now.Add(30 * time.Second).Unix()}
+
+	token, err := SignQuote(q, secret)
+	if err != nil {
+		t.Fatalf("Failed to sign quote: %v", err)
+	}
+
+	// Valid round trip
+	got, err := VerifyQuote(token, secret, now)
+	if err != nil {
+		t.Fatalf("Valid quote rejected: %v", err)
+	}
+	if *got != q {
+		t.Errorf("Expected %+v, got %+v", q, *got)
+	}
+
+	// Wrong secret
+	if _, err := VerifyQuote(token, []byte("other-secret"), now); err != ErrInvalidQuote {
+		t.Errorf("Expected ErrInvalidQuote for wrong secret, got %v", err)
+	}
+
+	// Tampered payload
+	tampered, _ := SignQuote(Quote{ID: "q1", UserID: "user_b", KwhAmount: 3, Price: 0.1, ExpiresAt: q.ExpiresAt}, secret)
+	forgedPayload, _, _ := strings.Cut(tampered, ".")
+	_, originalSig, _ := strings.Cut(token, ".")
+	forged := forgedPayload + "." + originalSig
+	if _, err := VerifyQuote(forged, secret, now); err != ErrInvalidQuote {
+		t.Errorf("Expected ErrInvalidQuote for tampered quote, got %v", err)
+	}
+
+	// Expired
+	if _, err := VerifyQuote(token, secret, now.Add(time.Minute)); err != ErrQuoteExpired {
+		t.Errorf("Expected ErrQuoteExpired, got %v", err)
+	}
+}
+
+func TestCheckQuoteSecret(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+	for _, secret := range []string{"", DefaultQuoteSecret} {
+		if !errors.Is(CheckQuoteSecret([]byte(secret)), ErrDefaultQuoteSecret) {
+			t.Errorf("Expected %q to be refused", secret)
+		}
+	}
+	if err := CheckQuoteSecret([]byte("a-long-random-production-secret")); err != nil {
+		t.Errorf("Expected a private secret to be accepted, got %v", err)
+	}
+
+	t.Setenv("APP_ENV", "development")
+	if err := CheckQuoteSecret([]byte(DefaultQuoteSecret)); err != nil {
+		t.Errorf("Expected the default secret to be accepted in development, got %v", err)
+	}
+}
+
+func TestFillLevels(t *testing.T) {
+	base := time.Now()
+	levels := []QuoteLevel{
+		{OrderID: "expensive", KwhAvailable: 5, Price: 7.0, priorityAt: base},
+		{OrderID: "cheap_new", KwhAvailable: 2, Price: 5.0, priorityAt: base.Add(time.Second)},
+		{OrderID: "cheap_old", KwhAvailable: 2, Price: 5.0, priorityAt: base},
+	}
+
+	quote := fillLevels(levels, 6)
+
+	if len(quote.Levels) != 3 {
+		t.Fatalf("Expected 3 levels, got %d", len(quote.Levels))
+	}
+	if quote.Levels[0].OrderID != "cheap_old" || quote.Levels[1].OrderID != "cheap_new" {
+		t.Errorf("Expected price-time order, got %s, %s", quote.Levels[0].OrderID, quote.Levels[1].OrderID)
+	}
+	if quote.Levels[2].KwhFilled != 2 {
+		t.Errorf("Expected last level to fill the remaining 2 kWh, got %f", quote.Levels[2].KwhFilled)
+	}
+	if quote.KwhFillable != 6 || quote.TotalCost != 34 || quote.WorstPrice != 7.0 {
+		t.Errorf("Unexpected totals: %+v", quote)
+	}
+	if math.Abs(quote.AveragePrice-34.0/6) > 1e-9 {
+		t.Errorf("Expected the size-weighted average price 5.667, got %f", quote.AveragePrice)
+	}
+
+	// Not enough liquidity
+	short := fillLevels([]QuoteLevel{{OrderID: "only", KwhAvailable: 1, Price: 5.0}}, 4)
+	if short.KwhFillable != 1 || short.AveragePrice != 5.0 {
+		t.Errorf("Expected partial fill of 1 kWh at 5.0, got %+v", short)
+	}
+}
+
+func TestDistanceKm(t *testing.T) {
+	// Seeded simulation users user_a and user_b are a little under 1 km apart
+	km, ok := DistanceKm("28.6139,77.2090", "28.6200,77.2150")
+	if !ok {
+		t.Fatal("Expected valid locations")
+	}
+	if km < 0.8 || km > 1.0 {
+		t.Errorf("Expected ~0.9 km, got %f", km)
+	}
+
+	if _, ok := DistanceKm("", "28.6200,77.2150"); ok {
+		t.Error("Expected empty location to be rejected")
+	}
+	if _, ok := DistanceKm("north pole", "28.6200,77.2150"); ok {
+		t.Error("Expected malformed location to be rejected")
+	}
+}
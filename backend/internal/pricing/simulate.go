@@ -0,0 +1,148 @@
+package pricing
+
+import (
+	"math"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+// ReplayPoint is one historical market state priced under two configurations.
+type ReplayPoint struct {
+	Timestamp     time.Time `json:"timestamp"`
+	GridSoC       float64   `json:"grid_soc"`
+	TotalSupply   float64   `json:"total_supply"`
+	TotalDemand   float64   `json:"total_demand"`
+	CurrentPrice  float64   `json:"current_price"`
+	ProposedPrice float64   `json:"proposed_price"`
+}
+
+// SeriesStats summarises a price series.
+type SeriesStats struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+}
+
+// ReplayResult is the outcome of a what-if simulation.
+type ReplayResult struct {
+	Current           PricingConfig `json:"current_config"`
+	Proposed          PricingConfig `json:"proposed_config"`
+	Series            []ReplayPoint `json:"series"`
+	CurrentStats      SeriesStats   `json:"current_stats"`
+	ProposedStats     SeriesStats   `json:"proposed_stats"`
+	MeanChangePercent float64       `json:"mean_change_percent"`
+	// SkippedWithoutForecast counts records left out because the proposal
+	// changes Delta but they hold no forecast, so the change would not show
+	SkippedWithoutForecast int `json:"skipped_without_forecast"`
+}
+
+// ReplayHistory re-prices the recorded market inputs (grid SoC, supply, demand)
+// under both the current and a proposed configuration. The time-of-day factor
+// follows each record's timestamp, and the quality factor, forecast SoC and
+// trade distance are taken as recorded. Records logged before the distance was
+// stored only have the distance factor, which is inverted with the current
+// Gamma; that is exact only if Gamma has not changed since. When the proposal
+// changes Delta, records priced without a forecast (logged while Delta was 0,
+// before forecasting, or with no forecast data) cannot tell what it would do,
+// so they are skipped and counted rather than reported as unchanged. The
+// current price uses the base price recorded with each record; the proposed
+// one uses the proposed base price if the proposal changes it.
+func ReplayHistory(current, proposed PricingConfig, history []domain.PricingHistory) ReplayResult {
+	currentEngine := &PricingEngine{Config: current}
+	proposedEngine := &PricingEngine{Config: proposed}
+
+	series := make([]ReplayPoint, 0, len(history))
+	currentPrices := make([]float64, 0, len(history))
+	proposedPrices := make([]float64, 0, len(history))
+
+	skipped := 0
+	for _, h := range history {
+		// Records from before Forecasted was stored still carry a forecast if it
+		// differs from the grid SoC
+		forecasted := h.Forecasted || (h.ForecastSoC > 0 && h.ForecastSoC != h.GridSoC)
+		if !forecasted && proposed.Delta != current.Delta {
+			skipped++
+			continue
+		}
+
+		distance := DefaultDistanceKm
+		if h.DistanceKm != nil {
+			distance = *h.DistanceKm
+		} else if current.Gamma > 0 && h.Distance > 0 {
+			distance = (h.Distance - 1.0) / current.Gamma
+		}
+		quality := h.Quality
+		if quality == 0 {
+			quality = 1.0 // Records logged before the quality factor existed
+		}
+		forecastSoC := h.GridSoC
+		if forecasted {
+			forecastSoC = h.ForecastSoC
+		}
+
+		// Records logged before the base price was stored use the current one
+		currentEngine.Config.BasePrice = current.BasePrice
+		if h.BasePrice > 0 {
+			currentEngine.Config.BasePrice = h.BasePrice
+		}
+		proposedEngine.Config.BasePrice = currentEngine.Config.BasePrice
+		if proposed.BasePrice != current.BasePrice {
+			proposedEngine.Config.BasePrice = proposed.BasePrice
+		}
+
+		currentPrice, _ := currentEngine.priceAt(h.Timestamp, h.TotalSupply, h.TotalDemand, h.GridSoC, forecastSoC, distance, quality)
+		proposedPrice, _ := proposedEngine.priceAt(h.Timestamp, h.TotalSupply, h.TotalDemand, h.GridSoC, forecastSoC, distance, quality)
+
+		series = append(series, ReplayPoint{
+			Timestamp:     h.Timestamp,
+			GridSoC:       h.GridSoC,
+			TotalSupply:   h.TotalSupply,
+			TotalDemand:   h.TotalDemand,
+			CurrentPrice:  currentPrice,
+			ProposedPrice: proposedPrice,
+		})
+		currentPrices = append(currentPrices, currentPrice)
+		proposedPrices = append(proposedPrices, proposedPrice)
+	}
+
+	result := ReplayResult{
+		Current:       current,
+		Proposed:      proposed,
+		Series:        series,
+		CurrentStats:  Summarize(currentPrices),
+		ProposedStats: Summarize(proposedPrices),
+
+		SkippedWithoutForecast: skipped,
+	}
+	if result.CurrentStats.Mean > 0 {
+		result.MeanChangePercent = (result.ProposedStats.Mean - result.CurrentStats.Mean) / result.CurrentStats.Mean * 100
+	}
+	return result
+}
+
+// Summarize computes count, min, max, mean and population standard deviation.
+func Summarize(values []float64) SeriesStats {
+	stats := SeriesStats{Count: len(values)}
+	if len(values) == 0 {
+		return stats
+	}
+
+	stats.Min, stats.Max = values[0], values[0]
+	var sum float64
+	for _, v := range values {
+		sum += v
+		stats.Min = math.Min(stats.Min, v)
+		stats.Max = math.Max(stats.Max, v)
+	}
+	stats.Mean = sum / float64(len(values))
+
+	var sq float64
+	for _, v := range values {
+		sq += (v - stats.Mean) * (v - stats.Mean)
+	}
+	stats.StdDev = math.Sqrt(sq / float64(len(values)))
+	return stats
+}
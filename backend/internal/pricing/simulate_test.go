@@ -0,0 +1,129 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func TestReplayHistory(t *testing.T) {
+	current := PricingConfig{BasePrice: 5.0, Alpha: 0.2, Beta: 0.5, Gamma: 0.2}
+	proposed := current
+	proposed.Beta = 1.0 // Council proposes stronger scarcity pricing
+
+	// Noon, so the time-of-day factor is 1.0
+	noon := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	history := []domain.PricingHistory{
+		{Timestamp: noon, GridSoC: 1.0, TotalSupply: 4, TotalDemand: 4, Distance: 1.2, Quality: 1.0},
+		{Timestamp: noon.Add(time.Minute), GridSoC: 0.5, TotalSupply: 4, TotalDemand: 4, Distance: 1.2, Quality: 1.0},
+	}
+
+	result := ReplayHistory(current, proposed, history)
+
+	if len(result.Series) != 2 {
+		t.Fatalf("Expected 2 points, got %d", len(result.Series))
+	}
+
+	// Full batteries: no scarcity, so Beta has no effect
+	first := result.Series[0]
+	if math.Abs(first.CurrentPrice-6.0) > 1e-9 || math.Abs(first.ProposedPrice-6.0) > 1e-9 {
+		t.Errorf("Expected 6.0 under both configs at full SoC, got %f / %f", first.CurrentPrice, first.ProposedPrice)
+	}
+
+	// Half-full: F_soc = 1 + β * 0.25
+	second := result.Series[1]
+	if math.Abs(second.CurrentPrice-6.0*1.125) > 1e-9 {
+		t.Errorf("Unexpected current price %f", second.CurrentPrice)
+	}
+	if math.Abs(second.ProposedPrice-6.0*1.25) > 1e-9 {
+		t.Errorf("Unexpected proposed price %f", second.ProposedPrice)
+	}
+
+	if result.MeanChangePercent <= 0 {
+		t.Errorf("Expected a positive mean change, got %f", result.MeanChangePercent)
+	}
+}
+
+func TestReplayHistoryUsesRecordedDistance(t *testing.T) {
+	// Gamma was 0.2 when the record was logged: 3 km gave f_dist = 1.6
+	noon := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	distanceKm := 3.0
+	record := domain.PricingHistory{Timestamp: noon, GridSoC: 1.0, TotalSupply: 4, TotalDemand: 4, Distance: 1.6, DistanceKm: &distanceKm, Quality: 1.0}
+
+	// Governance has since lowered Gamma to 0.1, so the current price is 5 * 1.3
+	current := PricingConfig{BasePrice: 5.0, Alpha: 0.2, Beta: 0.5, Gamma: 0.1}
+	proposed := current
+	proposed.Gamma = 0.3
+
+	point := ReplayHistory(current, proposed, []domain.PricingHistory{record}).Series[0]
+	if math.Abs(point.CurrentPrice-5.0*1.3) > 1e-9 {
+		t.Errorf("Expected the recorded 3 km priced under the current Gamma (6.5), got %f", point.CurrentPrice)
+	}
+	if math.Abs(point.ProposedPrice-5.0*1.9) > 1e-9 {
+		t.Errorf("Expected the recorded 3 km priced under the proposed Gamma (9.5), got %f", point.ProposedPrice)
+	}
+}
+
+func TestReplayHistoryUsesRecordedBasePrice(t *testing.T) {
+	// The record was priced from a base of 4 before governance raised it to 5
+	noon := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	record := domain.PricingHistory{Timestamp: noon, BasePrice: 4.0, GridSoC: 1.0, TotalSupply: 4, TotalDemand: 4, Distance: 1.2, Quality: 1.0}
+
+	current := PricingConfig{BasePrice: 5.0, Alpha: 0.2, Beta: 0.5, Gamma: 0.2}
+	proposed := current
+	proposed.Beta = 1.0
+
+	point := ReplayHistory(current, proposed, []domain.PricingHistory{record}).Series[0]
+	if math.Abs(point.CurrentPrice-4.0*1.2) > 1e-9 || math.Abs(point.ProposedPrice-4.0*1.2) > 1e-9 {
+		t.Errorf("Expected both prices from the recorded base (4.8), got %f / %f", point.CurrentPrice, point.ProposedPrice)
+	}
+
+	proposed.BasePrice = 6.0
+	point = ReplayHistory(current, proposed, []domain.PricingHistory{record}).Series[0]
+	if math.Abs(point.CurrentPrice-4.0*1.2) > 1e-9 || math.Abs(point.ProposedPrice-6.0*1.2) > 1e-9 {
+		t.Errorf("Expected the proposed base to replace the recorded one (4.8 / 7.2), got %f / %f", point.CurrentPrice, point.ProposedPrice)
+	}
+}
+
+func TestReplayHistorySkipsRecordsWithoutForecastForDelta(t *testing.T) {
+	noon := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	history := []domain.PricingHistory{
+		// Logged before forecasting, and while Delta was 0 (forecast SoC is the grid SoC)
+		{Timestamp: noon, GridSoC: 0.8, TotalSupply: 4, TotalDemand: 4, Quality: 1.0},
+		{Timestamp: noon, GridSoC: 0.8, ForecastSoC: 0.8, TotalSupply: 4, TotalDemand: 4, Quality: 1.0},
+		// A forecast dip to 0.4
+		{Timestamp: noon, GridSoC: 0.8, ForecastSoC: 0.4, Forecasted: true, TotalSupply: 4, TotalDemand: 4, Quality: 1.0},
+	}
+	current := PricingConfig{BasePrice: 5.0, Alpha: 0.2, Gamma: 0.2}
+
+	proposed := current
+	proposed.Delta = 1.0
+	result := ReplayHistory(current, proposed, history)
+	if result.SkippedWithoutForecast != 2 || len(result.Series) != 1 {
+		t.Fatalf("Expected the 2 records without a forecast to be skipped, got %d skipped and %d points", result.SkippedWithoutForecast, len(result.Series))
+	}
+	// F_forecast = 1 + 1.0 * (0.8 - 0.4)
+	if point := result.Series[0]; math.Abs(point.ProposedPrice-point.CurrentPrice*1.4) > 1e-9 {
+		t.Errorf("Expected Delta to raise the price by 40%%, got %f -> %f", point.CurrentPrice, point.ProposedPrice)
+	}
+
+	// Other proposals still replay every record
+	proposed = current
+	proposed.Beta = 1.0
+	if result := ReplayHistory(current, proposed, history); result.SkippedWithoutForecast != 0 || len(result.Series) != 3 {
+		t.Errorf("Expected every record replayed when Delta is unchanged, got %d skipped", result.SkippedWithoutForecast)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	stats := Summarize([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	if stats.Count != 8 || stats.Min != 2 || stats.Max != 9 || stats.Mean != 5 || stats.StdDev != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	if empty := Summarize(nil); empty.Count != 0 || empty.Mean != 0 {
+		t.Errorf("Expected zero stats for empty series, got %+v", empty)
+	}
+}
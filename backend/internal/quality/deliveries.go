@@ -0,0 +1,75 @@
+package quality
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/mqtt"
+)
+
+// lockTimeout is how long a device has to answer a lock command before it
+// counts as a failed delivery.
+var lockTimeout = time.Duration(config.GetEnvAsInt("QUALITY_LOCK_TIMEOUT_SECONDS", 120)) * time.Second
+
+// Topics carrying devices' answers to lock commands: the documented one, and
+// the one the current ESP32 firmware publishes to.
+const (
+	LockResponseTopic         = "energy/donor/+/lock/response"
+	FirmwareLockResponseTopic = "energy/lock/+/response"
+)
+
+// lockResponse is the JSON body of a lock response.
+type lockResponse struct {
+	OrderID string `json:"order_id"`
+	Status  string `json:"status"` // locked or rejected
+}
+
+// Subscribe registers the lock response handlers with the MQTT client. It
+// must be called before mqtt.Connect.
+func Subscribe() {
+	mqtt.Handle(LockResponseTopic, HandleLockResponse)
+	mqtt.Handle(FirmwareLockResponseTopic, HandleLockResponse)
+}
+
+// HandleLockResponse records a device's answer to a lock command.
+func HandleLockResponse(topic string, payload []byte) {
+	deviceID, response, err := parseLockResponse(topic, payload)
+	if err != nil {
+		log.Printf("Dropping lock response on %s: %v", topic, err)
+		return
+	}
+
+	resolved, err := database.ResolveLockRequest(database.DB, deviceID, response.OrderID, response.Status, time.Now())
+	if err != nil {
+		log.Printf("Error recording lock response from device %s: %v", deviceID, err)
+		return
+	}
+	if !resolved {
+		log.Printf("Lock response from device %s for order %s matches no pending lock command", deviceID, response.OrderID)
+	}
+}
+
+func parseLockResponse(topic string, payload []byte) (string, lockResponse, error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) < 4 || parts[2] == "" || parts[len(parts)-1] != "response" {
+		return "", lockResponse{}, fmt.Errorf("not a lock response topic")
+	}
+
+	var response lockResponse
+	if err := json.Unmarshal(payload, &response); err != nil {
+		return "", lockResponse{}, fmt.Errorf("invalid lock response: %w", err)
+	}
+	if response.OrderID == "" {
+		return "", lockResponse{}, fmt.Errorf("lock response has no order_id")
+	}
+	if response.Status != domain.LockRequestLocked && response.Status != domain.LockRequestRejected {
+		return "", lockResponse{}, fmt.Errorf("unknown lock response status %q", response.Status)
+	}
+	return parts[2], response, nil
+}
@@ -0,0 +1,231 @@
+package quality
+
+import (
+	"fmt"
+	"math"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+// Component weights in the composite score. Components without enough data
+// are left out and the others reweighted.
+const (
+	deliveryWeight = 0.4
+	voltageWeight  = 0.3
+	batteryWeight  = 0.3
+)
+
+// cyclePenalty is how many battery health points a battery loses over its
+// rated cycle life, on top of measured capacity fade.
+const cyclePenalty = 20.0
+
+// minChargeObserved is how much SoC (as a fraction of a full charge) must have
+// been charged in the window before capacity is estimated from it.
+const minChargeObserved = 0.5
+
+var (
+	// nominalVoltage applies to devices without a nominal_voltage in their
+	// metadata. At 0 the mean voltage is used, scoring plain variability.
+	nominalVoltage = config.GetEnvAsFloat("QUALITY_NOMINAL_VOLTAGE", 0)
+	// voltageTolerance is the RMS deviation from nominal, as a fraction of
+	// nominal, that scores 0.
+	voltageTolerance = config.GetEnvAsFloat("QUALITY_VOLTAGE_TOLERANCE", 0.1)
+	// ratedCycles is the battery's rated cycle life in full equivalent cycles.
+	ratedCycles = config.GetEnvAsFloat("QUALITY_RATED_CYCLES", 3000)
+	// minVoltageSamples is how many voltage readings are needed for a score.
+	minVoltageSamples = config.GetEnvAsInt("QUALITY_MIN_SAMPLES", 30)
+	// minDeliveries is how many answered or timed out lock commands are needed for a score.
+	minDeliveries = config.GetEnvAsInt("QUALITY_MIN_DELIVERIES", 3)
+)
+
+// VoltageStats summarises a device's voltage readings in the scoring window.
+type VoltageStats struct {
+	Samples    int
+	Mean       float64
+	MeanSquare float64 // Mean of the squared readings
+}
+
+// BatteryStats summarises a device's battery in the scoring window.
+type BatteryStats struct {
+	SoCCharged     float64  // Sum of SoC increases between consecutive samples
+	EnergyCharged  float64  // Sum of energy_in_kwh increases over the same samples
+	EnergyOutTotal *float64 // Latest lifetime discharge counter
+}
+
+// DeliveryStats counts a device's resolved lock commands in the scoring window.
+type DeliveryStats struct {
+	Locked   int
+	Rejected int
+	TimedOut int
+}
+
+// Total is the number of resolved lock commands.
+func (d DeliveryStats) Total() int {
+	return d.Locked + d.Rejected + d.TimedOut
+}
+
+// Inputs is everything a device is scored on.
+type Inputs struct {
+	Device     domain.IoTDevice
+	Voltage    VoltageStats
+	Battery    BatteryStats
+	Deliveries DeliveryStats
+}
+
+// Score computes a device's component scores and their weighted composite,
+// nil if no component had enough data.
+func Score(in Inputs) (*float64, domain.QualityBreakdown) {
+	breakdown := domain.QualityBreakdown{
+		deliveryComponent(in.Deliveries),
+		voltageComponent(in.Voltage, deviceNominalVoltage(in.Device)),
+		batteryComponent(in.Battery, in.Device.CapacityKwh),
+	}
+	weights := map[string]float64{
+		domain.QualityDeliveryReliability: deliveryWeight,
+		domain.QualityVoltageStability:    voltageWeight,
+		domain.QualityBatteryHealth:       batteryWeight,
+	}
+
+	var total float64
+	for _, c := range breakdown {
+		if c.Score != nil {
+			total += weights[c.Name]
+		}
+	}
+	if total == 0 {
+		return nil, breakdown
+	}
+
+	var composite float64
+	for i, c := range breakdown {
+		if c.Score != nil {
+			breakdown[i].Weight = round(weights[c.Name] / total)
+			composite += *c.Score * weights[c.Name] / total
+		}
+	}
+	composite = round(composite)
+	return &composite, breakdown
+}
+
+// Component returns the named component's score from a breakdown.
+func Component(breakdown domain.QualityBreakdown, name string) *float64 {
+	for _, c := range breakdown {
+		if c.Name == name {
+			return c.Score
+		}
+	}
+	return nil
+}
+
+func deviceNominalVoltage(device domain.IoTDevice) float64 {
+	if v, ok := device.NominalVoltage(); ok {
+		return v
+	}
+	return nominalVoltage
+}
+
+// deliveryComponent scores the share of lock commands the device accepted.
+func deliveryComponent(d DeliveryStats) domain.QualityComponent {
+	c := domain.QualityComponent{
+		Name: domain.QualityDeliveryReliability,
+		Inputs: map[string]float64{
+			"locked":    float64(d.Locked),
+			"rejected":  float64(d.Rejected),
+			"timed_out": float64(d.TimedOut),
+		},
+	}
+	if d.Total() < minDeliveries {
+		c.Explanation = fmt.Sprintf("Needs at least %d answered or timed out lock commands, has %d", minDeliveries, d.Total())
+		return c
+	}
+	score := round(100 * float64(d.Locked) / float64(d.Total()))
+	c.Score = &score
+	c.Explanation = fmt.Sprintf("Locked energy for %d of %d lock commands; %d rejected, %d unanswered", d.Locked, d.Total(), d.Rejected, d.TimedOut)
+	return c
+}
+
+// voltageComponent scores the RMS deviation of the voltage from nominal
+// (or from its mean when no nominal voltage is known).
+func voltageComponent(v VoltageStats, nominal float64) domain.QualityComponent {
+	c := domain.QualityComponent{
+		Name:   domain.QualityVoltageStability,
+		Inputs: map[string]float64{"samples": float64(v.Samples)},
+	}
+	if v.Samples < minVoltageSamples {
+		c.Explanation = fmt.Sprintf("Needs at least %d voltage readings, has %d", minVoltageSamples, v.Samples)
+		return c
+	}
+
+	reference := nominal
+	if reference <= 0 {
+		reference = v.Mean
+	}
+	if reference <= 0 {
+		c.Explanation = "Voltage readings average 0 V and no nominal voltage is set"
+		return c
+	}
+	// E[(v - n)²] = E[v²] - 2n·E[v] + n²
+	rms := math.Sqrt(math.Max(0, v.MeanSquare-2*reference*v.Mean+reference*reference))
+	score := round(100 * math.Max(0, 1-rms/(voltageTolerance*reference)))
+
+	c.Score = &score
+	c.Inputs["mean_v"] = round(v.Mean)
+	c.Inputs["reference_v"] = round(reference)
+	c.Inputs["rms_deviation_v"] = round(rms)
+	c.Inputs["tolerance"] = voltageTolerance
+	c.Explanation = fmt.Sprintf("Voltage deviates %.2f V RMS from %.2f V; a deviation of %.0f%% scores 0", rms, reference, voltageTolerance*100)
+	return c
+}
+
+// batteryComponent scores capacity fade, estimated from the energy needed per
+// unit of SoC charged, and wear from full equivalent cycles.
+func batteryComponent(b BatteryStats, ratedCapacity float64) domain.QualityComponent {
+	c := domain.QualityComponent{
+		Name:   domain.QualityBatteryHealth,
+		Inputs: map[string]float64{"rated_capacity_kwh": ratedCapacity},
+	}
+	if ratedCapacity <= 0 {
+		c.Explanation = "The device has no rated capacity"
+		return c
+	}
+
+	fadeKnown := b.SoCCharged >= minChargeObserved && b.EnergyCharged > 0
+	if !fadeKnown && b.EnergyOutTotal == nil {
+		c.Explanation = fmt.Sprintf("Needs energy counters and at least %.0f%% of a full charge observed", minChargeObserved*100)
+		return c
+	}
+
+	score := 100.0
+	var explanations []string
+	if fadeKnown {
+		estimated := b.EnergyCharged / b.SoCCharged
+		fade := math.Min(1, math.Max(0, 1-estimated/ratedCapacity))
+		score -= 100 * fade
+		c.Inputs["estimated_capacity_kwh"] = round(estimated)
+		c.Inputs["capacity_fade"] = round(fade)
+		explanations = append(explanations, fmt.Sprintf("Estimated capacity %.2f of %.2f kWh (%.0f%% fade)", estimated, ratedCapacity, fade*100))
+	} else {
+		explanations = append(explanations, "Capacity fade unknown (not enough charging observed)")
+	}
+	if b.EnergyOutTotal != nil {
+		cycles := *b.EnergyOutTotal / ratedCapacity
+		wear := math.Min(1, cycles/ratedCycles)
+		score -= cyclePenalty * wear
+		c.Inputs["equivalent_cycles"] = round(cycles)
+		c.Inputs["rated_cycles"] = ratedCycles
+		explanations = append(explanations, fmt.Sprintf("%.0f of %.0f rated cycles used (-%.1f)", cycles, ratedCycles, cyclePenalty*wear))
+	} else {
+		explanations = append(explanations, "cycle count unknown (no discharge counter)")
+	}
+
+	score = round(math.Max(0, score))
+	c.Score = &score
+	c.Explanation = fmt.Sprintf("%s; %s", explanations[0], explanations[1])
+	return c
+}
+
+// round keeps scores and inputs readable.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
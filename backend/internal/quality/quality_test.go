@@ -0,0 +1,94 @@
+package quality
+
+import (
+	"math"
+	"testing"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+func TestScoreReweightsMissingComponents(t *testing.T) {
+	device := domain.IoTDevice{CapacityKwh: 10}
+
+	if score, breakdown := Score(Inputs{Device: device}); score != nil || len(breakdown) != 3 {
+		t.Fatalf("Expected no score without data but a full breakdown, got %v and %d components", score, len(breakdown))
+	}
+
+	// Only deliveries are known: 3 of 4 locked
+	score, breakdown := Score(Inputs{Device: device, Deliveries: DeliveryStats{Locked: 3, TimedOut: 1}})
+	if score == nil || *score != 75 {
+		t.Fatalf("Expected the delivery score alone (75), got %v", score)
+	}
+	for _, c := range breakdown {
+		wantWeight := 0.0
+		if c.Name == domain.QualityDeliveryReliability {
+			wantWeight = 1
+		}
+		if c.Weight != wantWeight || c.Explanation == "" {
+			t.Errorf("%s: expected weight %g and an explanation, got %+v", c.Name, wantWeight, c)
+		}
+	}
+}
+
+func TestVoltageComponent(t *testing.T) {
+	// Readings of 47 and 49 V around a nominal 48 V: 1 V RMS, 2.08% of nominal
+	stats := VoltageStats{Samples: 100, Mean: 48, MeanSquare: (47*47 + 49*49) / 2.0}
+	c := voltageComponent(stats, 48)
+	want := 100 * (1 - 1/(voltageTolerance*48))
+	if c.Score == nil || math.Abs(*c.Score-want) > 0.01 {
+		t.Errorf("Expected %.2f, got %v", want, c.Score)
+	}
+
+	// Against a nominal 12 V the same readings are far off
+	if c := voltageComponent(stats, 12); c.Score == nil || *c.Score != 0 {
+		t.Errorf("Expected readings far from nominal to score 0, got %v", c.Score)
+	}
+
+	// Without a nominal voltage, only the spread around the mean counts
+	if c := voltageComponent(stats, 0); c.Inputs["reference_v"] != 48 {
+		t.Errorf("Expected the mean to be the reference, got %v", c.Inputs)
+	}
+
+	if c := voltageComponent(VoltageStats{Samples: 5, Mean: 48, MeanSquare: 48 * 48}, 48); c.Score != nil {
+		t.Errorf("Expected too few samples not to be scored, got %v", *c.Score)
+	}
+}
+
+func TestBatteryComponent(t *testing.T) {
+	// 9 kWh charged per full SoC on a 10 kWh battery: 10% fade. 1500 cycles is
+	// half the rated life.
+	c := batteryComponent(BatteryStats{SoCCharged: 2, EnergyCharged: 18, EnergyOutTotal: ptr(15000)}, 10)
+	want := 100 - 10 - cyclePenalty*1500/ratedCycles
+	if c.Score == nil || math.Abs(*c.Score-want) > 0.01 {
+		t.Errorf("Expected %.2f, got %v (%s)", want, c.Score, c.Explanation)
+	}
+	if c.Inputs["capacity_fade"] != 0.1 || c.Inputs["equivalent_cycles"] != 1500 {
+		t.Errorf("Expected the fade and cycles in the inputs, got %v", c.Inputs)
+	}
+
+	// Too little charging observed: cycles alone
+	if c := batteryComponent(BatteryStats{SoCCharged: 0.1, EnergyCharged: 1, EnergyOutTotal: ptr(0)}, 10); c.Score == nil || *c.Score != 100 {
+		t.Errorf("Expected a new battery with unknown fade to score 100, got %v", c.Score)
+	}
+
+	if c := batteryComponent(BatteryStats{SoCCharged: 0.1}, 10); c.Score != nil {
+		t.Errorf("Expected no score without counters, got %v", *c.Score)
+	}
+}
+
+func TestParseLockResponse(t *testing.T) {
+	for _, topic := range []string{"energy/donor/esp32_a/lock/response", "energy/lock/esp32_a/response"} {
+		deviceID, response, err := parseLockResponse(topic, []byte(`{"order_id": "o1", "status": "locked", "signature": "sig"}`))
+		if err != nil || deviceID != "esp32_a" || response.OrderID != "o1" || response.Status != domain.LockRequestLocked {
+			t.Errorf("%s: unexpected %q %+v %v", topic, deviceID, response, err)
+		}
+	}
+
+	for _, payload := range []string{`{"order_id": "o1", "status": "maybe"}`, `{"status": "locked"}`, `not json`} {
+		if _, _, err := parseLockResponse("energy/donor/esp32_a/lock/response", []byte(payload)); err == nil {
+			t.Errorf("Expected %s to be rejected", payload)
+		}
+	}
+}
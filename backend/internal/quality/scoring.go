@@ -0,0 +1,90 @@
+package quality
+
+import (
+	"log"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+)
+
+// window is how far back a device's telemetry and lock commands are scored.
+var window = time.Duration(config.GetEnvAsInt("QUALITY_WINDOW_DAYS", 7)) * 24 * time.Hour
+
+// scoringInterval is how often every device is rescored.
+var scoringInterval = time.Duration(config.GetEnvAsInt("QUALITY_SCORING_INTERVAL_MINUTES", 60)) * time.Minute
+
+// RunScoring starts a background process that rescores every live ESP32
+// every QUALITY_SCORING_INTERVAL_MINUTES, starting immediately.
+func RunScoring() {
+	log.Println("Starting device quality scoring...")
+	ticker := time.NewTicker(scoringInterval)
+
+	for ; ; <-ticker.C {
+		if err := ScoreDevices(time.Now()); err != nil {
+			log.Printf("Error scoring devices: %v", err)
+		}
+	}
+}
+
+// ScoreDevices times out unanswered lock commands, then rescores every live ESP32.
+func ScoreDevices(now time.Time) error {
+	if _, err := database.ExpireLockRequests(database.DB, now.Add(-lockTimeout)); err != nil {
+		return err
+	}
+
+	var devices []domain.IoTDevice
+	if err := database.DB.Where("device_type = ? AND status <> ?", "esp32", domain.DeviceStatusDecommissioned).Find(&devices).Error; err != nil {
+		return err
+	}
+
+	scored := 0
+	for _, device := range devices {
+		if err := ScoreDevice(device, now); err != nil {
+			log.Printf("Error scoring device %s: %v", device.ID, err)
+			continue
+		}
+		scored++
+	}
+	log.Printf("Scored the quality of %d devices", scored)
+	return nil
+}
+
+// ScoreDevice computes and stores one device's quality metrics over the
+// window ending at now.
+func ScoreDevice(device domain.IoTDevice, now time.Time) error {
+	since := now.Add(-window)
+	in := Inputs{Device: device}
+
+	var err error
+	if in.Voltage.Samples, in.Voltage.Mean, in.Voltage.MeanSquare, err = database.VoltageMoments(database.DB, device.ID, since); err != nil {
+		return err
+	}
+	if in.Battery.SoCCharged, in.Battery.EnergyCharged, in.Battery.EnergyOutTotal, err = database.BatteryMovement(database.DB, device.ID, since); err != nil {
+		return err
+	}
+	outcomes, err := database.LockOutcomes(database.DB, device.ID, since)
+	if err != nil {
+		return err
+	}
+	in.Deliveries = DeliveryStats{
+		Locked:   outcomes[domain.LockRequestLocked],
+		Rejected: outcomes[domain.LockRequestRejected],
+		TimedOut: outcomes[domain.LockRequestTimedOut],
+	}
+
+	score, breakdown := Score(in)
+	return database.SaveQualityMetrics(database.DB, &domain.DeviceQualityMetrics{
+		DeviceID:             device.ID,
+		SuccessfulDeliveries: in.Deliveries.Locked,
+		TotalDeliveries:      in.Deliveries.Total(),
+		DeliveryReliability:  Component(breakdown, domain.QualityDeliveryReliability),
+		VoltageStability:     Component(breakdown, domain.QualityVoltageStability),
+		BatteryHealthScore:   Component(breakdown, domain.QualityBatteryHealth),
+		Score:                score,
+		Breakdown:            breakdown,
+		WindowStart:          since,
+		LastUpdated:          now,
+	})
+}
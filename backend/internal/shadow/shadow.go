@@ -0,0 +1,184 @@
+package shadow
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"los-tecnicos/backend/internal/alerts"
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/mqtt"
+)
+
+// ReportedTopic carries the configuration devices report running.
+const ReportedTopic = "energy/device/+/config/reported"
+
+// republishAfter is how long a device has to apply its desired configuration
+// before it is published again.
+var republishAfter = time.Duration(config.GetEnvAsInt("SHADOW_REPUBLISH_MINUTES", 5)) * time.Minute
+
+// reconcileInterval is how often unapplied configurations are checked.
+const reconcileInterval = time.Minute
+
+// Document is the payload of the desired and reported topics.
+type Document struct {
+	Version int64               `json:"version"` // The desired version it is, or that the device applied
+	Config  domain.DeviceConfig `json:"config"`
+}
+
+// Validate checks that the settings in a configuration are within what the
+// firmware supports.
+func Validate(c domain.DeviceConfig) error {
+	if v := c.ReportingIntervalSeconds; v != nil && (*v < 10 || *v > 3600) {
+		return fmt.Errorf("reporting_interval_seconds must be between 10 and 3600")
+	}
+	if v := c.MinReserveSoC; v != nil && (*v < 0 || *v > 0.9) {
+		return fmt.Errorf("min_reserve_soc must be between 0 and 0.9")
+	}
+	if v := c.MaxExportKw; v != nil && (*v <= 0 || *v > 100) {
+		return fmt.Errorf("max_export_kw must be above 0 and at most 100")
+	}
+	return nil
+}
+
+// Set changes a device's desired configuration and publishes it. If the
+// broker is unreachable, the reconciler publishes it later.
+func Set(deviceID string, changes domain.DeviceConfig, userID string) (*domain.DeviceShadow, error) {
+	now := time.Now()
+	shadow, err := database.SetDesiredConfig(database.DB, deviceID, changes, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := Publish(*shadow, now); err != nil {
+		log.Printf("Failed to publish config version %d to device %s: %v", shadow.DesiredVersion, deviceID, err)
+	}
+	return shadow, nil
+}
+
+// Publish sends a device its desired configuration as a retained message.
+func Publish(shadow domain.DeviceShadow, now time.Time) error {
+	payload, err := json.Marshal(Document{Version: shadow.DesiredVersion, Config: shadow.Desired})
+	if err != nil {
+		return err
+	}
+	if err := mqtt.SendDesiredConfig(shadow.DeviceID, payload); err != nil {
+		return err
+	}
+	return database.MarkConfigPublished(database.DB, shadow.DeviceID, shadow.DesiredVersion, now)
+}
+
+// Clear removes a device's retained desired configuration from the broker,
+// e.g. when it is decommissioned.
+func Clear(deviceID string) error {
+	return mqtt.SendDesiredConfig(deviceID, nil)
+}
+
+// Subscribe registers the reported configuration handler with the MQTT
+// client. It must be called before mqtt.Connect.
+func Subscribe() {
+	mqtt.Handle(ReportedTopic, HandleReport)
+}
+
+// HandleReport records the configuration a device reports and reconciles it
+// with the desired one: a device behind on versions is sent the latest again,
+// and one that applied the latest but reports other values raises a
+// config_drift alert until it matches.
+func HandleReport(topic string, payload []byte) {
+	deviceID, doc, err := parseReport(topic, payload)
+	if err != nil {
+		log.Printf("Dropping reported config on %s: %v", topic, err)
+		return
+	}
+
+	now := time.Now()
+	shadow, err := database.RecordReportedConfig(database.DB, deviceID, doc.Version, doc.Config, now)
+	if err != nil {
+		log.Printf("Dropping reported config from device %s: %v", deviceID, err)
+		return
+	}
+
+	if shadow.ReportedVersion < shadow.DesiredVersion {
+		if shadow.PublishedAt == nil || now.Sub(*shadow.PublishedAt) >= republishAfter {
+			if err := Publish(*shadow, now); err != nil {
+				log.Printf("Failed to republish config to device %s: %v", deviceID, err)
+			}
+		}
+		return
+	}
+
+	var device domain.IoTDevice
+	if err := database.DB.First(&device, "id = ?", deviceID).Error; err != nil {
+		log.Printf("Error loading device %s to reconcile its config: %v", deviceID, err)
+		return
+	}
+	if err := alerts.Apply(device, driftFinding(*shadow), now); err != nil {
+		log.Printf("Error updating config drift alert for device %s: %v", deviceID, err)
+	}
+}
+
+// driftFinding raises config_drift for a shadow whose device applied the
+// latest version but reports other values, or clears it.
+func driftFinding(shadow domain.DeviceShadow) alerts.Finding {
+	f := alerts.Finding{Type: domain.AlertConfigDrift}
+	drift := domain.Drift(shadow.Desired, shadow.Reported)
+	if len(drift) == 0 {
+		return f
+	}
+
+	fields := make([]string, len(drift))
+	for i, d := range drift {
+		fields[i] = d.Field
+	}
+	f.Severity = domain.AlertSeverityWarning
+	f.Message = fmt.Sprintf("Device applied config version %d but reports different %s", shadow.ReportedVersion, strings.Join(fields, ", "))
+	return f
+}
+
+func parseReport(topic string, payload []byte) (string, Document, error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 5 || parts[2] == "" || parts[3] != "config" || parts[4] != "reported" {
+		return "", Document{}, fmt.Errorf("not a reported config topic")
+	}
+
+	var doc Document
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return "", Document{}, fmt.Errorf("invalid reported config: %w", err)
+	}
+	if doc.Version < 0 {
+		return "", Document{}, fmt.Errorf("negative config version %d", doc.Version)
+	}
+	return parts[2], doc, nil
+}
+
+// RunReconciliation starts a background process that republishes desired
+// configurations devices have not applied within SHADOW_REPUBLISH_MINUTES,
+// in case the broker lost its retained message or the device missed it.
+func RunReconciliation() {
+	log.Println("Starting device config reconciliation...")
+	ticker := time.NewTicker(reconcileInterval)
+
+	for range ticker.C {
+		if err := Reconcile(time.Now()); err != nil {
+			log.Printf("Error reconciling device configs: %v", err)
+		}
+	}
+}
+
+// Reconcile republishes every desired configuration still unapplied after
+// republishAfter.
+func Reconcile(now time.Time) error {
+	shadows, err := database.UnappliedShadows(database.DB, now.Add(-republishAfter))
+	if err != nil {
+		return err
+	}
+	for _, shadow := range shadows {
+		if err := Publish(shadow, now); err != nil {
+			log.Printf("Failed to republish config to device %s: %v", shadow.DeviceID, err)
+		}
+	}
+	return nil
+}
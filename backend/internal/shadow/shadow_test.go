@@ -0,0 +1,86 @@
+package shadow
+
+import (
+	"testing"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func intPtr(v int) *int           { return &v }
+func floatPtr(v float64) *float64 { return &v }
+
+func TestValidate(t *testing.T) {
+	if err := Validate(domain.DeviceConfig{ReportingIntervalSeconds: intPtr(60), MinReserveSoC: floatPtr(0.2), MaxExportKw: floatPtr(5)}); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+	for _, bad := range []domain.DeviceConfig{
+		{ReportingIntervalSeconds: intPtr(1)},
+		{MinReserveSoC: floatPtr(1)},
+		{MaxExportKw: floatPtr(0)},
+	} {
+		if err := Validate(bad); err == nil {
+			t.Errorf("Expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestMergeAndDrift(t *testing.T) {
+	desired := domain.DeviceConfig{ReportingIntervalSeconds: intPtr(60)}.Merge(domain.DeviceConfig{MaxExportKw: floatPtr(3)})
+	if desired.ReportingIntervalSeconds == nil || *desired.ReportingIntervalSeconds != 60 || *desired.MaxExportKw != 3 {
+		t.Fatalf("Expected the merge to keep the interval and add the export limit, got %+v", desired)
+	}
+
+	// The device clamped the export limit and does not know the reserve setting
+	reported := domain.DeviceConfig{ReportingIntervalSeconds: intPtr(60), MaxExportKw: floatPtr(2.5)}
+	drift := domain.Drift(desired, reported)
+	if len(drift) != 1 || drift[0].Field != "max_export_kw" || drift[0].Desired != 3.0 || drift[0].Reported != 2.5 {
+		t.Errorf("Expected drift on max_export_kw only, got %+v", drift)
+	}
+	if drift := domain.Drift(domain.DeviceConfig{MinReserveSoC: floatPtr(0.2)}, domain.DeviceConfig{}); len(drift) != 1 || drift[0].Reported != nil {
+		t.Errorf("Expected an unreported setting to drift with a nil reported value, got %+v", drift)
+	}
+
+	// Settings not desired never drift
+	if drift := domain.Drift(domain.DeviceConfig{}, reported); len(drift) != 0 {
+		t.Errorf("Expected no drift without desired settings, got %+v", drift)
+	}
+}
+
+func TestDriftFinding(t *testing.T) {
+	s := domain.DeviceShadow{
+		Desired:         domain.DeviceConfig{MaxExportKw: floatPtr(3)},
+		DesiredVersion:  2,
+		Reported:        domain.DeviceConfig{MaxExportKw: floatPtr(2.5)},
+		ReportedVersion: 2,
+	}
+	if f := driftFinding(s); f.Type != domain.AlertConfigDrift || f.Severity != domain.AlertSeverityWarning {
+		t.Errorf("Expected a config_drift warning, got %+v", f)
+	}
+	if s.InSync() {
+		t.Error("Expected a drifted shadow not to be in sync")
+	}
+
+	s.Reported.MaxExportKw = floatPtr(3)
+	if f := driftFinding(s); f.Severity != "" {
+		t.Errorf("Expected config_drift to clear, got %+v", f)
+	}
+	if !s.InSync() {
+		t.Error("Expected the shadow to be in sync")
+	}
+}
+
+func TestParseReport(t *testing.T) {
+	deviceID, doc, err := parseReport("energy/device/dev-1/config/reported", []byte(`{"version":3,"config":{"reporting_interval_seconds":30}}`))
+	if err != nil || deviceID != "dev-1" || doc.Version != 3 || *doc.Config.ReportingIntervalSeconds != 30 {
+		t.Fatalf("Unexpected report from %s: %+v (%v)", deviceID, doc, err)
+	}
+	for _, tc := range []struct{ topic, payload string }{
+		{"energy/device/dev-1/config/desired", `{"version":1}`},
+		{"energy/device/dev-1/config/reported", `{"version":-1}`},
+		{"energy/device/dev-1/config/reported", `not json`},
+	} {
+		if _, _, err := parseReport(tc.topic, []byte(tc.payload)); err == nil {
+			t.Errorf("Expected %s on %s to be rejected", tc.payload, tc.topic)
+		}
+	}
+}
@@ -5,12 +5,18 @@ import (
 	"math/rand"
 	"time"
 
+	"los-tecnicos/backend/internal/config"
 	"los-tecnicos/backend/internal/core/domain"
 	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/telemetry"
 )
 
-// SeedMockData populates the database with initial users and devices for the simulation.
+// SeedMockData populates the database with initial users and devices for the
+// simulation. The users are credited tokens, so it only runs in development.
 func SeedMockData() {
+	if !config.IsDevelopment() {
+		return
+	}
 	log.Println("Seeding mock data for simulation...")
 
 	users := []domain.User{
@@ -21,29 +27,18 @@ func SeedMockData() {
 
 	for _, u := range users {
 		database.DB.FirstOrCreate(&u, domain.User{ID: u.ID})
+		// Buyers need tokens to place orders
+		database.OpenAccount(database.DB, u.ID, 1000)
 	}
 
 	devices := []domain.IoTDevice{
-		{ID: "esp32_a", OwnerID: "user_a", DeviceType: "esp32", Location: "28.6139,77.2090", BatteryLevel: 0.85, Status: "Online"},
-		{ID: "esp32_c", OwnerID: "user_c", DeviceType: "esp32", Location: "28.7041,77.1025", BatteryLevel: 0.45, Status: "Online"},
+		{ID: "esp32_a", OwnerID: "user_a", DeviceType: "esp32", Location: "28.6139,77.2090", BatteryLevel: 0.85, CapacityKwh: 10, Status: "Online"},
+		{ID: "esp32_c", OwnerID: "user_c", DeviceType: "esp32", Location: "28.7041,77.1025", BatteryLevel: 0.45, CapacityKwh: 10, Status: "Online"},
 		{ID: "raspi_node_1", OwnerID: "admin", DeviceType: "raspi", Location: "28.6150,77.2100", Status: "Online"},
 	}
 
 	for _, d := range devices {
 		database.DB.FirstOrCreate(&d, domain.IoTDevice{ID: d.ID})
-
-		// Seed Quality Metrics for ESP32 devices
-		if d.DeviceType == "esp32" {
-			metrics := domain.DeviceQualityMetrics{
-				DeviceID:             d.ID,
-				SuccessfulDeliveries: 45 + rand.Intn(10), // Random success count
-				TotalDeliveries:      50 + rand.Intn(10),
-				VoltageStability:     98.0 + (rand.Float64() * 2.0),  // 98-100%
-				BatteryHealthScore:   90.0 + (rand.Float64() * 10.0), // 90-100%
-				LastUpdated:          time.Now(),
-			}
-			database.DB.FirstOrCreate(&metrics, domain.DeviceQualityMetrics{DeviceID: d.ID})
-		}
 	}
 }
 
@@ -61,9 +56,13 @@ func StartSimulation() {
 	}()
 }
 
+// energyCounters holds each simulated device's lifetime charge and discharge
+// counters. They restart at zero with the process, like a rebooted meter.
+var energyCounters = map[string][2]float64{}
+
 func fluctuateBatteries() {
 	var devices []domain.IoTDevice
-	database.DB.Where("device_type = ?", "esp32").Find(&devices)
+	database.DB.Where("device_type = ? AND status <> ?", "esp32", domain.DeviceStatusDecommissioned).Find(&devices)
 
 	for _, d := range devices {
 		// Simulate charging (daytime) or discharging (nighttime/usage)
@@ -79,23 +78,32 @@ func fluctuateBatteries() {
 			newLevel = 0.1
 		}
 
-		database.DB.Model(&d).Update("battery_level", newLevel)
-
-		// Also update Quality Metrics occasionally
-		if rand.Float64() < 0.3 {
-			var m domain.DeviceQualityMetrics
-			if err := database.DB.Where("device_id = ?", d.ID).First(&m).Error; err == nil {
-				// Fluctuate voltage more aggressively for demo
-				newVoltage := m.VoltageStability + (rand.Float64()*10.0 - 5.0)
-				if newVoltage > 100 {
-					newVoltage = 100
-				}
-				if newVoltage < 80 {
-					newVoltage = 80
-				}
-				database.DB.Model(&m).Update("voltage_stability", newVoltage)
-			}
+		// Meter the energy moved, at 95% charging efficiency
+		energyIn, energyOut := energyCounters[d.ID][0], energyCounters[d.ID][1]
+		if delta := (newLevel - d.BatteryLevel) * d.CapacityKwh; delta > 0 {
+			energyIn += delta / 0.95
+		} else {
+			energyOut -= delta
+		}
+		energyCounters[d.ID] = [2]float64{energyIn, energyOut}
+
+		// Report the new level as telemetry, which also updates the device.
+		// The quality scorer rates the devices from these samples and the
+		// alert rules check them.
+		voltage := 44 + newLevel*10 + rand.Float64()
+		temperature := 25 + rand.Float64()*10
+		sample := domain.DeviceTelemetry{
+			DeviceID:     d.ID,
+			RecordedAt:   time.Now(),
+			SoC:          &newLevel,
+			VoltageV:     &voltage,
+			TemperatureC: &temperature,
+			EnergyInKwh:  &energyIn,
+			EnergyOutKwh: &energyOut,
+		}
+		if err := telemetry.Record(sample, nil); err != nil {
+			log.Printf("[Simulation] Failed to record telemetry for %s: %v", d.ID, err)
 		}
 	}
-	log.Println("[Simulation] Battery levels and Quality Metrics updated across community.")
+	log.Println("[Simulation] Battery levels and telemetry updated across community.")
 }
@@ -0,0 +1,49 @@
+package telemetry
+
+import (
+	"log"
+	"time"
+
+	"los-tecnicos/backend/internal/alerts"
+	"los-tecnicos/backend/internal/core/domain"
+	"los-tecnicos/backend/internal/database"
+	"los-tecnicos/backend/internal/mqtt"
+)
+
+// Topics carrying telemetry: the dedicated telemetry report, and the status
+// report the current ESP32 firmware publishes every minute.
+const (
+	TelemetryTopic = "energy/device/+/telemetry"
+	StatusTopic    = "energy/device/+/status"
+)
+
+// Subscribe registers the telemetry handlers with the MQTT client. It must be
+// called before mqtt.Connect.
+func Subscribe() {
+	mqtt.Handle(TelemetryTopic, HandleMessage)
+	mqtt.Handle(StatusTopic, HandleMessage)
+}
+
+// HandleMessage stores a telemetry report received over MQTT. Malformed
+// reports and reports from unknown or decommissioned devices are dropped.
+func HandleMessage(topic string, payload []byte) {
+	report, err := ParseReport(topic, payload, time.Now())
+	if err != nil {
+		log.Printf("Dropping telemetry on %s: %v", topic, err)
+		return
+	}
+
+	if err := Record(report.Sample, report.StoredKwh); err != nil {
+		log.Printf("Dropping telemetry from device %s: %v", report.Sample.DeviceID, err)
+	}
+}
+
+// Record stores a sample from a live device (see database.RecordTelemetry)
+// and runs the alert rules against it.
+func Record(sample domain.DeviceTelemetry, storedKwh *float64) error {
+	if err := database.RecordTelemetry(database.DB, &sample, storedKwh); err != nil {
+		return err
+	}
+	alerts.CheckTelemetry(sample)
+	return nil
+}
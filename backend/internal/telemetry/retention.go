@@ -0,0 +1,65 @@
+package telemetry
+
+import (
+	"log"
+	"time"
+
+	"los-tecnicos/backend/internal/database"
+)
+
+// retentionInterval is how often raw samples are rolled up and expired data deleted.
+const retentionInterval = time.Hour
+
+// RunRetention starts a background process that downsamples raw telemetry
+// into hourly rollups and deletes raw samples older than
+// TELEMETRY_RAW_RETENTION_DAYS and rollups older than
+// TELEMETRY_ROLLUP_RETENTION_DAYS.
+func RunRetention() {
+	log.Println("Starting telemetry retention...")
+	ticker := time.NewTicker(retentionInterval)
+
+	for ; ; <-ticker.C {
+		if err := applyRetention(time.Now()); err != nil {
+			log.Printf("Error applying telemetry retention: %v", err)
+		}
+	}
+}
+
+// applyRetention rolls up the completed hours since the newest rollup (redoing
+// that hour to pick up late samples), then prunes expired data. Rollups come
+// first so no raw sample is deleted before it has been downsampled.
+func applyRetention(now time.Time) error {
+	latest, err := database.LatestTelemetryRollup(database.DB)
+	if err != nil {
+		return err
+	}
+	since, until := rollupWindow(latest, now)
+	if since.Before(until) {
+		rows, err := database.RollupTelemetry(database.DB, since, until)
+		if err != nil {
+			return err
+		}
+		log.Printf("Rolled up telemetry from %s to %s (%d device-hours)", since.Format(time.RFC3339), until.Format(time.RFC3339), rows)
+	}
+
+	raw, rollups, err := database.PruneTelemetry(database.DB, now.Add(-RawRetention), now.Add(-RollupRetention))
+	if err != nil {
+		return err
+	}
+	if raw > 0 || rollups > 0 {
+		log.Printf("Pruned %d raw telemetry samples and %d hourly rollups", raw, rollups)
+	}
+	return nil
+}
+
+// rollupWindow returns the whole hours to roll up at now, given the start of
+// the newest rollup: from that hour (or the oldest retained raw hour) up to the
+// start of the current, incomplete hour.
+func rollupWindow(latest, now time.Time) (since, until time.Time) {
+	until = now.UTC().Truncate(time.Hour)
+	since = now.Add(-RawRetention).UTC().Truncate(time.Hour)
+	if latest.After(since) {
+		since = latest.UTC().Truncate(time.Hour)
+	}
+	return since, until
+}
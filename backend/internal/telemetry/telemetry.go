@@ -0,0 +1,285 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"los-tecnicos/backend/internal/config"
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+// Resolutions maps the supported series resolutions to their bucket widths.
+var Resolutions = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// MaxPoints bounds how many buckets a single series request may span.
+const MaxPoints = 1000
+
+// RawRetention is how long raw samples are kept. Older data is only
+// available from the hourly rollups.
+var RawRetention = time.Duration(config.GetEnvAsInt("TELEMETRY_RAW_RETENTION_DAYS", 7)) * 24 * time.Hour
+
+// RollupRetention is how long hourly rollups are kept.
+var RollupRetention = time.Duration(config.GetEnvAsInt("TELEMETRY_ROLLUP_RETENTION_DAYS", 365)) * 24 * time.Hour
+
+// maxClockSkew is how far in the future a reported timestamp may be.
+const maxClockSkew = 5 * time.Minute
+
+// Report is a parsed telemetry message.
+type Report struct {
+	Sample domain.DeviceTelemetry
+	// StoredKwh is the energy in the battery, reported by firmware that does
+	// not compute an SoC itself.
+	StoredKwh *float64
+}
+
+// payload is the JSON body of both telemetry topics. On
+// energy/device/{id}/telemetry current is in amps; on the firmware's
+// energy/device/{id}/status report it is in milliamps and there is no SoC,
+// only available_kwh and locked_kwh.
+type payload struct {
+	DeviceID     string   `json:"device_id"`
+	Timestamp    int64    `json:"ts"` // Unix seconds; the receipt time if absent
+	SoC          *float64 `json:"soc"`
+	Voltage      *float64 `json:"voltage"`
+	Current      *float64 `json:"current"`
+	Temperature  *float64 `json:"temperature"`
+	EnergyInKwh  *float64 `json:"energy_in_kwh"`
+	EnergyOutKwh *float64 `json:"energy_out_kwh"`
+	AvailableKwh *float64 `json:"available_kwh"`
+	LockedKwh    *float64 `json:"locked_kwh"`
+}
+
+// ParseResolution resolves a resolution name such as "5m".
+func ParseResolution(name string) (time.Duration, error) {
+	d, ok := Resolutions[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported resolution %q (use 1m, 5m, 15m, 1h or 1d)", name)
+	}
+	return d, nil
+}
+
+// ParseReport parses a message received at now on energy/device/{id}/telemetry
+// or energy/device/{id}/status.
+func ParseReport(topic string, data []byte, now time.Time) (Report, error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 4 || parts[0] != "energy" || parts[1] != "device" || parts[2] == "" || (parts[3] != "telemetry" && parts[3] != "status") {
+		return Report{}, fmt.Errorf("not a telemetry topic: %s", topic)
+	}
+	deviceID := parts[2]
+
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Report{}, fmt.Errorf("invalid telemetry payload: %w", err)
+	}
+	if p.DeviceID != "" && p.DeviceID != deviceID {
+		return Report{}, fmt.Errorf("payload device_id %q does not match topic device %q", p.DeviceID, deviceID)
+	}
+
+	recordedAt := now
+	if p.Timestamp > 0 {
+		recordedAt = time.Unix(p.Timestamp, 0)
+		if recordedAt.After(now.Add(maxClockSkew)) {
+			return Report{}, fmt.Errorf("timestamp %s is in the future", recordedAt.UTC().Format(time.RFC3339))
+		}
+		if recordedAt.Before(now.Add(-RawRetention)) {
+			return Report{}, fmt.Errorf("timestamp %s is older than the raw retention period", recordedAt.UTC().Format(time.RFC3339))
+		}
+	}
+
+	current := p.Current
+	if parts[3] == "status" && current != nil {
+		amps := *current / 1000
+		current = &amps
+	}
+
+	checks := []struct {
+		name     string
+		value    *float64
+		min, max float64
+	}{
+		{"soc", p.SoC, 0, 1},
+		{"voltage", p.Voltage, 0, 1000},
+		{"temperature", p.Temperature, -60, 150},
+		{"energy_in_kwh", p.EnergyInKwh, 0, 1e9},
+		{"energy_out_kwh", p.EnergyOutKwh, 0, 1e9},
+		{"available_kwh", p.AvailableKwh, 0, 1e6},
+		{"locked_kwh", p.LockedKwh, 0, 1e6},
+	}
+	for _, c := range checks {
+		if c.value != nil && (*c.value < c.min || *c.value > c.max) {
+			return Report{}, fmt.Errorf("%s %g is outside [%g, %g]", c.name, *c.value, c.min, c.max)
+		}
+	}
+
+	report := Report{Sample: domain.DeviceTelemetry{
+		DeviceID:     deviceID,
+		RecordedAt:   recordedAt,
+		SoC:          p.SoC,
+		VoltageV:     p.Voltage,
+		CurrentA:     current,
+		TemperatureC: p.Temperature,
+		EnergyInKwh:  p.EnergyInKwh,
+		EnergyOutKwh: p.EnergyOutKwh,
+	}}
+	if p.AvailableKwh != nil {
+		stored := *p.AvailableKwh
+		if p.LockedKwh != nil {
+			stored += *p.LockedKwh
+		}
+		report.StoredKwh = &stored
+	}
+	if report.Sample.SoC == nil && report.Sample.VoltageV == nil && report.Sample.CurrentA == nil &&
+		report.Sample.TemperatureC == nil && report.Sample.EnergyInKwh == nil && report.Sample.EnergyOutKwh == nil && report.StoredKwh == nil {
+		return Report{}, fmt.Errorf("telemetry payload has no metrics")
+	}
+	return report, nil
+}
+
+// Stat summarises one metric over a bucket.
+type Stat struct {
+	Avg float64 `json:"avg"`
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// Point is one bucket of a telemetry series. Timestamps are RFC3339 in UTC.
+// Energy counters are the highest reading in the bucket; the deltas are the
+// increase since the previous point that reported the counter, and are
+// absent on the first one.
+type Point struct {
+	Start             string   `json:"start"`
+	End               string   `json:"end"`
+	Samples           int      `json:"samples"`
+	SoC               *Stat    `json:"soc,omitempty"`
+	Voltage           *Stat    `json:"voltage_v,omitempty"`
+	Current           *Stat    `json:"current_a,omitempty"`
+	Temperature       *Stat    `json:"temperature_c,omitempty"`
+	EnergyInKwh       *float64 `json:"energy_in_kwh,omitempty"`
+	EnergyOutKwh      *float64 `json:"energy_out_kwh,omitempty"`
+	EnergyInDeltaKwh  *float64 `json:"energy_in_delta_kwh,omitempty"`
+	EnergyOutDeltaKwh *float64 `json:"energy_out_delta_kwh,omitempty"`
+}
+
+// Points converts buckets of the given resolution into a series.
+func Points(buckets []domain.TelemetryBucket, resolution time.Duration) []Point {
+	points := make([]Point, 0, len(buckets))
+	var lastIn, lastOut *float64
+	for _, b := range buckets {
+		start := b.BucketStart.UTC()
+		points = append(points, Point{
+			Start:             start.Format(time.RFC3339),
+			End:               start.Add(resolution).Format(time.RFC3339),
+			Samples:           b.Samples,
+			SoC:               stat(b.SoCAvg, b.SoCMin, b.SoCMax),
+			Voltage:           stat(b.VoltageAvg, b.VoltageMin, b.VoltageMax),
+			Current:           stat(b.CurrentAvg, b.CurrentMin, b.CurrentMax),
+			Temperature:       stat(b.TemperatureAvg, b.TemperatureMin, b.TemperatureMax),
+			EnergyInKwh:       b.EnergyInKwh,
+			EnergyOutKwh:      b.EnergyOutKwh,
+			EnergyInDeltaKwh:  counterDelta(lastIn, b.EnergyInKwh),
+			EnergyOutDeltaKwh: counterDelta(lastOut, b.EnergyOutKwh),
+		})
+		if b.EnergyInKwh != nil {
+			lastIn = b.EnergyInKwh
+		}
+		if b.EnergyOutKwh != nil {
+			lastOut = b.EnergyOutKwh
+		}
+	}
+	return points
+}
+
+func stat(avg, min, max *float64) *Stat {
+	if avg == nil || min == nil || max == nil {
+		return nil
+	}
+	return &Stat{Avg: *avg, Min: *min, Max: *max}
+}
+
+// counterDelta is how far a cumulative counter advanced from previous to
+// current. A counter that went backwards was reset, so all of current counts.
+func counterDelta(previous, current *float64) *float64 {
+	if previous == nil || current == nil {
+		return nil
+	}
+	delta := *current
+	if *current >= *previous {
+		delta = *current - *previous
+	}
+	return &delta
+}
+
+// Merge combines two series sorted by bucket start, such as the rollups of
+// older hours and the raw samples of recent ones. Buckets that start at the
+// same time are combined, weighting averages by sample count.
+func Merge(a, b []domain.TelemetryBucket) []domain.TelemetryBucket {
+	merged := make([]domain.TelemetryBucket, 0, len(a)+len(b))
+	merged = append(merged, a...)
+	merged = append(merged, b...)
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].BucketStart.Before(merged[j].BucketStart) })
+
+	result := merged[:0]
+	for _, bucket := range merged {
+		if n := len(result); n > 0 && result[n-1].BucketStart.Equal(bucket.BucketStart) {
+			result[n-1].TelemetryAggregate = combine(result[n-1].TelemetryAggregate, bucket.TelemetryAggregate)
+			continue
+		}
+		result = append(result, bucket)
+	}
+	return result
+}
+
+func combine(a, b domain.TelemetryAggregate) domain.TelemetryAggregate {
+	wa, wb := float64(a.Samples), float64(b.Samples)
+	return domain.TelemetryAggregate{
+		Samples:        a.Samples + b.Samples,
+		SoCAvg:         weighted(a.SoCAvg, wa, b.SoCAvg, wb),
+		SoCMin:         pick(a.SoCMin, b.SoCMin, false),
+		SoCMax:         pick(a.SoCMax, b.SoCMax, true),
+		VoltageAvg:     weighted(a.VoltageAvg, wa, b.VoltageAvg, wb),
+		VoltageMin:     pick(a.VoltageMin, b.VoltageMin, false),
+		VoltageMax:     pick(a.VoltageMax, b.VoltageMax, true),
+		CurrentAvg:     weighted(a.CurrentAvg, wa, b.CurrentAvg, wb),
+		CurrentMin:     pick(a.CurrentMin, b.CurrentMin, false),
+		CurrentMax:     pick(a.CurrentMax, b.CurrentMax, true),
+		TemperatureAvg: weighted(a.TemperatureAvg, wa, b.TemperatureAvg, wb),
+		TemperatureMin: pick(a.TemperatureMin, b.TemperatureMin, false),
+		TemperatureMax: pick(a.TemperatureMax, b.TemperatureMax, true),
+		EnergyInKwh:    pick(a.EnergyInKwh, b.EnergyInKwh, true),
+		EnergyOutKwh:   pick(a.EnergyOutKwh, b.EnergyOutKwh, true),
+	}
+}
+
+func weighted(a *float64, wa float64, b *float64, wb float64) *float64 {
+	if a == nil || wa+wb == 0 {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	avg := (*a*wa + *b*wb) / (wa + wb)
+	return &avg
+}
+
+// pick returns the larger (or smaller) of two optional values.
+func pick(a, b *float64, larger bool) *float64 {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if (*b > *a) == larger {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,120 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+
+	"los-tecnicos/backend/internal/core/domain"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+func TestParseReport(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	report, err := ParseReport("energy/device/esp32_a/telemetry",
+		[]byte(`{"ts": 1767268740, "soc": 0.5, "voltage": 48.2, "current": -2.5, "temperature": 31, "energy_in_kwh": 12.5}`), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := report.Sample
+	if s.DeviceID != "esp32_a" || !s.RecordedAt.Equal(now.Add(-time.Minute)) {
+		t.Errorf("Expected esp32_a at %s, got %s at %s", now.Add(-time.Minute), s.DeviceID, s.RecordedAt)
+	}
+	if *s.SoC != 0.5 || *s.VoltageV != 48.2 || *s.CurrentA != -2.5 || *s.TemperatureC != 31 || *s.EnergyInKwh != 12.5 || s.EnergyOutKwh != nil {
+		t.Errorf("Unexpected sample %+v", s)
+	}
+
+	// The firmware status report: milliamps, no timestamp and no SoC
+	report, err = ParseReport("energy/device/esp32_a/status",
+		[]byte(`{"device_id": "esp32_a", "voltage": 12.1, "current": 1500, "available_kwh": 3, "locked_kwh": 1, "uptime": 1000}`), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *report.Sample.CurrentA != 1.5 {
+		t.Errorf("Expected 1500 mA to be stored as 1.5 A, got %g", *report.Sample.CurrentA)
+	}
+	if !report.Sample.RecordedAt.Equal(now) || report.Sample.SoC != nil || *report.StoredKwh != 4 {
+		t.Errorf("Expected the receipt time and 4 kWh stored, got %+v", report)
+	}
+}
+
+func TestParseReportRejects(t *testing.T) {
+	now := time.Now()
+	cases := map[string]struct{ topic, payload string }{
+		"wrong topic":       {"energy/donor/esp32_a/status", `{"soc": 0.5}`},
+		"mismatched device": {"energy/device/esp32_a/telemetry", `{"device_id": "esp32_b", "soc": 0.5}`},
+		"invalid JSON":      {"energy/device/esp32_a/telemetry", `{"soc":`},
+		"SoC out of range":  {"energy/device/esp32_a/telemetry", `{"soc": 85}`},
+		"negative counter":  {"energy/device/esp32_a/telemetry", `{"energy_out_kwh": -1}`},
+		"no metrics":        {"energy/device/esp32_a/telemetry", `{"uptime": 5}`},
+		"future timestamp":  {"energy/device/esp32_a/telemetry", `{"ts": 4102444800, "soc": 0.5}`},
+		"expired timestamp": {"energy/device/esp32_a/telemetry", `{"ts": 1000, "soc": 0.5}`},
+	}
+	for name, c := range cases {
+		if _, err := ParseReport(c.topic, []byte(c.payload), now); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPointsEnergyDeltas(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	bucket := func(hour int, energyIn *float64) domain.TelemetryBucket {
+		return domain.TelemetryBucket{
+			BucketStart:        start.Add(time.Duration(hour) * time.Hour),
+			TelemetryAggregate: domain.TelemetryAggregate{Samples: 1, EnergyInKwh: energyIn, SoCAvg: ptr(0.5), SoCMin: ptr(0.4), SoCMax: ptr(0.6)},
+		}
+	}
+
+	// 10 -> 12.5, a gap without the counter, 14, then a reset to 1
+	points := Points([]domain.TelemetryBucket{bucket(0, ptr(10)), bucket(1, ptr(12.5)), bucket(2, nil), bucket(3, ptr(14)), bucket(4, ptr(1))}, time.Hour)
+
+	want := []*float64{nil, ptr(2.5), nil, ptr(1.5), ptr(1)}
+	for i, p := range points {
+		got := p.EnergyInDeltaKwh
+		if (got == nil) != (want[i] == nil) || (got != nil && *got != *want[i]) {
+			t.Errorf("Point %d: expected delta %v, got %v", i, want[i], got)
+		}
+	}
+	if points[1].End != "2026-01-01T02:00:00Z" || points[1].SoC == nil || points[1].SoC.Max != 0.6 || points[1].Voltage != nil {
+		t.Errorf("Unexpected point %+v", points[1])
+	}
+}
+
+func TestMerge(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rollups := []domain.TelemetryBucket{
+		{BucketStart: start, TelemetryAggregate: domain.TelemetryAggregate{Samples: 30, SoCAvg: ptr(0.2), SoCMin: ptr(0.1), SoCMax: ptr(0.3), EnergyInKwh: ptr(5)}},
+	}
+	raw := []domain.TelemetryBucket{
+		{BucketStart: start, TelemetryAggregate: domain.TelemetryAggregate{Samples: 10, SoCAvg: ptr(0.6), SoCMin: ptr(0.5), SoCMax: ptr(0.7), EnergyInKwh: ptr(6)}},
+		{BucketStart: start.Add(24 * time.Hour), TelemetryAggregate: domain.TelemetryAggregate{Samples: 1}},
+	}
+
+	merged := Merge(rollups, raw)
+	if len(merged) != 2 {
+		t.Fatalf("Expected 2 buckets, got %d", len(merged))
+	}
+	day := merged[0]
+	if day.Samples != 40 || *day.SoCAvg != 0.3 || *day.SoCMin != 0.1 || *day.SoCMax != 0.7 || *day.EnergyInKwh != 6 {
+		t.Errorf("Unexpected merged bucket %+v", day.TelemetryAggregate)
+	}
+}
+
+func TestRollupWindow(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 30, 0, 0, time.UTC)
+
+	since, until := rollupWindow(time.Time{}, now)
+	if !until.Equal(time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected rollups to stop at the current hour, got %s", until)
+	}
+	if !since.Equal(now.Add(-RawRetention).Truncate(time.Hour)) {
+		t.Errorf("Expected the first run to start at the oldest retained hour, got %s", since)
+	}
+
+	latest := time.Date(2026, 1, 10, 10, 0, 0, 0, time.UTC)
+	if since, _ := rollupWindow(latest, now); !since.Equal(latest) {
+		t.Errorf("Expected later runs to redo the newest rolled-up hour, got %s", since)
+	}
+}